@@ -5,43 +5,25 @@ import (
 	"flag"
 	"log"
 
-	"github.com/hashicorp/terraform-plugin-sdk/v2/plugin"
-
 	"github.com/hashicorp/terraform-plugin-framework/providerserver"
-	"github.com/hashicorp/terraform-plugin-go/tfprotov6"
-	"github.com/hashicorp/terraform-plugin-go/tfprotov6/tf6server"
-	"github.com/hashicorp/terraform-plugin-mux/tf5to6server"
-	"github.com/hashicorp/terraform-plugin-mux/tf6muxserver"
 
 	provider "github.com/mildred/terraform-provider-sys/sys"
 )
 
-const enable_framework = false
-const enable_sdk = true
 const provider_address = "registry.terraform.io/mildred/sys"
 
 func main() {
-	if enable_framework && enable_sdk {
-		main_mux()
-	} else if enable_sdk {
-		main_sdk()
-	} else {
-		main_framework()
-	}
-}
+	var debug bool
 
-func main_sdk() {
-	plugin.Serve(&plugin.ServeOpts{
-		ProviderFunc: provider.Provider,
-	})
-}
+	flag.BoolVar(&debug, "debug", false, "set to true to run the provider with support for debuggers like delve")
+	flag.Parse()
 
-func main_framework() {
 	err := providerserver.Serve(
 		context.Background(),
 		provider.New,
 		providerserver.ServeOpts{
 			Address: provider_address,
+			Debug:   debug,
 		},
 	)
 
@@ -49,50 +31,3 @@ func main_framework() {
 		log.Fatal(err)
 	}
 }
-
-func main_mux() {
-	ctx := context.Background()
-
-	var debug bool
-
-	flag.BoolVar(&debug, "debug", false, "set to true to run the provider with support for debuggers like delve")
-	flag.Parse()
-
-	upgradedSdkServer, err := tf5to6server.UpgradeServer(
-		ctx,
-		provider.Provider().GRPCProvider, // Example terraform-plugin-sdk provider
-	)
-
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	providers := []func() tfprotov6.ProviderServer{
-		providerserver.NewProtocol6(provider.New()), // Example terraform-plugin-framework provider
-		func() tfprotov6.ProviderServer {
-			return upgradedSdkServer
-		},
-	}
-
-	muxServer, err := tf6muxserver.NewMuxServer(ctx, providers...)
-
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	var serveOpts []tf6server.ServeOpt
-
-	if debug {
-		serveOpts = append(serveOpts, tf6server.WithManagedDebug())
-	}
-
-	err = tf6server.Serve(
-		provider_address,
-		muxServer.ProviderServer,
-		serveOpts...,
-	)
-
-	if err != nil {
-		log.Fatal(err)
-	}
-}
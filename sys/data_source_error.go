@@ -1,38 +1,69 @@
 package sys
 
 import (
-	"errors"
+	"context"
 
-	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/types"
 )
 
-func dataSourceError() *schema.Resource {
-	return &schema.Resource{
-		Read: dataSourceErrorRead,
+type errorDataSource struct{}
 
-		Schema: map[string]*schema.Schema{
-			"error": {
-				Type:     schema.TypeBool,
+type errorDataSourceModel struct {
+	Id      types.String `tfsdk:"id"`
+	Error   types.Bool   `tfsdk:"error"`
+	Message types.String `tfsdk:"message"`
+}
+
+func newErrorDataSource() datasource.DataSource {
+	return &errorDataSource{}
+}
+
+func (d *errorDataSource) Metadata(ctx context.Context, req datasource.MetadataRequest, resp *datasource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_error"
+}
+
+func (d *errorDataSource) Schema(ctx context.Context, req datasource.SchemaRequest, resp *datasource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		Attributes: map[string]schema.Attribute{
+			"id": schema.StringAttribute{
+				Computed:    true,
+				Description: "The ID of this resource.",
+			},
+			"error": schema.BoolAttribute{
 				Optional: true,
-				Default:  true,
+				Computed: true,
 			},
-			"message": {
-				Type:     schema.TypeString,
+			"message": schema.StringAttribute{
 				Optional: true,
-				Default:  "An error occurred",
+				Computed: true,
 			},
 		},
 	}
 }
 
-func dataSourceErrorRead(d *schema.ResourceData, _ interface{}) error {
-	is_error := d.Get("error").(bool)
-	message := d.Get("message").(string)
+func (d *errorDataSource) Read(ctx context.Context, req datasource.ReadRequest, resp *datasource.ReadResponse) {
+	var data errorDataSourceModel
 
-	if is_error {
-		return errors.New(message)
-	} else {
-		d.SetId("")
-		return nil
+	resp.Diagnostics.Append(req.Config.Get(ctx, &data)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	if data.Error.IsNull() {
+		data.Error = types.BoolValue(true)
+	}
+	if data.Message.IsNull() {
+		data.Message = types.StringValue("An error occurred")
 	}
+
+	if data.Error.ValueBool() {
+		resp.Diagnostics.AddError(data.Message.ValueString(), "")
+		return
+	}
+
+	data.Id = types.StringValue("")
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
 }
@@ -1,49 +1,80 @@
 package sys
 
 import (
+	"context"
 	"crypto/sha1"
 	"encoding/base64"
 	"encoding/hex"
-	"io/ioutil"
 
-	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/types"
 )
 
-func dataSourceFile() *schema.Resource {
-	return &schema.Resource{
-		Read: dataSourceFileRead,
+type fileDataSource struct {
+	host sysHost
+}
+
+type fileDataSourceModel struct {
+	Id            types.String `tfsdk:"id"`
+	Filename      types.String `tfsdk:"filename"`
+	Content       types.String `tfsdk:"content"`
+	ContentBase64 types.String `tfsdk:"content_base64"`
+}
+
+func newFileDataSource() datasource.DataSource {
+	return &fileDataSource{}
+}
+
+func (d *fileDataSource) Metadata(ctx context.Context, req datasource.MetadataRequest, resp *datasource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_file"
+}
 
-		Schema: map[string]*schema.Schema{
-			"filename": {
-				Type:        schema.TypeString,
+func (d *fileDataSource) Configure(ctx context.Context, req datasource.ConfigureRequest, resp *datasource.ConfigureResponse) {
+	d.host = providerHost(req.ProviderData)
+}
+
+func (d *fileDataSource) Schema(ctx context.Context, req datasource.SchemaRequest, resp *datasource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		Attributes: map[string]schema.Attribute{
+			"id": schema.StringAttribute{
+				Computed:    true,
+				Description: "The ID of this resource.",
+			},
+			"filename": schema.StringAttribute{
 				Description: "Path to the output file",
 				Required:    true,
-				ForceNew:    true,
 			},
-			"content": {
-				Type:     schema.TypeString,
+			"content": schema.StringAttribute{
 				Computed: true,
 			},
-			"content_base64": {
-				Type:     schema.TypeString,
+			"content_base64": schema.StringAttribute{
 				Computed: true,
 			},
 		},
 	}
 }
 
-func dataSourceFileRead(d *schema.ResourceData, _ interface{}) error {
-	path := d.Get("filename").(string)
-	content, err := ioutil.ReadFile(path)
+func (d *fileDataSource) Read(ctx context.Context, req datasource.ReadRequest, resp *datasource.ReadResponse) {
+	var data fileDataSourceModel
+
+	resp.Diagnostics.Append(req.Config.Get(ctx, &data)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	content, err := d.host.ReadFile(data.Filename.ValueString())
 	if err != nil {
-		return err
+		resp.Diagnostics.AddError("cannot read file", err.Error())
+		return
 	}
 
-	d.Set("content", string(content))
-	d.Set("content_base64", base64.StdEncoding.EncodeToString(content))
+	data.Content = types.StringValue(string(content))
+	data.ContentBase64 = types.StringValue(base64.StdEncoding.EncodeToString(content))
 
-	checksum := sha1.Sum([]byte(content))
-	d.SetId(hex.EncodeToString(checksum[:]))
+	checksum := sha1.Sum(content)
+	data.Id = types.StringValue(hex.EncodeToString(checksum[:]))
 
-	return nil
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
 }
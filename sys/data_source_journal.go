@@ -0,0 +1,199 @@
+package sys
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+type journalDataSource struct {
+	host sysHost
+}
+
+type journalDataSourceModel struct {
+	Id         types.String        `tfsdk:"id"`
+	Unit       types.String        `tfsdk:"unit"`
+	User       types.Bool          `tfsdk:"user"`
+	Boot       types.String        `tfsdk:"boot"`
+	Identifier types.String        `tfsdk:"identifier"`
+	Since      types.String        `tfsdk:"since"`
+	Lines      types.Int64         `tfsdk:"lines"`
+	Priority   types.Int64         `tfsdk:"priority"`
+	Entries    []journalEntryModel `tfsdk:"entries"`
+}
+
+type journalEntryModel struct {
+	Cursor     string            `tfsdk:"cursor"`
+	Timestamp  string            `tfsdk:"timestamp"`
+	Message    string            `tfsdk:"message"`
+	Priority   int64             `tfsdk:"priority"`
+	Unit       string            `tfsdk:"unit"`
+	Identifier string            `tfsdk:"identifier"`
+	Pid        int64             `tfsdk:"pid"`
+	Fields     map[string]string `tfsdk:"fields"`
+}
+
+func newJournalDataSource() datasource.DataSource {
+	return &journalDataSource{}
+}
+
+func (d *journalDataSource) Metadata(ctx context.Context, req datasource.MetadataRequest, resp *datasource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_journal"
+}
+
+func (d *journalDataSource) Configure(ctx context.Context, req datasource.ConfigureRequest, resp *datasource.ConfigureResponse) {
+	d.host = providerHost(req.ProviderData)
+}
+
+func (d *journalDataSource) Schema(ctx context.Context, req datasource.SchemaRequest, resp *datasource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		Description: "Reads the last entries of the systemd journal, for a unit, a boot or a syslog identifier.",
+
+		Attributes: map[string]schema.Attribute{
+			"id": schema.StringAttribute{
+				Computed:    true,
+				Description: "The ID of this resource.",
+			},
+			"unit": schema.StringAttribute{
+				Description: "Read the entries of this unit",
+				Optional:    true,
+			},
+			"user": schema.BoolAttribute{
+				Description: "Read the journal of the current user, `unit` being a user unit",
+				Optional:    true,
+			},
+			"boot": schema.StringAttribute{
+				Description: "Read the entries of this boot: a boot ID, 0 for the current boot or a negative offset for the previous ones",
+				Optional:    true,
+			},
+			"identifier": schema.StringAttribute{
+				Description: "Read the entries with this syslog identifier",
+				Optional:    true,
+			},
+			"since": schema.StringAttribute{
+				Description: "Read the entries since this RFC 3339 timestamp, or for this duration before now (such as 10m)",
+				Optional:    true,
+			},
+			"lines": schema.Int64Attribute{
+				Description: "Maximum number of entries to read, the last ones, 0 to read all matching entries (default: 100)",
+				Optional:    true,
+				Computed:    true,
+			},
+			"priority": schema.Int64Attribute{
+				Description: "Maximum priority of the entries to read, from 0 (emerg) to 7 (debug) (default: 7)",
+				Optional:    true,
+				Computed:    true,
+				Validators:  []validator.Int64{int64Between{0, 7}},
+			},
+			"entries": schema.ListNestedAttribute{
+				Description: "Journal entries, oldest first",
+				Computed:    true,
+				NestedObject: schema.NestedAttributeObject{
+					Attributes: map[string]schema.Attribute{
+						"cursor": schema.StringAttribute{
+							Description: "Journal cursor of the entry",
+							Computed:    true,
+						},
+						"timestamp": schema.StringAttribute{
+							Description: "RFC 3339 timestamp of the entry",
+							Computed:    true,
+						},
+						"message": schema.StringAttribute{
+							Description: "Message of the entry",
+							Computed:    true,
+						},
+						"priority": schema.Int64Attribute{
+							Description: "Priority of the entry, from 0 (emerg) to 7 (debug)",
+							Computed:    true,
+						},
+						"unit": schema.StringAttribute{
+							Description: "Unit that logged the entry or that the entry is about",
+							Computed:    true,
+						},
+						"identifier": schema.StringAttribute{
+							Description: "Syslog identifier of the entry",
+							Computed:    true,
+						},
+						"pid": schema.Int64Attribute{
+							Description: "Process ID that logged the entry",
+							Computed:    true,
+						},
+						"fields": schema.MapAttribute{
+							Description: "All the fields of the entry",
+							ElementType: types.StringType,
+							Computed:    true,
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func (d *journalDataSource) Read(ctx context.Context, req datasource.ReadRequest, resp *datasource.ReadResponse) {
+	var data journalDataSourceModel
+
+	resp.Diagnostics.Append(req.Config.Get(ctx, &data)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	if data.Lines.IsNull() {
+		data.Lines = types.Int64Value(100)
+	}
+	if data.Priority.IsNull() {
+		data.Priority = types.Int64Value(7)
+	}
+
+	since, err := journalParseSince(data.Since.ValueString(), time.Now())
+	if err != nil {
+		resp.Diagnostics.AddAttributeError(path.Root("since"), "Invalid value", err.Error())
+		return
+	}
+
+	q := journalQuery{
+		Unit:       data.Unit.ValueString(),
+		User:       data.User.ValueBool(),
+		Boot:       data.Boot.ValueString(),
+		Identifier: data.Identifier.ValueString(),
+		Since:      since,
+		Lines:      int(data.Lines.ValueInt64()),
+		Priority:   int(data.Priority.ValueInt64()),
+	}
+
+	entries, err := journalRead(ctx, d.host, q)
+	if err != nil {
+		resp.Diagnostics.AddError("cannot read the journal", err.Error())
+		return
+	}
+
+	data.Entries = []journalEntryModel{}
+	checksum := sha256.New()
+	for _, e := range entries {
+		priority, _ := strconv.ParseInt(e.Fields["PRIORITY"], 10, 64)
+		pid, _ := strconv.ParseInt(e.Fields["_PID"], 10, 64)
+		data.Entries = append(data.Entries, journalEntryModel{
+			Cursor:     e.Cursor,
+			Timestamp:  e.Timestamp.Format(time.RFC3339Nano),
+			Message:    e.Fields["MESSAGE"],
+			Priority:   priority,
+			Unit:       e.Unit(),
+			Identifier: e.Fields["SYSLOG_IDENTIFIER"],
+			Pid:        pid,
+			Fields:     e.Fields,
+		})
+		checksum.Write([]byte(e.Cursor))
+	}
+
+	data.Id = types.StringValue(hex.EncodeToString(checksum.Sum(nil)))
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
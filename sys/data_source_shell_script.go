@@ -1,62 +1,97 @@
 package sys
 
 import (
+	"context"
 	"crypto/sha1"
 	"encoding/base64"
 	"encoding/hex"
 
-	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/types"
 )
 
-func dataSourceShellScript() *schema.Resource {
-	return &schema.Resource{
-		Read: dataSourceShellScriptRead,
+type shellScriptDataSource struct {
+	host sysHost
+}
+
+type shellScriptDataSourceModel struct {
+	Id               types.String `tfsdk:"id"`
+	WorkingDirectory types.String `tfsdk:"working_directory"`
+	Shell            types.String `tfsdk:"shell"`
+	Read             types.String `tfsdk:"read"`
+	Content          types.String `tfsdk:"content"`
+	ContentBase64    types.String `tfsdk:"content_base64"`
+}
+
+func newShellScriptDataSource() datasource.DataSource {
+	return &shellScriptDataSource{}
+}
 
-		Schema: map[string]*schema.Schema{
-			"working_directory": {
-				Type:     schema.TypeString,
+func (d *shellScriptDataSource) Metadata(ctx context.Context, req datasource.MetadataRequest, resp *datasource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_shell_script"
+}
+
+func (d *shellScriptDataSource) Configure(ctx context.Context, req datasource.ConfigureRequest, resp *datasource.ConfigureResponse) {
+	d.host = providerHost(req.ProviderData)
+}
+
+func (d *shellScriptDataSource) Schema(ctx context.Context, req datasource.SchemaRequest, resp *datasource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		Attributes: map[string]schema.Attribute{
+			"id": schema.StringAttribute{
+				Computed:    true,
+				Description: "The ID of this resource.",
+			},
+			"working_directory": schema.StringAttribute{
 				Optional: true,
-				ForceNew: true,
-				Default:  "",
+				Computed: true,
 			},
-			"shell": {
-				Type:     schema.TypeString,
+			"shell": schema.StringAttribute{
 				Optional: true,
-				ForceNew: true,
-				Default:  "/bin/sh",
+				Computed: true,
 			},
-			"read": {
-				Type:        schema.TypeString,
+			"read": schema.StringAttribute{
 				Description: "Shell script to read the value",
 				Required:    true,
-				ForceNew:    true,
 			},
-			"content": {
-				Type:     schema.TypeString,
+			"content": schema.StringAttribute{
 				Computed: true,
 			},
-			"content_base64": {
-				Type:     schema.TypeString,
+			"content_base64": schema.StringAttribute{
 				Computed: true,
 			},
 		},
 	}
 }
 
-func dataSourceShellScriptRead(d *schema.ResourceData, _ interface{}) error {
-	script := d.Get("read")
-	cwd := d.Get("working_directory")
-	shell := d.Get("shell").(string)
-	content, err := resourceShellScriptRun(cwd, shell, script.(string), true)
+func (d *shellScriptDataSource) Read(ctx context.Context, req datasource.ReadRequest, resp *datasource.ReadResponse) {
+	var data shellScriptDataSourceModel
+
+	resp.Diagnostics.Append(req.Config.Get(ctx, &data)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	if data.WorkingDirectory.IsNull() {
+		data.WorkingDirectory = types.StringValue("")
+	}
+	if data.Shell.IsNull() {
+		data.Shell = types.StringValue("/bin/sh")
+	}
+
+	content, err := resourceShellScriptRun(d.host, data.WorkingDirectory.ValueString(), data.Shell.ValueString(), data.Read.ValueString(), true)
 	if err != nil {
-		return err
+		resp.Diagnostics.AddError("cannot run read script", err.Error())
+		return
 	}
 
-	d.Set("content", string(content))
-	d.Set("content_base64", base64.StdEncoding.EncodeToString([]byte(content)))
+	data.Content = types.StringValue(string(content))
+	data.ContentBase64 = types.StringValue(base64.StdEncoding.EncodeToString([]byte(content)))
 
 	checksum := sha1.Sum([]byte(content))
-	d.SetId(hex.EncodeToString(checksum[:]))
+	data.Id = types.StringValue(hex.EncodeToString(checksum[:]))
 
-	return nil
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
 }
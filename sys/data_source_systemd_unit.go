@@ -0,0 +1,209 @@
+package sys
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+type systemdUnitDataSource struct {
+	config *providerConfiguration
+}
+
+type systemdUnitDataSourceModel struct {
+	Id             types.String      `tfsdk:"id"`
+	Name           types.String      `tfsdk:"name"`
+	System         types.Bool        `tfsdk:"system"`
+	User           types.Bool        `tfsdk:"user"`
+	UserName       types.String      `tfsdk:"user_name"`
+	Properties     []string          `tfsdk:"properties"`
+	PropertyValues map[string]string `tfsdk:"property_values"`
+	Triggers       []string          `tfsdk:"triggers"`
+	TriggeredBy    []string          `tfsdk:"triggered_by"`
+	Exists         types.Bool        `tfsdk:"exists"`
+	Description    types.String      `tfsdk:"description"`
+	LoadState      types.String      `tfsdk:"load_state"`
+	ActiveState    types.String      `tfsdk:"active_state"`
+	SubState       types.String      `tfsdk:"sub_state"`
+	UnitFileState  types.String      `tfsdk:"unit_file_state"`
+	Active         types.Bool        `tfsdk:"active"`
+	Enabled        types.Bool        `tfsdk:"enabled"`
+	Masked         types.Bool        `tfsdk:"masked"`
+}
+
+func newSystemdUnitDataSource() datasource.DataSource {
+	return &systemdUnitDataSource{}
+}
+
+func (d *systemdUnitDataSource) Metadata(ctx context.Context, req datasource.MetadataRequest, resp *datasource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_systemd_unit"
+}
+
+func (d *systemdUnitDataSource) Configure(ctx context.Context, req datasource.ConfigureRequest, resp *datasource.ConfigureResponse) {
+	d.config, _ = req.ProviderData.(*providerConfiguration)
+}
+
+func (d *systemdUnitDataSource) Schema(ctx context.Context, req datasource.SchemaRequest, resp *datasource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		Description: "Reads the state and properties of a systemd unit with the dBus API.",
+
+		Attributes: map[string]schema.Attribute{
+			"id": schema.StringAttribute{
+				Computed:    true,
+				Description: "The ID of this resource.",
+			},
+			"name": schema.StringAttribute{
+				Description: "systemd unit name",
+				Required:    true,
+			},
+			"system": schema.BoolAttribute{
+				Description: "Uses the system systemd socket",
+				Optional:    true,
+			},
+			"user": schema.BoolAttribute{
+				Description: "Uses the user systemd socket",
+				Optional:    true,
+			},
+			"user_name": schema.StringAttribute{
+				Description: "Uses the user manager of this user instead of the current user, requires root",
+				Optional:    true,
+			},
+			"properties": schema.ListAttribute{
+				Description: "Unit properties to read in `property_values`, such as MainPID, NRestarts or ActiveEnterTimestamp",
+				ElementType: types.StringType,
+				Optional:    true,
+			},
+			"property_values": schema.MapAttribute{
+				Description: "Values of the unit properties listed in `properties`",
+				ElementType: types.StringType,
+				Computed:    true,
+			},
+			"triggers": schema.ListAttribute{
+				Description: "Units activated by this unit, such as the service of a socket or a timer",
+				ElementType: types.StringType,
+				Computed:    true,
+			},
+			"triggered_by": schema.ListAttribute{
+				Description: "Units that can activate this unit, such as its socket or timer",
+				ElementType: types.StringType,
+				Computed:    true,
+			},
+			"exists": schema.BoolAttribute{
+				Description: "Whether the unit is known to systemd",
+				Computed:    true,
+			},
+			"description": schema.StringAttribute{
+				Description: "Unit description",
+				Computed:    true,
+			},
+			"load_state": schema.StringAttribute{
+				Description: "Unit load state",
+				Computed:    true,
+			},
+			"active_state": schema.StringAttribute{
+				Description: "Unit active state",
+				Computed:    true,
+			},
+			"sub_state": schema.StringAttribute{
+				Description: "Unit sub-state (specific to the unit type)",
+				Computed:    true,
+			},
+			"unit_file_state": schema.StringAttribute{
+				Description: "Unit file state (enabled, disabled, static, masked...)",
+				Computed:    true,
+			},
+			"active": schema.BoolAttribute{
+				Description: "Whether the unit is active",
+				Computed:    true,
+			},
+			"enabled": schema.BoolAttribute{
+				Description: "Whether the unit is enabled",
+				Computed:    true,
+			},
+			"masked": schema.BoolAttribute{
+				Description: "Whether the unit is masked",
+				Computed:    true,
+			},
+		},
+	}
+}
+
+func (d *systemdUnitDataSource) ConfigValidators(ctx context.Context) []datasource.ConfigValidator {
+	return []datasource.ConfigValidator{
+		conflictingAttributes{"system", "user"},
+		conflictingAttributes{"system", "user_name"},
+	}
+}
+
+func (d *systemdUnitDataSource) Read(ctx context.Context, req datasource.ReadRequest, resp *datasource.ReadResponse) {
+	var data systemdUnitDataSourceModel
+
+	resp.Diagnostics.Append(req.Config.Get(ctx, &data)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	unit := data.Name.ValueString()
+
+	sd, err := newSdScope(data.User.ValueBool(), data.UserName.ValueString(), d.config).Conn(ctx, d.config)
+	if err != nil {
+		resp.Diagnostics.AddError("cannot connect to systemd", err.Error())
+		return
+	}
+
+	defer sd.Close()
+
+	statuses, err := sd.ListUnitsByNamesContext(ctx, []string{unit})
+	if err != nil || len(statuses) < 1 {
+		resp.Diagnostics.AddError("cannot query unit "+unit, fmt.Sprint(err))
+		return
+	}
+	status := statuses[0]
+
+	data.Id = types.StringValue(status.Name)
+	data.Exists = types.BoolValue(status.LoadState != systemdNotFound)
+	data.Description = types.StringValue(status.Description)
+	data.LoadState = types.StringValue(status.LoadState)
+	data.ActiveState = types.StringValue(status.ActiveState)
+	data.SubState = types.StringValue(status.SubState)
+	data.Active = types.BoolValue(sdIsActive(status.ActiveState))
+	data.Masked = types.BoolValue(sdIsMasked(status.LoadState))
+
+	if status.LoadState == systemdNotFound {
+		data.UnitFileState = types.StringValue("")
+		data.Enabled = types.BoolValue(false)
+		data.PropertyValues = map[string]string{}
+		data.Triggers = []string{}
+		data.TriggeredBy = []string{}
+		resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+		return
+	}
+
+	unitFileState, err := sd.GetUnitFileStateContext(ctx, status.Name)
+	if err != nil {
+		resp.Diagnostics.AddError("cannot get unit file state for "+status.Name, err.Error())
+		return
+	}
+
+	enabled, _ := sdIsEnabled(unitFileState)
+	data.UnitFileState = types.StringValue(unitFileState)
+	data.Enabled = types.BoolValue(enabled)
+
+	data.PropertyValues, err = sdUnitProperties(ctx, sd, status.Name, data.Properties)
+	if err != nil {
+		resp.Diagnostics.AddError("cannot read the unit properties", err.Error())
+		return
+	}
+
+	data.Triggers, data.TriggeredBy, err = sdUnitTriggers(ctx, sd, status.Name)
+	if err != nil {
+		resp.Diagnostics.AddError("cannot read the unit triggers", err.Error())
+		return
+	}
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
@@ -0,0 +1,238 @@
+package sys
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"path/filepath"
+	"sort"
+
+	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+type systemdUnitsDataSource struct {
+	config *providerConfiguration
+}
+
+type systemdUnitsDataSourceModel struct {
+	Id               types.String          `tfsdk:"id"`
+	Patterns         []string              `tfsdk:"patterns"`
+	States           []string              `tfsdk:"states"`
+	UnitFileStates   []string              `tfsdk:"unit_file_states"`
+	IncludeUnitFiles types.Bool            `tfsdk:"include_unit_files"`
+	System           types.Bool            `tfsdk:"system"`
+	User             types.Bool            `tfsdk:"user"`
+	UserName         types.String          `tfsdk:"user_name"`
+	Names            []string              `tfsdk:"names"`
+	Units            []systemdUnitsElement `tfsdk:"units"`
+}
+
+type systemdUnitsElement struct {
+	Name          string `tfsdk:"name"`
+	Description   string `tfsdk:"description"`
+	LoadState     string `tfsdk:"load_state"`
+	ActiveState   string `tfsdk:"active_state"`
+	SubState      string `tfsdk:"sub_state"`
+	UnitFileState string `tfsdk:"unit_file_state"`
+	Active        bool   `tfsdk:"active"`
+	Enabled       bool   `tfsdk:"enabled"`
+}
+
+func newSystemdUnitsDataSource() datasource.DataSource {
+	return &systemdUnitsDataSource{}
+}
+
+func (d *systemdUnitsDataSource) Metadata(ctx context.Context, req datasource.MetadataRequest, resp *datasource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_systemd_units"
+}
+
+func (d *systemdUnitsDataSource) Configure(ctx context.Context, req datasource.ConfigureRequest, resp *datasource.ConfigureResponse) {
+	d.config, _ = req.ProviderData.(*providerConfiguration)
+}
+
+func (d *systemdUnitsDataSource) Schema(ctx context.Context, req datasource.SchemaRequest, resp *datasource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		Description: "Lists the systemd units matching glob patterns and states with the dBus API.",
+
+		Attributes: map[string]schema.Attribute{
+			"id": schema.StringAttribute{
+				Computed:    true,
+				Description: "The ID of this resource.",
+			},
+			"patterns": schema.ListAttribute{
+				Description: "Glob patterns the unit names must match, such as `getty@*.service`, all units if empty",
+				ElementType: types.StringType,
+				Optional:    true,
+			},
+			"states": schema.ListAttribute{
+				Description: "Load, active or sub states of the units to list, such as `active`, `failed` or `running`",
+				ElementType: types.StringType,
+				Optional:    true,
+			},
+			"unit_file_states": schema.ListAttribute{
+				Description: "Unit file states of the units to list, such as `enabled` or `masked`",
+				ElementType: types.StringType,
+				Optional:    true,
+			},
+			"include_unit_files": schema.BoolAttribute{
+				Description: "Also list the installed unit files that are not loaded, regardless of `states`",
+				Optional:    true,
+			},
+			"system": schema.BoolAttribute{
+				Description: "Uses the system systemd socket",
+				Optional:    true,
+			},
+			"user": schema.BoolAttribute{
+				Description: "Uses the user systemd socket",
+				Optional:    true,
+			},
+			"user_name": schema.StringAttribute{
+				Description: "Uses the user manager of this user instead of the current user, requires root",
+				Optional:    true,
+			},
+			"names": schema.ListAttribute{
+				Description: "Names of the listed units, sorted",
+				ElementType: types.StringType,
+				Computed:    true,
+			},
+			"units": schema.ListNestedAttribute{
+				Description: "Listed units, sorted by name",
+				Computed:    true,
+				NestedObject: schema.NestedAttributeObject{
+					Attributes: map[string]schema.Attribute{
+						"name": schema.StringAttribute{
+							Description: "systemd unit name",
+							Computed:    true,
+						},
+						"description": schema.StringAttribute{
+							Description: "Unit description",
+							Computed:    true,
+						},
+						"load_state": schema.StringAttribute{
+							Description: "Unit load state, empty for unit files that are not loaded",
+							Computed:    true,
+						},
+						"active_state": schema.StringAttribute{
+							Description: "Unit active state",
+							Computed:    true,
+						},
+						"sub_state": schema.StringAttribute{
+							Description: "Unit sub-state (specific to the unit type)",
+							Computed:    true,
+						},
+						"unit_file_state": schema.StringAttribute{
+							Description: "Unit file state (enabled, disabled, static, masked...), empty for units without unit file",
+							Computed:    true,
+						},
+						"active": schema.BoolAttribute{
+							Description: "Whether the unit is active",
+							Computed:    true,
+						},
+						"enabled": schema.BoolAttribute{
+							Description: "Whether the unit is enabled",
+							Computed:    true,
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func (d *systemdUnitsDataSource) ConfigValidators(ctx context.Context) []datasource.ConfigValidator {
+	return []datasource.ConfigValidator{
+		conflictingAttributes{"system", "user"},
+		conflictingAttributes{"system", "user_name"},
+	}
+}
+
+func (d *systemdUnitsDataSource) Read(ctx context.Context, req datasource.ReadRequest, resp *datasource.ReadResponse) {
+	var data systemdUnitsDataSourceModel
+
+	resp.Diagnostics.Append(req.Config.Get(ctx, &data)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	sd, err := newSdScope(data.User.ValueBool(), data.UserName.ValueString(), d.config).Conn(ctx, d.config)
+	if err != nil {
+		resp.Diagnostics.AddError("cannot connect to systemd", err.Error())
+		return
+	}
+
+	defer sd.Close()
+
+	statuses, err := sd.ListUnitsByPatternsContext(ctx, data.States, data.Patterns)
+	if err != nil {
+		resp.Diagnostics.AddError("cannot list units", err.Error())
+		return
+	}
+
+	// Unit file states of all the matching units, instances of templates and
+	// units without unit file are not listed
+	files, err := sd.ListUnitFilesByPatternsContext(ctx, nil, data.Patterns)
+	if err != nil {
+		resp.Diagnostics.AddError("cannot list unit files", err.Error())
+		return
+	}
+	fileState := map[string]string{}
+	for _, file := range files {
+		fileState[filepath.Base(file.Path)] = file.Type
+	}
+
+	units := map[string]systemdUnitsElement{}
+	for _, status := range statuses {
+		state, ok := fileState[status.Name]
+		if !ok {
+			// Errors are expected for units without unit file such as scopes
+			state, _ = sd.GetUnitFileStateContext(ctx, status.Name)
+		}
+		units[status.Name] = systemdUnitsElement{
+			Name:          status.Name,
+			Description:   status.Description,
+			LoadState:     status.LoadState,
+			ActiveState:   status.ActiveState,
+			SubState:      status.SubState,
+			UnitFileState: state,
+		}
+	}
+
+	if data.IncludeUnitFiles.ValueBool() {
+		for name, state := range fileState {
+			if _, loaded := units[name]; !loaded {
+				units[name] = systemdUnitsElement{
+					Name:          name,
+					ActiveState:   systemdInactive,
+					SubState:      systemdDead,
+					UnitFileState: state,
+				}
+			}
+		}
+	}
+
+	names := []string{}
+	for name, unit := range units {
+		if len(data.UnitFileStates) == 0 || stringListContains(data.UnitFileStates, unit.UnitFileState) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	data.Units = []systemdUnitsElement{}
+	checksum := sha256.New()
+	for _, name := range names {
+		unit := units[name]
+		unit.Active = sdIsActive(unit.ActiveState)
+		unit.Enabled, _ = sdIsEnabled(unit.UnitFileState)
+		data.Units = append(data.Units, unit)
+		checksum.Write([]byte(name + "\n"))
+	}
+
+	data.Names = names
+	data.Id = types.StringValue(hex.EncodeToString(checksum.Sum(nil)))
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
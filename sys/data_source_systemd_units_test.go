@@ -0,0 +1,97 @@
+package sys
+
+import (
+	"reflect"
+	"testing"
+)
+
+func testSystemdUnitsRead(t *testing.T, sd *fakeSystemd, config map[string]interface{}) map[string]interface{} {
+	t.Helper()
+	env := newTestFrameworkEnvWith(t, &providerConfiguration{SdConnect: sd.connect})
+	state, errs := env.read("sys_systemd_units", config)
+	if errs != "" {
+		t.Fatalf("read failed: %s", errs)
+	}
+	return state
+}
+
+func TestSystemdUnitsDataSource(t *testing.T) {
+	sd := newFakeSystemd()
+	sd.add("getty@tty1.service", systemdEnabled).start()
+	sd.add("getty@tty2.service", systemdEnabled)
+	sd.add("getty@.service", systemdEnabled).NotLoaded = true
+	sd.add("docker-abc.scope", "transient").start()
+	sd.add("sshd.service", systemdMasked)
+
+	state := testSystemdUnitsRead(t, sd, map[string]interface{}{
+		"patterns": []interface{}{"getty@*.service"},
+	})
+	if names := state["names"]; !reflect.DeepEqual(names, []interface{}{"getty@tty1.service", "getty@tty2.service"}) {
+		t.Errorf("unexpected units: %v", names)
+	}
+
+	state = testSystemdUnitsRead(t, sd, map[string]interface{}{
+		"patterns":           []interface{}{"getty@*.service"},
+		"include_unit_files": true,
+	})
+	if names := state["names"]; !reflect.DeepEqual(names, []interface{}{"getty@.service", "getty@tty1.service", "getty@tty2.service"}) {
+		t.Errorf("unexpected units: %v", names)
+	}
+	unit := state["units"].([]interface{})[0].(map[string]interface{})
+	if unit["load_state"] != "" || unit["unit_file_state"] != systemdEnabled {
+		t.Errorf("unexpected unit file: %v", unit)
+	}
+
+	state = testSystemdUnitsRead(t, sd, map[string]interface{}{
+		"states": []interface{}{systemdActive},
+	})
+	if names := state["names"]; !reflect.DeepEqual(names, []interface{}{"docker-abc.scope", "getty@tty1.service"}) {
+		t.Errorf("unexpected units: %v", names)
+	}
+	expected := map[string]interface{}{
+		"name":            "getty@tty1.service",
+		"description":     "Fake getty@tty1.service",
+		"load_state":      systemdLoaded,
+		"active_state":    systemdActive,
+		"sub_state":       systemdRunning,
+		"unit_file_state": systemdEnabled,
+		"active":          true,
+		"enabled":         true,
+	}
+	if unit := state["units"].([]interface{})[1]; !reflect.DeepEqual(unit, expected) {
+		t.Errorf("expected %v, got %v", expected, unit)
+	}
+
+	state = testSystemdUnitsRead(t, sd, map[string]interface{}{
+		"unit_file_states": []interface{}{systemdMasked},
+	})
+	if names := state["names"]; !reflect.DeepEqual(names, []interface{}{"sshd.service"}) {
+		t.Errorf("unexpected units: %v", names)
+	}
+}
+
+func TestSystemdUnitDataSource(t *testing.T) {
+	sd := newFakeSystemd()
+	sd.add("sshd.service", systemdEnabled).start()
+	env := newTestFrameworkEnvWith(t, &providerConfiguration{SdConnect: sd.connect})
+
+	state, errs := env.read("sys_systemd_unit", map[string]interface{}{"name": "sshd.service"})
+	if errs != "" {
+		t.Fatalf("read failed: %s", errs)
+	}
+	if state["id"] != "sshd.service" || state["active"] != true || state["enabled"] != true || state["exists"] != true {
+		t.Errorf("unexpected state %v", state)
+	}
+
+	state, errs = env.read("sys_systemd_unit", map[string]interface{}{"name": "missing.service"})
+	if errs != "" {
+		t.Fatalf("read failed: %s", errs)
+	}
+	if state["exists"] != false || state["unit_file_state"] != "" {
+		t.Errorf("unexpected state %v", state)
+	}
+
+	if _, errs = env.read("sys_systemd_unit", map[string]interface{}{"name": "sshd.service", "system": true, "user": true}); errs == "" {
+		t.Errorf("expected system and user to conflict")
+	}
+}
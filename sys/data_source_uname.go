@@ -1,84 +1,157 @@
 package sys
 
 import (
+	"context"
 	"crypto/sha1"
 	"encoding/hex"
-	"strings"
+	"fmt"
 	"regexp"
-	"os/exec"
+	"strings"
 
-	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/datasource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/types"
 )
 
-var uname_all_regexp *regexp.Regexp
-func init(){
-	uname_all_regexp = regexp.MustCompile(`^(\S+)\s+(\S+)\s+(\S+)\s+(.+)\s+(\S+)\s+(\S+)\s+(\S+)\s+(\S+)$`)
+var uname_all_regexp = regexp.MustCompile(`^(\S+)\s+(\S+)\s+(\S+)\s+(.+)\s+(\S+)\s+(\S+)\s+(\S+)\s+(\S+)$`)
+
+// unameFields are the fields of the uname -a output
+type unameFields struct {
+	KernelName       string `tfsdk:"kernel_name"`
+	Nodename         string `tfsdk:"nodename"`
+	KernelRelease    string `tfsdk:"kernel_release"`
+	KernelVersion    string `tfsdk:"kernel_version"`
+	Machine          string `tfsdk:"machine"`
+	Processor        string `tfsdk:"processor"`
+	HardwarePlatform string `tfsdk:"hardware_platform"`
+	OperatingSystem  string `tfsdk:"operating_system"`
+}
+
+// parseUname splits the uname -a output in its fields
+func parseUname(output string) (*unameFields, error) {
+	parts := uname_all_regexp.FindStringSubmatch(strings.Trim(output, "\n\r\t "))
+	if parts == nil {
+		return nil, fmt.Errorf("cannot parse uname output %q", output)
+	}
+	return &unameFields{
+		KernelName:       parts[1],
+		Nodename:         parts[2],
+		KernelRelease:    parts[3],
+		KernelVersion:    parts[4],
+		Machine:          parts[5],
+		Processor:        parts[6],
+		HardwarePlatform: parts[7],
+		OperatingSystem:  parts[8],
+	}, nil
+}
+
+type unameDataSource struct {
+	host sysHost
+	// deprecated serves the unprefixed uname data source, kept for existing
+	// configurations
+	deprecated bool
+}
+
+type unameDataSourceModel struct {
+	Id               types.String `tfsdk:"id"`
+	Flag             types.String `tfsdk:"flag"`
+	Output           types.String `tfsdk:"output"`
+	KernelName       types.String `tfsdk:"kernel_name"`
+	Nodename         types.String `tfsdk:"nodename"`
+	KernelRelease    types.String `tfsdk:"kernel_release"`
+	KernelVersion    types.String `tfsdk:"kernel_version"`
+	Machine          types.String `tfsdk:"machine"`
+	Processor        types.String `tfsdk:"processor"`
+	HardwarePlatform types.String `tfsdk:"hardware_platform"`
+	OperatingSystem  types.String `tfsdk:"operating_system"`
+}
+
+func newUnameDataSource() datasource.DataSource {
+	return &unameDataSource{}
+}
+
+func newUnameDeprecatedDataSource() datasource.DataSource {
+	return &unameDataSource{deprecated: true}
+}
+
+func (d *unameDataSource) Metadata(ctx context.Context, req datasource.MetadataRequest, resp *datasource.MetadataResponse) {
+	if d.deprecated {
+		resp.TypeName = "uname"
+	} else {
+		resp.TypeName = req.ProviderTypeName + "_uname"
+	}
 }
 
-func dataSourceUname() *schema.Resource {
-	return &schema.Resource{
-		Read: dataSourceUnameRead,
+func (d *unameDataSource) Configure(ctx context.Context, req datasource.ConfigureRequest, resp *datasource.ConfigureResponse) {
+	d.host = providerHost(req.ProviderData)
+}
+
+func (d *unameDataSource) Schema(ctx context.Context, req datasource.SchemaRequest, resp *datasource.SchemaResponse) {
+	resp.Schema = schema.Schema{
 		Description: "Return values from the uname executable",
 
-		Schema: map[string]*schema.Schema{
-			"flag": {
-				Type:        schema.TypeString,
-				Default:     "a",
+		Attributes: map[string]schema.Attribute{
+			"id": schema.StringAttribute{
+				Computed:    true,
+				Description: "The ID of this resource.",
+			},
+			"flag": schema.StringAttribute{
 				Optional:    true,
-				ForceNew:    true,
 				Description: `Uname flag without the dash: a: all, s: kernel name, n: nodename, r: kernel release, v: kernel version, m: machine, p: processor, i: hardware platform, o: operating system`,
 			},
-			"output": {
-				Type:     schema.TypeString,
-				Computed: true,
+			"output": schema.StringAttribute{
+				Computed:    true,
 				Description: `Output from uname command`,
 			},
-			"kernel_name": {
-				Type:     schema.TypeString,
-				Computed: true,
+			"kernel_name": schema.StringAttribute{
+				Computed:    true,
 				Description: `uname -s`,
 			},
-			"nodename": {
-				Type:     schema.TypeString,
-				Computed: true,
+			"nodename": schema.StringAttribute{
+				Computed:    true,
 				Description: `uname -n`,
 			},
-			"kernel_release": {
-				Type:     schema.TypeString,
-				Computed: true,
+			"kernel_release": schema.StringAttribute{
+				Computed:    true,
 				Description: `uname -r`,
 			},
-			"kernel_version": {
-				Type:     schema.TypeString,
-				Computed: true,
+			"kernel_version": schema.StringAttribute{
+				Computed:    true,
 				Description: `uname -v`,
 			},
-			"machine": {
-				Type:     schema.TypeString,
-				Computed: true,
+			"machine": schema.StringAttribute{
+				Computed:    true,
 				Description: `uname -m`,
 			},
-			"processor": {
-				Type:     schema.TypeString,
-				Computed: true,
+			"processor": schema.StringAttribute{
+				Computed:    true,
 				Description: `uname -p`,
 			},
-			"hardware_platform": {
-				Type:     schema.TypeString,
-				Computed: true,
+			"hardware_platform": schema.StringAttribute{
+				Computed:    true,
 				Description: `uname -i`,
 			},
-			"operating_system": {
-				Type:     schema.TypeString,
-				Computed: true,
+			"operating_system": schema.StringAttribute{
+				Computed:    true,
 				Description: `uname -o`,
 			},
 		},
 	}
+	if d.deprecated {
+		resp.Schema.DeprecationMessage = "The uname data source is deprecated, use sys_uname instead"
+	}
 }
 
-func dataSourceUnameRead(d *schema.ResourceData, _ interface{}) error {
-	flag := d.Get("flag").(string)
+func (d *unameDataSource) Read(ctx context.Context, req datasource.ReadRequest, resp *datasource.ReadResponse) {
+	var data unameDataSourceModel
+
+	resp.Diagnostics.Append(req.Config.Get(ctx, &data)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	flag := data.Flag.ValueString()
 	if len(flag) == 0 {
 		flag = "-a"
 	} else if flag[0] != '-' {
@@ -89,46 +162,51 @@ func dataSourceUnameRead(d *schema.ResourceData, _ interface{}) error {
 		}
 	}
 
-	out, err := exec.Command("uname", flag).Output()
+	out, err := d.host.Command(ctx, nil, "uname", flag).Output()
 	if err != nil {
-		return err
+		resp.Diagnostics.AddError("could not run uname command", err.Error())
+		return
 	}
 
 	out_line := strings.Trim(string(out), "\n\r\t ")
 
-	d.Set("output", out_line)
+	data.Output = types.StringValue(out_line)
 
 	switch flag {
 	case "-a", "--all":
-		parts := uname_all_regexp.FindStringSubmatch(out_line)
-		d.Set("kernel_name",       parts[1])
-		d.Set("nodename",          parts[2])
-		d.Set("kernel_release",    parts[3])
-		d.Set("kernel_version",    parts[4])
-		d.Set("machine",           parts[5])
-		d.Set("processor",         parts[6])
-		d.Set("hardware_platform", parts[7])
-		d.Set("operating_system",  parts[8])
+		fields, err := parseUname(out_line)
+		if err != nil {
+			resp.Diagnostics.AddError("could not parse uname output", err.Error())
+			return
+		}
+		data.KernelName = types.StringValue(fields.KernelName)
+		data.Nodename = types.StringValue(fields.Nodename)
+		data.KernelRelease = types.StringValue(fields.KernelRelease)
+		data.KernelVersion = types.StringValue(fields.KernelVersion)
+		data.Machine = types.StringValue(fields.Machine)
+		data.Processor = types.StringValue(fields.Processor)
+		data.HardwarePlatform = types.StringValue(fields.HardwarePlatform)
+		data.OperatingSystem = types.StringValue(fields.OperatingSystem)
 	case "-s", "--kernel-name":
-		d.Set("kernel_name",       out_line)
+		data.KernelName = types.StringValue(out_line)
 	case "-n", "--nodename":
-		d.Set("nodename",          out_line)
+		data.Nodename = types.StringValue(out_line)
 	case "-r", "--kernel-release":
-		d.Set("kernel_release",    out_line)
+		data.KernelRelease = types.StringValue(out_line)
 	case "-v", "--kernel-version":
-		d.Set("kernel_version",    out_line)
+		data.KernelVersion = types.StringValue(out_line)
 	case "-m", "--machine":
-		d.Set("machine",           out_line)
+		data.Machine = types.StringValue(out_line)
 	case "-p", "--processor":
-		d.Set("processor",         out_line)
+		data.Processor = types.StringValue(out_line)
 	case "-i", "--hardware-platform":
-		d.Set("hardware_platform", out_line)
+		data.HardwarePlatform = types.StringValue(out_line)
 	case "-o", "--operating-system":
-		d.Set("operating_system",  out_line)
+		data.OperatingSystem = types.StringValue(out_line)
 	}
 
 	checksum := sha1.Sum([]byte(flag + "\n" + out_line))
-	d.SetId(hex.EncodeToString(checksum[:]))
+	data.Id = types.StringValue(hex.EncodeToString(checksum[:]))
 
-	return nil
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
 }
@@ -0,0 +1,59 @@
+package sys
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/terraform-plugin-framework/function"
+	"github.com/mildred/terraform-provider-sys/sys/utils"
+)
+
+type fileModeApplyUmaskFunction struct{}
+
+func newFileModeApplyUmaskFunction() function.Function {
+	return &fileModeApplyUmaskFunction{}
+}
+
+func (f *fileModeApplyUmaskFunction) Metadata(ctx context.Context, req function.MetadataRequest, resp *function.MetadataResponse) {
+	resp.Name = "file_mode_apply_umask"
+}
+
+func (f *fileModeApplyUmaskFunction) Definition(ctx context.Context, req function.DefinitionRequest, resp *function.DefinitionResponse) {
+	resp.Definition = function.Definition{
+		Summary:     "Applies a umask to a file mode",
+		Description: "Returns the octal file mode a file created with the given mode gets under the umask, such as 0644 for 0666 and 022.",
+		Parameters: []function.Parameter{
+			function.StringParameter{
+				Name:        "mode",
+				Description: "Octal file mode",
+			},
+			function.StringParameter{
+				Name:        "umask",
+				Description: "Octal umask",
+			},
+		},
+		Return: function.StringReturn{},
+	}
+}
+
+func (f *fileModeApplyUmaskFunction) Run(ctx context.Context, req function.RunRequest, resp *function.RunResponse) {
+	var modeString, umaskString string
+
+	resp.Error = req.Arguments.Get(ctx, &modeString, &umaskString)
+	if resp.Error != nil {
+		return
+	}
+
+	mode, err := utils.FileModeDecode(modeString)
+	if err != nil {
+		resp.Error = function.NewArgumentFuncError(0, err.Error())
+		return
+	}
+	umask, err := utils.FileModeDecode(umaskString)
+	if err != nil {
+		resp.Error = function.NewArgumentFuncError(1, err.Error())
+		return
+	}
+
+	resp.Error = resp.Result.Set(ctx, fmt.Sprintf("%04o", utils.FileModeApplyUmask(mode, umask)))
+}
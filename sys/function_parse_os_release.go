@@ -0,0 +1,43 @@
+package sys
+
+import (
+	"context"
+
+	"github.com/hashicorp/terraform-plugin-framework/function"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+type parseOsReleaseFunction struct{}
+
+func newParseOsReleaseFunction() function.Function {
+	return &parseOsReleaseFunction{}
+}
+
+func (f *parseOsReleaseFunction) Metadata(ctx context.Context, req function.MetadataRequest, resp *function.MetadataResponse) {
+	resp.Name = "parse_os_release"
+}
+
+func (f *parseOsReleaseFunction) Definition(ctx context.Context, req function.DefinitionRequest, resp *function.DefinitionResponse) {
+	resp.Definition = function.Definition{
+		Summary:     "Parses an os-release file",
+		Description: "Returns the variables of the os-release file content, like the result attribute of the sys_os_release data source.",
+		Parameters: []function.Parameter{
+			function.StringParameter{
+				Name:        "content",
+				Description: "Content of the os-release file",
+			},
+		},
+		Return: function.MapReturn{ElementType: types.StringType},
+	}
+}
+
+func (f *parseOsReleaseFunction) Run(ctx context.Context, req function.RunRequest, resp *function.RunResponse) {
+	var content string
+
+	resp.Error = req.Arguments.Get(ctx, &content)
+	if resp.Error != nil {
+		return
+	}
+
+	resp.Error = resp.Result.Set(ctx, parseOsRelease(content))
+}
@@ -0,0 +1,61 @@
+package sys
+
+import (
+	"context"
+
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/function"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+type parseUnameFunction struct{}
+
+func newParseUnameFunction() function.Function {
+	return &parseUnameFunction{}
+}
+
+func (f *parseUnameFunction) Metadata(ctx context.Context, req function.MetadataRequest, resp *function.MetadataResponse) {
+	resp.Name = "parse_uname"
+}
+
+func (f *parseUnameFunction) Definition(ctx context.Context, req function.DefinitionRequest, resp *function.DefinitionResponse) {
+	resp.Definition = function.Definition{
+		Summary:     "Parses the output of uname -a",
+		Description: "Returns the kernel_name, nodename, kernel_release, kernel_version, machine, processor, hardware_platform and operating_system fields of the uname -a output, like the sys_uname data source.",
+		Parameters: []function.Parameter{
+			function.StringParameter{
+				Name:        "output",
+				Description: "Output of uname -a",
+			},
+		},
+		Return: function.ObjectReturn{
+			AttributeTypes: map[string]attr.Type{
+				"kernel_name":       types.StringType,
+				"nodename":          types.StringType,
+				"kernel_release":    types.StringType,
+				"kernel_version":    types.StringType,
+				"machine":           types.StringType,
+				"processor":         types.StringType,
+				"hardware_platform": types.StringType,
+				"operating_system":  types.StringType,
+			},
+		},
+	}
+}
+
+func (f *parseUnameFunction) Run(ctx context.Context, req function.RunRequest, resp *function.RunResponse) {
+	var output string
+
+	resp.Error = req.Arguments.Get(ctx, &output)
+	if resp.Error != nil {
+		return
+	}
+
+	fields, err := parseUname(output)
+	if err != nil {
+		resp.Error = function.NewArgumentFuncError(0, err.Error())
+		return
+	}
+
+	resp.Error = resp.Result.Set(ctx, fields)
+}
@@ -0,0 +1,46 @@
+package sys
+
+import (
+	"context"
+
+	"github.com/hashicorp/terraform-plugin-framework/function"
+)
+
+type semverCompareFunction struct{}
+
+func newSemverCompareFunction() function.Function {
+	return &semverCompareFunction{}
+}
+
+func (f *semverCompareFunction) Metadata(ctx context.Context, req function.MetadataRequest, resp *function.MetadataResponse) {
+	resp.Name = "semver_compare"
+}
+
+func (f *semverCompareFunction) Definition(ctx context.Context, req function.DefinitionRequest, resp *function.DefinitionResponse) {
+	resp.Definition = function.Definition{
+		Summary:     "Compares two package versions",
+		Description: "Returns -1, 0 or 1 when the first version is lower, equal or greater than the second, with the dpkg --compare-versions rules: epochs, upstream versions and Debian revisions, tilde sorting before anything.",
+		Parameters: []function.Parameter{
+			function.StringParameter{
+				Name:        "version1",
+				Description: "First version",
+			},
+			function.StringParameter{
+				Name:        "version2",
+				Description: "Second version",
+			},
+		},
+		Return: function.Int64Return{},
+	}
+}
+
+func (f *semverCompareFunction) Run(ctx context.Context, req function.RunRequest, resp *function.RunResponse) {
+	var version1, version2 string
+
+	resp.Error = req.Arguments.Get(ctx, &version1, &version2)
+	if resp.Error != nil {
+		return
+	}
+
+	resp.Error = resp.Result.Set(ctx, int64(debVersionCompare(version1, version2)))
+}
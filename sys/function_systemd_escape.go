@@ -0,0 +1,42 @@
+package sys
+
+import (
+	"context"
+
+	"github.com/hashicorp/terraform-plugin-framework/function"
+)
+
+type systemdEscapeFunction struct{}
+
+func newSystemdEscapeFunction() function.Function {
+	return &systemdEscapeFunction{}
+}
+
+func (f *systemdEscapeFunction) Metadata(ctx context.Context, req function.MetadataRequest, resp *function.MetadataResponse) {
+	resp.Name = "systemd_escape"
+}
+
+func (f *systemdEscapeFunction) Definition(ctx context.Context, req function.DefinitionRequest, resp *function.DefinitionResponse) {
+	resp.Definition = function.Definition{
+		Summary:     "Escapes a string for use in a systemd unit name",
+		Description: "Escapes the string like systemd-escape, such as the instance name of a template unit.",
+		Parameters: []function.Parameter{
+			function.StringParameter{
+				Name:        "value",
+				Description: "String to escape",
+			},
+		},
+		Return: function.StringReturn{},
+	}
+}
+
+func (f *systemdEscapeFunction) Run(ctx context.Context, req function.RunRequest, resp *function.RunResponse) {
+	var value string
+
+	resp.Error = req.Arguments.Get(ctx, &value)
+	if resp.Error != nil {
+		return
+	}
+
+	resp.Error = resp.Result.Set(ctx, sdEscape(value))
+}
@@ -0,0 +1,166 @@
+package sys
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-go/tfprotov6"
+)
+
+// testCallFunction calls a provider function through the provider server,
+// returning its result or error
+func testCallFunction(t *testing.T, name string, args ...interface{}) (interface{}, string) {
+	t.Helper()
+	ctx := context.Background()
+	provider := testProviderServer(t)
+	server, ok := provider.(tfprotov6.FunctionServer)
+	if !ok {
+		t.Fatal("the provider server does not serve functions")
+	}
+
+	// Terraform reads the provider schema, with the function definitions,
+	// before calling functions
+	functions, err := provider.GetProviderSchema(ctx, &tfprotov6.GetProviderSchemaRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	definition := functions.Functions[name]
+	if definition == nil {
+		t.Fatalf("missing function %s", name)
+	}
+
+	var arguments []*tfprotov6.DynamicValue
+	for i, arg := range args {
+		typ := definition.Parameters[i].Type
+		value, err := tfprotov6.NewDynamicValue(typ, testTfValue(typ, arg))
+		if err != nil {
+			t.Fatal(err)
+		}
+		arguments = append(arguments, &value)
+	}
+
+	resp, err := server.CallFunction(ctx, &tfprotov6.CallFunctionRequest{
+		Name:      name,
+		Arguments: arguments,
+	})
+	if err != nil {
+		t.Fatal(err)
+	} else if resp.Error != nil {
+		return nil, resp.Error.Text
+	}
+
+	result, err := resp.Result.Unmarshal(definition.Return.Type)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return testTfGo(t, result), ""
+}
+
+func TestFunctions(t *testing.T) {
+	testCases := []struct {
+		name     string
+		args     []interface{}
+		expected interface{}
+		err      string
+	}{
+		{
+			name:     "parse_os_release",
+			args:     []interface{}{"# comment\nID=debian\nPRETTY_NAME=\"Debian GNU/Linux 12 (bookworm)\"\n"},
+			expected: map[string]interface{}{"ID": "debian", "PRETTY_NAME": "Debian GNU/Linux 12 (bookworm)"},
+		},
+		{
+			name:     "file_mode_apply_umask",
+			args:     []interface{}{"0666", "022"},
+			expected: "0644",
+		},
+		{
+			name:     "file_mode_apply_umask",
+			args:     []interface{}{"0777", "0027"},
+			expected: "0750",
+		},
+		{
+			name: "file_mode_apply_umask",
+			args: []interface{}{"rwx", "022"},
+			err:  "cannot decode rwx",
+		},
+		{
+			name: "parse_uname",
+			args: []interface{}{"Linux host 6.1.0-18-amd64 #1 SMP PREEMPT_DYNAMIC Debian 6.1.76-1 (2024-02-01) x86_64 unknown unknown GNU/Linux\n"},
+			expected: map[string]interface{}{
+				"kernel_name":       "Linux",
+				"nodename":          "host",
+				"kernel_release":    "6.1.0-18-amd64",
+				"kernel_version":    "#1 SMP PREEMPT_DYNAMIC Debian 6.1.76-1 (2024-02-01)",
+				"machine":           "x86_64",
+				"processor":         "unknown",
+				"hardware_platform": "unknown",
+				"operating_system":  "GNU/Linux",
+			},
+		},
+		{
+			name: "parse_uname",
+			args: []interface{}{"Linux"},
+			err:  "cannot parse uname output",
+		},
+		{
+			name:     "systemd_escape",
+			args:     []interface{}{"/dev/disk/by-label/my-disk"},
+			expected: `-dev-disk-by\x2dlabel-my\x2ddisk`,
+		},
+		{
+			name:     "systemd_escape",
+			args:     []interface{}{".hidden file"},
+			expected: `\x2ehidden\x20file`,
+		},
+	}
+
+	for _, tc := range testCases {
+		result, err := testCallFunction(t, tc.name, tc.args...)
+		if tc.err != "" {
+			if !strings.Contains(err, tc.err) {
+				t.Errorf("%s%v: expected error %q, got %q", tc.name, tc.args, tc.err, err)
+			}
+		} else if err != "" {
+			t.Errorf("%s%v: %s", tc.name, tc.args, err)
+		} else if !reflect.DeepEqual(result, tc.expected) {
+			t.Errorf("%s%v: expected %#v, got %#v", tc.name, tc.args, tc.expected, result)
+		}
+	}
+}
+
+func TestDebVersionCompare(t *testing.T) {
+	testCases := []struct {
+		a, b     string
+		expected int
+	}{
+		{"1.0", "1.0", 0},
+		{"1.0", "1.0-0", 0},
+		{"1.0", "1.1", -1},
+		{"1.10", "1.9", 1},
+		{"1.0~rc1", "1.0", -1},
+		{"1.0~rc1", "1.0~rc2", -1},
+		{"1.0~~", "1.0~", -1},
+		{"1.0a", "1.0", 1},
+		{"1.0a", "1.0+", -1},
+		{"1:1.0", "2.0", 1},
+		{"2.30-1", "2.30-1ubuntu2", -1},
+		{"1.2.3-1", "1.2.3-10", -1},
+		{"0010", "10", 0},
+	}
+
+	for _, tc := range testCases {
+		if res := debVersionCompare(tc.a, tc.b); res != tc.expected {
+			t.Errorf("compare %q %q: expected %d, got %d", tc.a, tc.b, tc.expected, res)
+		}
+		if res := debVersionCompare(tc.b, tc.a); res != -tc.expected {
+			t.Errorf("compare %q %q: expected %d, got %d", tc.b, tc.a, -tc.expected, res)
+		}
+	}
+
+	result, err := testCallFunction(t, "semver_compare", "2:1.0", "1:2.0")
+	if err != "" || result != 1 {
+		t.Errorf("semver_compare: expected 1, got %v %s", result, err)
+	}
+}
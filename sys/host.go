@@ -0,0 +1,53 @@
+package sys
+
+import (
+	"context"
+	"os"
+	"os/exec"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// sysHost is the machine resources act on, either the local host or a remote
+// host reached through the provider connection, possibly within root_dir.
+// Errors follow the os package conventions so os.IsNotExist and os.IsExist
+// can be used.
+type sysHost interface {
+	Stat(name string) (os.FileInfo, error)
+	Lstat(name string) (os.FileInfo, error)
+	// ReadDir returns the lstat information of the directory entries, sorted
+	// by name
+	ReadDir(name string) ([]os.FileInfo, error)
+	ReadFile(name string) ([]byte, error)
+	// WriteFile writes a file like os.OpenFile would with the given flags,
+	// new files are created with perm minus the umask
+	WriteFile(name string, data []byte, flag int, perm os.FileMode) error
+	Readlink(name string) (string, error)
+	Symlink(oldname, newname string) error
+	Mkdir(name string, perm os.FileMode) error
+	MkdirAll(name string, perm os.FileMode) error
+	Chmod(name string, mode os.FileMode) error
+	Remove(name string) error
+	RemoveAll(name string) error
+	// Upload copies a local file or directory tree to the host. Directories
+	// are merged with the existing destination.
+	Upload(src, dst string) error
+	// Command prepares a command to run on the host, env is added to the
+	// environment
+	Command(ctx context.Context, env []string, name string, args ...string) *exec.Cmd
+	// DialBus opens a private connection to the system bus, or the session
+	// bus if user is set
+	DialBus(ctx context.Context, user bool) (*dbus.Conn, error)
+	// Remote tells if the file system is not the one terraform runs on, a
+	// remote host or a root directory
+	Remote() bool
+}
+
+// providerHost returns the host configured for the provider, the local host
+// by default
+func providerHost(m interface{}) sysHost {
+	if c, ok := m.(*providerConfiguration); ok && c != nil && c.Host != nil {
+		return c.Host
+	}
+	return localHost{}
+}
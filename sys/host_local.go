@@ -0,0 +1,109 @@
+package sys
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// localHost is the host terraform runs on
+type localHost struct{}
+
+var _ sysHost = localHost{}
+
+func (localHost) Stat(name string) (os.FileInfo, error)        { return os.Stat(name) }
+func (localHost) Lstat(name string) (os.FileInfo, error)       { return os.Lstat(name) }
+func (localHost) ReadDir(name string) ([]os.FileInfo, error)   { return ioutil.ReadDir(name) }
+func (localHost) ReadFile(name string) ([]byte, error)         { return ioutil.ReadFile(name) }
+func (localHost) Readlink(name string) (string, error)         { return os.Readlink(name) }
+func (localHost) Symlink(oldname, newname string) error        { return os.Symlink(oldname, newname) }
+func (localHost) Mkdir(name string, perm os.FileMode) error    { return os.Mkdir(name, perm) }
+func (localHost) MkdirAll(name string, perm os.FileMode) error { return os.MkdirAll(name, perm) }
+func (localHost) Chmod(name string, mode os.FileMode) error    { return os.Chmod(name, mode) }
+func (localHost) Remove(name string) error                     { return os.Remove(name) }
+func (localHost) RemoveAll(name string) error                  { return os.RemoveAll(name) }
+func (localHost) Remote() bool                                 { return false }
+
+func (localHost) WriteFile(name string, data []byte, flag int, perm os.FileMode) error {
+	f, err := os.OpenFile(name, flag, perm)
+	if err != nil {
+		return err
+	}
+	n, err := f.Write(data)
+	if err == nil && n < len(data) {
+		err = io.ErrShortWrite
+	}
+	if err1 := f.Close(); err == nil {
+		err = err1
+	}
+	return err
+}
+
+func (h localHost) Upload(src, dst string) error {
+	return filepath.Walk(src, func(filename string, st os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, filename)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		switch {
+		case st.IsDir():
+			return os.MkdirAll(target, st.Mode().Perm())
+		case st.Mode()&os.ModeSymlink != 0:
+			link, err := os.Readlink(filename)
+			if err != nil {
+				return err
+			}
+			os.Remove(target)
+			return os.Symlink(link, target)
+		default:
+			data, err := ioutil.ReadFile(filename)
+			if err != nil {
+				return err
+			}
+			return h.WriteFile(target, data, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, st.Mode().Perm())
+		}
+	})
+}
+
+func (localHost) Command(ctx context.Context, env []string, name string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, name, args...)
+	if env != nil {
+		cmd.Env = append(os.Environ(), env...)
+	}
+	return cmd
+}
+
+func (localHost) DialBus(ctx context.Context, user bool) (*dbus.Conn, error) {
+	var conn *dbus.Conn
+	var err error
+	if user {
+		conn, err = dbus.SessionBusPrivate(dbus.WithContext(ctx))
+	} else {
+		conn, err = dbus.SystemBusPrivate(dbus.WithContext(ctx))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err = conn.Auth(nil); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if err = conn.Hello(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return conn, nil
+}
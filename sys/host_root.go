@@ -0,0 +1,195 @@
+package sys
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// rootHost prefixes the paths of the file operations with the root
+// directory of an image being built. Symlinks are resolved within the root
+// as if it was /, so absolute links of the image never reach the build host.
+// Commands are not chrooted, see execRunner.Root.
+type rootHost struct {
+	sysHost
+	Root string
+}
+
+var _ sysHost = (*rootHost)(nil)
+
+// rootMaxSymlinks is the number of symlinks followed before failing with
+// ELOOP, as the kernel does
+const rootMaxSymlinks = 40
+
+// path returns the path of name on the underlying host. Every component is
+// resolved within the root, the last one is only followed if it is a symlink
+// and follow is set.
+func (h *rootHost) path(name string, follow bool) (string, error) {
+	resolved := "/"
+	rest := strings.Split(name, "/")
+	links := 0
+
+	for len(rest) > 0 {
+		part := rest[0]
+		rest = rest[1:]
+
+		switch part {
+		case "", ".":
+			continue
+		case "..":
+			resolved = filepath.Dir(resolved)
+			continue
+		}
+
+		next := filepath.Join(resolved, part)
+		if len(rest) == 0 && !follow {
+			resolved = next
+			break
+		}
+
+		st, err := h.sysHost.Lstat(filepath.Join(h.Root, next))
+		if os.IsNotExist(err) || (err == nil && st.Mode()&os.ModeSymlink == 0) {
+			resolved = next
+			continue
+		} else if err != nil {
+			return "", err
+		}
+
+		links++
+		if links > rootMaxSymlinks {
+			return "", &os.PathError{Op: "resolve", Path: name, Err: syscall.ELOOP}
+		}
+
+		target, err := h.sysHost.Readlink(filepath.Join(h.Root, next))
+		if err != nil {
+			return "", err
+		}
+		if filepath.IsAbs(target) {
+			resolved = "/"
+		}
+		rest = append(strings.Split(target, "/"), rest...)
+	}
+
+	return filepath.Join(h.Root, resolved), nil
+}
+
+// Remote is true, local paths do not exist under the root
+func (h *rootHost) Remote() bool {
+	return true
+}
+
+func (h *rootHost) Stat(name string) (os.FileInfo, error) {
+	p, err := h.path(name, true)
+	if err != nil {
+		return nil, err
+	}
+	return h.sysHost.Stat(p)
+}
+
+func (h *rootHost) Lstat(name string) (os.FileInfo, error) {
+	p, err := h.path(name, false)
+	if err != nil {
+		return nil, err
+	}
+	return h.sysHost.Lstat(p)
+}
+
+func (h *rootHost) ReadDir(name string) ([]os.FileInfo, error) {
+	p, err := h.path(name, true)
+	if err != nil {
+		return nil, err
+	}
+	return h.sysHost.ReadDir(p)
+}
+
+func (h *rootHost) ReadFile(name string) ([]byte, error) {
+	p, err := h.path(name, true)
+	if err != nil {
+		return nil, err
+	}
+	return h.sysHost.ReadFile(p)
+}
+
+func (h *rootHost) WriteFile(name string, data []byte, flag int, perm os.FileMode) error {
+	p, err := h.path(name, true)
+	if err != nil {
+		return err
+	}
+	return h.sysHost.WriteFile(p, data, flag, perm)
+}
+
+func (h *rootHost) Readlink(name string) (string, error) {
+	p, err := h.path(name, false)
+	if err != nil {
+		return "", err
+	}
+	return h.sysHost.Readlink(p)
+}
+
+// Symlink keeps the link target as is, it is resolved within the image
+func (h *rootHost) Symlink(oldname, newname string) error {
+	p, err := h.path(newname, false)
+	if err != nil {
+		return err
+	}
+	return h.sysHost.Symlink(oldname, p)
+}
+
+func (h *rootHost) Mkdir(name string, perm os.FileMode) error {
+	p, err := h.path(name, false)
+	if err != nil {
+		return err
+	}
+	return h.sysHost.Mkdir(p, perm)
+}
+
+func (h *rootHost) MkdirAll(name string, perm os.FileMode) error {
+	p, err := h.path(name, true)
+	if err != nil {
+		return err
+	}
+	return h.sysHost.MkdirAll(p, perm)
+}
+
+func (h *rootHost) Chmod(name string, mode os.FileMode) error {
+	p, err := h.path(name, true)
+	if err != nil {
+		return err
+	}
+	return h.sysHost.Chmod(p, mode)
+}
+
+func (h *rootHost) Remove(name string) error {
+	p, err := h.path(name, false)
+	if err != nil {
+		return err
+	}
+	return h.sysHost.Remove(p)
+}
+
+func (h *rootHost) RemoveAll(name string) error {
+	p, err := h.path(name, false)
+	if err != nil {
+		return err
+	}
+	return h.sysHost.RemoveAll(p)
+}
+
+func (h *rootHost) Upload(src, dst string) error {
+	p, err := h.path(dst, true)
+	if err != nil {
+		return err
+	}
+	return h.sysHost.Upload(src, p)
+}
+
+// DialBus always fails, no manager runs in the image and the systemd
+// resources manage the unit files offline
+func (h *rootHost) DialBus(ctx context.Context, user bool) (*dbus.Conn, error) {
+	return nil, fmt.Errorf("no bus is available in %s", h.Root)
+}
@@ -0,0 +1,216 @@
+package sys
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRootHost(t *testing.T) {
+	root := t.TempDir()
+	h := &rootHost{sysHost: localHost{}, Root: root}
+
+	if err := h.MkdirAll("/etc/app", 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.WriteFile("/etc/app/conf", []byte("conf"), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Symlink("/etc/app/conf", "/etc/app/link"); err != nil {
+		t.Fatal(err)
+	}
+
+	if content, err := ioutil.ReadFile(path.Join(root, "etc/app/conf")); err != nil || string(content) != "conf" {
+		t.Errorf("expected the file to be written in the root, got %q (%v)", content, err)
+	}
+	if target, err := os.Readlink(path.Join(root, "etc/app/link")); err != nil || target != "/etc/app/conf" {
+		t.Errorf("expected the link target to be kept, got %q (%v)", target, err)
+	}
+	if content, err := h.ReadFile("/etc/app/conf"); err != nil || string(content) != "conf" {
+		t.Errorf("expected %q, got %q (%v)", "conf", content, err)
+	}
+
+	src := path.Join(t.TempDir(), "pkg.deb")
+	if err := ioutil.WriteFile(src, []byte("deb"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Mkdir("/tmp", 0777); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Upload(src, "/tmp/pkg.deb"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path.Join(root, "tmp/pkg.deb")); err != nil {
+		t.Errorf("expected the file to be uploaded in the root, got %v", err)
+	}
+
+	if err := h.RemoveAll("/etc/app"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Lstat(path.Join(root, "etc/app")); !os.IsNotExist(err) {
+		t.Errorf("expected the directory to be removed, got %v", err)
+	}
+	if !h.Remote() {
+		t.Errorf("expected a root directory to be remote")
+	}
+}
+
+func TestRootHostRunner(t *testing.T) {
+	bin := t.TempDir()
+	log := path.Join(bin, "chroot.log")
+	script := "#!/bin/sh\necho \"$*\" >" + shellQuote(log) + "\n"
+	if err := ioutil.WriteFile(path.Join(bin, "chroot"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+":"+os.Getenv("PATH"))
+
+	r := &execRunner{Prefix: []string{"env"}, Root: "/mnt/image"}
+	if err := r.Run(nil, nil, "apt-get", "install", "-y", "nginx"); err != nil {
+		t.Fatal(err)
+	}
+
+	args, err := ioutil.ReadFile(log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res := strings.TrimSpace(string(args)); res != "/mnt/image apt-get install -y nginx" {
+		t.Errorf("expected the command to run chrooted, got %q", res)
+	}
+}
+
+func TestFileResourceRoot(t *testing.T) {
+	root := t.TempDir()
+	env := newTestFrameworkEnvWith(t, &providerConfiguration{Host: &rootHost{sysHost: localHost{}, Root: root}})
+
+	state := env.mustApply("sys_file", nil, map[string]interface{}{
+		"filename": "/etc/motd",
+		"content":  "hello",
+	})
+	if content, err := ioutil.ReadFile(path.Join(root, "etc/motd")); err != nil || string(content) != "hello" {
+		t.Fatalf("expected the file to be written in the root, got %q (%v)", content, err)
+	}
+
+	env.mustApply("sys_file", state, nil)
+	if _, err := os.Stat(path.Join(root, "etc/motd")); !os.IsNotExist(err) {
+		t.Fatalf("expected the file to be removed, got %v", err)
+	}
+}
+
+func TestRootHostSymlinks(t *testing.T) {
+	root := t.TempDir()
+	h := &rootHost{sysHost: localHost{}, Root: root}
+
+	// The same absolute path exists on the build host and in the image
+	outside := t.TempDir()
+	if err := ioutil.WriteFile(path.Join(outside, "conf"), []byte("host"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.MkdirAll(outside, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.WriteFile(path.Join(outside, "conf"), []byte("image"), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for link, target := range map[string]string{
+		"/abs":    outside,
+		"/rel":    "../../.." + outside,
+		"/loop":   "/loop",
+		"/broken": "/missing/dir",
+	} {
+		if err := h.Symlink(target, link); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, name := range []string{"/abs/conf", "/rel/conf", "/../.." + outside + "/conf"} {
+		if content, err := h.ReadFile(name); err != nil || string(content) != "image" {
+			t.Errorf("expected %s to resolve within the root, got %q (%v)", name, content, err)
+		}
+	}
+
+	if err := h.WriteFile("/abs/new", []byte("new"), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path.Join(outside, "new")); !os.IsNotExist(err) {
+		t.Errorf("expected the build host to be left alone, got %v", err)
+	}
+	if _, err := os.Stat(path.Join(root, outside, "new")); err != nil {
+		t.Errorf("expected the file to be written in the root, got %v", err)
+	}
+
+	if err := h.MkdirAll("/broken/sub", 0755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path.Join(root, "missing/dir/sub")); err != nil {
+		t.Errorf("expected the link target to be created in the root, got %v", err)
+	}
+
+	if _, err := h.Stat("/loop"); err == nil {
+		t.Errorf("expected a symlink loop to fail")
+	}
+	if target, err := h.Readlink("/abs"); err != nil || target != outside {
+		t.Errorf("expected the link itself to be read, got %q (%v)", target, err)
+	}
+	if err := h.Remove("/abs"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path.Join(root, outside, "conf")); err != nil {
+		t.Errorf("expected the link target to be kept, got %v", err)
+	}
+}
+
+func TestRepositoryResourcesRoot(t *testing.T) {
+	root := t.TempDir()
+	env := newTestFrameworkEnvWith(t, &providerConfiguration{Host: &rootHost{sysHost: localHost{}, Root: root}})
+
+	state := env.mustApply("sys_apt_repository", nil, map[string]interface{}{
+		"name":       "docker",
+		"uris":       []interface{}{"https://download.docker.com/linux/debian"},
+		"suites":     []interface{}{"bookworm"},
+		"components": []interface{}{"stable"},
+		"key":        "KEY",
+	})
+	sources := path.Join(root, aptSourcesDir, "docker.sources")
+	expected := "Types: deb\nURIs: https://download.docker.com/linux/debian\nSuites: bookworm\nComponents: stable\nSigned-By: /etc/apt/keyrings/docker.asc\n"
+	if content, err := ioutil.ReadFile(sources); err != nil || string(content) != expected {
+		t.Fatalf("unexpected source file %q (%v)", content, err)
+	}
+	if state["filename"] != "/etc/apt/sources.list.d/docker.sources" || state["keyring_filename"] != "/etc/apt/keyrings/docker.asc" {
+		t.Fatalf("unexpected state %v", state)
+	}
+
+	// Files modified externally are written again
+	ioutil.WriteFile(sources, []byte("modified"), 0644)
+	if refreshed := env.refresh("sys_apt_repository", state); refreshed != nil {
+		t.Fatalf("expected the modified repository to be recreated, got %v", refreshed)
+	}
+	env.mustApply("sys_apt_repository", state, nil)
+	if _, err := os.Stat(sources); !os.IsNotExist(err) {
+		t.Fatalf("expected the source file to be removed, got %v", err)
+	}
+
+	_, errs := env.apply("sys_yum_repository", nil, map[string]interface{}{
+		"name": "epel",
+	})
+	if !strings.Contains(errs, "one of `baseurl,mirrorlist,metalink` must be specified") {
+		t.Fatalf("expected a missing URL error, got %q", errs)
+	}
+
+	state = env.mustApply("sys_yum_repository", nil, map[string]interface{}{
+		"name":     "epel",
+		"metalink": "https://mirrors.fedoraproject.org/metalink?repo=epel-9",
+		"enabled":  false,
+		"options":  map[string]interface{}{"priority": "10"},
+	})
+	expected = "[epel]\nname=epel\nmetalink=https://mirrors.fedoraproject.org/metalink?repo=epel-9\nenabled=0\ngpgcheck=0\npriority=10\n"
+	if content, err := ioutil.ReadFile(path.Join(root, yumReposDir, "epel.repo")); err != nil || string(content) != expected {
+		t.Fatalf("unexpected repository file %q (%v)", content, err)
+	}
+	if refreshed := env.refresh("sys_yum_repository", state); !reflect.DeepEqual(refreshed, state) {
+		t.Fatalf("expected the repository to be kept, got %v", refreshed)
+	}
+}
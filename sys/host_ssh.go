@@ -0,0 +1,526 @@
+package sys
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// sshHost is a remote host reached with the ssh command. File operations are
+// run as small shell scripts, the bus is reached through systemd-stdio-bridge.
+// Connections are shared using the ssh control master.
+type sshHost struct {
+	Host string
+	User string
+	Port int
+	// Private key file
+	Key string
+	// Run everything through sudo -n
+	Sudo bool
+	// ssh executable, "ssh" when empty
+	Program string
+}
+
+var _ sysHost = (*sshHost)(nil)
+
+// Exit codes of the file operation scripts mapped to errors
+var sshExitErrors = map[int]error{
+	3: syscall.ENOENT,
+	4: syscall.EEXIST,
+	5: syscall.ENOTDIR,
+	6: syscall.EINVAL,
+	7: syscall.ENOTEMPTY,
+}
+
+// shellQuote quotes a word for the POSIX shell
+func shellQuote(s string) string {
+	if s != "" && strings.Trim(s, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789@%_-+=:,./") == "" {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func (h *sshHost) Remote() bool {
+	return true
+}
+
+var sshControl struct {
+	once sync.Once
+	dir  string
+}
+
+// sshControlDir returns the directory of the control master sockets, private
+// to the user so that no other user can plant or use a socket there. It is
+// empty if no such directory could be made.
+func sshControlDir() string {
+	sshControl.once.Do(func() {
+		var err error
+		if runtime := os.Getenv("XDG_RUNTIME_DIR"); runtime != "" {
+			sshControl.dir, err = sshPrivateDir(filepath.Join(runtime, "terraform-provider-sys"))
+		} else {
+			sshControl.dir, err = sshPrivateDir(filepath.Join(os.TempDir(), fmt.Sprintf("terraform-provider-sys-%d", os.Getuid())))
+		}
+		if err != nil {
+			log.Printf("[WARN] ssh connections are not shared: %v\n", err)
+		}
+	})
+	return sshControl.dir
+}
+
+// sshPrivateDir creates a directory only accessible by the current user, or
+// checks that the existing one is
+func sshPrivateDir(dir string) (string, error) {
+	if err := os.Mkdir(dir, 0700); err != nil && !os.IsExist(err) {
+		return "", err
+	}
+
+	st, err := os.Lstat(dir)
+	if err != nil {
+		return "", err
+	}
+	if sys, ok := st.Sys().(*syscall.Stat_t); !st.IsDir() || !ok || int(sys.Uid) != os.Getuid() {
+		return "", fmt.Errorf("%s is not a directory owned by the current user", dir)
+	} else if st.Mode().Perm()&077 != 0 {
+		return "", fmt.Errorf("%s is accessible by other users (%s)", dir, st.Mode().Perm())
+	}
+	return dir, nil
+}
+
+func (h *sshHost) args() []string {
+	args := []string{
+		"-T",
+		"-o", "BatchMode=yes",
+	}
+	if dir := sshControlDir(); dir != "" {
+		args = append(args,
+			"-o", "ControlMaster=auto",
+			"-o", "ControlPersist=60",
+			"-o", "ControlPath="+filepath.Join(dir, "%C"))
+	} else {
+		args = append(args, "-o", "ControlMaster=no")
+	}
+	if h.Port != 0 {
+		args = append(args, "-p", strconv.Itoa(h.Port))
+	}
+	if h.User != "" {
+		args = append(args, "-l", h.User)
+	}
+	if h.Key != "" {
+		args = append(args, "-i", h.Key)
+	}
+	return append(args, "--", h.Host)
+}
+
+func (h *sshHost) Command(ctx context.Context, env []string, name string, args ...string) *exec.Cmd {
+	var words []string
+	if h.Sudo {
+		words = append(words, "sudo", "-n")
+	}
+	if len(env) > 0 {
+		words = append(append(words, "env"), env...)
+	}
+	words = append(append(words, name), args...)
+
+	for i, word := range words {
+		words[i] = shellQuote(word)
+	}
+
+	program := h.Program
+	if program == "" {
+		program = "ssh"
+	}
+	return exec.CommandContext(ctx, program, append(h.args(), strings.Join(words, " "))...)
+}
+
+// script runs a shell script on the host with the given arguments, failures
+// are returned as *os.PathError
+func (h *sshHost) script(op, name string, stdin io.Reader, stdout io.Writer, script string, args ...string) error {
+	stderr := new(bytes.Buffer)
+	cmd := h.Command(context.Background(), nil, "sh", append([]string{"-c", script, "sh"}, args...)...)
+	cmd.Stdin = stdin
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	err := cmd.Run()
+	if er, ok := err.(*exec.ExitError); ok {
+		if errno, ok := sshExitErrors[er.ExitCode()]; ok {
+			err = errno
+		} else if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			err = errors.New(msg)
+		}
+	}
+	if err != nil {
+		return &os.PathError{Op: op, Path: name, Err: err}
+	}
+	return nil
+}
+
+// sshFileInfo is parsed from the stat --printf '%f %s %Y %n' output
+type sshFileInfo struct {
+	name    string
+	size    int64
+	mode    os.FileMode
+	modTime time.Time
+}
+
+func (st *sshFileInfo) Name() string       { return st.name }
+func (st *sshFileInfo) Size() int64        { return st.size }
+func (st *sshFileInfo) Mode() os.FileMode  { return st.mode }
+func (st *sshFileInfo) ModTime() time.Time { return st.modTime }
+func (st *sshFileInfo) IsDir() bool        { return st.mode.IsDir() }
+func (st *sshFileInfo) Sys() interface{}   { return nil }
+
+const sshStatFormat = `'%f %s %Y %n\0'`
+
+// unixFileMode converts a raw stat mode to an os.FileMode
+func unixFileMode(raw uint32) os.FileMode {
+	mode := os.FileMode(raw & 0777)
+	switch raw & syscall.S_IFMT {
+	case syscall.S_IFDIR:
+		mode |= os.ModeDir
+	case syscall.S_IFLNK:
+		mode |= os.ModeSymlink
+	case syscall.S_IFIFO:
+		mode |= os.ModeNamedPipe
+	case syscall.S_IFSOCK:
+		mode |= os.ModeSocket
+	case syscall.S_IFCHR:
+		mode |= os.ModeDevice | os.ModeCharDevice
+	case syscall.S_IFBLK:
+		mode |= os.ModeDevice
+	}
+	if raw&syscall.S_ISUID != 0 {
+		mode |= os.ModeSetuid
+	}
+	if raw&syscall.S_ISGID != 0 {
+		mode |= os.ModeSetgid
+	}
+	if raw&syscall.S_ISVTX != 0 {
+		mode |= os.ModeSticky
+	}
+	return mode
+}
+
+// unixPerm converts the permission bits of an os.FileMode to an octal string
+func unixPerm(mode os.FileMode) string {
+	perm := uint32(mode.Perm())
+	if mode&os.ModeSetuid != 0 {
+		perm |= syscall.S_ISUID
+	}
+	if mode&os.ModeSetgid != 0 {
+		perm |= syscall.S_ISGID
+	}
+	if mode&os.ModeSticky != 0 {
+		perm |= syscall.S_ISVTX
+	}
+	return fmt.Sprintf("%04o", perm)
+}
+
+func parseSSHStat(output string) ([]os.FileInfo, error) {
+	var res []os.FileInfo
+	for _, entry := range strings.Split(output, "\x00") {
+		if entry == "" {
+			continue
+		}
+		fields := strings.SplitN(entry, " ", 4)
+		if len(fields) != 4 {
+			return nil, fmt.Errorf("cannot parse stat output %q", entry)
+		}
+		raw, err1 := strconv.ParseUint(fields[0], 16, 32)
+		size, err2 := strconv.ParseInt(fields[1], 10, 64)
+		mtime, err3 := strconv.ParseInt(fields[2], 10, 64)
+		if err1 != nil || err2 != nil || err3 != nil {
+			return nil, fmt.Errorf("cannot parse stat output %q", entry)
+		}
+		res = append(res, &sshFileInfo{
+			name:    path.Base(fields[3]),
+			size:    size,
+			mode:    unixFileMode(uint32(raw)),
+			modTime: time.Unix(mtime, 0),
+		})
+	}
+	return res, nil
+}
+
+func (h *sshHost) stat(op, name, script string) (os.FileInfo, error) {
+	stdout := new(strings.Builder)
+	err := h.script(op, name, nil, stdout, script, name)
+	if err != nil {
+		return nil, err
+	}
+	sts, err := parseSSHStat(stdout.String())
+	if err == nil && len(sts) != 1 {
+		err = fmt.Errorf("cannot parse stat output %q", stdout.String())
+	}
+	if err != nil {
+		return nil, &os.PathError{Op: op, Path: name, Err: err}
+	}
+	return sts[0], nil
+}
+
+func (h *sshHost) Stat(name string) (os.FileInfo, error) {
+	return h.stat("stat", name, `[ -e "$1" ] || exit 3; exec stat -L --printf `+sshStatFormat+` -- "$1"`)
+}
+
+func (h *sshHost) Lstat(name string) (os.FileInfo, error) {
+	return h.stat("lstat", name, `[ -e "$1" ] || [ -L "$1" ] || exit 3; exec stat --printf `+sshStatFormat+` -- "$1"`)
+}
+
+func (h *sshHost) ReadDir(name string) ([]os.FileInfo, error) {
+	stdout := new(strings.Builder)
+	err := h.script("open", name, nil, stdout, `
+		[ -d "$1" ] || { [ -e "$1" ] && exit 5; exit 3; }
+		cd -- "$1" || exit
+		for f in .[!.]* ..?* *; do
+			[ -e "$f" ] || [ -L "$f" ] || continue
+			stat --printf `+sshStatFormat+` -- "$f" || exit
+		done`, name)
+	if err != nil {
+		return nil, err
+	}
+	sts, err := parseSSHStat(stdout.String())
+	if err != nil {
+		return nil, &os.PathError{Op: "readdirent", Path: name, Err: err}
+	}
+	sort.Slice(sts, func(a, b int) bool {
+		return sts[a].Name() < sts[b].Name()
+	})
+	return sts, nil
+}
+
+func (h *sshHost) ReadFile(name string) ([]byte, error) {
+	stdout := new(bytes.Buffer)
+	err := h.script("open", name, nil, stdout, `[ -e "$1" ] || exit 3; exec cat -- "$1"`, name)
+	if err != nil {
+		return nil, err
+	}
+	return stdout.Bytes(), nil
+}
+
+// WriteFile honors O_EXCL, existing files are always truncated
+func (h *sshHost) WriteFile(name string, data []byte, flag int, perm os.FileMode) error {
+	excl := "0"
+	if flag&os.O_EXCL != 0 {
+		excl = "1"
+	}
+	return h.script("open", name, bytes.NewReader(data), nil, `
+		if [ -e "$1" ] || [ -L "$1" ]; then
+			[ "$3" = 1 ] && exit 4
+			exec cat >"$1"
+		fi
+		mode=$(printf %04o $(( 0$2 & ~0$(umask) )))
+		umask 077
+		cat >"$1" && exec chmod "$mode" -- "$1"`, name, unixPerm(perm), excl)
+}
+
+func (h *sshHost) Readlink(name string) (string, error) {
+	stdout := new(strings.Builder)
+	err := h.script("readlink", name, nil, stdout, `
+		[ -L "$1" ] || { [ -e "$1" ] && exit 6; exit 3; }
+		exec readlink -- "$1"`, name)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(stdout.String(), "\n"), nil
+}
+
+func (h *sshHost) Symlink(oldname, newname string) error {
+	return h.script("symlink", newname, nil, nil, `
+		{ [ -e "$2" ] || [ -L "$2" ]; } && exit 4
+		exec ln -s -- "$1" "$2"`, oldname, newname)
+}
+
+// The mkdir scripts restrict the umask so that directories are created with
+// perm minus the umask, like the mkdir system call
+const sshMkdirUmask = `umask $(printf %04o $(( (~0$2 | 0$(umask)) & 0777 )))`
+
+func (h *sshHost) Mkdir(name string, perm os.FileMode) error {
+	return h.script("mkdir", name, nil, nil, `
+		{ [ -e "$1" ] || [ -L "$1" ]; } && exit 4
+		[ -d "$(dirname -- "$1")" ] || exit 3
+		`+sshMkdirUmask+`
+		exec mkdir -- "$1"`, name, unixPerm(perm))
+}
+
+func (h *sshHost) MkdirAll(name string, perm os.FileMode) error {
+	return h.script("mkdir", name, nil, nil, `
+		[ -d "$1" ] && exit 0
+		`+sshMkdirUmask+`
+		exec mkdir -p -- "$1"`, name, unixPerm(perm))
+}
+
+func (h *sshHost) Chmod(name string, mode os.FileMode) error {
+	return h.script("chmod", name, nil, nil, `
+		[ -e "$1" ] || exit 3
+		exec chmod "$2" -- "$1"`, name, unixPerm(mode))
+}
+
+func (h *sshHost) Remove(name string) error {
+	return h.script("remove", name, nil, nil, `
+		[ -e "$1" ] || [ -L "$1" ] || exit 3
+		[ -L "$1" ] || [ ! -d "$1" ] && exec rm -f -- "$1"
+		[ -z "$(ls -A -- "$1")" ] || exit 7
+		exec rmdir -- "$1"`, name)
+}
+
+func (h *sshHost) RemoveAll(name string) error {
+	return h.script("unlinkat", name, nil, nil, `exec rm -rf -- "$1"`, name)
+}
+
+// Upload streams a tar archive of src extracted in the destination directory
+func (h *sshHost) Upload(src, dst string) error {
+	st, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+
+	dir, base := dst, "."
+	if !st.IsDir() {
+		dir, base = path.Dir(dst), path.Base(dst)
+	}
+
+	r, w := io.Pipe()
+	go func() {
+		w.CloseWithError(sshTar(w, src, base))
+	}()
+
+	err = h.script("upload", dst, r, nil, `mkdir -p -- "$1" && exec tar -C "$1" -xf -`, dir)
+	r.Close()
+	return err
+}
+
+// sshTar writes src to the archive under the name base
+func sshTar(w io.Writer, src, base string) error {
+	tw := tar.NewWriter(w)
+	err := filepath.Walk(src, func(filename string, st os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, filename)
+		if err != nil {
+			return err
+		}
+
+		var link string
+		if st.Mode()&os.ModeSymlink != 0 {
+			if link, err = os.Readlink(filename); err != nil {
+				return err
+			}
+		}
+
+		header, err := tar.FileInfoHeader(st, link)
+		if err != nil {
+			return err
+		}
+		header.Name = path.Join(base, filepath.ToSlash(rel))
+		header.Uid, header.Gid, header.Uname, header.Gname = 0, 0, "", ""
+		if st.IsDir() {
+			header.Name += "/"
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+
+		if !st.Mode().IsRegular() {
+			return nil
+		}
+		f, err := os.Open(filename)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return tw.Close()
+}
+
+// sshPipe is the standard input and output of a remote command
+type sshPipe struct {
+	io.ReadCloser
+	stdin io.WriteCloser
+	cmd   *exec.Cmd
+}
+
+func (p *sshPipe) Write(b []byte) (int, error) {
+	return p.stdin.Write(b)
+}
+
+func (p *sshPipe) Close() error {
+	p.stdin.Close()
+	p.ReadCloser.Close()
+	return p.cmd.Wait()
+}
+
+// DialBus connects to the bus through systemd-stdio-bridge
+// uid returns the user ID commands run as on the host
+func (h *sshHost) uid(ctx context.Context) (string, error) {
+	out, err := h.Command(ctx, nil, "id", "-u").Output()
+	if err != nil {
+		return "", fmt.Errorf("cannot get the user ID on %s: %v", h.Host, err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+func (h *sshHost) DialBus(ctx context.Context, user bool) (*dbus.Conn, error) {
+	var args []string
+	if user {
+		args = append(args, "--user")
+	}
+
+	// EXTERNAL authentication is checked against the credentials of the
+	// bridge, running as the remote user
+	uid, err := h.uid(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := h.Command(ctx, nil, "systemd-stdio-bridge", args...)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("cannot run systemd-stdio-bridge on %s: %v", h.Host, err)
+	}
+
+	conn, err := dbus.NewConn(&sshPipe{stdout, stdin, cmd}, dbus.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	err = conn.Auth([]dbus.Auth{dbus.AuthExternal(uid), dbus.AuthAnonymous()})
+	if err == nil {
+		err = conn.Hello()
+	}
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("cannot connect to the bus on %s: %v", h.Host, err)
+	}
+
+	return conn, nil
+}
@@ -0,0 +1,394 @@
+package sys
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/mildred/terraform-provider-sys/sys/utils"
+)
+
+// testSSHHost returns a host using a fake ssh command running the remote
+// command locally. The ssh arguments are logged to the returned file.
+func testSSHHost(t *testing.T) (*sshHost, string) {
+	t.Helper()
+	dir := t.TempDir()
+	log := path.Join(dir, "ssh.log")
+	program := path.Join(dir, "ssh")
+
+	script := `#!/bin/sh
+echo "$*" >>` + shellQuote(log) + `
+while [ "$1" != -- ]; do shift; done
+shift 2
+exec sh -c "$1"
+`
+	if err := ioutil.WriteFile(program, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	return &sshHost{Host: "example.org", Program: program}, log
+}
+
+func TestShellQuote(t *testing.T) {
+	for word, expected := range map[string]string{
+		"":          "''",
+		"foo":       "foo",
+		"/etc/foo":  "/etc/foo",
+		"foo bar":   "'foo bar'",
+		"it's":      `'it'\''s'`,
+		"$HOME":     "'$HOME'",
+		"a\nb":      "'a\nb'",
+		"--x=1,2:3": "--x=1,2:3",
+	} {
+		if res := shellQuote(word); res != expected {
+			t.Errorf("shellQuote(%q): expected %s, got %s", word, expected, res)
+		}
+	}
+}
+
+func TestSSHHostCommand(t *testing.T) {
+	h, log := testSSHHost(t)
+	h.User = "admin"
+	h.Port = 2222
+	h.Key = "/keys/id"
+
+	out, err := h.Command(context.Background(), []string{"FOO=it's $bar"}, "sh", "-c", `printf '%s|' "$FOO" "$1"`, "sh", "a b").Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != "it's $bar|a b|" {
+		t.Errorf("arguments were not quoted, got %q", out)
+	}
+
+	args, err := ioutil.ReadFile(log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, arg := range []string{"-T", "BatchMode=yes", "-p 2222", "-l admin", "-i /keys/id", "-- example.org "} {
+		if !strings.Contains(string(args), arg) {
+			t.Errorf("expected %q in the ssh arguments %q", arg, args)
+		}
+	}
+
+	r := &execRunner{Host: h}
+	err = r.Run(nil, nil, "sh", "-c", "exit 3")
+	if er, ok := err.(*ExitError); !ok || er.ExitCode() != 3 {
+		t.Fatalf("expected exit error, got %v", err)
+	}
+}
+
+func TestSSHPrivateDir(t *testing.T) {
+	dir := path.Join(t.TempDir(), "control")
+
+	for i := 0; i < 2; i++ {
+		if res, err := sshPrivateDir(dir); err != nil || res != dir {
+			t.Fatalf("expected %s, got %q (%v)", dir, res, err)
+		}
+	}
+	if st, err := os.Stat(dir); err != nil || st.Mode().Perm() != 0700 {
+		t.Fatalf("expected a private directory, got %v (%v)", st, err)
+	}
+
+	if err := os.Chmod(dir, 0777); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sshPrivateDir(dir); err == nil {
+		t.Errorf("expected a directory accessible by other users to be refused")
+	}
+
+	link := path.Join(t.TempDir(), "link")
+	if err := os.Symlink(dir, link); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sshPrivateDir(link); err == nil {
+		t.Errorf("expected a symlink to be refused")
+	}
+}
+
+func TestSSHHostUID(t *testing.T) {
+	h, _ := testSSHHost(t)
+
+	uid, err := h.uid(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uid != strconv.Itoa(os.Getuid()) {
+		t.Errorf("expected the user ID %d, got %q", os.Getuid(), uid)
+	}
+}
+
+func TestSSHHostSudo(t *testing.T) {
+	h, _ := testSSHHost(t)
+	h.Sudo = true
+
+	cmd := h.Command(context.Background(), []string{"FOO=bar"}, "true")
+	if remote := cmd.Args[len(cmd.Args)-1]; remote != "sudo -n env FOO=bar true" {
+		t.Errorf("expected the command to run with sudo, got %q", remote)
+	}
+}
+
+func TestSSHHostFiles(t *testing.T) {
+	h, _ := testSSHHost(t)
+	dir := t.TempDir()
+	sub := path.Join(dir, "a b", "c")
+
+	if err := h.MkdirAll(sub, 0750); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.MkdirAll(sub, 0750); err != nil {
+		t.Fatalf("MkdirAll on an existing directory: %v", err)
+	}
+	if err := h.Mkdir(sub, 0750); !os.IsExist(err) {
+		t.Errorf("expected Mkdir to fail on an existing directory, got %v", err)
+	}
+	if err := h.Mkdir(path.Join(dir, "missing", "d"), 0750); !os.IsNotExist(err) {
+		t.Errorf("expected Mkdir to fail without parent, got %v", err)
+	}
+
+	st, err := h.Stat(sub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !st.IsDir() || st.Name() != "c" || st.Mode().Perm() != 0750&^utils.Umask {
+		t.Errorf("unexpected directory stat %s %s", st.Name(), st.Mode())
+	}
+
+	filename := path.Join(sub, "it's")
+	content := []byte("line 1\nline 2\x00\n")
+	if err := h.WriteFile(filename, content, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0640); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.WriteFile(filename, content, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0640); !os.IsExist(err) {
+		t.Errorf("expected O_EXCL to fail on an existing file, got %v", err)
+	}
+	if res, err := h.ReadFile(filename); err != nil || string(res) != string(content) {
+		t.Errorf("expected %q, got %q (%v)", content, res, err)
+	}
+	if _, err := h.ReadFile(filename + ".missing"); !os.IsNotExist(err) {
+		t.Errorf("expected missing file, got %v", err)
+	}
+
+	if err := h.Chmod(filename, 0604); err != nil {
+		t.Fatal(err)
+	}
+	st, err = h.Stat(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	local, _ := os.Stat(filename)
+	if st.Mode() != 0604 || st.Size() != int64(len(content)) || !st.ModTime().Equal(local.ModTime().Truncate(1e9)) {
+		t.Errorf("unexpected file stat %s %d %s", st.Mode(), st.Size(), st.ModTime())
+	}
+
+	link := path.Join(sub, "link")
+	if err := h.Symlink("it's", link); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Symlink("it's", link); !os.IsExist(err) {
+		t.Errorf("expected Symlink to fail on an existing file, got %v", err)
+	}
+	if target, err := h.Readlink(link); err != nil || target != "it's" {
+		t.Errorf("expected link to it's, got %q (%v)", target, err)
+	}
+	if st, err := h.Lstat(link); err != nil || st.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("expected a symlink, got %v (%v)", st, err)
+	}
+	if st, err := h.Stat(link); err != nil || !st.Mode().IsRegular() {
+		t.Errorf("expected a regular file, got %v (%v)", st, err)
+	}
+
+	entries, err := h.ReadDir(sub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	if strings.Join(names, ",") != "it's,link" {
+		t.Errorf("unexpected directory entries %v", names)
+	}
+
+	// checksums must not depend on the host
+	remoteSum, err := checksumFile(h, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	localSum, err := checksumFile(localHost{}, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if remoteSum != localSum {
+		t.Errorf("expected checksum %s, got %s", localSum, remoteSum)
+	}
+
+	if err := h.Remove(path.Join(dir, "a b")); !os.IsExist(err) {
+		t.Errorf("expected Remove to fail on a non empty directory, got %v", err)
+	}
+	if err := h.Remove(link); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Remove(link); !os.IsNotExist(err) {
+		t.Errorf("expected Remove to fail on a missing file, got %v", err)
+	}
+	if err := h.RemoveAll(path.Join(dir, "a b")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.Lstat(sub); !os.IsNotExist(err) {
+		t.Errorf("expected RemoveAll to remove %s, got %v", sub, err)
+	}
+}
+
+func TestSSHHostUpload(t *testing.T) {
+	h, _ := testSSHHost(t)
+	src := t.TempDir()
+	dst := path.Join(t.TempDir(), "dst")
+
+	if err := os.MkdirAll(path.Join(src, "dir"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path.Join(src, "dir", "file"), []byte("content"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("dir/file", path.Join(src, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := h.Upload(src, dst); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Upload(path.Join(src, "dir", "file"), path.Join(dst, "copy")); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, expected := range map[string]string{"dir/file": "content", "link": "content", "copy": "content"} {
+		if content, err := ioutil.ReadFile(path.Join(dst, name)); err != nil || string(content) != expected {
+			t.Errorf("expected %s to contain %q, got %q (%v)", name, expected, content, err)
+		}
+	}
+	if target, err := os.Readlink(path.Join(dst, "link")); err != nil || target != "dir/file" {
+		t.Errorf("expected the symlink to be uploaded, got %q (%v)", target, err)
+	}
+}
+
+func TestFileResourceSSH(t *testing.T) {
+	h, _ := testSSHHost(t)
+	env := newTestFrameworkEnvWith(t, &providerConfiguration{Host: h})
+	filename := path.Join(t.TempDir(), "dir", "file")
+
+	state := env.mustApply("sys_file", nil, map[string]interface{}{
+		"filename":        filename,
+		"content":         "hello",
+		"file_permission": "0600",
+	})
+
+	content, err := ioutil.ReadFile(filename)
+	if err != nil || string(content) != "hello" {
+		t.Fatalf("expected the file to be written, got %q (%v)", content, err)
+	}
+
+	if state = env.refresh("sys_file", state); state == nil {
+		t.Fatalf("expected the file to be found on refresh")
+	}
+
+	env.mustApply("sys_file", state, nil)
+	if _, err := os.Stat(filename); !os.IsNotExist(err) {
+		t.Fatalf("expected the file to be removed, got %v", err)
+	}
+}
+
+func TestShellScriptResourceSSH(t *testing.T) {
+	h, log := testSSHHost(t)
+	meta := &providerConfiguration{Host: h}
+	dir := t.TempDir()
+	filename := path.Join(dir, "file")
+
+	env := newTestFrameworkEnvWith(t, meta)
+	state := env.mustApply("sys_shell_script", nil, map[string]interface{}{
+		"working_directory": dir,
+		"create":            "echo hello >file",
+		"filename":          filename,
+	})
+	if content, err := ioutil.ReadFile(filename); err != nil || string(content) != "hello\n" {
+		t.Fatalf("expected the script to run in the working directory, got %q (%v)", content, err)
+	}
+	if args, err := ioutil.ReadFile(log); err != nil || !strings.Contains(string(args), shellQuote(dir)) {
+		t.Errorf("expected the script to run through ssh, got %q (%v)", args, err)
+	}
+
+	env.mustApply("sys_shell_script", state, nil)
+	if _, err := os.Stat(filename); !os.IsNotExist(err) {
+		t.Fatalf("expected the file to be removed, got %v", err)
+	}
+}
+
+func TestDataSourcesSSH(t *testing.T) {
+	h, log := testSSHHost(t)
+	meta := &providerConfiguration{Host: h}
+	filename := path.Join(t.TempDir(), "file")
+	if err := ioutil.WriteFile(filename, []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	env := newTestFrameworkEnvWith(t, meta)
+	state, errs := env.read("sys_file", map[string]interface{}{"filename": filename})
+	if errs != "" {
+		t.Fatal(errs)
+	}
+	if state["content"] != "hello" {
+		t.Errorf("expected %q, got %q", "hello", state["content"])
+	}
+
+	if _, errs = env.read("sys_uname", map[string]interface{}{"flag": "s"}); errs != "" {
+		t.Fatal(errs)
+	}
+
+	args, err := ioutil.ReadFile(log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(args), shellQuote(filename)) || !strings.Contains(string(args), "uname -s") {
+		t.Errorf("expected the data sources to read through ssh, got %q", args)
+	}
+}
+
+func TestSystemdUserUnitDirSSH(t *testing.T) {
+	h, _ := testSSHHost(t)
+	t.Setenv("XDG_CONFIG_HOME", "/home/admin/.config")
+
+	dir, err := sdScope{User: true, host: h}.UnitDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dir != "/home/admin/.config/systemd/user" {
+		t.Errorf("expected the remote user configuration directory, got %s", dir)
+	}
+
+	if _, err := (sdScope{User: true, host: &rootHost{sysHost: localHost{}, Root: "/mnt"}}).UnitDir(); err == nil {
+		t.Errorf("expected user units to be rejected with root_dir")
+	}
+}
+
+func TestProviderConnection(t *testing.T) {
+	h, log := testSSHHost(t)
+	t.Setenv("PATH", path.Dir(h.Program)+":"+os.Getenv("PATH"))
+	filename := path.Join(t.TempDir(), "file")
+	if err := ioutil.WriteFile(filename, []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	env := newTestFrameworkEnvConfig(t, map[string]interface{}{
+		"connection": map[string]interface{}{"host": "example.org", "user": "admin"},
+	})
+	if _, errs := env.read("sys_file", map[string]interface{}{"filename": filename}); errs != "" {
+		t.Fatal(errs)
+	}
+
+	if args, err := ioutil.ReadFile(log); err != nil || !strings.Contains(string(args), "-l admin -- example.org") {
+		t.Errorf("expected the connection to manage the host over ssh, got %q (%v)", args, err)
+	}
+}
@@ -0,0 +1,179 @@
+package sys
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// errJournalUnavailable is returned by the journal library reader when it
+// cannot handle the query, journalctl is used instead
+var errJournalUnavailable = errors.New("journal library not available")
+
+// journalQuery selects journal entries, the zero value matches the last
+// entries of the system journal
+type journalQuery struct {
+	Unit       string
+	User       bool
+	UID        string // user units of another user, read by root
+	Boot       string // journalctl -b argument: boot ID, 0 or a negative offset
+	Identifier string
+	Since      time.Time
+	Lines      int
+	Priority   int // maximum priority, 0 (emerg) to 7 (debug)
+}
+
+type journalEntry struct {
+	Cursor    string
+	Timestamp time.Time
+	Fields    map[string]string
+}
+
+// Unit returns the unit the entry was logged by or is about
+func (e *journalEntry) Unit() string {
+	for _, field := range []string{"_SYSTEMD_UNIT", "_SYSTEMD_USER_UNIT", "UNIT", "USER_UNIT"} {
+		if unit := e.Fields[field]; unit != "" {
+			return unit
+		}
+	}
+	return ""
+}
+
+// journalRead returns the matching entries of the host journal, oldest first,
+// with the journal library when built in and journalctl otherwise. Remote
+// hosts always use journalctl.
+func journalRead(ctx context.Context, host sysHost, q journalQuery) ([]journalEntry, error) {
+	if host.Remote() {
+		return journalReadCtl(ctx, host, q)
+	}
+	entries, err := journalReadLibrary(q)
+	if err == errJournalUnavailable {
+		log.Printf("[DEBUG] Read the journal with journalctl: %v\n", err)
+		return journalReadCtl(ctx, host, q)
+	}
+	return entries, err
+}
+
+// journalParseSince parses an RFC 3339 timestamp or a duration before now
+func journalParseSince(since string, now time.Time) (time.Time, error) {
+	if since == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, since); err == nil {
+		return t, nil
+	}
+	d, err := time.ParseDuration(since)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid since %q, expected an RFC 3339 timestamp or a duration", since)
+	}
+	return now.Add(-d), nil
+}
+
+func journalCtlArgs(q journalQuery) []string {
+	args := []string{"--no-pager", "--quiet", "-o", "json"}
+	if q.User {
+		args = append(args, "--user")
+	}
+	if q.Lines > 0 {
+		args = append(args, "-n", strconv.Itoa(q.Lines))
+	}
+	if q.Unit != "" && q.UID != "" {
+		args = append(args, "--user-unit", q.Unit)
+	} else if q.Unit != "" {
+		args = append(args, "-u", q.Unit)
+	}
+	if q.Boot != "" {
+		args = append(args, "-b", q.Boot)
+	}
+	if q.Identifier != "" {
+		args = append(args, "-t", q.Identifier)
+	}
+	if !q.Since.IsZero() {
+		args = append(args, "--since", fmt.Sprintf("@%d", q.Since.Unix()))
+	}
+	if q.Priority < 7 {
+		args = append(args, "-p", strconv.Itoa(q.Priority))
+	}
+	if q.UID != "" {
+		args = append(args, "_UID="+q.UID)
+	}
+	return args
+}
+
+func journalReadCtl(ctx context.Context, host sysHost, q journalQuery) ([]journalEntry, error) {
+	args := journalCtlArgs(q)
+	log.Printf("[TRACE] journalctl %s\n", strings.Join(args, " "))
+
+	var stderr bytes.Buffer
+	cmd := host.Command(ctx, nil, "journalctl", args...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("journalctl: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	return journalParseJSON(out)
+}
+
+// journalParseJSON parses the journalctl -o json output, one entry per line.
+// Binary fields are skipped and repeated fields are joined by newlines.
+func journalParseJSON(out []byte) ([]journalEntry, error) {
+	var entries []journalEntry
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	scanner.Buffer(nil, 16*1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var raw map[string]interface{}
+		if err := json.Unmarshal(line, &raw); err != nil {
+			return nil, fmt.Errorf("cannot parse journal entry: %v", err)
+		}
+
+		fields := map[string]string{}
+		for key, value := range raw {
+			switch v := value.(type) {
+			case string:
+				fields[key] = v
+			case []interface{}:
+				var values []string
+				for _, item := range v {
+					if s, ok := item.(string); ok {
+						values = append(values, s)
+					}
+				}
+				if len(values) > 0 {
+					fields[key] = strings.Join(values, "\n")
+				}
+			}
+		}
+
+		entries = append(entries, journalEntryFrom(fields))
+	}
+
+	return entries, scanner.Err()
+}
+
+// journalEntryFrom builds an entry from its fields, moving the address
+// fields out of them
+func journalEntryFrom(fields map[string]string) journalEntry {
+	e := journalEntry{Cursor: fields["__CURSOR"], Fields: map[string]string{}}
+	if usec, err := strconv.ParseInt(fields["__REALTIME_TIMESTAMP"], 10, 64); err == nil {
+		e.Timestamp = time.Unix(0, usec*int64(time.Microsecond)).UTC()
+	}
+	for key, value := range fields {
+		if !strings.HasPrefix(key, "__") {
+			e.Fields[key] = value
+		}
+	}
+	return e
+}
@@ -0,0 +1,9 @@
+//go:build !sdjournal || !cgo
+
+package sys
+
+// journalReadLibrary is not available without the sdjournal build tag, which
+// requires the libsystemd headers
+func journalReadLibrary(q journalQuery) ([]journalEntry, error) {
+	return nil, errJournalUnavailable
+}
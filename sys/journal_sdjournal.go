@@ -0,0 +1,152 @@
+//go:build sdjournal && cgo
+
+package sys
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/coreos/go-systemd/v22/sdjournal"
+)
+
+var journalBootIDRegexp = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+// journalBootID returns the boot ID to match, relative boot offsets other
+// than the current boot are left to journalctl
+func journalBootID(boot string) (string, error) {
+	if journalBootIDRegexp.MatchString(boot) {
+		return boot, nil
+	} else if boot != "0" {
+		return "", errJournalUnavailable
+	}
+
+	id, err := os.ReadFile("/proc/sys/kernel/random/boot_id")
+	if err != nil {
+		return "", errJournalUnavailable
+	}
+	return strings.ReplaceAll(strings.TrimSpace(string(id)), "-", ""), nil
+}
+
+// journalPriorities returns the PRIORITY values up to the maximum priority
+func journalPriorities(max int) []string {
+	var res []string
+	for p := 0; p <= max; p++ {
+		res = append(res, strconv.Itoa(p))
+	}
+	return res
+}
+
+// journalAddMatches adds the matches of the query, each filter being a
+// conjunction of alternatives like journalctl does
+func journalAddMatches(j *sdjournal.Journal, q journalQuery) error {
+	var groups [][][]string
+
+	if q.Unit != "" && q.UID != "" {
+		groups = append(groups, [][]string{
+			{"_SYSTEMD_USER_UNIT=" + q.Unit},
+			{"USER_UNIT=" + q.Unit},
+		}, [][]string{{"_UID=" + q.UID}})
+	} else if q.Unit != "" {
+		groups = append(groups, [][]string{
+			{"_SYSTEMD_UNIT=" + q.Unit},
+			{"UNIT=" + q.Unit, "_PID=1"},
+		})
+	}
+	if q.Boot != "" {
+		id, err := journalBootID(q.Boot)
+		if err != nil {
+			return err
+		}
+		groups = append(groups, [][]string{{"_BOOT_ID=" + id}})
+	}
+	if q.Identifier != "" {
+		groups = append(groups, [][]string{{"SYSLOG_IDENTIFIER=" + q.Identifier}})
+	}
+	if q.Priority < 7 {
+		var priorities []string
+		for _, p := range journalPriorities(q.Priority) {
+			priorities = append(priorities, "PRIORITY="+p)
+		}
+		groups = append(groups, [][]string{priorities})
+	}
+
+	for i, group := range groups {
+		if i > 0 {
+			if err := j.AddConjunction(); err != nil {
+				return err
+			}
+		}
+		for k, matches := range group {
+			if k > 0 {
+				if err := j.AddDisjunction(); err != nil {
+					return err
+				}
+			}
+			for _, match := range matches {
+				if err := j.AddMatch(match); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// journalReadLibrary reads the system journal with sd-journal, walking back
+// from its tail
+func journalReadLibrary(q journalQuery) ([]journalEntry, error) {
+	if q.User {
+		// sdjournal cannot open the journal of the current user only
+		return nil, errJournalUnavailable
+	}
+
+	j, err := sdjournal.NewJournal()
+	if err != nil {
+		return nil, errJournalUnavailable
+	}
+	defer j.Close()
+
+	if err := journalAddMatches(j, q); err != nil {
+		return nil, err
+	}
+
+	if err := j.SeekTail(); err != nil {
+		return nil, fmt.Errorf("cannot seek the journal: %v", err)
+	}
+
+	var entries []journalEntry
+	for q.Lines <= 0 || len(entries) < q.Lines {
+		n, err := j.Previous()
+		if err != nil {
+			return nil, fmt.Errorf("cannot read the journal: %v", err)
+		} else if n == 0 {
+			break
+		}
+
+		entry, err := j.GetEntry()
+		if err != nil {
+			return nil, fmt.Errorf("cannot read the journal: %v", err)
+		}
+
+		timestamp := time.Unix(0, int64(entry.RealtimeTimestamp)*int64(time.Microsecond))
+		if !q.Since.IsZero() && timestamp.Before(q.Since) {
+			break
+		}
+
+		entry.Fields["__CURSOR"] = entry.Cursor
+		entry.Fields["__REALTIME_TIMESTAMP"] = strconv.FormatUint(entry.RealtimeTimestamp, 10)
+		entries = append(entries, journalEntryFrom(entry.Fields))
+	}
+
+	// Oldest first, like journalctl
+	for i, k := 0, len(entries)-1; i < k; i, k = i+1, k-1 {
+		entries[i], entries[k] = entries[k], entries[i]
+	}
+
+	return entries, nil
+}
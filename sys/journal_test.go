@@ -0,0 +1,86 @@
+package sys
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestJournalParseSince(t *testing.T) {
+	now := time.Date(2021, 3, 4, 12, 0, 0, 0, time.UTC)
+
+	for since, expected := range map[string]time.Time{
+		"":                     {},
+		"10m":                  now.Add(-10 * time.Minute),
+		"2021-03-04T10:00:00Z": time.Date(2021, 3, 4, 10, 0, 0, 0, time.UTC),
+	} {
+		res, err := journalParseSince(since, now)
+		if err != nil {
+			t.Fatalf("cannot parse %q: %v", since, err)
+		} else if !res.Equal(expected) {
+			t.Errorf("parse %q: expected %v, got %v", since, expected, res)
+		}
+	}
+
+	if _, err := journalParseSince("yesterday", now); err == nil {
+		t.Errorf("expected an error for an invalid since")
+	}
+}
+
+func TestJournalCtlArgs(t *testing.T) {
+	args := journalCtlArgs(journalQuery{
+		Unit:     "nginx.service",
+		Boot:     "0",
+		Since:    time.Unix(1614859200, 0),
+		Lines:    10,
+		Priority: 3,
+	})
+	expected := []string{
+		"--no-pager", "--quiet", "-o", "json", "-n", "10", "-u", "nginx.service",
+		"-b", "0", "--since", "@1614859200", "-p", "3",
+	}
+	if !reflect.DeepEqual(args, expected) {
+		t.Errorf("expected %q, got %q", expected, args)
+	}
+
+	args = journalCtlArgs(journalQuery{User: true, Identifier: "sshd", Priority: 7})
+	expected = []string{"--no-pager", "--quiet", "-o", "json", "--user", "-t", "sshd"}
+	if !reflect.DeepEqual(args, expected) {
+		t.Errorf("expected %q, got %q", expected, args)
+	}
+
+	args = journalCtlArgs(journalQuery{Unit: "app.service", UID: "1000", Priority: 7})
+	expected = []string{"--no-pager", "--quiet", "-o", "json", "--user-unit", "app.service", "_UID=1000"}
+	if !reflect.DeepEqual(args, expected) {
+		t.Errorf("expected %q, got %q", expected, args)
+	}
+}
+
+func TestJournalParseJSON(t *testing.T) {
+	out := []byte(`{"__CURSOR":"s=1;i=2","__REALTIME_TIMESTAMP":"1614859200123456","_SYSTEMD_UNIT":"nginx.service","MESSAGE":"started","PRIORITY":"6","_PID":"42"}
+{"__CURSOR":"s=1;i=3","__REALTIME_TIMESTAMP":"1614859201000000","UNIT":"nginx.service","MESSAGE":[104,105],"TAG":["a","b"]}
+`)
+
+	entries, err := journalParseJSON(out)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(entries) != 2 {
+		t.Fatalf("expected 2 entries, got %v", entries)
+	}
+
+	e := entries[0]
+	if e.Cursor != "s=1;i=2" || !e.Timestamp.Equal(time.Unix(1614859200, 123456000)) {
+		t.Errorf("unexpected entry address: %v", e)
+	}
+	if e.Fields["MESSAGE"] != "started" || e.Unit() != "nginx.service" {
+		t.Errorf("unexpected entry fields: %v", e.Fields)
+	}
+	if _, ok := e.Fields["__CURSOR"]; ok {
+		t.Errorf("address fields should not be in the entry fields: %v", e.Fields)
+	}
+
+	e = entries[1]
+	if _, ok := e.Fields["MESSAGE"]; ok || e.Fields["TAG"] != "a\nb" || e.Unit() != "nginx.service" {
+		t.Errorf("unexpected entry fields: %v", e.Fields)
+	}
+}
@@ -0,0 +1,135 @@
+package sys
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+var apkLock sync.Mutex
+
+type apkBackend struct {
+	runner commandRunner
+}
+
+func (b *apkBackend) Lock() sync.Locker {
+	return &apkLock
+}
+
+func (b *apkBackend) UpdateCache() error {
+	return packageRun(b.runner, nil, "apk", "update")
+}
+
+func (b *apkBackend) Query(name string) (*packageInfo, error) {
+	version, err := apkList(b.runner, name, "--installed")
+	if err != nil || version == "" {
+		return nil, err
+	}
+
+	return &packageInfo{Name: name, Version: version}, nil
+}
+
+// apkList returns the version of the package listed by apk list
+func apkList(r commandRunner, name, filter string) (string, error) {
+	stdout := new(bytes.Buffer)
+	err := r.Run(stdout, nil, "apk", "list", filter, name)
+	if err != nil {
+		return "", fmt.Errorf("cannot query apk list: %v", err)
+	}
+
+	// Lines are formatted as: name-version-rREL arch {origin} (license) [status]
+	for _, line := range strings.Split(stdout.String(), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || !strings.HasPrefix(fields[0], name+"-") {
+			continue
+		}
+		version := strings.TrimPrefix(fields[0], name+"-")
+		if version == "" || version[0] < '0' || version[0] > '9' {
+			continue
+		}
+		return version, nil
+	}
+
+	return "", nil
+}
+
+func (b *apkBackend) QueryFile(filename string) (*packageInfo, error) {
+	stdout := new(bytes.Buffer)
+	err := b.runner.Run(stdout, nil, "tar", "-xzOf", filename, ".PKGINFO")
+	if err != nil {
+		return nil, fmt.Errorf("cannot read .PKGINFO from %s: %v", filename, err)
+	}
+
+	var info packageInfo
+	for _, line := range strings.Split(stdout.String(), "\n") {
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) < 2 {
+			continue
+		}
+		switch strings.TrimSpace(parts[0]) {
+		case "pkgname":
+			info.Name = strings.TrimSpace(parts[1])
+		case "pkgver":
+			info.Version = strings.TrimSpace(parts[1])
+		}
+	}
+	if info.Name == "" || info.Version == "" {
+		return nil, fmt.Errorf("cannot parse .PKGINFO (name: %s, version: %s):\n%s", info.Name, info.Version, stdout.String())
+	}
+
+	return &info, nil
+}
+
+func (b *apkBackend) InstallFile(filename string) error {
+	return packageRun(b.runner, nil, "apk", "add", filename)
+}
+
+func (b *apkBackend) Install(pkgs ...*packageSpec) error {
+	args := []string{"add"}
+	for _, pkg := range pkgs {
+		pkgspec := pkg.Name
+		if pkg.Version != "" {
+			pkgspec = fmt.Sprintf("%s=%s", pkgspec, pkg.Version)
+		}
+		if pkg.TargetRelease != "" {
+			pkgspec = fmt.Sprintf("%s@%s", pkgspec, pkg.TargetRelease)
+		}
+		args = append(args, pkgspec)
+	}
+
+	return packageRun(b.runner, nil, "apk", args...)
+}
+
+// Candidate only returns a version if an upgrade is available
+func (b *apkBackend) Candidate(name string) (string, error) {
+	return apkList(b.runner, name, "--upgradable")
+}
+
+func (b *apkBackend) Upgrade(names ...string) error {
+	return packageRun(b.runner, nil, "apk", append([]string{"add", "--upgrade"}, names...)...)
+}
+
+// Hold pins the installed versions in /etc/apk/world
+func (b *apkBackend) Hold(hold bool, names ...string) error {
+	if !hold {
+		return packageRun(b.runner, nil, "apk", append([]string{"add"}, names...)...)
+	}
+
+	args := []string{"add"}
+	for _, name := range names {
+		info, err := b.Query(name)
+		if err != nil {
+			return err
+		} else if info == nil {
+			return fmt.Errorf("cannot hold %s, package is not installed", name)
+		}
+		args = append(args, fmt.Sprintf("%s=%s", name, info.Version))
+	}
+
+	return packageRun(b.runner, nil, "apk", args...)
+}
+
+func (b *apkBackend) Remove(names ...string) error {
+	return packageRun(b.runner, nil, "apk", append([]string{"del"}, names...)...)
+}
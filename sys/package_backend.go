@@ -0,0 +1,159 @@
+package sys
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"sync"
+)
+
+// packageBackend abstracts a system package manager. Every backend has its
+// own lock that must be held while calling any of its methods.
+type packageBackend interface {
+	Lock() sync.Locker
+	UpdateCache() error
+	// Query returns nil if the package is not installed
+	Query(name string) (*packageInfo, error)
+	// QueryFile reads the name and version from a package file
+	QueryFile(filename string) (*packageInfo, error)
+	// Candidate returns the version that would be installed by an upgrade
+	Candidate(name string) (string, error)
+	// Install installs, upgrades or downgrades packages to the requested
+	// versions in a single transaction
+	Install(pkgs ...*packageSpec) error
+	// InstallFile installs, upgrades or downgrades from a package file
+	InstallFile(filename string) error
+	// Upgrade upgrades installed packages to their candidate version
+	Upgrade(names ...string) error
+	// Hold prevents or allows automatic upgrades of the packages
+	Hold(hold bool, names ...string) error
+	Remove(names ...string) error
+}
+
+type packageSpec struct {
+	Name          string
+	Version       string
+	TargetRelease string
+}
+
+type packageInfo struct {
+	Name    string
+	Version string
+}
+
+// packageScope is the installation scope of language package managers
+type packageScope struct {
+	Virtualenv string
+	Prefix     string
+	User       bool
+}
+
+// Backends are instanciated with the command runner and the installation scope
+// used by language package managers
+var packageBackends = map[string]func(r commandRunner, scope packageScope) packageBackend{
+	"deb":    func(r commandRunner, scope packageScope) packageBackend { return &debBackend{r} },
+	"rpm":    func(r commandRunner, scope packageScope) packageBackend { return &dnfBackend{r} },
+	"apk":    func(r commandRunner, scope packageScope) packageBackend { return &apkBackend{r} },
+	"pacman": func(r commandRunner, scope packageScope) packageBackend { return &pacmanBackend{r} },
+	"zypper": func(r commandRunner, scope packageScope) packageBackend { return &zypperBackend{r} },
+	"pip":    func(r commandRunner, scope packageScope) packageBackend { return &pipBackend{r, scope} },
+	"npm":    func(r commandRunner, scope packageScope) packageBackend { return &npmBackend{r, scope} },
+	"gem":    func(r commandRunner, scope packageScope) packageBackend { return &gemBackend{r, scope} },
+	"cargo":  func(r commandRunner, scope packageScope) packageBackend { return &cargoBackend{r, scope} },
+}
+
+// Alternate names for package types
+var packageTypeAliases = map[string]string{
+	"dnf": "rpm",
+}
+
+const osReleaseFile = "/etc/os-release"
+
+// Maps os-release ID and ID_LIKE values to package types
+var osReleasePackageTypes = map[string]string{
+	"debian":   "deb",
+	"ubuntu":   "deb",
+	"fedora":   "rpm",
+	"rhel":     "rpm",
+	"centos":   "rpm",
+	"alpine":   "apk",
+	"arch":     "pacman",
+	"suse":     "zypper",
+	"opensuse": "zypper",
+	"sles":     "zypper",
+}
+
+func packageTypeAuto(host sysHost) (string, error) {
+	content, err := host.ReadFile(osReleaseFile)
+	if err != nil {
+		return "", fmt.Errorf("cannot detect package type, %v", err)
+	}
+
+	release := parseOsRelease(string(content))
+	ids := append([]string{release["ID"]}, strings.Fields(release["ID_LIKE"])...)
+	for _, id := range ids {
+		if t, ok := osReleasePackageTypes[id]; ok {
+			return t, nil
+		}
+	}
+
+	return "", fmt.Errorf("cannot detect package type for %s (ID_LIKE: %s)", release["ID"], release["ID_LIKE"])
+}
+
+func packageBackendFor(t string, scope packageScope, r commandRunner, host sysHost) (string, packageBackend, error) {
+	var err error
+	if t == "auto" {
+		t, err = packageTypeAuto(host)
+		if err != nil {
+			return t, nil, err
+		}
+	} else if alias, ok := packageTypeAliases[t]; ok {
+		t = alias
+	}
+
+	newBackend, ok := packageBackends[t]
+	if !ok {
+		return t, nil, fmt.Errorf("Unknown package type %s", t)
+	}
+
+	return t, newBackend(r, scope), nil
+}
+
+// packageNoFile is used by backends that cannot install package files
+func packageNoFile(t, filename string) error {
+	return fmt.Errorf("%s cannot install package file %s", t, filename)
+}
+
+// packageNoHold is used by backends that cannot hold packages
+func packageNoHold(t string, hold bool, names []string) error {
+	if hold {
+		return fmt.Errorf("%s cannot hold %s", t, strings.Join(names, ", "))
+	}
+	return nil
+}
+
+// packageVersionMatches tells if an installed version satisfies the wanted
+// version, either exactly, as a glob pattern or ignoring the package release.
+func packageVersionMatches(wanted, installed string) bool {
+	if wanted == "" || wanted == installed || strings.HasPrefix(installed, wanted+"-") {
+		return true
+	}
+	matched, err := path.Match(wanted, installed)
+	return err == nil && matched
+}
+
+// packageField extracts a "Key: value" field from a package manager output
+func packageField(output, key string) string {
+	for _, line := range strings.Split(output, "\n") {
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) == 2 && strings.TrimSpace(parts[0]) == key {
+			return strings.TrimSpace(parts[1])
+		}
+	}
+	return ""
+}
+
+func isExitError(err error) bool {
+	_, ok := err.(*ExitError)
+	return ok
+}
@@ -0,0 +1,62 @@
+package sys
+
+import (
+	"time"
+)
+
+// packageBatch collects the packages to install in a single transaction
+type packageBatch struct {
+	pkgs []*packageSpec
+	done chan struct{}
+	err  error
+}
+
+// packageBatchKey identifies the packages that can be installed together, a
+// package type within an installation scope
+type packageBatchKey struct {
+	Type  string
+	Scope packageScope
+}
+
+// packageInstallBatched coalesces concurrent installations of the same package
+// type and scope into a single transaction. The first caller waits for the batch window
+// to collect other packages, then installs all of them while the other callers
+// wait for the result. The backend lock must not be held.
+func packageInstallBatched(c *providerConfiguration, t string, scope packageScope, backend packageBackend, pkgs []*packageSpec) error {
+	key := packageBatchKey{t, scope}
+
+	c.Lock.Lock()
+	if c.PkgBatches == nil {
+		c.PkgBatches = map[packageBatchKey]*packageBatch{}
+	}
+	batch, pending := c.PkgBatches[key]
+	if !pending {
+		batch = &packageBatch{done: make(chan struct{})}
+		c.PkgBatches[key] = batch
+	}
+	batch.pkgs = append(batch.pkgs, pkgs...)
+	c.Lock.Unlock()
+
+	if pending {
+		<-batch.done
+		return batch.err
+	}
+
+	time.Sleep(c.PkgBatchWindow)
+
+	c.Lock.Lock()
+	delete(c.PkgBatches, key)
+	c.Lock.Unlock()
+
+	lock := backend.Lock()
+	lock.Lock()
+	defer lock.Unlock()
+	defer close(batch.done)
+
+	batch.err = packageUpdateCache(c, t, backend)
+	if batch.err == nil {
+		batch.err = backend.Install(batch.pkgs...)
+	}
+
+	return batch.err
+}
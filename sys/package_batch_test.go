@@ -0,0 +1,63 @@
+package sys
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// batchBackend records the packages of each Install call
+type batchBackend struct {
+	packageBackend
+	lock     *sync.Mutex
+	installs *[]string
+}
+
+func (b *batchBackend) Lock() sync.Locker {
+	return b.lock
+}
+
+func (b *batchBackend) UpdateCache() error {
+	return nil
+}
+
+func (b *batchBackend) Install(pkgs ...*packageSpec) error {
+	var names []string
+	for _, pkg := range pkgs {
+		names = append(names, pkg.Name)
+	}
+	sort.Strings(names)
+	*b.installs = append(*b.installs, strings.Join(names, " "))
+	return nil
+}
+
+func TestPackageInstallBatchedScope(t *testing.T) {
+	c := &providerConfiguration{PkgBatchWindow: 100 * time.Millisecond}
+	var installs []string
+	backend := &batchBackend{lock: new(sync.Mutex), installs: &installs}
+
+	var wg sync.WaitGroup
+	for name, scope := range map[string]packageScope{
+		"requests": {Virtualenv: "/srv/a"},
+		"flask":    {Virtualenv: "/srv/a"},
+		"pyyaml":   {Virtualenv: "/srv/b"},
+		"black":    {User: true},
+	} {
+		wg.Add(1)
+		go func(name string, scope packageScope) {
+			defer wg.Done()
+			err := packageInstallBatched(c, "pip", scope, backend, []*packageSpec{{Name: name}})
+			if err != nil {
+				t.Error(err)
+			}
+		}(name, scope)
+	}
+	wg.Wait()
+
+	sort.Strings(installs)
+	if res := strings.Join(installs, ", "); res != "black, flask requests, pyyaml" {
+		t.Errorf("expected one transaction per scope, got %s", res)
+	}
+}
@@ -0,0 +1,103 @@
+package sys
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+var cargoLock sync.Mutex
+
+type cargoBackend struct {
+	runner commandRunner
+	scope  packageScope
+}
+
+func (b *cargoBackend) Lock() sync.Locker {
+	return &cargoLock
+}
+
+func (b *cargoBackend) args(args ...string) []string {
+	if b.scope.Prefix != "" {
+		args = append(args, "--root", b.scope.Prefix)
+	}
+	return args
+}
+
+func (b *cargoBackend) UpdateCache() error {
+	return nil
+}
+
+func (b *cargoBackend) Query(name string) (*packageInfo, error) {
+	stdout := new(bytes.Buffer)
+	err := b.runner.Run(stdout, nil, "cargo", b.args("install", "--list")...)
+	if err != nil {
+		return nil, fmt.Errorf("cannot query cargo install --list: %v", err)
+	}
+
+	// Crates are listed as "name v1.2.3:" followed by indented binaries
+	for _, line := range strings.Split(stdout.String(), "\n") {
+		fields := strings.Fields(strings.TrimSuffix(line, ":"))
+		if len(fields) >= 2 && fields[0] == name && !strings.HasPrefix(line, " ") {
+			return &packageInfo{Name: name, Version: strings.TrimPrefix(fields[1], "v")}, nil
+		}
+	}
+
+	return nil, nil
+}
+
+func (b *cargoBackend) QueryFile(filename string) (*packageInfo, error) {
+	return nil, packageNoFile("cargo", filename)
+}
+
+func (b *cargoBackend) Candidate(name string) (string, error) {
+	stdout := new(bytes.Buffer)
+	err := b.runner.Run(stdout, nil, "cargo", "search", "--limit", "1", name)
+	if err != nil {
+		return "", fmt.Errorf("cannot query cargo search: %v", err)
+	}
+
+	// Results are formatted as: name = "1.2.3"    # description
+	for _, line := range strings.Split(stdout.String(), "\n") {
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) < 2 || strings.TrimSpace(parts[0]) != name {
+			continue
+		}
+		if fields := strings.Fields(parts[1]); len(fields) > 0 {
+			return strings.Trim(fields[0], "\""), nil
+		}
+	}
+
+	return "", nil
+}
+
+func (b *cargoBackend) Install(pkgs ...*packageSpec) error {
+	args := []string{"install"}
+	for _, pkg := range pkgs {
+		pkgspec := pkg.Name
+		if pkg.Version != "" {
+			pkgspec = fmt.Sprintf("%s@%s", pkgspec, pkg.Version)
+		}
+		args = append(args, pkgspec)
+	}
+
+	return packageRun(b.runner, nil, "cargo", b.args(args...)...)
+}
+
+func (b *cargoBackend) InstallFile(filename string) error {
+	return packageNoFile("cargo", filename)
+}
+
+// Upgrade relies on cargo install replacing outdated crates
+func (b *cargoBackend) Upgrade(names ...string) error {
+	return packageRun(b.runner, nil, "cargo", b.args(append([]string{"install"}, names...)...)...)
+}
+
+func (b *cargoBackend) Hold(hold bool, names ...string) error {
+	return packageNoHold("cargo", hold, names)
+}
+
+func (b *cargoBackend) Remove(names ...string) error {
+	return packageRun(b.runner, nil, "cargo", b.args(append([]string{"uninstall"}, names...)...)...)
+}
@@ -0,0 +1,226 @@
+package sys
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var debLock sync.Mutex
+
+var debEnv = []string{
+	"DEBIAN_FRONTEND=noninteractive",
+}
+
+type debBackend struct {
+	runner commandRunner
+}
+
+func (b *debBackend) Lock() sync.Locker {
+	return &debLock
+}
+
+func (b *debBackend) UpdateCache() error {
+	return packageRun(b.runner, nil, "apt-get", "update")
+}
+
+func (b *debBackend) Query(name string) (*packageInfo, error) {
+	stdout := new(bytes.Buffer)
+	err := b.runner.Run(stdout, nil, "dpkg", "-s", name)
+	if isExitError(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("cannot query dpkg -s: %v", err)
+	}
+
+	var info packageInfo
+
+	r := bufio.NewScanner(stdout)
+	for r.Scan() {
+		line := r.Text()
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) < 2 {
+			continue
+		}
+		value := strings.TrimSpace(parts[1])
+
+		switch key := parts[0]; key {
+		case "Package":
+			info.Name = value
+		case "Version":
+			info.Version = value
+		}
+	}
+	if err := r.Err(); err != nil {
+		return nil, fmt.Errorf("cannot parse dpkg -s results: %v\n%s", err, stdout.String())
+	} else if info.Name == "" || info.Version == "" {
+		return nil, fmt.Errorf("cannot parse dpkg -s results (name: %s, version: %s):\n%s", info.Name, info.Version, stdout.String())
+	}
+
+	return &info, nil
+}
+
+func (b *debBackend) QueryFile(filename string) (*packageInfo, error) {
+	stdout := new(bytes.Buffer)
+	err := b.runner.Run(stdout, nil, "dpkg-deb", "--show", "--showformat", "${Package}\t${Version}\n", filename)
+	if err != nil {
+		return nil, fmt.Errorf("cannot query dpkg-deb --show: %v", err)
+	}
+
+	parts := strings.SplitN(strings.TrimSpace(stdout.String()), "\t", 2)
+	if len(parts) < 2 || parts[0] == "" || parts[1] == "" {
+		return nil, fmt.Errorf("cannot parse dpkg-deb --show results:\n%s", stdout.String())
+	}
+
+	return &packageInfo{Name: parts[0], Version: parts[1]}, nil
+}
+
+func (b *debBackend) Install(pkgs ...*packageSpec) error {
+	args := []string{"install", "-y", "--allow-downgrades"}
+	for _, pkg := range pkgs {
+		pkgspec := pkg.Name
+		if pkg.Version != "" {
+			pkgspec = fmt.Sprintf("%s=%s", pkgspec, pkg.Version)
+		}
+		if pkg.TargetRelease != "" {
+			pkgspec = fmt.Sprintf("%s/%s", pkgspec, pkg.TargetRelease)
+		}
+		args = append(args, pkgspec)
+	}
+
+	return packageRun(b.runner, debEnv, "apt-get", args...)
+}
+
+func (b *debBackend) InstallFile(filename string) error {
+	return packageRun(b.runner, debEnv, "apt-get", "install", "-y", "--allow-downgrades", filename)
+}
+
+func (b *debBackend) Candidate(name string) (string, error) {
+	stdout := new(bytes.Buffer)
+	err := b.runner.Run(stdout, nil, "apt-cache", "policy", name)
+	if err != nil {
+		return "", fmt.Errorf("cannot query apt-cache policy: %v", err)
+	}
+
+	candidate := packageField(stdout.String(), "Candidate")
+	if candidate == "(none)" {
+		candidate = ""
+	}
+	return candidate, nil
+}
+
+func (b *debBackend) Upgrade(names ...string) error {
+	return packageRun(b.runner, debEnv, "apt-get", append([]string{"install", "-y", "--only-upgrade"}, names...)...)
+}
+
+func (b *debBackend) Hold(hold bool, names ...string) error {
+	if hold {
+		return packageRun(b.runner, nil, "apt-mark", append([]string{"hold"}, names...)...)
+	} else {
+		return packageRun(b.runner, nil, "apt-mark", append([]string{"unhold"}, names...)...)
+	}
+}
+
+func (b *debBackend) Remove(names ...string) error {
+	return packageRun(b.runner, debEnv, "apt-get", append([]string{"remove", "-y"}, names...)...)
+}
+
+// debVersionCompare compares two Debian package versions like
+// dpkg --compare-versions, returning -1, 0 or 1
+func debVersionCompare(a, b string) int {
+	epochA, upstreamA, revisionA := debVersionSplit(a)
+	epochB, upstreamB, revisionB := debVersionSplit(b)
+
+	if epochA != epochB {
+		return debSign(epochA - epochB)
+	}
+	if res := debVersionPartCompare(upstreamA, upstreamB); res != 0 {
+		return res
+	}
+	return debVersionPartCompare(revisionA, revisionB)
+}
+
+// debVersionSplit splits a version in its epoch, upstream version and
+// Debian revision
+func debVersionSplit(version string) (epoch int, upstream, revision string) {
+	upstream = strings.TrimSpace(version)
+	if i := strings.Index(upstream, ":"); i >= 0 {
+		epoch, _ = strconv.Atoi(upstream[:i])
+		upstream = upstream[i+1:]
+	}
+	if i := strings.LastIndex(upstream, "-"); i >= 0 {
+		upstream, revision = upstream[:i], upstream[i+1:]
+	}
+	return
+}
+
+// debVersionOrder orders the non digit characters: tilde before the end of
+// the part, before letters, before other characters
+func debVersionOrder(s string, i int) int {
+	if i >= len(s) {
+		return 0
+	}
+	switch c := s[i]; {
+	case c >= '0' && c <= '9':
+		return 0
+	case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z':
+		return int(c)
+	case c == '~':
+		return -1
+	default:
+		return int(c) + 256
+	}
+}
+
+func debIsDigit(s string, i int) bool {
+	return i < len(s) && s[i] >= '0' && s[i] <= '9'
+}
+
+// debVersionPartCompare compares upstream versions or revisions, alternating
+// non digit and digit sequences
+func debVersionPartCompare(a, b string) int {
+	i, k := 0, 0
+	for i < len(a) || k < len(b) {
+		for (i < len(a) && !debIsDigit(a, i)) || (k < len(b) && !debIsDigit(b, k)) {
+			if ac, bc := debVersionOrder(a, i), debVersionOrder(b, k); ac != bc {
+				return debSign(ac - bc)
+			}
+			i++
+			k++
+		}
+		for i < len(a) && a[i] == '0' {
+			i++
+		}
+		for k < len(b) && b[k] == '0' {
+			k++
+		}
+		firstDiff := 0
+		for debIsDigit(a, i) && debIsDigit(b, k) {
+			if firstDiff == 0 {
+				firstDiff = int(a[i]) - int(b[k])
+			}
+			i++
+			k++
+		}
+		if debIsDigit(a, i) {
+			return 1
+		} else if debIsDigit(b, k) {
+			return -1
+		} else if firstDiff != 0 {
+			return debSign(firstDiff)
+		}
+	}
+	return 0
+}
+
+func debSign(n int) int {
+	if n < 0 {
+		return -1
+	} else if n > 0 {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,113 @@
+package sys
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+var gemLock sync.Mutex
+
+type gemBackend struct {
+	runner commandRunner
+	scope  packageScope
+}
+
+func (b *gemBackend) Lock() sync.Locker {
+	return &gemLock
+}
+
+func (b *gemBackend) env() []string {
+	if b.scope.Prefix != "" {
+		return []string{"GEM_HOME=" + b.scope.Prefix}
+	}
+	return nil
+}
+
+func (b *gemBackend) UpdateCache() error {
+	return nil
+}
+
+// gemParseList parses a "name (version, ...)" line from gem list
+func gemParseList(output, name string) string {
+	for _, line := range strings.Split(output, "\n") {
+		if !strings.HasPrefix(line, name+" (") {
+			continue
+		}
+		versions := strings.TrimSuffix(strings.TrimPrefix(line, name+" ("), ")")
+		version := strings.TrimSpace(strings.SplitN(versions, ",", 2)[0])
+		return strings.TrimPrefix(version, "default: ")
+	}
+	return ""
+}
+
+func (b *gemBackend) Query(name string) (*packageInfo, error) {
+	stdout := new(bytes.Buffer)
+	err := b.runner.Run(stdout, b.env(), "gem", "list", "--local", "--exact", name)
+	if err != nil {
+		return nil, fmt.Errorf("cannot query gem list: %v", err)
+	}
+
+	version := gemParseList(stdout.String(), name)
+	if version == "" {
+		return nil, nil
+	}
+
+	return &packageInfo{Name: name, Version: version}, nil
+}
+
+func (b *gemBackend) QueryFile(filename string) (*packageInfo, error) {
+	return nil, packageNoFile("gem", filename)
+}
+
+func (b *gemBackend) Candidate(name string) (string, error) {
+	stdout := new(bytes.Buffer)
+	err := b.runner.Run(stdout, b.env(), "gem", "list", "--remote", "--exact", name)
+	if err != nil {
+		return "", fmt.Errorf("cannot query gem list --remote: %v", err)
+	}
+
+	return gemParseList(stdout.String(), name), nil
+}
+
+func (b *gemBackend) installArgs(args ...string) []string {
+	args = append(args, "--no-document")
+	if b.scope.User {
+		args = append(args, "--user-install")
+	}
+	return args
+}
+
+func (b *gemBackend) Install(pkgs ...*packageSpec) error {
+	args := b.installArgs("install")
+	for _, pkg := range pkgs {
+		pkgspec := pkg.Name
+		if pkg.Version != "" {
+			pkgspec = fmt.Sprintf("%s:%s", pkgspec, pkg.Version)
+		}
+		args = append(args, pkgspec)
+	}
+
+	return packageRun(b.runner, b.env(), "gem", args...)
+}
+
+func (b *gemBackend) InstallFile(filename string) error {
+	return packageNoFile("gem", filename)
+}
+
+func (b *gemBackend) Upgrade(names ...string) error {
+	return packageRun(b.runner, b.env(), "gem", append(b.installArgs("update"), names...)...)
+}
+
+func (b *gemBackend) Hold(hold bool, names ...string) error {
+	return packageNoHold("gem", hold, names)
+}
+
+func (b *gemBackend) Remove(names ...string) error {
+	args := []string{"uninstall", "--all", "--executables"}
+	if b.scope.User {
+		args = append(args, "--user-install")
+	}
+	return packageRun(b.runner, b.env(), "gem", append(args, names...)...)
+}
@@ -0,0 +1,105 @@
+package sys
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+var npmLock sync.Mutex
+
+type npmBackend struct {
+	runner commandRunner
+	scope  packageScope
+}
+
+func (b *npmBackend) Lock() sync.Locker {
+	return &npmLock
+}
+
+func (b *npmBackend) args(args ...string) []string {
+	args = append(args, "--global")
+	if b.scope.Prefix != "" {
+		args = append(args, "--prefix", b.scope.Prefix)
+	}
+	return args
+}
+
+func (b *npmBackend) UpdateCache() error {
+	return nil
+}
+
+func (b *npmBackend) Query(name string) (*packageInfo, error) {
+	stdout := new(bytes.Buffer)
+	err := b.runner.Run(stdout, nil, "npm", b.args("ls", "--depth=0", "--json", name)...)
+	// npm ls fails when the package is missing, the JSON output tells it
+	if err != nil && !isExitError(err) {
+		return nil, fmt.Errorf("cannot query npm ls: %v", err)
+	}
+
+	var result struct {
+		Dependencies map[string]struct {
+			Version string `json:"version"`
+		} `json:"dependencies"`
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &result); err != nil {
+		return nil, fmt.Errorf("cannot parse npm ls results: %v\n%s", err, stdout.String())
+	}
+
+	dep, ok := result.Dependencies[name]
+	if !ok || dep.Version == "" {
+		return nil, nil
+	}
+
+	return &packageInfo{Name: name, Version: dep.Version}, nil
+}
+
+func (b *npmBackend) QueryFile(filename string) (*packageInfo, error) {
+	return nil, packageNoFile("npm", filename)
+}
+
+func (b *npmBackend) Candidate(name string) (string, error) {
+	stdout := new(bytes.Buffer)
+	err := b.runner.Run(stdout, nil, "npm", "view", name, "version")
+	if err != nil {
+		return "", fmt.Errorf("cannot query npm view: %v", err)
+	}
+
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+func (b *npmBackend) Install(pkgs ...*packageSpec) error {
+	args := []string{"install"}
+	for _, pkg := range pkgs {
+		pkgspec := pkg.Name
+		if pkg.Version != "" {
+			pkgspec = fmt.Sprintf("%s@%s", pkgspec, pkg.Version)
+		}
+		args = append(args, pkgspec)
+	}
+
+	return packageRun(b.runner, nil, "npm", b.args(args...)...)
+}
+
+func (b *npmBackend) InstallFile(filename string) error {
+	return packageNoFile("npm", filename)
+}
+
+func (b *npmBackend) Upgrade(names ...string) error {
+	args := []string{"install"}
+	for _, name := range names {
+		args = append(args, name+"@latest")
+	}
+
+	return packageRun(b.runner, nil, "npm", b.args(args...)...)
+}
+
+func (b *npmBackend) Hold(hold bool, names ...string) error {
+	return packageNoHold("npm", hold, names)
+}
+
+func (b *npmBackend) Remove(names ...string) error {
+	return packageRun(b.runner, nil, "npm", b.args(append([]string{"uninstall"}, names...)...)...)
+}
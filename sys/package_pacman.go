@@ -0,0 +1,100 @@
+package sys
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+var pacmanLock sync.Mutex
+
+type pacmanBackend struct {
+	runner commandRunner
+}
+
+func (b *pacmanBackend) Lock() sync.Locker {
+	return &pacmanLock
+}
+
+func (b *pacmanBackend) UpdateCache() error {
+	return packageRun(b.runner, nil, "pacman", "-Sy", "--noconfirm")
+}
+
+func (b *pacmanBackend) Query(name string) (*packageInfo, error) {
+	stdout := new(bytes.Buffer)
+	err := b.runner.Run(stdout, nil, "pacman", "-Q", name)
+	if isExitError(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("cannot query pacman -Q: %v", err)
+	}
+
+	fields := strings.Fields(stdout.String())
+	if len(fields) < 2 {
+		return nil, fmt.Errorf("cannot parse pacman -Q results:\n%s", stdout.String())
+	}
+
+	return &packageInfo{Name: fields[0], Version: fields[1]}, nil
+}
+
+func (b *pacmanBackend) QueryFile(filename string) (*packageInfo, error) {
+	stdout := new(bytes.Buffer)
+	err := b.runner.Run(stdout, nil, "pacman", "-Qp", filename)
+	if err != nil {
+		return nil, fmt.Errorf("cannot query pacman -Qp: %v", err)
+	}
+
+	fields := strings.Fields(stdout.String())
+	if len(fields) < 2 {
+		return nil, fmt.Errorf("cannot parse pacman -Qp results:\n%s", stdout.String())
+	}
+
+	return &packageInfo{Name: fields[0], Version: fields[1]}, nil
+}
+
+func (b *pacmanBackend) InstallFile(filename string) error {
+	return packageRun(b.runner, nil, "pacman", "-U", "--noconfirm", filename)
+}
+
+func (b *pacmanBackend) Install(pkgs ...*packageSpec) error {
+	args := []string{"-S", "--noconfirm", "--needed"}
+	for _, pkg := range pkgs {
+		if pkg.Version != "" {
+			return fmt.Errorf("pacman cannot install a specific version of %s", pkg.Name)
+		}
+
+		pkgspec := pkg.Name
+		if pkg.TargetRelease != "" {
+			pkgspec = fmt.Sprintf("%s/%s", pkg.TargetRelease, pkgspec)
+		}
+		args = append(args, pkgspec)
+	}
+
+	return packageRun(b.runner, nil, "pacman", args...)
+}
+
+func (b *pacmanBackend) Candidate(name string) (string, error) {
+	stdout := new(bytes.Buffer)
+	err := b.runner.Run(stdout, nil, "pacman", "-Si", name)
+	if err != nil {
+		return "", fmt.Errorf("cannot query pacman -Si: %v", err)
+	}
+
+	return packageField(stdout.String(), "Version"), nil
+}
+
+func (b *pacmanBackend) Upgrade(names ...string) error {
+	return packageRun(b.runner, nil, "pacman", append([]string{"-S", "--noconfirm", "--needed"}, names...)...)
+}
+
+func (b *pacmanBackend) Hold(hold bool, names ...string) error {
+	if hold {
+		return fmt.Errorf("pacman cannot hold %s, use IgnorePkg in pacman.conf", strings.Join(names, ", "))
+	}
+	return nil
+}
+
+func (b *pacmanBackend) Remove(names ...string) error {
+	return packageRun(b.runner, nil, "pacman", append([]string{"-R", "--noconfirm"}, names...)...)
+}
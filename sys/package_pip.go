@@ -0,0 +1,119 @@
+package sys
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"strings"
+	"sync"
+)
+
+var pipLock sync.Mutex
+
+type pipBackend struct {
+	runner commandRunner
+	scope  packageScope
+}
+
+func (b *pipBackend) Lock() sync.Locker {
+	return &pipLock
+}
+
+func (b *pipBackend) pip(stdout *bytes.Buffer, args ...string) error {
+	python := "python3"
+	if b.scope.Virtualenv != "" {
+		python = path.Join(b.scope.Virtualenv, "bin", "python")
+	}
+	return b.runner.Run(stdout, nil, python, append([]string{"-m", "pip", "--disable-pip-version-check"}, args...)...)
+}
+
+func (b *pipBackend) run(args ...string) error {
+	err := b.pip(nil, args...)
+	if err != nil {
+		return fmt.Errorf("Error running pip %s: %v", strings.Join(args, " "), err)
+	}
+	return nil
+}
+
+func (b *pipBackend) UpdateCache() error {
+	return nil
+}
+
+func (b *pipBackend) Query(name string) (*packageInfo, error) {
+	stdout := new(bytes.Buffer)
+	err := b.pip(stdout, "show", name)
+	if isExitError(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("cannot query pip show: %v", err)
+	}
+
+	info := &packageInfo{
+		Name:    packageField(stdout.String(), "Name"),
+		Version: packageField(stdout.String(), "Version"),
+	}
+	if info.Name == "" || info.Version == "" {
+		return nil, fmt.Errorf("cannot parse pip show results (name: %s, version: %s):\n%s", info.Name, info.Version, stdout.String())
+	}
+
+	return info, nil
+}
+
+func (b *pipBackend) QueryFile(filename string) (*packageInfo, error) {
+	return nil, packageNoFile("pip", filename)
+}
+
+func (b *pipBackend) Candidate(name string) (string, error) {
+	stdout := new(bytes.Buffer)
+	err := b.pip(stdout, "index", "versions", name)
+	if err != nil {
+		return "", fmt.Errorf("cannot query pip index versions: %v", err)
+	}
+
+	// The first line is formatted as: name (version)
+	line := strings.SplitN(stdout.String(), "\n", 2)[0]
+	start := strings.Index(line, "(")
+	end := strings.Index(line, ")")
+	if start < 0 || end < start {
+		return "", fmt.Errorf("cannot parse pip index versions results:\n%s", stdout.String())
+	}
+
+	return line[start+1 : end], nil
+}
+
+func (b *pipBackend) installArgs() []string {
+	args := []string{"install"}
+	if b.scope.User {
+		args = append(args, "--user")
+	}
+	return args
+}
+
+func (b *pipBackend) Install(pkgs ...*packageSpec) error {
+	args := b.installArgs()
+	for _, pkg := range pkgs {
+		pkgspec := pkg.Name
+		if pkg.Version != "" {
+			pkgspec = fmt.Sprintf("%s==%s", pkgspec, pkg.Version)
+		}
+		args = append(args, pkgspec)
+	}
+
+	return b.run(args...)
+}
+
+func (b *pipBackend) InstallFile(filename string) error {
+	return packageNoFile("pip", filename)
+}
+
+func (b *pipBackend) Upgrade(names ...string) error {
+	return b.run(append(append(b.installArgs(), "--upgrade"), names...)...)
+}
+
+func (b *pipBackend) Hold(hold bool, names ...string) error {
+	return packageNoHold("pip", hold, names)
+}
+
+func (b *pipBackend) Remove(names ...string) error {
+	return b.run(append([]string{"uninstall", "-y"}, names...)...)
+}
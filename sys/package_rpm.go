@@ -0,0 +1,200 @@
+package sys
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+var dnfLock sync.Mutex
+var zypperLock sync.Mutex
+
+// rpmQuery is shared by all the backends working on the rpm database
+func rpmQuery(r commandRunner, name string) (*packageInfo, error) {
+	stdout := new(bytes.Buffer)
+	err := r.Run(stdout, nil, "rpm", "-q", "--queryformat", "%{NAME}\t%{VERSION}-%{RELEASE}\n", name)
+	if isExitError(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("cannot query rpm -q: %v", err)
+	}
+
+	// Multiple versions can be installed at once (kernels), keep the last one
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	parts := strings.SplitN(lines[len(lines)-1], "\t", 2)
+	if len(parts) < 2 || parts[0] == "" || parts[1] == "" {
+		return nil, fmt.Errorf("cannot parse rpm -q results:\n%s", stdout.String())
+	}
+
+	return &packageInfo{Name: parts[0], Version: parts[1]}, nil
+}
+
+func rpmQueryFile(r commandRunner, filename string) (*packageInfo, error) {
+	stdout := new(bytes.Buffer)
+	err := r.Run(stdout, nil, "rpm", "-q", "-p", "--queryformat", "%{NAME}\t%{VERSION}-%{RELEASE}\n", filename)
+	if err != nil {
+		return nil, fmt.Errorf("cannot query rpm -qp: %v", err)
+	}
+
+	parts := strings.SplitN(strings.TrimSpace(stdout.String()), "\t", 2)
+	if len(parts) < 2 || parts[0] == "" || parts[1] == "" {
+		return nil, fmt.Errorf("cannot parse rpm -qp results:\n%s", stdout.String())
+	}
+
+	return &packageInfo{Name: parts[0], Version: parts[1]}, nil
+}
+
+type dnfBackend struct {
+	runner commandRunner
+}
+
+func (b *dnfBackend) Lock() sync.Locker {
+	return &dnfLock
+}
+
+func (b *dnfBackend) UpdateCache() error {
+	return packageRun(b.runner, nil, "dnf", "makecache", "-y")
+}
+
+func (b *dnfBackend) Query(name string) (*packageInfo, error) {
+	return rpmQuery(b.runner, name)
+}
+
+// rpmTargetReleases returns the distinct repositories requested by pkgs
+func rpmTargetReleases(pkgs []*packageSpec) []string {
+	var repos []string
+	seen := map[string]bool{}
+	for _, pkg := range pkgs {
+		if pkg.TargetRelease != "" && !seen[pkg.TargetRelease] {
+			seen[pkg.TargetRelease] = true
+			repos = append(repos, pkg.TargetRelease)
+		}
+	}
+	return repos
+}
+
+func (b *dnfBackend) QueryFile(filename string) (*packageInfo, error) {
+	return rpmQueryFile(b.runner, filename)
+}
+
+func (b *dnfBackend) InstallFile(filename string) error {
+	return packageRun(b.runner, nil, "dnf", "install", "-y", filename)
+}
+
+func (b *dnfBackend) Install(pkgs ...*packageSpec) error {
+	args := []string{"install", "-y"}
+	for _, repo := range rpmTargetReleases(pkgs) {
+		args = append(args, "--enablerepo", repo)
+	}
+
+	for _, pkg := range pkgs {
+		pkgspec := pkg.Name
+		if pkg.Version != "" {
+			pkgspec = fmt.Sprintf("%s-%s", pkgspec, pkg.Version)
+		}
+		args = append(args, pkgspec)
+	}
+
+	return packageRun(b.runner, nil, "dnf", args...)
+}
+
+func (b *dnfBackend) Candidate(name string) (string, error) {
+	stdout := new(bytes.Buffer)
+	err := b.runner.Run(stdout, nil, "dnf", "repoquery", "-q", "--latest-limit=1", "--qf", "%{version}-%{release}", name)
+	if err != nil {
+		return "", fmt.Errorf("cannot query dnf repoquery: %v", err)
+	}
+
+	return strings.SplitN(strings.TrimSpace(stdout.String()), "\n", 2)[0], nil
+}
+
+func (b *dnfBackend) Upgrade(names ...string) error {
+	return packageRun(b.runner, nil, "dnf", append([]string{"upgrade", "-y"}, names...)...)
+}
+
+// Hold requires the dnf versionlock plugin
+func (b *dnfBackend) Hold(hold bool, names ...string) error {
+	if hold {
+		return packageRun(b.runner, nil, "dnf", append([]string{"versionlock", "add"}, names...)...)
+	} else {
+		return packageRun(b.runner, nil, "dnf", append([]string{"versionlock", "delete"}, names...)...)
+	}
+}
+
+func (b *dnfBackend) Remove(names ...string) error {
+	return packageRun(b.runner, nil, "dnf", append([]string{"remove", "-y"}, names...)...)
+}
+
+type zypperBackend struct {
+	runner commandRunner
+}
+
+func (b *zypperBackend) Lock() sync.Locker {
+	return &zypperLock
+}
+
+func (b *zypperBackend) UpdateCache() error {
+	return packageRun(b.runner, nil, "zypper", "--non-interactive", "refresh")
+}
+
+func (b *zypperBackend) Query(name string) (*packageInfo, error) {
+	return rpmQuery(b.runner, name)
+}
+
+func (b *zypperBackend) QueryFile(filename string) (*packageInfo, error) {
+	return rpmQueryFile(b.runner, filename)
+}
+
+func (b *zypperBackend) InstallFile(filename string) error {
+	return packageRun(b.runner, nil, "zypper", "--non-interactive", "install", "--oldpackage", filename)
+}
+
+func (b *zypperBackend) Install(pkgs ...*packageSpec) error {
+	args := []string{"--non-interactive", "install"}
+	for _, pkg := range pkgs {
+		if pkg.Version != "" {
+			args = append(args, "--oldpackage")
+			break
+		}
+	}
+	for _, repo := range rpmTargetReleases(pkgs) {
+		args = append(args, "--from", repo)
+	}
+
+	for _, pkg := range pkgs {
+		pkgspec := pkg.Name
+		if pkg.Version != "" {
+			pkgspec = fmt.Sprintf("%s=%s", pkgspec, pkg.Version)
+		}
+		args = append(args, pkgspec)
+	}
+
+	return packageRun(b.runner, nil, "zypper", args...)
+}
+
+func (b *zypperBackend) Candidate(name string) (string, error) {
+	stdout := new(bytes.Buffer)
+	err := b.runner.Run(stdout, nil, "zypper", "--non-interactive", "info", name)
+	if err != nil {
+		return "", fmt.Errorf("cannot query zypper info: %v", err)
+	}
+
+	return packageField(stdout.String(), "Version"), nil
+}
+
+func (b *zypperBackend) Upgrade(names ...string) error {
+	return packageRun(b.runner, nil, "zypper", append([]string{"--non-interactive", "update"}, names...)...)
+}
+
+func (b *zypperBackend) Hold(hold bool, names ...string) error {
+	if hold {
+		return packageRun(b.runner, nil, "zypper", append([]string{"--non-interactive", "addlock"}, names...)...)
+	} else {
+		return packageRun(b.runner, nil, "zypper", append([]string{"--non-interactive", "removelock"}, names...)...)
+	}
+}
+
+func (b *zypperBackend) Remove(names ...string) error {
+	return packageRun(b.runner, nil, "zypper", append([]string{"--non-interactive", "remove"}, names...)...)
+}
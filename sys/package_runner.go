@@ -0,0 +1,82 @@
+package sys
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"path"
+	"strings"
+)
+
+// commandRunner runs the package manager commands. Exit failures are returned
+// as *ExitError with the standard error collected.
+type commandRunner interface {
+	Run(stdout io.Writer, env []string, name string, args ...string) error
+}
+
+// execRunner runs commands on the provider host
+type execRunner struct {
+	// Directories searched for commands before PATH
+	Path []string
+	// Command and arguments prepended to every command (e.g. sudo)
+	Prefix []string
+	// Host running the commands, the local host if nil
+	Host sysHost
+	// Directory the commands are chrooted into, the prefix runs outside
+	Root string
+}
+
+func (r *execRunner) host() sysHost {
+	if r.Host == nil {
+		return localHost{}
+	}
+	return r.Host
+}
+
+func (r *execRunner) lookPath(name string) string {
+	for _, dir := range r.Path {
+		filename := path.Join(dir, name)
+		if st, err := r.host().Stat(filename); err == nil && !st.IsDir() && st.Mode()&0111 != 0 {
+			return filename
+		}
+	}
+	return name
+}
+
+func (r *execRunner) Run(stdout io.Writer, env []string, name string, args ...string) error {
+	stderr := new(bytes.Buffer)
+
+	name = r.lookPath(name)
+	if r.Root != "" {
+		args = append([]string{r.Root, name}, args...)
+		name = "chroot"
+	}
+	if len(r.Prefix) > 0 {
+		args = append(append(append([]string{}, r.Prefix[1:]...), name), args...)
+		name = r.Prefix[0]
+	}
+
+	cmd := r.host().Command(context.Background(), env, name, args...)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	err := cmd.Run()
+	if er, ok := err.(*exec.ExitError); ok && er != nil {
+		er.Stderr = stderr.Bytes()
+		return &ExitError{*er}
+	} else if err != nil {
+		return fmt.Errorf("%v\n%s", err, stderr.String())
+	}
+
+	return nil
+}
+
+func packageRun(r commandRunner, env []string, name string, args ...string) error {
+	err := r.Run(nil, env, name, args...)
+	if err != nil {
+		return fmt.Errorf("Error running %s %s: %v", name, strings.Join(args, " "), err)
+	}
+	return nil
+}
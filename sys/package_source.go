@@ -0,0 +1,85 @@
+package sys
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/hashicorp/go-getter/v2"
+)
+
+// packageFetch downloads a package file using go-getter, the same way sys_file
+// does. The checksum (e.g. "sha256:...") is verified by go-getter. The file is
+// uploaded to remote hosts. The returned cleanup function removes the
+// downloaded file.
+func packageFetch(ctx context.Context, host sysHost, source, checksum string) (string, func(), error) {
+	dir, err := ioutil.TempDir("", "terraform-provider-sys-package")
+	if err != nil {
+		return "", nil, fmt.Errorf("cannot create temporary directory, %v", err)
+	}
+	cleanup := func() {
+		os.RemoveAll(dir)
+	}
+
+	// Package managers need the file extension to recognize the package
+	basename := "package"
+	if u, err := url.Parse(source); err == nil && path.Base(u.Path) != "." && path.Base(u.Path) != "/" {
+		basename = path.Base(u.Path)
+	}
+
+	src := source
+	if checksum != "" {
+		if strings.Contains(src, "?") {
+			src = src + "&checksum=" + url.QueryEscape(checksum)
+		} else {
+			src = src + "?checksum=" + url.QueryEscape(checksum)
+		}
+	}
+
+	get := &getter.Client{
+		Getters: append([]getter.Getter{new(getter.FileGetter)}, getter.Getters...),
+		// Packages must not be extracted
+		Decompressors: map[string]getter.Decompressor{},
+	}
+
+	filename := path.Join(dir, basename)
+	_, err = get.Get(ctx, &getter.Request{
+		Src:     src,
+		Dst:     filename,
+		GetMode: getter.ModeFile,
+		Copy:    true,
+	})
+	if err != nil {
+		cleanup()
+		return "", nil, fmt.Errorf("cannot fetch source %v, %v", source, err)
+	}
+
+	if host.Remote() {
+		suffix := make([]byte, 8)
+		if _, err := rand.Read(suffix); err != nil {
+			cleanup()
+			return "", nil, err
+		}
+
+		remoteDir := "/tmp/terraform-provider-sys-package-" + hex.EncodeToString(suffix)
+		remoteFilename := path.Join(remoteDir, basename)
+		err = host.Upload(filename, remoteFilename)
+		cleanup()
+		cleanup = func() {
+			host.RemoveAll(remoteDir)
+		}
+		if err != nil {
+			cleanup()
+			return "", nil, fmt.Errorf("cannot upload %v, %v", source, err)
+		}
+		filename = remoteFilename
+	}
+
+	return filename, cleanup, nil
+}
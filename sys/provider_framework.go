@@ -3,29 +3,83 @@ package sys
 import (
 	"context"
 	"sync"
+	"time"
 
 	hclog "github.com/hashicorp/go-hclog"
 
 	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/function"
 	"github.com/hashicorp/terraform-plugin-framework/provider"
 	"github.com/hashicorp/terraform-plugin-framework/provider/schema"
 	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
 	"github.com/hashicorp/terraform-plugin-framework/types"
-
-	"github.com/mildred/terraform-provider-sys/sys/data_uname"
 )
 
-type sysProvider struct {
-	debUpdated bool
-	Logger     hclog.Logger
-	SdLocks    map[string]sync.Locker
-	Lock       sync.Mutex
-}
+type sysProvider struct{}
+
+var _ provider.ProviderWithFunctions = (*sysProvider)(nil)
 
 type sysProviderModel struct {
-	LogLevel types.String `tfsdk:"log_level"`
+	LogLevel             types.String        `tfsdk:"log_level"`
+	PackageBatchWindow   types.String        `tfsdk:"package_batch_window"`
+	PackageCommandPath   types.List          `tfsdk:"package_command_path"`
+	PackageCommandPrefix types.List          `tfsdk:"package_command_prefix"`
+	RootDir              types.String        `tfsdk:"root_dir"`
+	Connection           *sysConnectionModel `tfsdk:"connection"`
+}
+
+type sysConnectionModel struct {
+	Host types.String `tfsdk:"host"`
+	User types.String `tfsdk:"user"`
+	Port types.Int64  `tfsdk:"port"`
+	Key  types.String `tfsdk:"key"`
+	Sudo types.Bool   `tfsdk:"sudo"`
+}
+
+type providerConfiguration struct {
+	PkgUpdated     map[string]bool
+	PkgBatches     map[packageBatchKey]*packageBatch
+	PkgBatchWindow time.Duration
+	PkgRunner      commandRunner
+	Host           sysHost
+	Logger         hclog.Logger
+	SdLocks        map[string]sync.Locker
+	SdConnect      func(ctx context.Context, scope sdScope) (sdManager, error)
+	Lock           sync.Mutex
+}
+
+// newProviderConfiguration builds the configuration passed to the resources
+// and data sources, host is nil for the local host
+func newProviderConfiguration(logLevel, batchWindow string, commandPath, commandPrefix []string, host sysHost, rootDir string) *providerConfiguration {
+	if logLevel == "" {
+		logLevel = "info"
+	}
+	if host == nil {
+		host = localHost{}
+	}
+	if rootDir != "" {
+		host = &rootHost{sysHost: host, Root: rootDir}
+	}
+	configuration := &providerConfiguration{
+		Logger: hclog.New(&hclog.LoggerOptions{
+			Level: hclog.LevelFromString(logLevel),
+		}),
+		PkgRunner: &execRunner{
+			Path:   commandPath,
+			Prefix: commandPrefix,
+			Host:   host,
+			Root:   rootDir,
+		},
+		Host: host,
+	}
+	if batchWindow != "" {
+		configuration.PkgBatchWindow, _ = time.ParseDuration(batchWindow)
+	}
+	return configuration
 }
 
+// New returns the provider
 func New() provider.Provider {
 	return &sysProvider{}
 }
@@ -40,6 +94,51 @@ func (p *sysProvider) Schema(ctx context.Context, req provider.SchemaRequest, re
 			"log_level": schema.StringAttribute{
 				Optional: true,
 			},
+			"package_batch_window": schema.StringAttribute{
+				Description: "Duration during which concurrent sys_package creations of the same type are collected and installed in a single transaction (disabled by default)",
+				Optional:    true,
+				Validators:  []validator.String{durationValidator},
+			},
+			"package_command_path": schema.ListAttribute{
+				Description: "Directories where package manager commands are looked up before PATH",
+				ElementType: types.StringType,
+				Optional:    true,
+			},
+			"package_command_prefix": schema.ListAttribute{
+				Description: "Command prepended to package manager commands (e.g. [\"sudo\", \"-n\"])",
+				ElementType: types.StringType,
+				Optional:    true,
+			},
+			"root_dir": schema.StringAttribute{
+				Description: "Root directory of an image to build: paths of sys_file, sys_dir, sys_symlink and of the systemd unit files are prefixed with it, package managers run chrooted into it and units are enabled or masked offline",
+				Optional:    true,
+			},
+			"connection": schema.SingleNestedAttribute{
+				Description: "Manage a remote host over SSH instead of the local host, used by sys_file, sys_dir, sys_symlink, sys_package, sys_shell_script, the systemd resources, the repository resources and the data sources",
+				Optional:    true,
+				Attributes: map[string]schema.Attribute{
+					"host": schema.StringAttribute{
+						Description: "Host name or address",
+						Required:    true,
+					},
+					"user": schema.StringAttribute{
+						Description: "User to log in as",
+						Optional:    true,
+					},
+					"port": schema.Int64Attribute{
+						Description: "SSH port",
+						Optional:    true,
+					},
+					"key": schema.StringAttribute{
+						Description: "Path to the private key file",
+						Optional:    true,
+					},
+					"sudo": schema.BoolAttribute{
+						Description: "Run commands and file operations with sudo -n",
+						Optional:    true,
+					},
+				},
+			},
 		},
 	}
 }
@@ -53,23 +152,74 @@ func (p *sysProvider) Configure(ctx context.Context, req provider.ConfigureReque
 		return
 	}
 
-	log_level := data.LogLevel.ValueString()
-	if log_level == "" {
-		log_level = "info"
+	var commandPath, commandPrefix []string
+	resp.Diagnostics.Append(data.PackageCommandPath.ElementsAs(ctx, &commandPath, false)...)
+	resp.Diagnostics.Append(data.PackageCommandPrefix.ElementsAs(ctx, &commandPrefix, false)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	var host sysHost
+	if c := data.Connection; c != nil {
+		host = &sshHost{
+			Host: c.Host.ValueString(),
+			User: c.User.ValueString(),
+			Port: int(c.Port.ValueInt64()),
+			Key:  c.Key.ValueString(),
+			Sudo: c.Sudo.ValueBool(),
+		}
 	}
 
-	p.Logger = hclog.New(&hclog.LoggerOptions{
-		Level: hclog.LevelFromString(log_level),
-	})
+	configuration := newProviderConfiguration(
+		data.LogLevel.ValueString(),
+		data.PackageBatchWindow.ValueString(),
+		commandPath,
+		commandPrefix,
+		host,
+		data.RootDir.ValueString(),
+	)
+	resp.DataSourceData = configuration
+	resp.ResourceData = configuration
 }
 
 func (p *sysProvider) Resources(ctx context.Context) []func() resource.Resource {
-	return []func() resource.Resource {
+	return []func() resource.Resource{
+		newNullResource,
+		newDirResource,
+		newSymlinkResource,
+		newFileResource,
+		newAptRepositoryResource,
+		newYumRepositoryResource,
+		newSystemdDropinResource,
+		newSystemdTimerResource,
+		newSystemdRunResource,
+		newSystemdUnitResource,
+		newPackageResource,
+		newShellScriptResource,
 	}
 }
 
 func (p *sysProvider) DataSources(ctx context.Context) []func() datasource.DataSource {
-	return []func() datasource.DataSource {
-		data_uname.NewDataSource,
+	return []func() datasource.DataSource{
+		newUnameDataSource,
+		newUnameDeprecatedDataSource,
+		newOsReleaseDataSource,
+		newFileDataSource,
+		newShellScriptDataSource,
+		newErrorDataSource,
+		newJournalDataSource,
+		newSystemdUnitDataSource,
+		newSystemdUnitsDataSource,
+	}
+}
+
+func (p *sysProvider) Functions(ctx context.Context) []func() function.Function {
+	return []func() function.Function{
+		newParseOsReleaseFunction,
+		newFileModeApplyUmaskFunction,
+		newParseUnameFunction,
+		newSystemdEscapeFunction,
+		newSemverCompareFunction,
 	}
 }
@@ -0,0 +1,923 @@
+package sys
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/provider"
+	"github.com/hashicorp/terraform-plugin-framework/providerserver"
+	"github.com/hashicorp/terraform-plugin-go/tfprotov6"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
+)
+
+// testProviderServer serves the provider like main does
+func testProviderServer(t *testing.T) tfprotov6.ProviderServer {
+	t.Helper()
+	return providerserver.NewProtocol6(New())()
+}
+
+func testDiagnostics(t *testing.T, diags []*tfprotov6.Diagnostic) string {
+	t.Helper()
+	var res []string
+	for _, d := range diags {
+		if d.Severity == tfprotov6.DiagnosticSeverityError {
+			res = append(res, d.Summary+": "+d.Detail)
+		}
+	}
+	return strings.Join(res, "\n")
+}
+
+func TestProviderSchema(t *testing.T) {
+	server := testProviderServer(t)
+
+	resp, err := server.GetProviderSchema(context.Background(), &tfprotov6.GetProviderSchemaRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if errs := testDiagnostics(t, resp.Diagnostics); errs != "" {
+		t.Fatalf("schema errors: %s", errs)
+	}
+
+	for _, name := range []string{"sys_null", "sys_dir", "sys_symlink", "sys_file", "sys_package", "sys_shell_script", "sys_systemd_unit"} {
+		if resp.ResourceSchemas[name] == nil {
+			t.Errorf("missing resource %s", name)
+		}
+	}
+	for _, name := range []string{"sys_uname", "sys_os_release", "sys_file", "sys_journal", "sys_systemd_units"} {
+		if resp.DataSourceSchemas[name] == nil {
+			t.Errorf("missing data source %s", name)
+		}
+	}
+	if uname := resp.DataSourceSchemas["uname"]; uname == nil || !uname.Block.Deprecated {
+		t.Errorf("expected the unprefixed uname data source to be served as deprecated")
+	}
+	for _, name := range []string{"parse_os_release", "file_mode_apply_umask", "parse_uname", "systemd_escape", "semver_compare"} {
+		if resp.Functions[name] == nil {
+			t.Errorf("missing function %s", name)
+		}
+	}
+}
+
+// testTfValue converts a Go value to a terraform value of the given type,
+// missing object attributes are null
+func testTfValue(typ tftypes.Type, v interface{}) tftypes.Value {
+	if m, ok := v.(map[string]interface{}); v == nil || ok && m == nil {
+		return tftypes.NewValue(typ, nil)
+	}
+	switch typ := typ.(type) {
+	case tftypes.Object:
+		attrs := map[string]tftypes.Value{}
+		for name, attrType := range typ.AttributeTypes {
+			attrs[name] = testTfValue(attrType, v.(map[string]interface{})[name])
+		}
+		return tftypes.NewValue(typ, attrs)
+	case tftypes.Map:
+		elems := map[string]tftypes.Value{}
+		for key, elem := range v.(map[string]interface{}) {
+			elems[key] = testTfValue(typ.ElementType, elem)
+		}
+		return tftypes.NewValue(typ, elems)
+	case tftypes.List:
+		var elems []tftypes.Value
+		for _, elem := range v.([]interface{}) {
+			elems = append(elems, testTfValue(typ.ElementType, elem))
+		}
+		return tftypes.NewValue(typ, elems)
+	case tftypes.Set:
+		var elems []tftypes.Value
+		for _, elem := range v.([]interface{}) {
+			elems = append(elems, testTfValue(typ.ElementType, elem))
+		}
+		return tftypes.NewValue(typ, elems)
+	}
+	if n, ok := v.(int); ok {
+		return tftypes.NewValue(typ, big.NewFloat(float64(n)))
+	}
+	return tftypes.NewValue(typ, v)
+}
+
+// testTfGo converts a known terraform value back to Go values
+func testTfGo(t *testing.T, v tftypes.Value) interface{} {
+	t.Helper()
+	if v.IsNull() {
+		return nil
+	} else if !v.IsKnown() {
+		t.Fatalf("unknown value %v", v)
+	}
+
+	switch {
+	case v.Type().Is(tftypes.Object{}), v.Type().Is(tftypes.Map{}):
+		var values map[string]tftypes.Value
+		v.As(&values)
+		res := map[string]interface{}{}
+		for key, value := range values {
+			res[key] = testTfGo(t, value)
+		}
+		return res
+	case v.Type().Is(tftypes.List{}), v.Type().Is(tftypes.Set{}):
+		var values []tftypes.Value
+		v.As(&values)
+		res := []interface{}{}
+		for _, value := range values {
+			res = append(res, testTfGo(t, value))
+		}
+		return res
+	case v.Type().Is(tftypes.String):
+		var s string
+		v.As(&s)
+		return s
+	case v.Type().Is(tftypes.Bool):
+		var b bool
+		v.As(&b)
+		return b
+	case v.Type().Is(tftypes.Number):
+		var n big.Float
+		v.As(&n)
+		i, _ := n.Int64()
+		return int(i)
+	}
+	t.Fatalf("unsupported value %v", v)
+	return nil
+}
+
+// testFrameworkEnv runs resources and data sources of the framework provider
+// through the protocol, like terraform does
+type testFrameworkEnv struct {
+	t         *testing.T
+	server    tfprotov6.ProviderServer
+	schemas   map[string]*tfprotov6.Schema
+	types     map[string]tftypes.Type
+	dataTypes map[string]tftypes.Type
+}
+
+// testProvider is the framework provider configured with meta, to use fakes
+type testProvider struct {
+	sysProvider
+	meta *providerConfiguration
+}
+
+func (p *testProvider) Configure(ctx context.Context, req provider.ConfigureRequest, resp *provider.ConfigureResponse) {
+	resp.DataSourceData = p.meta
+	resp.ResourceData = p.meta
+}
+
+func newTestFrameworkEnv(t *testing.T) *testFrameworkEnv {
+	return newTestProtocolEnv(t, providerserver.NewProtocol6(New())(), map[string]interface{}{})
+}
+
+// newTestFrameworkEnvConfig configures the provider with the given provider
+// configuration
+func newTestFrameworkEnvConfig(t *testing.T, config map[string]interface{}) *testFrameworkEnv {
+	return newTestProtocolEnv(t, providerserver.NewProtocol6(New())(), config)
+}
+
+// newTestFrameworkEnvWith configures the provider with meta instead of the
+// provider configuration
+func newTestFrameworkEnvWith(t *testing.T, meta *providerConfiguration) *testFrameworkEnv {
+	return newTestProtocolEnv(t, providerserver.NewProtocol6(&testProvider{meta: meta})(), map[string]interface{}{})
+}
+
+func newTestProtocolEnv(t *testing.T, server tfprotov6.ProviderServer, config map[string]interface{}) *testFrameworkEnv {
+	ctx := context.Background()
+
+	schemas, err := server.GetProviderSchema(ctx, &tfprotov6.GetProviderSchemaRequest{})
+	if err != nil {
+		t.Fatal(err)
+	} else if errs := testDiagnostics(t, schemas.Diagnostics); errs != "" {
+		t.Fatalf("schema errors: %s", errs)
+	}
+
+	env := &testFrameworkEnv{
+		t:         t,
+		server:    server,
+		schemas:   schemas.ResourceSchemas,
+		types:     map[string]tftypes.Type{},
+		dataTypes: map[string]tftypes.Type{},
+	}
+	for name, schema := range schemas.ResourceSchemas {
+		env.types[name] = schema.ValueType()
+	}
+	for name, schema := range schemas.DataSourceSchemas {
+		env.dataTypes[name] = schema.ValueType()
+	}
+
+	configure, err := server.ConfigureProvider(ctx, &tfprotov6.ConfigureProviderRequest{
+		Config: env.dynamicValue(schemas.Provider.ValueType(), config),
+	})
+	if err != nil {
+		t.Fatal(err)
+	} else if errs := testDiagnostics(t, configure.Diagnostics); errs != "" {
+		t.Fatalf("configure failed: %s", errs)
+	}
+	return env
+}
+
+func (env *testFrameworkEnv) dynamicValue(typ tftypes.Type, v interface{}) *tfprotov6.DynamicValue {
+	env.t.Helper()
+	dv, err := tfprotov6.NewDynamicValue(typ, testTfValue(typ, v))
+	if err != nil {
+		env.t.Fatal(err)
+	}
+	return &dv
+}
+
+func (env *testFrameworkEnv) value(typ tftypes.Type, dv *tfprotov6.DynamicValue) map[string]interface{} {
+	env.t.Helper()
+	v, err := dv.Unmarshal(typ)
+	if err != nil {
+		env.t.Fatal(err)
+	}
+	res, _ := testTfGo(env.t, v).(map[string]interface{})
+	return res
+}
+
+// apply validates, plans and applies the configuration from the state, a
+// nil config destroys the resource and a nil state creates it. It returns
+// the new state and the errors.
+func (env *testFrameworkEnv) apply(name string, state, config map[string]interface{}) (map[string]interface{}, string) {
+	env.t.Helper()
+	ctx := context.Background()
+	typ := env.types[name]
+
+	plan, errs := env.planChange(name, state, config)
+	if errs != "" {
+		return state, errs
+	}
+
+	if state != nil && config != nil {
+		// Terraform does not apply a plan without changes
+		planned, err := plan.PlannedState.Unmarshal(typ)
+		if err != nil {
+			env.t.Fatal(err)
+		} else if planned.Equal(testTfValue(typ, state)) {
+			return state, ""
+		}
+	}
+
+	if state != nil && config != nil && len(plan.RequiresReplace) > 0 {
+		// Replace the resource, destroying it first
+		state, errs := env.apply(name, state, nil)
+		if errs != "" {
+			return state, errs
+		}
+		return env.apply(name, nil, config)
+	}
+
+	apply, err := env.server.ApplyResourceChange(ctx, &tfprotov6.ApplyResourceChangeRequest{
+		TypeName:     name,
+		PriorState:   env.dynamicValue(typ, state),
+		PlannedState: plan.PlannedState,
+		Config:       env.dynamicValue(typ, config),
+	})
+	if err != nil {
+		env.t.Fatal(err)
+	} else if errs := testDiagnostics(env.t, apply.Diagnostics); errs != "" {
+		return state, errs
+	}
+
+	return env.value(typ, apply.NewState), ""
+}
+
+// planChange validates and plans the configuration from the state
+func (env *testFrameworkEnv) planChange(name string, state, config map[string]interface{}) (*tfprotov6.PlanResourceChangeResponse, string) {
+	env.t.Helper()
+	ctx := context.Background()
+	typ := env.types[name]
+
+	if config != nil {
+		validate, err := env.server.ValidateResourceConfig(ctx, &tfprotov6.ValidateResourceConfigRequest{
+			TypeName: name,
+			Config:   env.dynamicValue(typ, config),
+		})
+		if err != nil {
+			env.t.Fatal(err)
+		} else if errs := testDiagnostics(env.t, validate.Diagnostics); errs != "" {
+			return nil, errs
+		}
+	}
+
+	// Terraform proposes the configuration, with the prior state of the
+	// computed attributes that are not configured
+	var proposed map[string]interface{}
+	if config != nil {
+		proposed = map[string]interface{}{}
+		for key, value := range config {
+			proposed[key] = value
+		}
+		for _, attr := range env.schemas[name].Block.Attributes {
+			if attr.Computed && proposed[attr.Name] == nil {
+				proposed[attr.Name] = state[attr.Name]
+			}
+		}
+	}
+
+	plan, err := env.server.PlanResourceChange(ctx, &tfprotov6.PlanResourceChangeRequest{
+		TypeName:         name,
+		PriorState:       env.dynamicValue(typ, state),
+		ProposedNewState: env.dynamicValue(typ, proposed),
+		Config:           env.dynamicValue(typ, config),
+	})
+	if err != nil {
+		env.t.Fatal(err)
+	} else if errs := testDiagnostics(env.t, plan.Diagnostics); errs != "" {
+		return nil, errs
+	}
+
+	return plan, ""
+}
+
+// plan plans the configuration from the state without applying it. It returns
+// the known planned attributes, whether the resource is replaced and the
+// errors.
+func (env *testFrameworkEnv) plan(name string, state, config map[string]interface{}) (map[string]interface{}, bool, string) {
+	env.t.Helper()
+	plan, errs := env.planChange(name, state, config)
+	if errs != "" {
+		return nil, false, errs
+	}
+
+	v, err := plan.PlannedState.Unmarshal(env.types[name])
+	if err != nil {
+		env.t.Fatal(err)
+	}
+	var values map[string]tftypes.Value
+	v.As(&values)
+	planned := map[string]interface{}{}
+	for key, value := range values {
+		if value.IsFullyKnown() {
+			planned[key] = testTfGo(env.t, value)
+		}
+	}
+	return planned, len(plan.RequiresReplace) > 0, ""
+}
+
+func (env *testFrameworkEnv) mustApply(name string, state, config map[string]interface{}) map[string]interface{} {
+	env.t.Helper()
+	state, errs := env.apply(name, state, config)
+	if errs != "" {
+		env.t.Fatalf("apply failed: %s", errs)
+	}
+	return state
+}
+
+// refresh reads the resource, returning nil if it is gone
+func (env *testFrameworkEnv) refresh(name string, state map[string]interface{}) map[string]interface{} {
+	env.t.Helper()
+	typ := env.types[name]
+
+	resp, err := env.server.ReadResource(context.Background(), &tfprotov6.ReadResourceRequest{
+		TypeName:     name,
+		CurrentState: env.dynamicValue(typ, state),
+	})
+	if err != nil {
+		env.t.Fatal(err)
+	} else if errs := testDiagnostics(env.t, resp.Diagnostics); errs != "" {
+		env.t.Fatalf("refresh failed: %s", errs)
+	}
+	return env.value(typ, resp.NewState)
+}
+
+// importState imports the resource with the given id
+func (env *testFrameworkEnv) importState(name, id string) map[string]interface{} {
+	env.t.Helper()
+	resp, err := env.server.ImportResourceState(context.Background(), &tfprotov6.ImportResourceStateRequest{
+		TypeName: name,
+		ID:       id,
+	})
+	if err != nil {
+		env.t.Fatal(err)
+	} else if errs := testDiagnostics(env.t, resp.Diagnostics); errs != "" {
+		env.t.Fatalf("import failed: %s", errs)
+	} else if len(resp.ImportedResources) != 1 {
+		env.t.Fatalf("expected one imported resource, got %d", len(resp.ImportedResources))
+	}
+	return env.value(env.types[name], resp.ImportedResources[0].State)
+}
+
+// read validates and reads a data source, returning its state and the errors
+func (env *testFrameworkEnv) read(name string, config map[string]interface{}) (map[string]interface{}, string) {
+	env.t.Helper()
+	ctx := context.Background()
+	typ := env.dataTypes[name]
+
+	validate, err := env.server.ValidateDataResourceConfig(ctx, &tfprotov6.ValidateDataResourceConfigRequest{
+		TypeName: name,
+		Config:   env.dynamicValue(typ, config),
+	})
+	if err != nil {
+		env.t.Fatal(err)
+	} else if errs := testDiagnostics(env.t, validate.Diagnostics); errs != "" {
+		return nil, errs
+	}
+
+	resp, err := env.server.ReadDataSource(ctx, &tfprotov6.ReadDataSourceRequest{
+		TypeName: name,
+		Config:   env.dynamicValue(typ, config),
+	})
+	if err != nil {
+		env.t.Fatal(err)
+	} else if errs := testDiagnostics(env.t, resp.Diagnostics); errs != "" {
+		return nil, errs
+	}
+	return env.value(typ, resp.State), ""
+}
+
+// upgrade upgrades a state written with the given schema version
+func (env *testFrameworkEnv) upgrade(name string, version int64, state map[string]interface{}) (map[string]interface{}, string) {
+	env.t.Helper()
+
+	raw, err := json.Marshal(state)
+	if err != nil {
+		env.t.Fatal(err)
+	}
+	resp, err := env.server.UpgradeResourceState(context.Background(), &tfprotov6.UpgradeResourceStateRequest{
+		TypeName: name,
+		Version:  version,
+		RawState: &tfprotov6.RawState{JSON: raw},
+	})
+	if err != nil {
+		env.t.Fatal(err)
+	} else if errs := testDiagnostics(env.t, resp.Diagnostics); errs != "" {
+		return nil, errs
+	}
+	return env.value(env.types[name], resp.UpgradedState), ""
+}
+
+func TestNullResource(t *testing.T) {
+	env := newTestFrameworkEnv(t)
+
+	state := env.mustApply("sys_null", nil, map[string]interface{}{
+		"inputs":   map[string]interface{}{"a": "1"},
+		"triggers": map[string]interface{}{"t": "1"},
+	})
+	id := state["id"]
+	if id == "" || state["outputs"].(map[string]interface{})["a"] != "1" {
+		t.Fatalf("unexpected state %v", state)
+	}
+
+	state = env.mustApply("sys_null", state, map[string]interface{}{
+		"inputs":   map[string]interface{}{"a": "2"},
+		"triggers": map[string]interface{}{"t": "1"},
+	})
+	if state["id"] != id || state["outputs"].(map[string]interface{})["a"] != "1" {
+		t.Fatalf("outputs must only be set on creation: %v", state)
+	}
+
+	state = env.mustApply("sys_null", state, map[string]interface{}{
+		"inputs":   map[string]interface{}{"a": "2"},
+		"triggers": map[string]interface{}{"t": "2"},
+	})
+	if state["outputs"].(map[string]interface{})["a"] != "2" {
+		t.Fatalf("triggers must replace the resource: %v", state)
+	}
+}
+
+func TestDirResource(t *testing.T) {
+	env := newTestFrameworkEnv(t)
+	dir := filepath.Join(t.TempDir(), "parent", "dir")
+
+	state := env.mustApply("sys_dir", nil, map[string]interface{}{
+		"path":       dir,
+		"permission": "0750",
+	})
+	if st, err := os.Stat(dir); err != nil || st.Mode().Perm() != 0750 {
+		t.Fatalf("directory not created: %v %v", st, err)
+	}
+	if state["id"] != dir || state["parent_permission"] != "0777" || state["force_remove"] != false {
+		t.Fatalf("unexpected state %v", state)
+	}
+
+	state = env.mustApply("sys_dir", state, map[string]interface{}{
+		"path":         dir,
+		"permission":   "0700",
+		"force_remove": true,
+	})
+	if st, _ := os.Stat(dir); st.Mode().Perm() != 0700 {
+		t.Fatalf("directory not chmoded: %v", st.Mode())
+	}
+	if state = env.refresh("sys_dir", state); state["permission"] != "0700" {
+		t.Fatalf("unexpected refreshed state %v", state)
+	}
+
+	os.WriteFile(filepath.Join(dir, "file"), nil, 0644)
+	if _, errs := env.apply("sys_dir", state, nil); errs != "" {
+		t.Fatalf("destroy failed: %s", errs)
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Fatalf("directory not removed: %v", err)
+	}
+	if state = env.refresh("sys_dir", state); state != nil {
+		t.Fatalf("removed directory still in state: %v", state)
+	}
+
+	_, errs := env.apply("sys_dir", nil, map[string]interface{}{
+		"path":           dir,
+		"allow_existing": true,
+		"force_remove":   false,
+	})
+	if !strings.Contains(errs, "conflicts with allow_existing") {
+		t.Fatalf("expected a conflict error, got %q", errs)
+	}
+
+	_, errs = env.apply("sys_dir", nil, map[string]interface{}{
+		"path":       dir,
+		"permission": "999",
+	})
+	if !strings.Contains(errs, "bad mode for file") {
+		t.Fatalf("expected a mode error, got %q", errs)
+	}
+}
+
+func TestSymlinkResource(t *testing.T) {
+	env := newTestFrameworkEnv(t)
+	link := filepath.Join(t.TempDir(), "parent", "link")
+
+	state := env.mustApply("sys_symlink", nil, map[string]interface{}{
+		"source": "/target",
+		"path":   link,
+	})
+	if target, err := os.Readlink(link); err != nil || target != "/target" {
+		t.Fatalf("symlink not created: %q %v", target, err)
+	}
+
+	state = env.mustApply("sys_symlink", state, map[string]interface{}{
+		"source": "/other",
+		"path":   link,
+	})
+	if target, _ := os.Readlink(link); target != "/other" {
+		t.Fatalf("symlink not replaced: %q", target)
+	}
+
+	os.Remove(link)
+	os.Symlink("/changed", link)
+	if state = env.refresh("sys_symlink", state); state["source"] != "/changed" {
+		t.Fatalf("unexpected refreshed state %v", state)
+	}
+
+	env.mustApply("sys_symlink", state, nil)
+	if _, err := os.Lstat(link); !os.IsNotExist(err) {
+		t.Fatalf("symlink not removed: %v", err)
+	}
+}
+
+func TestFileResource(t *testing.T) {
+	env := newTestFrameworkEnv(t)
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "parent", "file")
+
+	state := env.mustApply("sys_file", nil, map[string]interface{}{
+		"filename":        filename,
+		"content_base64":  "aGVsbG8=",
+		"file_permission": "0600",
+	})
+	if content, err := os.ReadFile(filename); err != nil || string(content) != "hello" {
+		t.Fatalf("file not written: %q %v", content, err)
+	}
+	if state["id"] != "aaf4c61ddcc5e8a2dabede0f3b482cd9aea9434d" || state["force_overwrite"] != false {
+		t.Fatalf("unexpected state %v", state)
+	}
+
+	// Files modified externally are created again
+	os.WriteFile(filename, []byte("changed"), 0600)
+	if refreshed := env.refresh("sys_file", state); refreshed != nil {
+		t.Fatalf("expected the modified file to be recreated, got %v", refreshed)
+	}
+	env.mustApply("sys_file", state, nil)
+
+	// Directories are copied from the source
+	os.MkdirAll(filepath.Join(dir, "source"), 0755)
+	os.WriteFile(filepath.Join(dir, "source", "a"), []byte("a"), 0644)
+	state = env.mustApply("sys_file", nil, map[string]interface{}{
+		"source":           filepath.Join(dir, "source"),
+		"target_directory": filepath.Join(dir, "target"),
+	})
+	if content, err := os.ReadFile(filepath.Join(dir, "target", "a")); err != nil || string(content) != "a" {
+		t.Fatalf("directory not copied: %q %v", content, err)
+	}
+	if refreshed := env.refresh("sys_file", state); refreshed["id"] != state["id"] {
+		t.Fatalf("unexpected refreshed state %v", refreshed)
+	}
+
+	_, errs := env.apply("sys_file", nil, map[string]interface{}{
+		"content":          "hello",
+		"target_directory": filepath.Join(dir, "target"),
+	})
+	if !strings.Contains(errs, "conflicts with content") {
+		t.Fatalf("expected a conflict error, got %q", errs)
+	}
+}
+
+// TestSDKStateUpgrade checks that the states written by the SDK
+// implementations, schema version 0, are upgraded to the framework ones. The
+// resources are managed within a root directory.
+func TestSDKStateUpgrade(t *testing.T) {
+	root := t.TempDir()
+	env := newTestFrameworkEnvWith(t, &providerConfiguration{Host: &rootHost{sysHost: localHost{}, Root: root}})
+	dir := "/srv"
+	os.MkdirAll(filepath.Join(root, dir, "dir"), 0750)
+	os.Symlink("/target", filepath.Join(root, dir, "link"))
+	os.WriteFile(filepath.Join(root, dir, "file"), []byte("hello"), 0644)
+	aptSource := "Types: deb\nURIs: http://deb.debian.org/debian\nSuites: bookworm\n"
+	os.MkdirAll(filepath.Join(root, aptSourcesDir), 0755)
+	os.WriteFile(filepath.Join(root, aptSourcesDir, "debian.sources"), []byte(aptSource), 0644)
+	timerContent := "[Unit]\nDescription=Timer backup.timer\n\n[Timer]\nOnCalendar=daily\n\n[Install]\nWantedBy=timers.target\n"
+	serviceContent := "[Unit]\nDescription=Timer backup.timer\n\n[Service]\nExecStart=/bin/backup\nType=oneshot\n"
+	os.MkdirAll(filepath.Join(root, sdSystemUnitDir), 0755)
+	os.WriteFile(filepath.Join(root, sdSystemUnitDir, "backup.timer"), []byte(timerContent), 0644)
+	os.WriteFile(filepath.Join(root, sdSystemUnitDir, "backup.service"), []byte(serviceContent), 0644)
+
+	for _, test := range []struct {
+		name   string
+		config map[string]interface{}
+		state  map[string]interface{}
+	}{
+		{
+			name: "sys_null",
+			config: map[string]interface{}{
+				"inputs":   map[string]interface{}{"a": "1"},
+				"triggers": map[string]interface{}{"t": "1"},
+			},
+			state: map[string]interface{}{
+				"id":       "5577006791947779410",
+				"inputs":   map[string]interface{}{"a": "1"},
+				"outputs":  map[string]interface{}{"a": "1"},
+				"triggers": map[string]interface{}{"t": "1"},
+				"values":   nil,
+			},
+		},
+		{
+			name: "sys_dir",
+			config: map[string]interface{}{
+				"path":       filepath.Join(dir, "dir"),
+				"permission": "0750",
+			},
+			state: map[string]interface{}{
+				"id":                filepath.Join(dir, "dir"),
+				"path":              filepath.Join(dir, "dir"),
+				"permission":        "0750",
+				"parent_permission": "0777",
+				"allow_existing":    false,
+				"force_remove":      false,
+			},
+		},
+		{
+			name: "sys_symlink",
+			config: map[string]interface{}{
+				"source": "/target",
+				"path":   filepath.Join(dir, "link"),
+			},
+			state: map[string]interface{}{
+				"id":                   filepath.Join(dir, "link"),
+				"source":               "/target",
+				"path":                 filepath.Join(dir, "link"),
+				"directory_permission": "0777",
+			},
+		},
+		{
+			name: "sys_file",
+			config: map[string]interface{}{
+				"filename":        filepath.Join(dir, "file"),
+				"content":         "hello",
+				"file_permission": "0644",
+			},
+			state: map[string]interface{}{
+				"id":                   "aaf4c61ddcc5e8a2dabede0f3b482cd9aea9434d",
+				"content":              "hello",
+				"sensitive_content":    nil,
+				"content_base64":       nil,
+				"source":               nil,
+				"filename":             filepath.Join(dir, "file"),
+				"target_directory":     nil,
+				"file_permission":      "0644",
+				"directory_permission": "0777",
+				"force_overwrite":      false,
+				"clear_destination":    false,
+				"symlink_destination":  false,
+				"unlink_before_create": false,
+			},
+		},
+		{
+			name: "sys_apt_repository",
+			config: map[string]interface{}{
+				"name":   "debian",
+				"uris":   []interface{}{"http://deb.debian.org/debian"},
+				"suites": []interface{}{"bookworm"},
+			},
+			state: map[string]interface{}{
+				"id":               repositoryChecksum(aptSource, ""),
+				"name":             "debian",
+				"types":            nil,
+				"uris":             []interface{}{"http://deb.debian.org/debian"},
+				"suites":           []interface{}{"bookworm"},
+				"components":       nil,
+				"architectures":    nil,
+				"key":              nil,
+				"enabled":          true,
+				"options":          nil,
+				"filename":         "/etc/apt/sources.list.d/debian.sources",
+				"keyring_filename": "",
+			},
+		},
+		{
+			name: "sys_systemd_dropin",
+			config: map[string]interface{}{
+				"unit":    "postgresql.service",
+				"service": map[string]interface{}{"LimitNOFILE": "65536"},
+			},
+			state: map[string]interface{}{
+				"id":           "/etc/systemd/system/postgresql.service.d/override.conf",
+				"unit":         "postgresql.service",
+				"name":         "override",
+				"content":      "[Service]\nLimitNOFILE=65536\n",
+				"unit_section": nil,
+				"service":      map[string]interface{}{"LimitNOFILE": "65536"},
+				"timer":        nil,
+				"install":      nil,
+				"restart":      true,
+				"system":       nil,
+				"user":         nil,
+				"user_name":    nil,
+				"path":         "/etc/systemd/system/postgresql.service.d/override.conf",
+			},
+		},
+		{
+			name: "sys_systemd_timer",
+			config: map[string]interface{}{
+				"name":        "backup",
+				"command":     "/bin/backup",
+				"on_calendar": "daily",
+				"start":       false,
+			},
+			state: map[string]interface{}{
+				"id":                   "backup.timer",
+				"name":                 "backup",
+				"command":              "/bin/backup",
+				"description":          nil,
+				"on_calendar":          "daily",
+				"on_boot_sec":          nil,
+				"persistent":           nil,
+				"randomized_delay_sec": nil,
+				"service":              nil,
+				"enable":               true,
+				"start":                false,
+				"system":               nil,
+				"user":                 nil,
+				"user_name":            nil,
+				"timer_content":        timerContent,
+				"service_content":      serviceContent,
+				"next_elapse":          "",
+				"last_trigger":         "",
+				"active_state":         systemdInactive,
+			},
+		},
+		{
+			name: "sys_systemd_run",
+			config: map[string]interface{}{
+				"name":    "job",
+				"command": []interface{}{"/bin/job"},
+			},
+			state: map[string]interface{}{
+				"id":           "job.service",
+				"name":         "job",
+				"command":      []interface{}{"/bin/job"},
+				"properties":   nil,
+				"on_calendar":  nil,
+				"on_active":    nil,
+				"system":       nil,
+				"user":         nil,
+				"user_name":    nil,
+				"service":      "job.service",
+				"timer":        "",
+				"active_state": systemdActive,
+				"sub_state":    systemdRunning,
+			},
+		},
+		{
+			name: "sys_package",
+			config: map[string]interface{}{
+				"type": "deb",
+				"name": "foo",
+				"hold": true,
+			},
+			state: map[string]interface{}{
+				"id":                 "foo_1.0",
+				"type":               "deb",
+				"name":               "foo",
+				"names":              nil,
+				"source":             nil,
+				"checksum":           nil,
+				"version":            nil,
+				"versions":           nil,
+				"target_release":     nil,
+				"ensure":             packageEnsurePresent,
+				"hold":               true,
+				"virtualenv":         nil,
+				"prefix":             nil,
+				"user":               nil,
+				"package_name":       "foo",
+				"installed_version":  "1.0",
+				"installed_versions": map[string]interface{}{"foo": "1.0"},
+			},
+		},
+		{
+			name: "sys_shell_script",
+			config: map[string]interface{}{
+				"create": "echo res",
+				"read":   "echo res",
+			},
+			state: map[string]interface{}{
+				"id":                "res",
+				"working_directory": nil,
+				"shell":             "/bin/sh",
+				"make":              nil,
+				"create":            "echo res",
+				"read":              "echo res",
+				"delete":            nil,
+				"filename":          nil,
+				"missing_exit_code": nil,
+				"stdout":            "res\n",
+				"stderr":            "",
+				"exit_code":         0,
+			},
+		},
+		{
+			name: "sys_systemd_unit",
+			config: map[string]interface{}{
+				"name":   "backup.timer",
+				"enable": false,
+			},
+			state: map[string]interface{}{
+				"id":            "backup.timer",
+				"name":          "backup.timer",
+				"enable":        false,
+				"mask":          nil,
+				"also":          nil,
+				"runtime":       nil,
+				"preset":        nil,
+				"rollback_mode": sdRollbackRestore,
+				"start":         nil,
+				"content":       "",
+				"unit":          nil,
+				"service":       nil,
+				"timer":         nil,
+				"install":       nil,
+				"path":          "",
+				"restart_on":    nil,
+				"reload_on":     nil,
+				"try_restart":   nil,
+				"job_mode":      "replace",
+				"kill":          nil,
+				"reset_failed":  nil,
+				"rollback": map[string]interface{}{
+					"exists":          "true",
+					"load_state":      systemdLoaded,
+					"active_state":    systemdInactive,
+					"sub_state":       systemdDead,
+					"active":          "false",
+					"enabled":         "false",
+					"masked":          "false",
+					"unit_file_state": systemdDisabled,
+				},
+				"system":          nil,
+				"user":            nil,
+				"user_name":       nil,
+				"linger":          nil,
+				"description":     "",
+				"load_state":      systemdLoaded,
+				"active_state":    systemdInactive,
+				"sub_state":       systemdDead,
+				"followed":        "",
+				"job_id":          0,
+				"job_type":        "",
+				"triggers":        []interface{}{},
+				"triggered_by":    []interface{}{},
+				"wait_active":     []interface{}{},
+				"properties":      nil,
+				"property_values": map[string]interface{}{},
+				"ignore_errors":   false,
+			},
+		},
+	} {
+		state, errs := env.upgrade(test.name, sdkSchemaVersion, test.state)
+		if errs != "" {
+			t.Fatalf("%s: cannot upgrade the SDK state: %s", test.name, errs)
+		}
+		if !reflect.DeepEqual(state, test.state) {
+			t.Errorf("%s: expected the state %v, got %v", test.name, test.state, state)
+		}
+		if res := env.mustApply(test.name, state, test.config); !reflect.DeepEqual(res, state) {
+			t.Errorf("%s: expected no change, got %v", test.name, res)
+		}
+
+		// States of the current version are read as is
+		if res, errs := env.upgrade(test.name, 1, state); errs != "" || !reflect.DeepEqual(res, state) {
+			t.Errorf("%s: expected the state to be kept, got %v %s", test.name, res, errs)
+		}
+	}
+}
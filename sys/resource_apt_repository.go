@@ -0,0 +1,323 @@
+package sys
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/booldefault"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+const (
+	aptSourcesDir  = "/etc/apt/sources.list.d"
+	aptKeyringsDir = "/etc/apt/keyrings"
+)
+
+type aptRepositoryResource struct {
+	config *providerConfiguration
+}
+
+type aptRepositoryResourceModel struct {
+	Id              types.String      `tfsdk:"id"`
+	Name            types.String      `tfsdk:"name"`
+	Types           []string          `tfsdk:"types"`
+	URIs            []string          `tfsdk:"uris"`
+	Suites          []string          `tfsdk:"suites"`
+	Components      []string          `tfsdk:"components"`
+	Architectures   []string          `tfsdk:"architectures"`
+	Key             types.String      `tfsdk:"key"`
+	Enabled         types.Bool        `tfsdk:"enabled"`
+	Options         map[string]string `tfsdk:"options"`
+	Filename        types.String      `tfsdk:"filename"`
+	KeyringFilename types.String      `tfsdk:"keyring_filename"`
+}
+
+func newAptRepositoryResource() resource.Resource {
+	return &aptRepositoryResource{}
+}
+
+func (r *aptRepositoryResource) Metadata(ctx context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_apt_repository"
+}
+
+func (r *aptRepositoryResource) Configure(ctx context.Context, req resource.ConfigureRequest, resp *resource.ConfigureResponse) {
+	r.config, _ = req.ProviderData.(*providerConfiguration)
+}
+
+func (r *aptRepositoryResource) Schema(ctx context.Context, req resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		Description: `
+sys_apt_repository manages an APT source in the deb822 format in /etc/apt/sources.list.d, with an optional signing key stored in /etc/apt/keyrings. The package index is updated again by the next sys_package creation.
+`,
+		Version: 1,
+
+		Attributes: map[string]schema.Attribute{
+			"id": schema.StringAttribute{
+				Computed:    true,
+				Description: "The ID of this resource.",
+			},
+			"name": schema.StringAttribute{
+				Description:   "Repository name, used for the source and keyring file names",
+				Required:      true,
+				PlanModifiers: []planmodifier.String{stringplanmodifier.RequiresReplace()},
+			},
+			"types": schema.ListAttribute{
+				Description: "(default: [\"deb\"]) Archive types, deb and/or deb-src",
+				ElementType: types.StringType,
+				Optional:    true,
+			},
+			"uris": schema.ListAttribute{
+				Description: "Repository URIs",
+				ElementType: types.StringType,
+				Required:    true,
+			},
+			"suites": schema.ListAttribute{
+				Description: "Repository suites (distribution codenames or paths)",
+				ElementType: types.StringType,
+				Required:    true,
+			},
+			"components": schema.ListAttribute{
+				Description: "Repository components",
+				ElementType: types.StringType,
+				Optional:    true,
+			},
+			"architectures": schema.ListAttribute{
+				Description: "Restrict the repository to these architectures",
+				ElementType: types.StringType,
+				Optional:    true,
+			},
+			"key": schema.StringAttribute{
+				Description: "ASCII armored signing key, written to a keyring used with Signed-By",
+				Optional:    true,
+			},
+			"enabled": schema.BoolAttribute{
+				Description: "(default: true) Enable the repository",
+				Optional:    true,
+				Computed:    true,
+				Default:     booldefault.StaticBool(true),
+			},
+			"options": schema.MapAttribute{
+				Description: "Additional deb822 fields",
+				ElementType: types.StringType,
+				Optional:    true,
+			},
+			"filename": schema.StringAttribute{
+				Description:   "Path of the generated source file",
+				Computed:      true,
+				PlanModifiers: []planmodifier.String{stringplanmodifier.UseStateForUnknown()},
+			},
+			"keyring_filename": schema.StringAttribute{
+				Description: "Path of the generated keyring",
+				Computed:    true,
+			},
+		},
+	}
+}
+
+func (r *aptRepositoryResource) UpgradeState(ctx context.Context) map[int64]resource.StateUpgrader {
+	return sdkStateUpgraders(ctx, r)
+}
+
+func aptRepositoryFiles(data *aptRepositoryResourceModel) (string, string) {
+	name := data.Name.ValueString()
+	return path.Join(aptSourcesDir, name+".sources"), path.Join(aptKeyringsDir, name+".asc")
+}
+
+func aptRepositoryContent(data *aptRepositoryResourceModel, keyring string) string {
+	var lines []string
+	field := func(key string, values []string) {
+		if len(values) > 0 {
+			lines = append(lines, fmt.Sprintf("%s: %s", key, strings.Join(values, " ")))
+		}
+	}
+
+	types := data.Types
+	if len(types) == 0 {
+		types = []string{"deb"}
+	}
+
+	field("Types", types)
+	field("URIs", data.URIs)
+	field("Suites", data.Suites)
+	field("Components", data.Components)
+	field("Architectures", data.Architectures)
+	if data.Key.ValueString() != "" {
+		field("Signed-By", []string{keyring})
+	}
+	if !data.Enabled.ValueBool() {
+		field("Enabled", []string{"no"})
+	}
+
+	var keys []string
+	for key := range data.Options {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		field(key, []string{data.Options[key]})
+	}
+
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// repositoryWriteFile writes a package manager configuration file, creating
+// the parent directories if needed.
+func repositoryWriteFile(host sysHost, filename string, content string) error {
+	err := host.MkdirAll(path.Dir(filename), 0755)
+	if err != nil {
+		return fmt.Errorf("cannot create parent directories, %v", err)
+	}
+
+	err = host.WriteFile(filename, []byte(content), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("cannot write %s, %v", filename, err)
+	}
+
+	return nil
+}
+
+func repositoryChecksum(contents ...string) string {
+	checksum := sha1.Sum([]byte(strings.Join(contents, "\n")))
+	return hex.EncodeToString(checksum[:])
+}
+
+func (r *aptRepositoryResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	var data aptRepositoryResourceModel
+
+	resp.Diagnostics.Append(req.State.Get(ctx, &data)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	filename, keyring := aptRepositoryFiles(&data)
+	host := providerHost(r.config)
+
+	content, err := host.ReadFile(filename)
+	if os.IsNotExist(err) {
+		resp.State.RemoveResource(ctx)
+		return
+	} else if err != nil {
+		resp.Diagnostics.AddError("cannot read "+filename, err.Error())
+		return
+	}
+
+	var key []byte
+	if data.Key.ValueString() != "" {
+		key, err = host.ReadFile(keyring)
+		if err != nil && !os.IsNotExist(err) {
+			resp.Diagnostics.AddError("cannot read "+keyring, err.Error())
+			return
+		}
+	}
+
+	// Files modified externally must be written again
+	if repositoryChecksum(string(content), string(key)) != data.Id.ValueString() {
+		resp.State.RemoveResource(ctx)
+	}
+}
+
+func (r *aptRepositoryResource) write(data *aptRepositoryResourceModel) error {
+	filename, keyring := aptRepositoryFiles(data)
+	key := data.Key.ValueString()
+	content := aptRepositoryContent(data, keyring)
+	host := providerHost(r.config)
+
+	lock := &debLock
+	lock.Lock()
+	defer lock.Unlock()
+
+	if key != "" {
+		err := repositoryWriteFile(host, keyring, key)
+		if err != nil {
+			return err
+		}
+	} else if err := host.Remove(keyring); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("cannot remove %s, %v", keyring, err)
+	}
+
+	err := repositoryWriteFile(host, filename, content)
+	if err != nil {
+		return err
+	}
+
+	r.config.setPkgUpdated("deb", false)
+
+	data.Filename = types.StringValue(filename)
+	if key != "" {
+		data.KeyringFilename = types.StringValue(keyring)
+	} else {
+		data.KeyringFilename = types.StringValue("")
+	}
+	data.Id = types.StringValue(repositoryChecksum(content, key))
+	return nil
+}
+
+func (r *aptRepositoryResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	var data aptRepositoryResourceModel
+
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &data)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	if err := r.write(&data); err != nil {
+		resp.Diagnostics.AddError("cannot write the repository", err.Error())
+		return
+	}
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+func (r *aptRepositoryResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	var data aptRepositoryResourceModel
+
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &data)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	if err := r.write(&data); err != nil {
+		resp.Diagnostics.AddError("cannot write the repository", err.Error())
+		return
+	}
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+func (r *aptRepositoryResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	var data aptRepositoryResourceModel
+
+	resp.Diagnostics.Append(req.State.Get(ctx, &data)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	filename, keyring := aptRepositoryFiles(&data)
+
+	lock := &debLock
+	lock.Lock()
+	defer lock.Unlock()
+
+	for _, f := range []string{filename, keyring} {
+		if err := providerHost(r.config).Remove(f); err != nil && !os.IsNotExist(err) {
+			resp.Diagnostics.AddError("cannot remove "+f, err.Error())
+			return
+		}
+	}
+
+	r.config.setPkgUpdated("deb", false)
+}
@@ -1,142 +1,210 @@
 package sys
 
 import (
-	"fmt"
+	"context"
 	"os"
 	"path"
 	"strconv"
 
-	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/booldefault"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringdefault"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
 	"github.com/mildred/terraform-provider-sys/sys/utils"
 )
 
-func resourceDir() *schema.Resource {
-	return &schema.Resource{
-		Create: resourceDirCreate,
-		Read:   resourceDirRead,
-		Delete: resourceDirDelete,
-		Update: resourceDirUpdate,
-
-		Schema: map[string]*schema.Schema{
-			"path": {
-				Type:        schema.TypeString,
-				Description: "Path to the output file",
-				Required:    true,
-				ForceNew:    true,
+type dirResource struct {
+	host sysHost
+}
+
+type dirResourceModel struct {
+	Id               types.String `tfsdk:"id"`
+	Path             types.String `tfsdk:"path"`
+	Permission       types.String `tfsdk:"permission"`
+	ParentPermission types.String `tfsdk:"parent_permission"`
+	AllowExisting    types.Bool   `tfsdk:"allow_existing"`
+	ForceRemove      types.Bool   `tfsdk:"force_remove"`
+}
+
+func newDirResource() resource.Resource {
+	return &dirResource{}
+}
+
+func (r *dirResource) Metadata(ctx context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_dir"
+}
+
+func (r *dirResource) Configure(ctx context.Context, req resource.ConfigureRequest, resp *resource.ConfigureResponse) {
+	r.host = providerHost(req.ProviderData)
+}
+
+func (r *dirResource) Schema(ctx context.Context, req resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		Version: 1,
+		Attributes: map[string]schema.Attribute{
+			"id": schema.StringAttribute{
+				Computed:      true,
+				Description:   "The ID of this resource.",
+				PlanModifiers: []planmodifier.String{stringplanmodifier.UseStateForUnknown()},
 			},
-			"permission": {
-				Type:         schema.TypeString,
-				Description:  "Permissions to set for the output file",
-				Optional:     true,
-				Default:      "0777",
-				ValidateFunc: validateMode,
+			"path": schema.StringAttribute{
+				Description:   "Path to the output file",
+				Required:      true,
+				PlanModifiers: []planmodifier.String{stringplanmodifier.RequiresReplace()},
 			},
-			"parent_permission": {
-				Type:         schema.TypeString,
-				Description:  "Permissions to set for directories created",
-				Optional:     true,
-				ForceNew:     true,
-				Default:      "0777",
-				ValidateFunc: validateMode,
+			"permission": schema.StringAttribute{
+				Description: "Permissions to set for the output file",
+				Optional:    true,
+				Computed:    true,
+				Default:     stringdefault.StaticString("0777"),
+				Validators:  []validator.String{modeValidator},
 			},
-			"allow_existing": {
-				Type:          schema.TypeBool,
-				Description:   "Allow directory to exist prior to running terraform",
+			"parent_permission": schema.StringAttribute{
+				Description:   "Permissions to set for directories created",
 				Optional:      true,
-				Default:       false,
-				ConflictsWith: []string{"force_remove"},
+				Computed:      true,
+				Default:       stringdefault.StaticString("0777"),
+				Validators:    []validator.String{modeValidator},
+				PlanModifiers: []planmodifier.String{stringplanmodifier.RequiresReplace()},
 			},
-			"force_remove": {
-				Type:          schema.TypeBool,
-				Description:   "Force removing the directory even if not empty",
-				Optional:      true,
-				Default:       false,
-				ConflictsWith: []string{"allow_existing"},
+			"allow_existing": schema.BoolAttribute{
+				Description: "Allow directory to exist prior to running terraform",
+				Optional:    true,
+				Computed:    true,
+				Default:     booldefault.StaticBool(false),
+			},
+			"force_remove": schema.BoolAttribute{
+				Description: "Force removing the directory even if not empty",
+				Optional:    true,
+				Computed:    true,
+				Default:     booldefault.StaticBool(false),
 			},
 		},
 	}
 }
 
-func resourceDirRead(d *schema.ResourceData, _ interface{}) error {
+func (r *dirResource) ConfigValidators(ctx context.Context) []resource.ConfigValidator {
+	return []resource.ConfigValidator{
+		conflictingAttributes{"allow_existing", "force_remove"},
+	}
+}
+
+func (r *dirResource) UpgradeState(ctx context.Context) map[int64]resource.StateUpgrader {
+	return sdkStateUpgraders(ctx, r)
+}
+
+func (r *dirResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	var data dirResourceModel
+
+	resp.Diagnostics.Append(req.State.Get(ctx, &data)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
 	// If the output file doesn't exist, mark the resource for creation.
-	outputPath := d.Get("path").(string)
-	st, err := os.Stat(outputPath)
+	outputPath := data.Path.ValueString()
+	st, err := r.host.Stat(outputPath)
 	if os.IsNotExist(err) {
-		d.SetId("")
-		return nil
+		resp.State.RemoveResource(ctx)
+		return
+	} else if err != nil {
+		resp.Diagnostics.AddError("cannot stat directory", err.Error())
+		return
 	}
 
-	same, err := utils.FileModeSame(d.Get("permission").(string), st.Mode(), utils.Umask)
+	same, err := utils.FileModeSame(data.Permission.ValueString(), st.Mode(), utils.Umask)
 	if err != nil {
-		return err
+		resp.Diagnostics.AddError("cannot compare permissions", err.Error())
+		return
 	}
 	if !same {
-		d.Set("permission", st.Mode().String())
+		data.Permission = types.StringValue(st.Mode().String())
 	}
 
-	d.SetId(outputPath)
-	return nil
+	data.Id = types.StringValue(outputPath)
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
 }
 
-func resourceDirUpdate(d *schema.ResourceData, _ interface{}) error {
-	destination := d.Get("path").(string)
+func (r *dirResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	var data, state dirResourceModel
+
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &data)...)
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
 
-	if d.HasChange("permission") {
-		perm := d.Get("permission").(string)
-		modeInt, _ := strconv.ParseInt(perm, 8, 64)
+	if !data.Permission.Equal(state.Permission) {
+		modeInt, _ := strconv.ParseInt(data.Permission.ValueString(), 8, 64)
 		mode := os.FileMode(modeInt)
 
-		err := os.Chmod(destination, mode)
-		if err != nil {
-			return fmt.Errorf("cannot chmod %s, %s", mode, err)
+		if err := r.host.Chmod(data.Path.ValueString(), mode); err != nil {
+			resp.Diagnostics.AddError("cannot chmod "+mode.String(), err.Error())
+			return
 		}
 	}
-	return nil
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
 }
 
-func resourceDirCreate(d *schema.ResourceData, _ interface{}) error {
-	destination := d.Get("path").(string)
-	allowExisting := d.Get("allow_existing").(bool)
+func (r *dirResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	var data dirResourceModel
 
-	destinationDir := path.Dir(destination)
-	if _, err := os.Stat(destinationDir); err != nil {
-		dirPerm := d.Get("permission").(string)
-		dirMode, _ := strconv.ParseInt(dirPerm, 8, 64)
-		if err := os.MkdirAll(destinationDir, os.FileMode(dirMode)); err != nil {
-			return err
-		}
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &data)...)
+
+	if resp.Diagnostics.HasError() {
+		return
 	}
 
-	dirPerm := d.Get("permission").(string)
+	destination := data.Path.ValueString()
+	dirMode, _ := strconv.ParseInt(data.Permission.ValueString(), 8, 64)
 
-	dirMode, _ := strconv.ParseInt(dirPerm, 8, 64)
+	destinationDir := path.Dir(destination)
+	if _, err := r.host.Stat(destinationDir); err != nil {
+		if err := r.host.MkdirAll(destinationDir, os.FileMode(dirMode)); err != nil {
+			resp.Diagnostics.AddError("cannot create parent directories", err.Error())
+			return
+		}
+	}
 
-	err := os.Mkdir(destination, os.FileMode(dirMode))
-	if allowExisting && os.IsExist(err) {
+	err := r.host.Mkdir(destination, os.FileMode(dirMode))
+	if data.AllowExisting.ValueBool() && os.IsExist(err) {
 		err = nil
 	} else if err != nil {
-		return err
+		resp.Diagnostics.AddError("cannot create directory", err.Error())
+		return
 	}
 
-	d.SetId(destination)
-
-	return nil
+	data.Id = types.StringValue(destination)
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
 }
 
-func resourceDirDelete(d *schema.ResourceData, _ interface{}) error {
-	var err error
-	forceRemove := d.Get("force_remove").(bool)
-	allowExisting := d.Get("allow_existing").(bool)
-	path := d.Get("path").(string)
+func (r *dirResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	var data dirResourceModel
+
+	resp.Diagnostics.Append(req.State.Get(ctx, &data)...)
 
-	if forceRemove {
-		err = os.RemoveAll(path)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	var err error
+	if data.ForceRemove.ValueBool() {
+		err = r.host.RemoveAll(data.Path.ValueString())
 	} else {
-		err = os.Remove(path)
-		if allowExisting && os.IsExist(err) {
+		err = r.host.Remove(data.Path.ValueString())
+		if data.AllowExisting.ValueBool() && os.IsExist(err) {
 			err = nil
 		}
 	}
 
-	return err
+	if err != nil {
+		resp.Diagnostics.AddError("cannot remove directory", err.Error())
+	}
 }
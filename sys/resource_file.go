@@ -10,23 +10,55 @@ import (
 	"io/ioutil"
 	"os"
 	"path"
-	"sort"
 	"strconv"
 
 	"github.com/hashicorp/go-getter/v2"
-	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
-	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/booldefault"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringdefault"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
 	// "github.com/mildred/terraform-provider-sys/sys/file_getter"
 	"github.com/mildred/terraform-provider-sys/sys/utils"
 )
 
-func resourceFile() *schema.Resource {
-	return &schema.Resource{
-		CreateContext: resourceFileCreate,
-		ReadContext:   resourceFileRead,
-		DeleteContext: resourceFileDelete,
-		UpdateContext: resourceFileUpdate,
+type fileResource struct {
+	host sysHost
+}
+
+type fileResourceModel struct {
+	Id                  types.String `tfsdk:"id"`
+	Content             types.String `tfsdk:"content"`
+	SensitiveContent    types.String `tfsdk:"sensitive_content"`
+	ContentBase64       types.String `tfsdk:"content_base64"`
+	Source              types.String `tfsdk:"source"`
+	Filename            types.String `tfsdk:"filename"`
+	TargetDirectory     types.String `tfsdk:"target_directory"`
+	FilePermission      types.String `tfsdk:"file_permission"`
+	DirectoryPermission types.String `tfsdk:"directory_permission"`
+	ForceOverwrite      types.Bool   `tfsdk:"force_overwrite"`
+	ClearDestination    types.Bool   `tfsdk:"clear_destination"`
+	SymlinkDestination  types.Bool   `tfsdk:"symlink_destination"`
+	UnlinkBeforeCreate  types.Bool   `tfsdk:"unlink_before_create"`
+}
+
+func newFileResource() resource.Resource {
+	return &fileResource{}
+}
+
+func (r *fileResource) Metadata(ctx context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_file"
+}
 
+func (r *fileResource) Configure(ctx context.Context, req resource.ConfigureRequest, resp *resource.ConfigureResponse) {
+	r.host = providerHost(req.ProviderData)
+}
+
+func (r *fileResource) Schema(ctx context.Context, req resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
 		Description: `
 sys_file generates a local, similarly to local_file, with a number of options. Files or directories can be generated from:
 - direct file content (plain, base64 or sensitive)
@@ -38,107 +70,113 @@ Any required parent directories will be created automatically, and any existing
 If the destination file exists, creation will block. However the resource has the ability to remove it if it exists prior to running in every case, or force overwriting it. if the source is a local file or directory, it can generate a symlink too (default behaviour of go-getter).
 `,
 
-		Schema: map[string]*schema.Schema{
-			"content": {
+		Version: 1,
+
+		Attributes: map[string]schema.Attribute{
+			"id": schema.StringAttribute{
+				Computed:      true,
+				Description:   "The ID of this resource.",
+				PlanModifiers: []planmodifier.String{stringplanmodifier.UseStateForUnknown()},
+			},
+			"content": schema.StringAttribute{
 				Description:   "The content of file to create. Conflicts with `sensitive_content` and `content_base64`.",
-				Type:          schema.TypeString,
 				Optional:      true,
-				ForceNew:      true,
-				ConflictsWith: []string{"sensitive_content", "content_base64", "source"},
+				PlanModifiers: []planmodifier.String{stringplanmodifier.RequiresReplace()},
 			},
-			"sensitive_content": {
+			"sensitive_content": schema.StringAttribute{
 				Description:   "The content of file to create. Will not be displayed in diffs. Conflicts with `content` and `content_base64`.",
-				Type:          schema.TypeString,
 				Optional:      true,
-				ForceNew:      true,
 				Sensitive:     true,
-				ConflictsWith: []string{"content", "content_base64", "source"},
+				PlanModifiers: []planmodifier.String{stringplanmodifier.RequiresReplace()},
 			},
-			"content_base64": {
+			"content_base64": schema.StringAttribute{
 				Description:   "The base64 encoded content of the file to create. Use this when dealing with binary data. Conflicts with `content` and `sensitive_content`.",
-				Type:          schema.TypeString,
 				Optional:      true,
-				ForceNew:      true,
-				ConflictsWith: []string{"sensitive_content", "content", "source"},
+				PlanModifiers: []planmodifier.String{stringplanmodifier.RequiresReplace()},
 			},
-			"source": {
+			"source": schema.StringAttribute{
 				Description:   "The source file to copy, compatible with go-getter.",
-				Type:          schema.TypeString,
 				Optional:      true,
-				ForceNew:      true,
-				ConflictsWith: []string{"content", "sensitive_content", "content_base64"},
+				PlanModifiers: []planmodifier.String{stringplanmodifier.RequiresReplace()},
 			},
-			"filename": {
+			"filename": schema.StringAttribute{
 				Description:   "(Required unless `target_directory` is specified) The path of the file to create.",
-				Type:          schema.TypeString,
 				Optional:      true,
-				ForceNew:      true,
-				ConflictsWith: []string{"target_directory"},
+				PlanModifiers: []planmodifier.String{stringplanmodifier.RequiresReplace()},
 			},
-			"target_directory": {
+			"target_directory": schema.StringAttribute{
 				Description:   "(Conflicts with `filename` or `content*`) The path of target directory where the file should be put, must not exists unless `force_overwrite` is `true`. Upon resource deletion, the target directory will be entorely removed with no additional check. Can be useful when the source is an archive that go-getter extracts (it will refuse to do so with `filename`).",
-				Type:          schema.TypeString,
 				Optional:      true,
-				ForceNew:      true,
-				ConflictsWith: []string{"filename", "content", "sensitive_content", "content_base64"},
+				PlanModifiers: []planmodifier.String{stringplanmodifier.RequiresReplace()},
 			},
-			"file_permission": {
-				Description:  "(default: \"0666\") The permission to set for the created file. Expects an a string.",
-				Type:         schema.TypeString,
-				Optional:     true,
-				ForceNew:     true,
-				Default:      "0666",
-				ValidateFunc: validateMode,
+			"file_permission": schema.StringAttribute{
+				Description:   "(default: \"0666\") The permission to set for the created file. Expects an a string.",
+				Optional:      true,
+				Computed:      true,
+				Default:       stringdefault.StaticString("0666"),
+				Validators:    []validator.String{modeValidator},
+				PlanModifiers: []planmodifier.String{stringplanmodifier.RequiresReplace()},
 			},
-			"directory_permission": {
-				Description:  "(default: \"0777\") The permission to set for any directories created. Expects a string.",
-				Type:         schema.TypeString,
-				Optional:     true,
-				ForceNew:     true,
-				Default:      "0777",
-				ValidateFunc: validateMode,
+			"directory_permission": schema.StringAttribute{
+				Description:   "(default: \"0777\") The permission to set for any directories created. Expects a string.",
+				Optional:      true,
+				Computed:      true,
+				Default:       stringdefault.StaticString("0777"),
+				Validators:    []validator.String{modeValidator},
+				PlanModifiers: []planmodifier.String{stringplanmodifier.RequiresReplace()},
 			},
 			/*
-			"user": {
-				Description: "User to write the file as (works only as root)",
-				Type: schema.TypeString,
-				Optional: true,
-				Default: false,
-			}
-			"group": {
-				Description: "Group to write the file as (works only as root)",
-				Type: schema.TypeString,
-				Optional: true,
-				Default: false,
-			}*/
-			"force_overwrite": {
+				"user": schema.StringAttribute{
+					Description: "User to write the file as (works only as root)",
+					Optional:    true,
+				},
+				"group": schema.StringAttribute{
+					Description: "Group to write the file as (works only as root)",
+					Optional:    true,
+				},
+			*/
+			"force_overwrite": schema.BoolAttribute{
 				Description: "(default: false) When `true`, allows to overwrite target file or directory.",
-				Type:        schema.TypeBool,
 				Optional:    true,
-				Default:     false,
+				Computed:    true,
+				Default:     booldefault.StaticBool(false),
 			},
-			"clear_destination": {
+			"clear_destination": schema.BoolAttribute{
 				Description: "(default: false) Remove directory destination before recreating it. Must be used with force_overwrite",
-				Type:        schema.TypeBool,
 				Optional:    true,
-				Default:     false,
+				Computed:    true,
+				Default:     booldefault.StaticBool(false),
 			},
-			"symlink_destination": {
+			"symlink_destination": schema.BoolAttribute{
 				Description: "(default: false) Symlink destination if source is a directory and target_directory is set. Consider using sys_symlink resource",
-				Type:        schema.TypeBool,
 				Optional:    true,
-				Default:     false,
+				Computed:    true,
+				Default:     booldefault.StaticBool(false),
 			},
-			"unlink_before_create": {
-			        Description: "Unlink file before creating it (allows to use a new inode)",
-				Type:        schema.TypeBool,
+			"unlink_before_create": schema.BoolAttribute{
+				Description: "Unlink file before creating it (allows to use a new inode)",
 				Optional:    true,
-				Default:     false,
+				Computed:    true,
+				Default:     booldefault.StaticBool(false),
 			},
 		},
 	}
 }
 
+func (r *fileResource) ConfigValidators(ctx context.Context) []resource.ConfigValidator {
+	return []resource.ConfigValidator{
+		conflictingAttributes{"content", "sensitive_content", "content_base64", "source"},
+		conflictingAttributes{"filename", "target_directory"},
+		conflictingAttributes{"content", "target_directory"},
+		conflictingAttributes{"sensitive_content", "target_directory"},
+		conflictingAttributes{"content_base64", "target_directory"},
+	}
+}
+
+func (r *fileResource) UpgradeState(ctx context.Context) map[int64]resource.StateUpgrader {
+	return sdkStateUpgraders(ctx, r)
+}
+
 type resourceFileSystemd struct {
 	unit       string
 	enable     bool
@@ -151,108 +189,129 @@ type resourceFileSystemd struct {
 	had_start  bool
 }
 
-func resourceFileRead(ctx context.Context, d *schema.ResourceData, _ interface{}) diag.Diagnostics {
-	outputPath, isDir, err := getDestination(d)
+func (r *fileResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	var data fileResourceModel
+
+	resp.Diagnostics.Append(req.State.Get(ctx, &data)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	outputPath, isDir, err := getDestination(&data)
 	if err != nil {
-		return diag.Errorf("cannot get destination, %v", err)
+		resp.Diagnostics.AddError("cannot get destination", err.Error())
+		return
 	}
 
 	// If the output file doesn't exist, mark the resource for creation.
-	st, err := os.Stat(outputPath)
+	st, err := r.host.Stat(outputPath)
 	if os.IsNotExist(err) {
-		d.SetId("")
-		return nil
+		resp.State.RemoveResource(ctx)
+		return
 	}
 	if err != nil {
-		return diag.Errorf("stat failed, %v", err)
+		resp.Diagnostics.AddError("stat failed", err.Error())
+		return
 	}
 
-	same, err := utils.FileModeSame(d.Get("file_permission").(string), st.Mode(), utils.Umask)
+	same, err := utils.FileModeSame(data.FilePermission.ValueString(), st.Mode(), utils.Umask)
 	if err != nil {
-		return diag.Errorf("checking file mode, %v", err)
+		resp.Diagnostics.AddError("checking file mode", err.Error())
+		return
 	}
 	if !same {
-		d.Set("file_permission", st.Mode().String())
+		data.FilePermission = types.StringValue(st.Mode().String())
 	}
 
 	// Verify that the content of the destination file matches the content we
 	// expect. Otherwise, the file might have been modified externally and we
 	// must reconcile.
 	if !isDir {
-		outputContent, err := ioutil.ReadFile(outputPath)
+		outputContent, err := r.host.ReadFile(outputPath)
 		if err != nil {
-			return diag.Errorf("cannot read file, %v", err)
+			resp.Diagnostics.AddError("cannot read file", err.Error())
+			return
 		}
 
 		outputChecksum := sha1.Sum([]byte(outputContent))
-		if hex.EncodeToString(outputChecksum[:]) != d.Id() {
-			d.SetId("")
-			return nil
+		if hex.EncodeToString(outputChecksum[:]) != data.Id.ValueString() {
+			resp.State.RemoveResource(ctx)
+			return
 		}
 	} else {
-		sum, err := checksumFile(outputPath)
+		sum, err := checksumFile(r.host, outputPath)
 		if err != nil {
-			return diag.Errorf("cannot checksum %s, %v", outputPath, err)
+			resp.Diagnostics.AddError("cannot checksum "+outputPath, err.Error())
+			return
 		}
-		d.SetId(sum)
+		data.Id = types.StringValue(sum)
 	}
 
-	return nil
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
 }
 
-func resourceFileContent(d *schema.ResourceData) ([]byte, bool, error) {
-	if content, sensitiveSpecified := d.GetOk("sensitive_content"); sensitiveSpecified {
-		return []byte(content.(string)), true, nil
+func resourceFileContent(data *fileResourceModel) ([]byte, bool, error) {
+	if content := data.SensitiveContent.ValueString(); content != "" {
+		return []byte(content), true, nil
 	}
-	if b64Content, b64Specified := d.GetOk("content_base64"); b64Specified {
-		res, err := base64.StdEncoding.DecodeString(b64Content.(string))
+	if b64Content := data.ContentBase64.ValueString(); b64Content != "" {
+		res, err := base64.StdEncoding.DecodeString(b64Content)
 		return res, true, err
 	}
-	if content, contentSpecified := d.GetOk("content"); contentSpecified {
-		return []byte(content.(string)), true, nil
+	if content := data.Content.ValueString(); content != "" {
+		return []byte(content), true, nil
 	}
 	return nil, false, nil
 }
 
-func resourceFileUpdate(ctx context.Context, d *schema.ResourceData, _ interface{}) diag.Diagnostics {
-	destination, is_directory, err := getDestination(d)
+func (r *fileResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	var data, state fileResourceModel
+
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &data)...)
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	destination, is_directory, err := getDestination(&data)
 	if err != nil {
-		return diag.Errorf("destination, %s", err)
+		resp.Diagnostics.AddError("destination", err.Error())
+		return
 	}
 
-	var perm_name string
+	perm, oldPerm := data.FilePermission, state.FilePermission
 	if is_directory {
-		perm_name = "directory_permission"
-	} else {
-		perm_name = "file_permission"
+		perm, oldPerm = data.DirectoryPermission, state.DirectoryPermission
 	}
 
-	if d.HasChange(perm_name) {
-		perm := d.Get(perm_name).(string)
-		modeInt, _ := strconv.ParseInt(perm, 8, 64)
+	if !perm.Equal(oldPerm) {
+		modeInt, _ := strconv.ParseInt(perm.ValueString(), 8, 64)
 		mode := os.FileMode(modeInt)
 
-		err := os.Chmod(destination, mode)
+		err := r.host.Chmod(destination, mode)
 		if err != nil {
-			return diag.Errorf("cannot chmod %s, %s", mode, err)
+			resp.Diagnostics.AddError("cannot chmod "+mode.String(), err.Error())
+			return
 		}
 	}
 
-	return nil
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
 }
 
-func getDestination(d *schema.ResourceData) (string, bool, error) {
+func getDestination(data *fileResourceModel) (string, bool, error) {
 	var destination = ""
 	var is_directory bool
 	var good bool
 
-	if filename, ok := d.GetOk("filename"); ok {
-		destination = filename.(string)
+	if filename := data.Filename.ValueString(); filename != "" {
+		destination = filename
 		is_directory = false
 		good = true
 	}
-	if target_directory, ok := d.GetOk("target_directory"); ok {
-		destination = target_directory.(string)
+	if target_directory := data.TargetDirectory.ValueString(); target_directory != "" {
+		destination = target_directory
 		is_directory = true
 		good = true
 	}
@@ -264,17 +323,17 @@ func getDestination(d *schema.ResourceData) (string, bool, error) {
 	return destination, is_directory, nil
 }
 
-func readFileOrDir(w io.Writer, filename string, st os.FileInfo) error {
+func readFileOrDir(w io.Writer, host sysHost, filename string, st os.FileInfo) error {
 	var err error
 	if st == nil {
-		st, err = os.Lstat(filename)
+		st, err = host.Lstat(filename)
 		if err != nil {
 			return fmt.Errorf("lstat %s, %e", filename, err)
 		}
 	}
 
 	if st.Mode()&os.ModeSymlink != 0 {
-		link, err := os.Readlink(filename)
+		link, err := host.Readlink(filename)
 		if err != nil {
 			return fmt.Errorf("readlink %s, %v", filename, err)
 		}
@@ -283,38 +342,30 @@ func readFileOrDir(w io.Writer, filename string, st os.FileInfo) error {
 		return nil
 	}
 
-	f, err := os.Open(filename)
-	if err != nil {
-		return fmt.Errorf("cannot open file %s, %v", filename, err)
-	}
-	defer f.Close()
-
 	if st.IsDir() {
-		files, err := f.Readdir(-1)
+		files, err := host.ReadDir(filename)
 		if err != nil {
 			return fmt.Errorf("readdir %s, %e", filename, err)
 		}
-		sort.Slice(files, func(a, b int) bool {
-			return files[a].Name() < files[b].Name()
-		})
 		for _, fst := range files {
 			fmt.Fprintf(w, "%d.%s.%d.", len(fst.Name()), fst.Name(), fst.Size())
-			readFileOrDir(w, path.Join(filename, fst.Name()), fst)
+			readFileOrDir(w, host, path.Join(filename, fst.Name()), fst)
 		}
 	} else if st.Mode().IsRegular() {
-		_, err = io.Copy(w, f)
+		content, err := host.ReadFile(filename)
 		if err != nil {
 			return fmt.Errorf("reading %s, %v", filename, err)
 		}
+		w.Write(content)
 	} else {
 		return fmt.Errorf("cannot handle %s type %s", filename, st.Mode().String())
 	}
 	return nil
 }
 
-func checksumFile(destination string) (string, error) {
+func checksumFile(host sysHost, destination string) (string, error) {
 	h := sha1.New()
-	err := readFileOrDir(h, destination, nil)
+	err := readFileOrDir(h, host, destination, nil)
 	if err != nil {
 		return "", err
 	}
@@ -322,50 +373,65 @@ func checksumFile(destination string) (string, error) {
 	return hex.EncodeToString(checksum[:]), nil
 }
 
-func resourceFileCreate(ctx context.Context, d *schema.ResourceData, _ interface{}) diag.Diagnostics {
-	forceOverwrite := d.Get("force_overwrite").(bool)
-	clearDestination := d.Get("clear_destination").(bool)
-	unlinkBeforeCreate := d.Get("unlink_before_create").(bool)
-	symlink_destination := d.Get("symlink_destination").(bool)
-	source, sourceSpecified := d.GetOk("source")
-	content, contentSpecified, err := resourceFileContent(d)
+func (r *fileResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	var data fileResourceModel
+
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &data)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	host := r.host
+	forceOverwrite := data.ForceOverwrite.ValueBool()
+	clearDestination := data.ClearDestination.ValueBool()
+	unlinkBeforeCreate := data.UnlinkBeforeCreate.ValueBool()
+	symlink_destination := data.SymlinkDestination.ValueBool()
+	source := data.Source.ValueString()
+	content, contentSpecified, err := resourceFileContent(&data)
 	if err != nil {
-		return diag.Errorf("content error, %v", err)
+		resp.Diagnostics.AddError("content error", err.Error())
+		return
 	}
 
-	destination, is_directory, err := getDestination(d)
+	destination, is_directory, err := getDestination(&data)
 	if err != nil {
-		return diag.Errorf("finding destination, %v", err)
+		resp.Diagnostics.AddError("finding destination", err.Error())
+		return
 	}
 
-	dirPerm := d.Get("directory_permission").(string)
+	dirPerm := data.DirectoryPermission.ValueString()
 	dirMode, _ := strconv.ParseInt(dirPerm, 8, 64)
 
 	destinationDir := path.Dir(destination)
-	if _, err := os.Stat(destinationDir); err != nil {
-		if err := os.MkdirAll(destinationDir, os.FileMode(dirMode)); err != nil {
-			return diag.Errorf("cannot create parent directories, %v", err)
+	if _, err := host.Stat(destinationDir); err != nil {
+		if err := host.MkdirAll(destinationDir, os.FileMode(dirMode)); err != nil {
+			resp.Diagnostics.AddError("cannot create parent directories", err.Error())
+			return
 		}
 	}
 
-	filePerm := d.Get("file_permission").(string)
+	filePerm := data.FilePermission.ValueString()
 	fileMode, _ := strconv.ParseInt(filePerm, 8, 64)
 
-	if sourceSpecified {
+	if source != "" {
 		if !forceOverwrite {
-			if _, err := os.Lstat(destination); err == nil || !os.IsNotExist(err) {
-				return diag.Errorf("destination exists at %v", destination)
+			if _, err := host.Lstat(destination); err == nil || !os.IsNotExist(err) {
+				resp.Diagnostics.AddError("destination exists at "+destination, "")
+				return
 			}
 		}
 		if forceOverwrite && clearDestination && is_directory {
-			err := os.RemoveAll(destination)
+			err := host.RemoveAll(destination)
 			if err != nil {
-				return diag.Errorf("cannot delete target directory, %v", err)
+				resp.Diagnostics.AddError("cannot delete target directory", err.Error())
+				return
 			}
 		} else if unlinkBeforeCreate {
-		        err := os.Remove(destination)
-			if err != nil && ! os.IsNotExist(err) {
-				return diag.Errorf("cannot unlink target before creation, %v", err)
+			err := host.Remove(destination)
+			if err != nil && !os.IsNotExist(err) {
+				resp.Diagnostics.AddError("cannot unlink target before creation", err.Error())
+				return
 			}
 		}
 		get := &getter.Client{
@@ -383,76 +449,99 @@ func resourceFileCreate(ctx context.Context, d *schema.ResourceData, _ interface
 			mode = getter.ModeAny
 		}
 
+		// Remote hosts get a copy of the source fetched locally
+		fetchDestination := destination
+		if host.Remote() {
+			if symlink_destination {
+				resp.Diagnostics.AddError("symlink_destination is only supported on the local host", "")
+				return
+			}
+			tmp, err := ioutil.TempDir("", "terraform-provider-sys-file")
+			if err != nil {
+				resp.Diagnostics.AddError("cannot create temporary directory", err.Error())
+				return
+			}
+			defer os.RemoveAll(tmp)
+			fetchDestination = path.Join(tmp, path.Base(destination))
+		}
+
 		_, err = get.Get(ctx, &getter.Request{
-			Src:     source.(string),
-			Dst:     destination,
+			Src:     source,
+			Dst:     fetchDestination,
 			GetMode: mode,
 			Copy:    !symlink_destination,
 		})
 
 		if err != nil {
-			return diag.Errorf("cannot fetch source %v, %v", source, err)
+			resp.Diagnostics.AddError("cannot fetch source "+source, err.Error())
+			return
+		}
+
+		if fetchDestination != destination {
+			if err := host.Upload(fetchDestination, destination); err != nil {
+				resp.Diagnostics.AddError("cannot upload source "+source, err.Error())
+				return
+			}
 		}
 	}
 
 	if contentSpecified {
-		data := []byte(content)
 		flags := os.O_WRONLY | os.O_CREATE
 		if forceOverwrite {
 			flags = flags | os.O_EXCL
 		} else {
 			flags = flags | os.O_TRUNC
 		}
-		f, err := os.OpenFile(destination, flags, os.FileMode(fileMode))
-		if err != nil {
-			return diag.Errorf("cannot write file, %v", err)
-		}
-		n, err := f.Write(data)
-		if err == nil && n < len(data) {
-			err = io.ErrShortWrite
-		}
-		if err1 := f.Close(); err == nil {
-			err = err1
-		}
+		err := host.WriteFile(destination, content, flags, os.FileMode(fileMode))
 		if err != nil {
-			return diag.Errorf("cannot write file, %v", err)
+			resp.Diagnostics.AddError("cannot write file", err.Error())
+			return
 		}
 
-		checksum := sha1.Sum([]byte(content))
-		d.SetId(hex.EncodeToString(checksum[:]))
+		checksum := sha1.Sum(content)
+		data.Id = types.StringValue(hex.EncodeToString(checksum[:]))
 	} else {
 		if is_directory {
-			err = os.Chmod(destination, os.FileMode(dirMode))
+			err = host.Chmod(destination, os.FileMode(dirMode))
 		} else {
-			err = os.Chmod(destination, os.FileMode(fileMode))
+			err = host.Chmod(destination, os.FileMode(fileMode))
 		}
 		if err != nil {
-			return diag.Errorf("cannot chmod %s, %v", filePerm, err)
+			resp.Diagnostics.AddError("cannot chmod "+filePerm, err.Error())
+			return
 		}
-		id, err := checksumFile(destination)
+		id, err := checksumFile(host, destination)
 		if err != nil {
-			return diag.Errorf("cannot checksum file %s, %v", destination, err)
+			resp.Diagnostics.AddError("cannot checksum file "+destination, err.Error())
+			return
 		}
-		d.SetId(id)
+		data.Id = types.StringValue(id)
 	}
 
-	return nil
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
 }
 
-func resourceFileDelete(ctx context.Context, d *schema.ResourceData, _ interface{}) diag.Diagnostics {
-	if filename := d.Get("filename").(string); filename != "" {
-		err := os.Remove(filename)
+func (r *fileResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	var data fileResourceModel
+
+	resp.Diagnostics.Append(req.State.Get(ctx, &data)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	if filename := data.Filename.ValueString(); filename != "" {
+		err := r.host.Remove(filename)
 		if err != nil {
-			return diag.Errorf("cannot delete file, %v", err)
+			resp.Diagnostics.AddError("cannot delete file", err.Error())
+			return
 		}
 	}
 
-	if target_directory := d.Get("target_directory").(string); target_directory != "" {
-		err := os.RemoveAll(target_directory)
+	if target_directory := data.TargetDirectory.ValueString(); target_directory != "" {
+		err := r.host.RemoveAll(target_directory)
 		if err != nil {
-			return diag.Errorf("cannot delete target directory, %v", err)
+			resp.Diagnostics.AddError("cannot delete target directory", err.Error())
 		}
 	}
-
-	return nil
 }
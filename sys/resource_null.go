@@ -1,63 +1,108 @@
 package sys
 
 import (
+	"context"
 	"fmt"
 	"math/rand"
 	"time"
 
-	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/mapplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/types"
 )
 
 func init() {
 	rand.Seed(time.Now().Unix())
 }
 
-func resourceNull() *schema.Resource {
-	return &schema.Resource{
-		Create: resourceNullCreate,
-		Read:   resourceNullRead,
-		Delete: resourceNullDelete,
-		Update: resourceNullUpdate,
+type nullResource struct{}
 
-		Schema: map[string]*schema.Schema{
-			"triggers": &schema.Schema{
-				Type:     schema.TypeMap,
-				Optional: true,
-				ForceNew: true,
+type nullResourceModel struct {
+	Id       types.String `tfsdk:"id"`
+	Triggers types.Map    `tfsdk:"triggers"`
+	Values   types.Map    `tfsdk:"values"`
+	Inputs   types.Map    `tfsdk:"inputs"`
+	Outputs  types.Map    `tfsdk:"outputs"`
+}
+
+func newNullResource() resource.Resource {
+	return &nullResource{}
+}
+
+func (r *nullResource) Metadata(ctx context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_null"
+}
+
+func (r *nullResource) Schema(ctx context.Context, req resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		Version: 1,
+		Attributes: map[string]schema.Attribute{
+			"id": schema.StringAttribute{
+				Computed:      true,
+				Description:   "The ID of this resource.",
+				PlanModifiers: []planmodifier.String{stringplanmodifier.UseStateForUnknown()},
+			},
+			"triggers": schema.MapAttribute{
+				ElementType:   types.StringType,
+				Optional:      true,
+				PlanModifiers: []planmodifier.Map{mapplanmodifier.RequiresReplace()},
 			},
-			"values": &schema.Schema{
-				Type:     schema.TypeMap,
-				Optional: true,
+			"values": schema.MapAttribute{
+				ElementType: types.StringType,
+				Optional:    true,
 			},
-			"inputs": &schema.Schema{
-				Type:     schema.TypeMap,
-				Optional: true,
+			"inputs": schema.MapAttribute{
+				ElementType: types.StringType,
+				Optional:    true,
 			},
-			"outputs": &schema.Schema{
-				Type:     schema.TypeMap,
-				Computed: true,
+			"outputs": schema.MapAttribute{
+				ElementType: types.StringType,
+				Computed:    true,
+				// outputs are copied from inputs on creation only
+				PlanModifiers: []planmodifier.Map{mapplanmodifier.UseStateForUnknown()},
 			},
 		},
 	}
 }
 
-func resourceNullCreate(d *schema.ResourceData, meta interface{}) error {
-	id := fmt.Sprintf("%d", rand.Int())
-	d.SetId(id)
-	d.Set("outputs", d.Get("inputs"))
-	d.Set("triggers", d.Get("triggers"))
-	return nil
+func (r *nullResource) UpgradeState(ctx context.Context) map[int64]resource.StateUpgrader {
+	return sdkStateUpgraders(ctx, r)
+}
+
+func (r *nullResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	var data nullResourceModel
+
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &data)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	data.Id = types.StringValue(fmt.Sprintf("%d", rand.Int()))
+	data.Outputs = data.Inputs
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
 }
 
-func resourceNullRead(d *schema.ResourceData, meta interface{}) error {
-	return nil
+func (r *nullResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
 }
 
-func resourceNullUpdate(d *schema.ResourceData, meta interface{}) error {
-	return nil
+func (r *nullResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	var data, state nullResourceModel
+
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &data)...)
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	data.Outputs = state.Outputs
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
 }
 
-func resourceNullDelete(d *schema.ResourceData, meta interface{}) error {
-	d.SetId("")
-	return nil
+func (r *nullResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
 }
@@ -1,193 +1,803 @@
 package sys
 
 import (
-	"bufio"
-	"bytes"
 	"context"
+	"crypto/sha1"
+	"encoding/hex"
 	"fmt"
-	"os"
-	"os/exec"
+	"reflect"
+	"sort"
 	"strings"
-	"sync"
 
-	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
-	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/booldefault"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/boolplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/mapplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringdefault"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
 )
 
-var debLock sync.Mutex
+const (
+	packageEnsurePresent = "present"
+	packageEnsureLatest  = "latest"
+)
+
+type packageResource struct {
+	config *providerConfiguration
+}
+
+type packageResourceModel struct {
+	Id                types.String      `tfsdk:"id"`
+	Type              types.String      `tfsdk:"type"`
+	Name              types.String      `tfsdk:"name"`
+	Names             []string          `tfsdk:"names"`
+	Source            types.String      `tfsdk:"source"`
+	Checksum          types.String      `tfsdk:"checksum"`
+	Version           types.String      `tfsdk:"version"`
+	Versions          map[string]string `tfsdk:"versions"`
+	TargetRelease     types.String      `tfsdk:"target_release"`
+	Ensure            types.String      `tfsdk:"ensure"`
+	Hold              types.Bool        `tfsdk:"hold"`
+	Virtualenv        types.String      `tfsdk:"virtualenv"`
+	Prefix            types.String      `tfsdk:"prefix"`
+	User              types.Bool        `tfsdk:"user"`
+	PackageName       types.String      `tfsdk:"package_name"`
+	InstalledVersion  types.String      `tfsdk:"installed_version"`
+	InstalledVersions types.Map         `tfsdk:"installed_versions"`
+}
+
+func newPackageResource() resource.Resource {
+	return &packageResource{}
+}
+
+func (r *packageResource) Metadata(ctx context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_package"
+}
+
+func (r *packageResource) Configure(ctx context.Context, req resource.ConfigureRequest, resp *resource.ConfigureResponse) {
+	r.config, _ = req.ProviderData.(*providerConfiguration)
+}
 
-func resourcePackage() *schema.Resource {
-	return &schema.Resource{
-		CreateContext: resourcePackageCreate,
-		ReadContext:   resourcePackageRead,
-		DeleteContext: resourcePackageDelete,
+func (r *packageResource) Schema(ctx context.Context, req resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		Description: "Installs a package with the system or a language package manager.",
+		Version:     1,
 
-		Schema: map[string]*schema.Schema{
-			"type": {
-				Type:     schema.TypeString,
-				Required: true,
-				ForceNew: true,
+		Attributes: map[string]schema.Attribute{
+			"id": schema.StringAttribute{
+				Computed:      true,
+				Description:   "The ID of this resource.",
+				PlanModifiers: []planmodifier.String{stringplanmodifier.UseStateForUnknown()},
 			},
-			"name": {
-				Type:     schema.TypeString,
-				Required: true,
-				ForceNew: true,
+			"type": schema.StringAttribute{
+				Description:   "Package type: deb, rpm (dnf), apk, pacman, zypper, auto to detect it from /etc/os-release, or the language package managers pip, npm, gem and cargo",
+				Required:      true,
+				PlanModifiers: []planmodifier.String{stringplanmodifier.RequiresReplace()},
 			},
-			"version": {
-				Type:     schema.TypeString,
-				Optional: true,
-				ForceNew: true,
+			"name": schema.StringAttribute{
+				Description: "Package name, conflicts with `names`. Read from the package file if `source` is given",
+				Optional:    true,
+				Computed:    true,
+			},
+			"names": schema.SetAttribute{
+				Description: "Package names to install in a single transaction, conflicts with `name`",
+				ElementType: types.StringType,
+				Optional:    true,
+			},
+			"source": schema.StringAttribute{
+				Description: "Package file to install (.deb, .rpm, ...), compatible with go-getter. The package is reinstalled when the file version changes",
+				Optional:    true,
+			},
+			"checksum": schema.StringAttribute{
+				Description: "Checksum of the `source` file verified by go-getter (e.g. \"sha256:...\")",
+				Optional:    true,
+			},
+			"version": schema.StringAttribute{
+				Description: "Version to install, the package is upgraded or downgraded in place when it changes",
+				Optional:    true,
 			},
-			"target_release": {
-				Type:     schema.TypeString,
+			"versions": schema.MapAttribute{
+				Description: "Versions to install for each package in `names`",
+				ElementType: types.StringType,
+				Optional:    true,
+			},
+			"target_release": schema.StringAttribute{
 				Optional: true,
-				ForceNew: true,
 			},
-			"installed_version": {
-				Type:     schema.TypeString,
-				Computed: true,
+			"ensure": schema.StringAttribute{
+				Description: "(default: \"present\") Set to \"latest\" to upgrade the package whenever a newer candidate version is available",
+				Optional:    true,
+				Computed:    true,
+				Default:     stringdefault.StaticString(packageEnsurePresent),
+				Validators:  []validator.String{stringOneOf{packageEnsurePresent, packageEnsureLatest}},
+			},
+			"hold": schema.BoolAttribute{
+				Description: "Prevent automatic upgrades of the package (apt-mark hold, dnf versionlock, zypper addlock or apk version pinning)",
+				Optional:    true,
+				Computed:    true,
+				Default:     booldefault.StaticBool(false),
+			},
+			"virtualenv": schema.StringAttribute{
+				Description:   "Python virtualenv where pip packages are installed",
+				Optional:      true,
+				PlanModifiers: []planmodifier.String{stringplanmodifier.RequiresReplace()},
+			},
+			"prefix": schema.StringAttribute{
+				Description:   "Installation prefix for npm (--prefix), gem (GEM_HOME) and cargo (--root) packages",
+				Optional:      true,
+				PlanModifiers: []planmodifier.String{stringplanmodifier.RequiresReplace()},
+			},
+			"user": schema.BoolAttribute{
+				Description:   "Install pip and gem packages in the user directory",
+				Optional:      true,
+				PlanModifiers: []planmodifier.Bool{boolplanmodifier.RequiresReplace()},
+			},
+			"package_name": schema.StringAttribute{
+				Description:   "Name of the package given in `name` as reported by the package manager, which may differ in case or punctuation",
+				Computed:      true,
+				PlanModifiers: []planmodifier.String{stringplanmodifier.UseStateForUnknown()},
+			},
+			"installed_version": schema.StringAttribute{
+				Description:   "Installed version of the package given in `name`",
+				Computed:      true,
+				PlanModifiers: []planmodifier.String{stringplanmodifier.UseStateForUnknown()},
+			},
+			"installed_versions": schema.MapAttribute{
+				Description:   "Installed version of each package",
+				ElementType:   types.StringType,
+				Computed:      true,
+				PlanModifiers: []planmodifier.Map{mapplanmodifier.UseStateForUnknown()},
 			},
 		},
 	}
 }
 
-func debRead(d *schema.ResourceData, m *providerConfiguration, lock bool) diag.Diagnostics {
-	if lock {
-		debLock.Lock()
-		defer debLock.Unlock()
+func (r *packageResource) ConfigValidators(ctx context.Context) []resource.ConfigValidator {
+	return []resource.ConfigValidator{
+		atLeastOneOfAttributes{"name", "names", "source"},
+		conflictingAttributes{"name", "names"},
+		conflictingAttributes{"names", "source"},
+		conflictingAttributes{"source", "version"},
+		conflictingAttributes{"source", "target_release"},
+		conflictingAttributes{"version", "names"},
+		conflictingAttributes{"versions", "name"},
+		requiredWithAttributes{"checksum", "source"},
 	}
+}
+
+func (r *packageResource) UpgradeState(ctx context.Context) map[int64]resource.StateUpgrader {
+	return sdkStateUpgraders(ctx, r)
+}
 
-	stdout := new(bytes.Buffer)
-	stderr := new(bytes.Buffer)
+// pkgUpdated tells if the package cache was updated for this package type
+func (c *providerConfiguration) pkgUpdated(t string) bool {
+	c.Lock.Lock()
+	defer c.Lock.Unlock()
+	return c.PkgUpdated[t]
+}
+
+func (c *providerConfiguration) setPkgUpdated(t string, updated bool) {
+	c.Lock.Lock()
+	defer c.Lock.Unlock()
+	if c.PkgUpdated == nil {
+		c.PkgUpdated = map[string]bool{}
+	}
+	c.PkgUpdated[t] = updated
+}
 
-	cmd := exec.Command("dpkg", "-s", d.Get("name").(string))
-	cmd.Stdout = stdout
-	cmd.Stderr = stderr
+// packageUpdateCache updates the package cache once per provider run, the
+// backend lock must be held.
+func packageUpdateCache(c *providerConfiguration, t string, backend packageBackend) error {
+	if c.pkgUpdated(t) {
+		return nil
+	}
 
-	err := cmd.Run()
+	err := backend.UpdateCache()
 	if err != nil {
-		if er := err.(*exec.ExitError); er != nil {
-			d.SetId("")
-		} else {
-			return diag.Errorf("cannot query dpkg -s: %v\n%s", err, stderr.String())
+		return err
+	}
+
+	c.setPkgUpdated(t, true)
+	return nil
+}
+
+func (data *packageResourceModel) scope() packageScope {
+	return packageScope{
+		Virtualenv: data.Virtualenv.ValueString(),
+		Prefix:     data.Prefix.ValueString(),
+		User:       data.User.ValueBool(),
+	}
+}
+
+func (data *packageResourceModel) backend(c *providerConfiguration) (string, packageBackend, error) {
+	var runner commandRunner
+	if c != nil {
+		runner = c.PkgRunner
+	}
+	return packageBackendFor(data.Type.ValueString(), data.scope(), runner, providerHost(c))
+}
+
+// specs returns the packages managed by the resource given either a single
+// name and version or a set of names with their versions.
+func (data *packageResourceModel) specs() map[string]*packageSpec {
+	var specs = map[string]*packageSpec{}
+	targetRelease := data.TargetRelease.ValueString()
+
+	if name := data.Name.ValueString(); name != "" {
+		specs[name] = &packageSpec{
+			Name:          name,
+			Version:       data.Version.ValueString(),
+			TargetRelease: targetRelease,
 		}
-	} else {
-		var name, version string
+	}
 
-		r := bufio.NewScanner(stdout)
-		for r.Scan() {
-			line := r.Text()
-			parts := strings.SplitN(line, ":", 2)
-			if len(parts) < 2 {
-				continue
-			}
-			value := strings.TrimSpace(parts[1])
-
-			switch key := parts[0]; key {
-			case "Package":
-				d.Set("name", value)
-				name = value
-			case "Version":
-				d.Set("installed_version", value)
-				version = value
+	for _, name := range data.Names {
+		specs[name] = &packageSpec{
+			Name:          name,
+			Version:       data.Versions[name],
+			TargetRelease: targetRelease,
+		}
+	}
+
+	return specs
+}
+
+func packageSpecsList(specs map[string]*packageSpec) []*packageSpec {
+	var list []*packageSpec
+	for _, name := range packageSpecsNames(specs) {
+		list = append(list, specs[name])
+	}
+	return list
+}
+
+func packageSpecsNames(specs map[string]*packageSpec) []string {
+	var names []string
+	for name := range specs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// packageRead reads the installed packages, it returns false if none is
+// installed. The drift from the wanted versions and the missing packages are
+// only recorded on refresh, for the plan to install them again.
+func packageRead(ctx context.Context, data *packageResourceModel, backend packageBackend, refresh bool) (bool, error) {
+	if name := data.Name.ValueString(); name != "" {
+		info, err := backend.Query(name)
+		if err != nil || info == nil {
+			return false, err
+		}
+
+		// Let the plan reinstall the wanted version if it drifted
+		if refresh && !packageVersionMatches(data.Version.ValueString(), info.Version) {
+			data.Version = types.StringValue(info.Version)
+		}
+
+		// The configured name is kept, the package manager may report it
+		// differently
+		data.PackageName = types.StringValue(info.Name)
+		data.InstalledVersion = types.StringValue(info.Version)
+		data.InstalledVersions, _ = types.MapValueFrom(ctx, types.StringType, map[string]string{name: info.Version})
+		data.Id = types.StringValue(fmt.Sprintf("%s_%s", info.Name, info.Version))
+		return true, nil
+	}
+
+	specs := data.specs()
+	var names []string
+	var installed = map[string]string{}
+	var ids []string
+
+	for _, name := range packageSpecsNames(specs) {
+		info, err := backend.Query(name)
+		if err != nil {
+			return false, err
+		} else if info == nil {
+			continue
+		}
+
+		if refresh && !packageVersionMatches(specs[name].Version, info.Version) {
+			if data.Versions == nil {
+				data.Versions = map[string]string{}
 			}
+			data.Versions[name] = info.Version
+		}
+
+		names = append(names, name)
+		installed[name] = info.Version
+		ids = append(ids, fmt.Sprintf("%s_%s", name, info.Version))
+	}
+
+	if len(installed) == 0 {
+		return false, nil
+	}
+
+	// Missing packages are removed from the state to be reinstalled
+	if refresh {
+		data.Names = names
+	}
+	if data.Name.IsUnknown() {
+		data.Name = types.StringNull()
+	}
+	data.PackageName = types.StringNull()
+	data.InstalledVersion = types.StringNull()
+	data.InstalledVersions, _ = types.MapValueFrom(ctx, types.StringType, installed)
+
+	checksum := sha1.Sum([]byte(strings.Join(ids, "\n")))
+	data.Id = types.StringValue(hex.EncodeToString(checksum[:]))
+	return true, nil
+}
+
+func (r *packageResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	var data packageResourceModel
+
+	resp.Diagnostics.Append(req.State.Get(ctx, &data)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	_, backend, err := data.backend(r.config)
+	if err != nil {
+		resp.Diagnostics.AddError("cannot read the packages", err.Error())
+		return
+	}
+
+	lock := backend.Lock()
+	lock.Lock()
+	defer lock.Unlock()
+
+	found, err := packageRead(ctx, &data, backend, true)
+	if err != nil {
+		resp.Diagnostics.AddError("cannot read the packages", err.Error())
+		return
+	} else if !found {
+		resp.State.RemoveResource(ctx)
+		return
+	}
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+func (r *packageResource) ModifyPlan(ctx context.Context, req resource.ModifyPlanRequest, resp *resource.ModifyPlanResponse) {
+	if req.Plan.Raw.IsNull() {
+		return
+	}
+
+	var ensure, version types.String
+	var versions types.Map
+	resp.Diagnostics.Append(resp.Plan.GetAttribute(ctx, path.Root("ensure"), &ensure)...)
+	resp.Diagnostics.Append(resp.Plan.GetAttribute(ctx, path.Root("version"), &version)...)
+	resp.Diagnostics.Append(resp.Plan.GetAttribute(ctx, path.Root("versions"), &versions)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	if ensure.ValueString() == packageEnsureLatest && (version.ValueString() != "" || len(versions.Elements()) > 0) {
+		resp.Diagnostics.AddAttributeError(path.Root("ensure"), "Invalid ensure",
+			fmt.Sprintf("ensure = \"%s\" cannot be used with versions", ensure.ValueString()))
+		return
+	}
+
+	if req.State.Raw.IsNull() {
+		return
+	}
+
+	// The name read from the source is kept
+	var name, stateName types.String
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root("name"), &name)...)
+	resp.Diagnostics.Append(req.State.GetAttribute(ctx, path.Root("name"), &stateName)...)
+	if name.IsNull() {
+		resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, path.Root("name"), stateName)...)
+	}
+
+	if !planKnown(ctx, resp.Plan, "names", "versions") {
+		resp.Plan.SetAttribute(ctx, path.Root("id"), types.StringUnknown())
+		resp.Plan.SetAttribute(ctx, path.Root("installed_version"), types.StringUnknown())
+		resp.Plan.SetAttribute(ctx, path.Root("installed_versions"), types.MapUnknown(types.StringType))
+		return
+	}
+
+	var data, state packageResourceModel
+	resp.Diagnostics.Append(resp.Plan.Get(ctx, &data)...)
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	if err := r.plan(ctx, &data, &state, !name.IsNull()); err != nil {
+		resp.Diagnostics.AddError("cannot plan the packages", err.Error())
+		return
+	}
+
+	if !data.Name.Equal(state.Name) {
+		resp.RequiresReplace = append(resp.RequiresReplace, path.Root("name"))
+	}
+	if !data.InstalledVersion.Equal(state.InstalledVersion) || !data.InstalledVersions.Equal(state.InstalledVersions) {
+		data.Id = types.StringUnknown()
+	}
+
+	resp.Diagnostics.Append(resp.Plan.Set(ctx, &data)...)
+}
+
+// plan plans the installed versions of an existing resource: the source file
+// version, the wanted versions or the candidates of the latest versions.
+func (r *packageResource) plan(ctx context.Context, data, state *packageResourceModel, hasName bool) error {
+	if data.Source.ValueString() != "" {
+		return r.planSource(ctx, data, state, hasName)
+	}
+
+	if !reflect.DeepEqual(data.specs(), state.specs()) {
+		data.InstalledVersion = types.StringUnknown()
+		data.InstalledVersions = types.MapUnknown(types.StringType)
+		return nil
+	}
+
+	if data.Ensure.ValueString() != packageEnsureLatest {
+		return nil
+	}
+
+	t, backend, err := data.backend(r.config)
+	if err != nil {
+		return err
+	}
+
+	lock := backend.Lock()
+	lock.Lock()
+	defer lock.Unlock()
+
+	err = packageUpdateCache(r.config, t, backend)
+	if err != nil {
+		return err
+	}
+
+	var installed map[string]string
+	state.InstalledVersions.ElementsAs(ctx, &installed, false)
+	candidates := map[string]string{}
+	outdated := false
+	for name, version := range installed {
+		candidate, err := backend.Candidate(name)
+		if err != nil {
+			return err
 		}
-		if err := r.Err(); err != nil {
-			return diag.Errorf("cannot parse dpkg -s results: %v\n%s", err, stdout.String())
-		} else if name == "" || version == "" {
-			return diag.Errorf("cannot parse dpkg -s results (name: %s, version: %s):\n%s", name, version, stdout.String())
+
+		if candidate != "" && candidate != version {
+			outdated = true
+			candidates[name] = candidate
+		} else {
+			candidates[name] = version
 		}
-		d.SetId(fmt.Sprintf("%s_%s", name, version))
 	}
+
+	if !outdated {
+		return nil
+	}
+
+	if name := data.Name.ValueString(); name != "" {
+		data.InstalledVersion = types.StringValue(candidates[name])
+	}
+	data.InstalledVersions, _ = types.MapValueFrom(ctx, types.StringType, candidates)
 	return nil
 }
 
-func resourcePackageRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
-	switch t := d.Get("type").(string); t {
-	case "deb":
-		return debRead(d, m.(*providerConfiguration), true)
-	default:
-		return diag.Errorf("Unknown package type %s", t)
+// planSource detects when the source package file version differs from the
+// installed version.
+func (r *packageResource) planSource(ctx context.Context, data, state *packageResourceModel, hasName bool) error {
+	_, backend, err := data.backend(r.config)
+	if err != nil {
+		return err
+	}
+
+	source := data.Source.ValueString()
+	filename, cleanup, err := packageFetch(ctx, providerHost(r.config), source, data.Checksum.ValueString())
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	info, err := backend.QueryFile(filename)
+	if err != nil {
+		return err
+	}
+
+	if info.Name != data.Name.ValueString() {
+		if hasName {
+			return fmt.Errorf("package %s from %s does not match name %s", info.Name, source, data.Name.ValueString())
+		}
+		data.Name = types.StringValue(info.Name)
+	}
+
+	if info.Version != state.InstalledVersion.ValueString() {
+		data.InstalledVersion = types.StringValue(info.Version)
+		data.InstalledVersions = types.MapUnknown(types.StringType)
+	}
+
+	return nil
+}
+
+// installSource fetches and installs the source package file, the
+// backend lock must be held.
+func (r *packageResource) installSource(ctx context.Context, data *packageResourceModel, backend packageBackend) error {
+	source := data.Source.ValueString()
+	filename, cleanup, err := packageFetch(ctx, providerHost(r.config), source, data.Checksum.ValueString())
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	info, err := backend.QueryFile(filename)
+	if err != nil {
+		return err
+	}
+
+	if name := data.Name.ValueString(); name != "" && name != info.Name {
+		return fmt.Errorf("package %s from %s does not match name %s", info.Name, source, name)
+	} else if name == "" {
+		data.Name = types.StringValue(info.Name)
+	}
+
+	return backend.InstallFile(filename)
+}
+
+func (r *packageResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	var data packageResourceModel
+
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &data)...)
+
+	if resp.Diagnostics.HasError() {
+		return
 	}
+
+	if err := r.create(ctx, &data); err != nil {
+		resp.Diagnostics.AddError("cannot install the packages", err.Error())
+		return
+	}
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
 }
 
-func debCreate(d *schema.ResourceData, m *providerConfiguration) diag.Diagnostics {
-	debLock.Lock()
-	defer debLock.Unlock()
+func (r *packageResource) create(ctx context.Context, data *packageResourceModel) error {
+	c := r.config
+	t, backend, err := data.backend(c)
+	if err != nil {
+		return err
+	}
+
+	if data.Source.ValueString() != "" {
+		lock := backend.Lock()
+		lock.Lock()
+		defer lock.Unlock()
 
-	if !m.debUpdated {
-		stderr := new(bytes.Buffer)
-		cmd := exec.Command("apt-get", "update")
-		cmd.Stderr = stderr
-		//cmd.Stdout = os.Stdout
-		err := cmd.Run()
+		err = r.installSource(ctx, data, backend)
 		if err != nil {
-			return diag.Errorf("Error running apt-get update: %s\n%s", err.Error(), stderr.String())
+			return err
 		}
-		m.debUpdated = true
+
+		if data.Hold.ValueBool() {
+			err = backend.Hold(true, data.Name.ValueString())
+			if err != nil {
+				return err
+			}
+		}
+
+		return packageReadInstalled(ctx, data, backend)
 	}
 
-	pkgspec := d.Get("name").(string)
-	version := d.Get("version")
-	if version != nil && version.(string) != "" {
-		pkgspec = fmt.Sprintf("%s=%s", pkgspec, version)
+	specs := data.specs()
+
+	if c.PkgBatchWindow > 0 {
+		err = packageInstallBatched(c, t, data.scope(), backend, packageSpecsList(specs))
+		if err != nil {
+			return err
+		}
 	}
 
-	target_release := d.Get("target_release")
-	if target_release != nil && target_release.(string) != "" {
-		pkgspec = fmt.Sprintf("%s/%s", pkgspec, target_release)
+	lock := backend.Lock()
+	lock.Lock()
+	defer lock.Unlock()
+
+	if c.PkgBatchWindow <= 0 {
+		err = packageUpdateCache(c, t, backend)
+		if err != nil {
+			return err
+		}
+
+		err = backend.Install(packageSpecsList(specs)...)
+		if err != nil {
+			return err
+		}
 	}
 
-	stderr := new(bytes.Buffer)
-	cmd := exec.Command("apt-get", "install", "-y", pkgspec)
-	cmd.Stderr = stderr
-	//cmd.Stdout = os.Stdout
-	cmd.Env = append(os.Environ(),
-		"DEBIAN_FRONTEND=noninteractive",
-	)
-	err := cmd.Run()
-	if err != nil {
-		return diag.Errorf("Error running apt-get install %s: %s\n%s", pkgspec, err.Error(), stderr.String())
+	if data.Hold.ValueBool() {
+		err = backend.Hold(true, packageSpecsNames(specs)...)
+		if err != nil {
+			return err
+		}
 	}
 
-	return debRead(d, m, false)
+	return packageReadInstalled(ctx, data, backend)
 }
 
-func resourcePackageCreate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
-	switch t := d.Get("type").(string); t {
-	case "deb":
-		return debCreate(d, m.(*providerConfiguration))
-	default:
-		return diag.Errorf("Unknown package type %s", t)
+// packageReadInstalled reads the packages once installed
+func packageReadInstalled(ctx context.Context, data *packageResourceModel, backend packageBackend) error {
+	found, err := packageRead(ctx, data, backend, false)
+	if err != nil {
+		return err
+	} else if !found {
+		return fmt.Errorf("%s not installed", strings.Join(packageSpecsNames(data.specs()), ", "))
 	}
+	return nil
 }
 
-func debDelete(d *schema.ResourceData, m *providerConfiguration) diag.Diagnostics {
-	debLock.Lock()
-	defer debLock.Unlock()
+func (r *packageResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	var data, state packageResourceModel
+
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &data)...)
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	if err := r.update(ctx, &data, &state); err != nil {
+		resp.Diagnostics.AddError("cannot update the packages", err.Error())
+		return
+	}
 
-	stderr := new(bytes.Buffer)
-	pkgspec := d.Get("name").(string)
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
 
-	cmd := exec.Command("apt-get", "remove", "-y")
-	cmd.Stderr = stderr
-	//cmd.Stdout = os.Stdout
-	cmd.Env = append(os.Environ(),
-		"DEBIAN_FRONTEND=noninteractive",
-	)
-	err := cmd.Run()
+func (r *packageResource) update(ctx context.Context, data, state *packageResourceModel) error {
+	t, backend, err := data.backend(r.config)
 	if err != nil {
-		return diag.Errorf("Error running apt-get remove %s: %s\n%s", pkgspec, err.Error(), stderr.String())
+		return err
+	}
+
+	lock := backend.Lock()
+	lock.Lock()
+	defer lock.Unlock()
+
+	old_specs := state.specs()
+	specs := data.specs()
+	old_hold := state.Hold.ValueBool()
+	hold := data.Hold.ValueBool()
+	holdChanged := !data.Hold.Equal(state.Hold)
+
+	var removed []string
+	for name := range old_specs {
+		if _, ok := specs[name]; !ok {
+			removed = append(removed, name)
+		}
 	}
+	sort.Strings(removed)
 
-	return debRead(d, m, false)
+	var changed []*packageSpec
+	for _, spec := range packageSpecsList(specs) {
+		if old, ok := old_specs[spec.Name]; !ok || *old != *spec {
+			changed = append(changed, spec)
+		}
+	}
+
+	has_source := data.Source.ValueString() != ""
+	reinstalled := false
+	if has_source {
+		changed = nil
+		if !data.Source.Equal(state.Source) || !data.Checksum.Equal(state.Checksum) || !data.InstalledVersion.Equal(state.InstalledVersion) {
+			if old_hold {
+				err = backend.Hold(false, data.Name.ValueString())
+				if err != nil {
+					return err
+				}
+			}
+
+			err = r.installSource(ctx, data, backend)
+			if err != nil {
+				return err
+			}
+			reinstalled = true
+		}
+	}
+
+	var upgraded []string
+	if !has_source && len(changed) == 0 && data.Ensure.ValueString() == packageEnsureLatest && !data.InstalledVersions.Equal(state.InstalledVersions) {
+		upgraded = packageSpecsNames(specs)
+	}
+
+	if len(removed) > 0 {
+		if old_hold {
+			err = backend.Hold(false, removed...)
+			if err != nil {
+				return err
+			}
+		}
+
+		err = backend.Remove(removed...)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(changed) > 0 || len(upgraded) > 0 {
+		err = packageUpdateCache(r.config, t, backend)
+		if err != nil {
+			return err
+		}
+
+		// A held package cannot change version
+		if old_hold {
+			var names []string
+			for _, spec := range changed {
+				if _, ok := old_specs[spec.Name]; ok {
+					names = append(names, spec.Name)
+				}
+			}
+			names = append(names, upgraded...)
+			if len(names) > 0 {
+				err = backend.Hold(false, names...)
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		if len(changed) > 0 {
+			err = backend.Install(changed...)
+		} else {
+			err = backend.Upgrade(upgraded...)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if hold && (reinstalled || len(changed) > 0 || len(upgraded) > 0 || holdChanged) {
+		err = backend.Hold(true, packageSpecsNames(specs)...)
+	} else if !hold && holdChanged {
+		err = backend.Hold(false, packageSpecsNames(specs)...)
+	}
+	if err != nil {
+		return err
+	}
+
+	return packageReadInstalled(ctx, data, backend)
 }
 
-func resourcePackageDelete(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
-	switch t := d.Get("type").(string); t {
-	case "deb":
-		return debDelete(d, m.(*providerConfiguration))
-	default:
-		return diag.Errorf("Unknown package type %s", t)
+func (r *packageResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	var data packageResourceModel
+
+	resp.Diagnostics.Append(req.State.Get(ctx, &data)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	_, backend, err := data.backend(r.config)
+	if err != nil {
+		resp.Diagnostics.AddError("cannot remove the packages", err.Error())
+		return
+	}
+
+	lock := backend.Lock()
+	lock.Lock()
+	defer lock.Unlock()
+
+	names := packageSpecsNames(data.specs())
+
+	if data.Hold.ValueBool() {
+		err = backend.Hold(false, names...)
+		if err != nil {
+			resp.Diagnostics.AddError("cannot unhold the packages", err.Error())
+			return
+		}
+	}
+
+	err = backend.Remove(names...)
+	if err != nil {
+		resp.Diagnostics.AddError("cannot remove the packages", err.Error())
 	}
 }
@@ -0,0 +1,359 @@
+package sys
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testPackageEnv sets up the fake dpkg/apt commands from testdata/fake-deb
+// and returns the provider configuration using them.
+type testPackageEnv struct {
+	t    *testing.T
+	db   string
+	repo string
+	log  string
+	meta *providerConfiguration
+	tf   *testFrameworkEnv
+}
+
+func newTestPackageEnv(t *testing.T) *testPackageEnv {
+	fake, err := filepath.Abs("testdata/fake-deb")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	env := &testPackageEnv{
+		t:    t,
+		db:   path.Join(dir, "db"),
+		repo: path.Join(dir, "repo"),
+		log:  path.Join(dir, "log"),
+		meta: &providerConfiguration{
+			PkgRunner: &execRunner{Path: []string{fake}},
+		},
+	}
+
+	for _, d := range []string{env.db, env.repo} {
+		if err := os.Mkdir(d, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Setenv("FAKE_PKG_DB", env.db)
+	t.Setenv("FAKE_PKG_REPO", env.repo)
+	t.Setenv("FAKE_PKG_LOG", env.log)
+
+	env.tf = newTestFrameworkEnvWith(t, env.meta)
+	return env
+}
+
+func (env *testPackageEnv) write(dir, name, content string) {
+	err := ioutil.WriteFile(path.Join(dir, name), []byte(content+"\n"), 0644)
+	if err != nil {
+		env.t.Fatal(err)
+	}
+}
+
+func (env *testPackageEnv) available(name, version string) {
+	env.write(env.repo, name, version)
+}
+
+func (env *testPackageEnv) installed(name, version string) {
+	env.write(env.db, name, version)
+}
+
+func (env *testPackageEnv) installedVersion(name string) string {
+	content, err := ioutil.ReadFile(path.Join(env.db, name))
+	if os.IsNotExist(err) {
+		return ""
+	} else if err != nil {
+		env.t.Fatal(err)
+	}
+	return strings.TrimSpace(string(content))
+}
+
+func (env *testPackageEnv) held(name string) bool {
+	_, err := os.Stat(path.Join(env.db, name+".hold"))
+	return err == nil
+}
+
+// calls returns the commands recorded since the last call and clears the log
+func (env *testPackageEnv) calls() []string {
+	content, err := ioutil.ReadFile(env.log)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		env.t.Fatal(err)
+	}
+	os.Remove(env.log)
+	return strings.Split(strings.TrimSpace(string(content)), "\n")
+}
+
+func (env *testPackageEnv) expectCalls(expected ...string) {
+	env.t.Helper()
+	calls := env.calls()
+	for _, exp := range expected {
+		found := false
+		for _, call := range calls {
+			if call == exp {
+				found = true
+				break
+			}
+		}
+		if !found {
+			env.t.Fatalf("expected call %q, got %q", exp, calls)
+		}
+	}
+}
+
+func (env *testPackageEnv) apply(state, config map[string]interface{}) map[string]interface{} {
+	env.t.Helper()
+	return env.tf.mustApply("sys_package", state, config)
+}
+
+func (env *testPackageEnv) refresh(state map[string]interface{}) map[string]interface{} {
+	env.t.Helper()
+	return env.tf.refresh("sys_package", state)
+}
+
+func installedVersions(state map[string]interface{}) map[string]interface{} {
+	versions, _ := state["installed_versions"].(map[string]interface{})
+	return versions
+}
+
+func TestPackageDebLifecycle(t *testing.T) {
+	env := newTestPackageEnv(t)
+	env.available("foo", "1.0")
+
+	state := env.apply(nil, map[string]interface{}{
+		"type": "deb",
+		"name": "foo",
+	})
+	env.expectCalls("apt-get update", "apt-get install -y --allow-downgrades foo", "dpkg -s foo")
+
+	if v := env.installedVersion("foo"); v != "1.0" {
+		t.Fatalf("expected foo 1.0 to be installed, got %q", v)
+	}
+	if state["id"] != "foo_1.0" || state["installed_version"] != "1.0" {
+		t.Fatalf("unexpected state after create: %v", state)
+	}
+
+	state = env.apply(state, nil)
+	env.expectCalls("apt-get remove -y foo")
+
+	if v := env.installedVersion("foo"); v != "" {
+		t.Fatalf("expected foo to be removed, got version %q", v)
+	}
+	if state != nil {
+		t.Fatalf("expected state to be removed, got %v", state)
+	}
+}
+
+func TestPackageDebCanonicalName(t *testing.T) {
+	env := newTestPackageEnv(t)
+	env.available("Foo", "1.0")
+	env.write(env.db, "Foo.name", "foo")
+
+	config := map[string]interface{}{
+		"type": "deb",
+		"name": "Foo",
+	}
+	state := env.apply(nil, config)
+	state = env.refresh(state)
+	if state["name"] != "Foo" || state["package_name"] != "foo" || installedVersions(state)["Foo"] != "1.0" {
+		t.Fatalf("expected the configured name to be kept, got %v", state)
+	}
+	env.calls()
+
+	// The package is not replaced on the next plan
+	env.apply(state, config)
+	for _, call := range env.calls() {
+		if strings.HasPrefix(call, "apt-get") {
+			t.Errorf("expected no change, got %q", call)
+		}
+	}
+}
+
+func TestPackageDebReadMissing(t *testing.T) {
+	env := newTestPackageEnv(t)
+	env.available("foo", "1.0")
+
+	state := env.apply(nil, map[string]interface{}{
+		"type": "deb",
+		"name": "foo",
+	})
+
+	os.Remove(path.Join(env.db, "foo"))
+
+	state = env.refresh(state)
+	if state != nil {
+		t.Fatalf("expected missing package to be removed from the state, got %v", state)
+	}
+}
+
+func TestPackageDebUnknownPackage(t *testing.T) {
+	env := newTestPackageEnv(t)
+
+	_, errs := env.tf.apply("sys_package", nil, map[string]interface{}{
+		"type": "deb",
+		"name": "missing",
+	})
+	if !strings.Contains(errs, "Unable to locate package missing") {
+		t.Fatalf("expected apt-get error, got %q", errs)
+	}
+}
+
+func TestPackageDebVersionInPlace(t *testing.T) {
+	env := newTestPackageEnv(t)
+	env.available("foo", "2.0")
+
+	state := env.apply(nil, map[string]interface{}{
+		"type":    "deb",
+		"name":    "foo",
+		"version": "2.0",
+		"hold":    true,
+	})
+	env.expectCalls("apt-get install -y --allow-downgrades foo=2.0", "apt-mark hold foo")
+	if !env.held("foo") {
+		t.Fatalf("expected foo to be held")
+	}
+
+	state = env.apply(state, map[string]interface{}{
+		"type":    "deb",
+		"name":    "foo",
+		"version": "1.0",
+		"hold":    true,
+	})
+	env.expectCalls("apt-mark unhold foo", "apt-get install -y --allow-downgrades foo=1.0", "apt-mark hold foo")
+
+	if v := env.installedVersion("foo"); v != "1.0" {
+		t.Fatalf("expected foo to be downgraded to 1.0, got %q", v)
+	}
+	if state["id"] != "foo_1.0" {
+		t.Fatalf("expected the package to be updated in place, got %v", state)
+	}
+
+	// Drift from the wanted version is planned again
+	env.installed("foo", "3.0")
+	state = env.refresh(state)
+	state = env.apply(state, map[string]interface{}{
+		"type":    "deb",
+		"name":    "foo",
+		"version": "1.0",
+		"hold":    true,
+	})
+	if v := env.installedVersion("foo"); v != "1.0" {
+		t.Fatalf("expected foo to be reinstalled at 1.0, got %q", v)
+	}
+
+	env.apply(state, nil)
+	env.expectCalls("apt-mark unhold foo", "apt-get remove -y foo")
+}
+
+func TestPackageDebEnsureLatest(t *testing.T) {
+	env := newTestPackageEnv(t)
+	env.available("foo", "1.0")
+
+	config := map[string]interface{}{
+		"type":   "deb",
+		"name":   "foo",
+		"ensure": "latest",
+	}
+
+	state := env.apply(nil, config)
+	env.calls()
+
+	state = env.apply(state, config)
+	if calls := env.calls(); len(calls) != 1 || calls[0] != "apt-cache policy foo" {
+		t.Fatalf("expected no change, got calls %q", calls)
+	}
+
+	env.available("foo", "1.1")
+	state = env.apply(state, config)
+	env.expectCalls("apt-get install -y --only-upgrade foo")
+
+	if state["installed_version"] != "1.1" {
+		t.Fatalf("expected foo to be upgraded, got %v", state)
+	}
+}
+
+func TestPackageDebNames(t *testing.T) {
+	env := newTestPackageEnv(t)
+	env.available("foo", "1.0")
+	env.available("bar", "2.0")
+	env.available("baz", "3.0")
+
+	state := env.apply(nil, map[string]interface{}{
+		"type":  "deb",
+		"names": []interface{}{"foo", "bar"},
+	})
+	env.expectCalls("apt-get install -y --allow-downgrades bar foo")
+
+	if installedVersions(state)["foo"] != "1.0" || installedVersions(state)["bar"] != "2.0" {
+		t.Fatalf("unexpected installed versions: %v", state)
+	}
+
+	state = env.apply(state, map[string]interface{}{
+		"type":     "deb",
+		"names":    []interface{}{"foo", "baz"},
+		"versions": map[string]interface{}{"foo": "0.9"},
+	})
+	env.expectCalls("apt-get remove -y bar", "apt-get install -y --allow-downgrades baz foo=0.9")
+
+	if env.installedVersion("bar") != "" || env.installedVersion("baz") != "3.0" || env.installedVersion("foo") != "0.9" {
+		t.Fatalf("unexpected packages after update")
+	}
+
+	env.apply(state, nil)
+	env.expectCalls("apt-get remove -y baz foo")
+}
+
+func TestPackageDebSource(t *testing.T) {
+	env := newTestPackageEnv(t)
+
+	deb := path.Join(t.TempDir(), "foo_1.0_all.deb")
+	if err := ioutil.WriteFile(deb, []byte("foo 1.0\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	config := map[string]interface{}{
+		"type":   "deb",
+		"source": deb,
+	}
+
+	state := env.apply(nil, config)
+	if state["name"] != "foo" || env.installedVersion("foo") != "1.0" {
+		t.Fatalf("expected foo 1.0 to be installed from %s, got %v", deb, state)
+	}
+
+	// The new artifact version is detected at plan time
+	if err := ioutil.WriteFile(deb, []byte("foo 1.1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	state = env.apply(state, config)
+	if state["installed_version"] != "1.1" || env.installedVersion("foo") != "1.1" {
+		t.Fatalf("expected foo 1.1 to be installed from %s, got %v", deb, state)
+	}
+}
+
+func TestExecRunnerPrefix(t *testing.T) {
+	r := &execRunner{Prefix: []string{"env", "FOO=bar"}}
+
+	stdout := new(strings.Builder)
+	err := r.Run(stdout, nil, "sh", "-c", "echo $FOO")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stdout.String() != "bar\n" {
+		t.Fatalf("expected the prefix to be applied, got %q", stdout.String())
+	}
+
+	err = r.Run(nil, nil, "sh", "-c", "exit 3")
+	if er, ok := err.(*ExitError); !ok || er.ExitCode() != 3 {
+		t.Fatalf("expected exit error, got %v", err)
+	}
+}
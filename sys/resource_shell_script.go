@@ -2,6 +2,7 @@ package sys
 
 import (
 	"bytes"
+	"context"
 	"crypto/sha1"
 	"encoding/hex"
 	"fmt"
@@ -9,63 +10,119 @@ import (
 	"os/exec"
 	"strings"
 
-	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringdefault"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
+	"github.com/hashicorp/terraform-plugin-framework/types"
 )
 
-func resourceShellScript() *schema.Resource {
-	return &schema.Resource{
-		Create: resourceShellScriptCreate,
-		Read:   resourceShellScriptRead,
-		Delete: resourceShellScriptDelete,
+// Scripts can identify the resource they act on using this environment
+// variable. It is set for every script, empty until the resource is created,
+// and is especially useful for the read script after an import.
+const shellScriptIdEnv = "SYS_RESOURCE_ID"
 
-		Schema: map[string]*schema.Schema{
-			"working_directory": {
+type shellScriptResource struct {
+	config *providerConfiguration
+}
+
+type shellScriptResourceModel struct {
+	Id               types.String `tfsdk:"id"`
+	WorkingDirectory types.String `tfsdk:"working_directory"`
+	Shell            types.String `tfsdk:"shell"`
+	Make             types.String `tfsdk:"make"`
+	Create           types.String `tfsdk:"create"`
+	Read             types.String `tfsdk:"read"`
+	Delete           types.String `tfsdk:"delete"`
+	Filename         types.String `tfsdk:"filename"`
+	MissingExitCode  types.Int64  `tfsdk:"missing_exit_code"`
+	Stdout           types.String `tfsdk:"stdout"`
+	Stderr           types.String `tfsdk:"stderr"`
+	ExitCode         types.Int64  `tfsdk:"exit_code"`
+}
+
+func newShellScriptResource() resource.Resource {
+	return &shellScriptResource{}
+}
+
+func (r *shellScriptResource) Metadata(ctx context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_shell_script"
+}
+
+func (r *shellScriptResource) Configure(ctx context.Context, req resource.ConfigureRequest, resp *resource.ConfigureResponse) {
+	r.config, _ = req.ProviderData.(*providerConfiguration)
+}
+
+func (r *shellScriptResource) Schema(ctx context.Context, req resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		Description: "Runs shell scripts to create, read and delete a resource.",
+		Version:     1,
+
+		Attributes: map[string]schema.Attribute{
+			"id": schema.StringAttribute{
+				Computed:    true,
+				Description: "The ID of this resource.",
+			},
+			"working_directory": schema.StringAttribute{
 				Description: "Working directory where to run the script",
-				Type:     schema.TypeString,
-				Optional: true,
-				ForceNew: true,
+				Optional:    true,
 			},
-			"shell": {
+			"shell": schema.StringAttribute{
 				Description: "Shell to use",
-				Type:     schema.TypeString,
-				Optional: true,
-				ForceNew: true,
-				Default:  "/bin/sh",
+				Optional:    true,
+				Computed:    true,
+				Default:     stringdefault.StaticString("/bin/sh"),
 			},
-			"make": {
+			"make": schema.StringAttribute{
 				Description: "Script to construct the resource (does not read the value)",
-				Type:     schema.TypeString,
-				Optional: true,
-				ForceNew: true,
+				Optional:    true,
 			},
-			"create": {
+			"create": schema.StringAttribute{
 				Description: "Script to construct the resource, in addition to `make`. Must output on the standard output the resource id (used to determine if the resource needs to be reconstructed).",
-				Type:     schema.TypeString,
-				Optional: true,
-				ForceNew: true,
+				Optional:    true,
 			},
-			"read": {
-				Description: "Script that reads the resource id on the standard output",
-				Type:     schema.TypeString,
-				Optional: true,
-				ForceNew: true,
+			"read": schema.StringAttribute{
+				Description: "Script that reads the resource id on the standard output. The current resource id (or the import id) is available in the `SYS_RESOURCE_ID` environment variable. Required to import the resource, it runs with the import id on the first refresh once the import is applied and a missing resource is then created again.",
+				Optional:    true,
 			},
-			"delete": {
+			"delete": schema.StringAttribute{
 				Description: "Script to delete the resource",
-				Type:     schema.TypeString,
-				Optional: true,
-				ForceNew: true,
+				Optional:    true,
 			},
-			"filename": {
+			"filename": schema.StringAttribute{
 				Description: "Filename created by the resource, can be used to avoid implementing `read`. The file is removed on resource deletion.",
-				Type:     schema.TypeString,
-				Optional: true,
-				ForceNew: true,
+				Optional:    true,
+			},
+			"missing_exit_code": schema.Int64Attribute{
+				Description: "Exit code of the `read` script meaning that the resource is gone and must be recreated",
+				Optional:    true,
+			},
+			"stdout": schema.StringAttribute{
+				Description: "Standard output of the last script run collecting the resource id",
+				Computed:    true,
+			},
+			"stderr": schema.StringAttribute{
+				Description: "Standard error of the last script run collecting the resource id",
+				Computed:    true,
+			},
+			"exit_code": schema.Int64Attribute{
+				Description: "Exit code of the last script run collecting the resource id",
+				Computed:    true,
 			},
 		},
 	}
 }
 
+func (r *shellScriptResource) UpgradeState(ctx context.Context) map[int64]resource.StateUpgrader {
+	return sdkStateUpgraders(ctx, r)
+}
+
+func (r *shellScriptResource) ImportState(ctx context.Context, req resource.ImportStateRequest, resp *resource.ImportStateResponse) {
+	resource.ImportStatePassthroughID(ctx, path.Root("id"), req, resp)
+}
+
 type ExitError struct {
 	exec.ExitError
 }
@@ -74,119 +131,303 @@ func (err *ExitError) Error() string {
 	return fmt.Sprintf("%v: %v", err.ExitError.Error(), string(err.Stderr))
 }
 
-func resourceShellScriptRead(d *schema.ResourceData, _ interface{}) error {
-	script, ok := d.GetOk("read")
-	cwd := d.Get("working_directory")
-	shell := d.Get("shell").(string)
-	if ok {
-		id, err := resourceShellScriptRun(cwd, shell, script.(string), true)
-		d.SetId(id)
+func (data *shellScriptResourceModel) env() []string {
+	return []string{fmt.Sprintf("%s=%s", shellScriptIdEnv, data.Id.ValueString())}
+}
+
+func (data *shellScriptResourceModel) setOutput(out *shellScriptOutput) {
+	data.Stdout = types.StringValue(out.Stdout)
+	data.Stderr = types.StringValue(out.Stderr)
+	data.ExitCode = types.Int64Value(int64(out.ExitCode))
+}
+
+// missing tells if the read script reports the resource as missing
+func (data *shellScriptResourceModel) missing(out *shellScriptOutput) bool {
+	return !data.MissingExitCode.IsNull() && int64(out.ExitCode) == data.MissingExitCode.ValueInt64()
+}
+
+func (r *shellScriptResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	var data shellScriptResourceModel
+
+	resp.Diagnostics.Append(req.State.Get(ctx, &data)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	err := r.readId(&data, true)
+	if err != nil {
+		resp.Diagnostics.AddError("cannot read the resource", err.Error())
+		return
+	} else if data.Id.ValueString() == "" {
+		resp.State.RemoveResource(ctx)
+		return
+	}
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+// readId reads the resource id. A read script exiting with missing_exit_code
+// clears the id if allowMissing is set, and fails otherwise.
+func (r *shellScriptResource) readId(data *shellScriptResourceModel, allowMissing bool) error {
+	host := providerHost(r.config)
+	cwd := data.WorkingDirectory.ValueString()
+	shell := data.Shell.ValueString()
+	if script := data.Read.ValueString(); script != "" {
+		id, out, err := resourceShellScriptRunEnv(host, cwd, shell, script, true, data.env())
+		data.setOutput(out)
+		if data.missing(out) {
+			if !allowMissing {
+				return fmt.Errorf("read script reports resource %s as missing", data.Id.ValueString())
+			}
+			data.Id = types.StringValue("")
+			return nil
+		}
 		if err != nil {
 			return fmt.Errorf("cannot execute read script, %v", err)
 		}
-	} else if filename, ok := d.GetOk("filename"); ok {
-		id, err := checksumFile(filename.(string))
+		data.Id = types.StringValue(id)
+	} else if filename := data.Filename.ValueString(); filename != "" {
+		id, err := checksumFile(host, filename)
 		if err != nil && !os.IsNotExist(err) {
 			return fmt.Errorf("cannot checksum file, %v", err)
 		}
-		d.SetId(id)
+		data.Id = types.StringValue(id)
 	}
 	return nil
 }
 
-func resourceShellScriptCreate(d *schema.ResourceData, _ interface{}) error {
-	script_make, okm := d.GetOk("make")
-	script, okc := d.GetOk("create")
-	filename, okf := d.GetOk("filename")
-	script_read, okr := d.GetOk("read")
-	cwd := d.Get("working_directory")
-	shell := d.Get("shell").(string)
+func (r *shellScriptResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	var data shellScriptResourceModel
+
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &data)...)
 
-	scriptname := "create"
-	if script.(string) == "" {
-		script = script_read
-		scriptname = "read"
+	if resp.Diagnostics.HasError() {
+		return
 	}
 
-	if okm {
-		_, err := resourceShellScriptRun(cwd, shell, script_make.(string), false)
+	data.Id = types.StringValue("")
+	data.Stdout = types.StringNull()
+	data.Stderr = types.StringNull()
+	data.ExitCode = types.Int64Null()
+
+	err := r.create(&data)
+	if err != nil {
+		resp.Diagnostics.AddError("cannot create the resource", err.Error())
+		// The resource is partially created when the id is known
+		if data.Id.ValueString() == "" {
+			return
+		}
+	}
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+func (r *shellScriptResource) create(data *shellScriptResourceModel) error {
+	host := providerHost(r.config)
+	script_make := data.Make.ValueString()
+	script := data.Create.ValueString()
+	filename := data.Filename.ValueString()
+	cwd := data.WorkingDirectory.ValueString()
+	shell := data.Shell.ValueString()
+	env := data.env()
+
+	if script_make != "" {
+		_, _, err := resourceShellScriptRunEnv(host, cwd, shell, script_make, false, env)
 		if err != nil {
 			return fmt.Errorf("cannot execute make script, %v", err)
 		}
 	}
 
-	if okc && okr {
-		id, err := resourceShellScriptRun(cwd, shell, script.(string), true)
-		d.SetId(id)
+	if script != "" && data.Read.ValueString() != "" {
+		id, out, err := resourceShellScriptRunEnv(host, cwd, shell, script, true, env)
+		data.setOutput(out)
+		data.Id = types.StringValue(id)
 		if err != nil {
-			return fmt.Errorf("cannot execute %s script, %v", scriptname, err)
+			return fmt.Errorf("cannot execute create script, %v", err)
 		}
-	} else if okc && okf {
-		_, err := resourceShellScriptRun(cwd, shell, script.(string), false)
+	} else if script != "" && filename != "" {
+		_, _, err := resourceShellScriptRunEnv(host, cwd, shell, script, false, env)
 		if err != nil {
-			return fmt.Errorf("cannot execute %s script, %v", scriptname, err)
+			return fmt.Errorf("cannot execute create script, %v", err)
 		}
-		id, err := checksumFile(filename.(string))
+		id, err := checksumFile(host, filename)
 		if err != nil && !os.IsNotExist(err) {
 			return fmt.Errorf("cannot checksum file, %v", err)
 		}
-		d.SetId(id)
-	} else if okc {
-		_, err := resourceShellScriptRun(cwd, shell, script.(string), false)
+		data.Id = types.StringValue(id)
+	} else if script != "" {
+		_, _, err := resourceShellScriptRunEnv(host, cwd, shell, script, false, env)
 		if err != nil {
-			return fmt.Errorf("cannot execute %s script, %v", scriptname, err)
+			return fmt.Errorf("cannot execute create script, %v", err)
 		}
-		d.SetId("1")
+		data.Id = types.StringValue("1")
 	} else {
-		d.SetId("1")
+		data.Id = types.StringValue("1")
 	}
 	return nil
 }
 
-func resourceShellScriptDelete(d *schema.ResourceData, _ interface{}) error {
-	script, ok := d.GetOk("delete")
-	cwd := d.Get("working_directory")
-	shell := d.Get("shell").(string)
-	if ok {
-		_, err := resourceShellScriptRun(cwd, shell, script.(string), false)
-		return err
-	} else if filename, ok := d.GetOk("filename"); ok {
-		return os.RemoveAll(filename.(string))
+var shellScriptAttributes = []string{"working_directory", "shell", "make", "create", "read", "delete", "filename"}
+
+// Scripts can only change in place when the resource has just been imported
+// and the state contains nothing else than the id. Otherwise any script change
+// recreates the resource as it always did. The import is applied without
+// running any script, the next refresh reads the resource with the import id
+// and removes it from the state if it is missing.
+func (r *shellScriptResource) ModifyPlan(ctx context.Context, req resource.ModifyPlanRequest, resp *resource.ModifyPlanResponse) {
+	if req.State.Raw.IsNull() || req.Plan.Raw.IsNull() {
+		return
 	}
-	return nil
+
+	if !shellScriptImported(ctx, req.State) {
+		for _, key := range shellScriptAttributes {
+			var planned, prior attr.Value
+			resp.Diagnostics.Append(resp.Plan.GetAttribute(ctx, path.Root(key), &planned)...)
+			resp.Diagnostics.Append(req.State.GetAttribute(ctx, path.Root(key), &prior)...)
+			if planned != nil && !planned.Equal(prior) {
+				resp.RequiresReplace = append(resp.RequiresReplace, path.Root(key))
+			}
+		}
+		return
+	}
+
+	var id, read types.String
+	resp.Diagnostics.Append(req.State.GetAttribute(ctx, path.Root("id"), &id)...)
+	resp.Diagnostics.Append(resp.Plan.GetAttribute(ctx, path.Root("read"), &read)...)
+	if read.IsNull() {
+		resp.Diagnostics.AddAttributeError(path.Root("read"), "Missing read script",
+			fmt.Sprintf("cannot read imported resource %s without a read script", id.ValueString()))
+		return
+	}
+
+	// The id stays the import id until the resource is read
+	resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, path.Root("id"), id)...)
 }
 
-func resourceShellScriptRun(cwd interface{}, shell, script string, collectId bool) (string, error) {
+// shellScriptImported tells if the prior state comes from an import. Created
+// resources always store the shell, which has a default, while imported ones
+// only have an id.
+func shellScriptImported(ctx context.Context, state tfsdk.State) bool {
+	if !state.Raw.IsFullyKnown() {
+		return false
+	}
+	for _, key := range shellScriptAttributes {
+		var value attr.Value
+		state.GetAttribute(ctx, path.Root(key), &value)
+		if value != nil && !value.IsNull() {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *shellScriptResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	var data, state shellScriptResourceModel
+
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &data)...)
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	// Only the attributes that do not recreate the resource are updated, the
+	// resource is read again and must still exist. An imported resource is
+	// only read on the next refresh.
+	data.Id = state.Id
+	if data.Stdout.IsUnknown() {
+		data.Stdout, data.Stderr, data.ExitCode = state.Stdout, state.Stderr, state.ExitCode
+	}
+	if !shellScriptImported(ctx, req.State) {
+		err := r.readId(&data, false)
+		if err != nil {
+			resp.Diagnostics.AddError("cannot read the resource", err.Error())
+			return
+		}
+	}
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+func (r *shellScriptResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	var data shellScriptResourceModel
+
+	resp.Diagnostics.Append(req.State.Get(ctx, &data)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	host := providerHost(r.config)
+	if script := data.Delete.ValueString(); script != "" {
+		_, _, err := resourceShellScriptRunEnv(host, data.WorkingDirectory.ValueString(), data.Shell.ValueString(), script, false, data.env())
+		if err != nil {
+			resp.Diagnostics.AddError("cannot execute delete script", err.Error())
+		}
+	} else if filename := data.Filename.ValueString(); filename != "" {
+		if err := host.RemoveAll(filename); err != nil {
+			resp.Diagnostics.AddError("cannot remove "+filename, err.Error())
+		}
+	}
+}
+
+type shellScriptOutput struct {
+	Stdout   string
+	Stderr   string
+	ExitCode int
+}
+
+func resourceShellScriptRun(host sysHost, cwd interface{}, shell, script string, collectId bool) (string, error) {
+	id, _, err := resourceShellScriptRunEnv(host, cwd, shell, script, collectId, nil)
+	return id, err
+}
+
+// resourceShellScriptRunEnv runs the script on the host, feeding it to the
+// shell standard input
+func resourceShellScriptRunEnv(host sysHost, cwd interface{}, shell, script string, collectId bool, env []string) (string, *shellScriptOutput, error) {
 	stdout := new(bytes.Buffer)
 	stderr := new(bytes.Buffer)
 
-	cmd := exec.Command(shell)
+	dir, _ := cwd.(string)
+	cmd := host.Command(context.Background(), env, shell)
+	if dir != "" && host.Remote() {
+		// The working directory of the command is local
+		cmd = host.Command(context.Background(), env, "sh", "-c", `cd -- "$1" && exec "$2"`, "sh", dir, shell)
+	} else {
+		cmd.Dir = dir
+	}
 	cmd.Stdin = bytes.NewReader([]byte(script))
 	if collectId {
-	    cmd.Stdout = stdout
+		cmd.Stdout = stdout
 	} else {
-	    cmd.Stdout = stderr
+		cmd.Stdout = stderr
 	}
 	cmd.Stderr = stderr
-	if cwd != nil {
-		cmd.Dir = cwd.(string)
-	}
 
 	err := cmd.Run()
 
+	out := &shellScriptOutput{}
+
 	if err != nil {
 		if er, ok := err.(*exec.ExitError); ok && er != nil {
+			out.ExitCode = er.ExitCode()
 			er.Stderr = stderr.Bytes()
 			err = &ExitError{*er}
+		} else {
+			out.ExitCode = -1
 		}
 	}
 
-	id := strings.TrimRight(stdout.String(), "\n")
+	out.Stdout = stdout.String()
+	out.Stderr = stderr.String()
+
+	id := strings.TrimRight(out.Stdout, "\n")
 
 	if len(id) > 64 {
 		checksum := sha1.Sum([]byte(id))
 		id = hex.EncodeToString(checksum[:])
 	}
 
-	return id, err
+	return id, out, err
 }
@@ -0,0 +1,132 @@
+package sys
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+func testShellScriptConfig(dir string) map[string]interface{} {
+	return map[string]interface{}{
+		"working_directory": dir,
+		"create":            "echo create >>log; echo res",
+		"read":              `[ -f "$SYS_RESOURCE_ID" ] || exit 3; echo "$SYS_RESOURCE_ID"`,
+		"missing_exit_code": 3,
+	}
+}
+
+func TestShellScriptImport(t *testing.T) {
+	dir := t.TempDir()
+	if err := ioutil.WriteFile(path.Join(dir, "res"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	env := newTestFrameworkEnvWith(t, &providerConfiguration{})
+	config := testShellScriptConfig(dir)
+	config["read"] = `[ -f "$SYS_RESOURCE_ID" ] || exit 3; echo "$SYS_RESOURCE_ID" | tee -a log`
+
+	// The import state only contains the id, there is no read script yet
+	state := env.refresh("sys_shell_script", env.importState("sys_shell_script", "res"))
+	if state["id"] != "res" {
+		t.Fatalf("expected the imported id to be kept, got %v", state["id"])
+	}
+
+	// The plan has no side effect and keeps the import id
+	planned, replace, errs := env.plan("sys_shell_script", state, config)
+	if errs != "" {
+		t.Fatal(errs)
+	}
+	if replace || planned["id"] != "res" {
+		t.Fatalf("expected the imported resource to be updated in place, got %v", planned)
+	}
+
+	state = env.mustApply("sys_shell_script", state, config)
+	if state["id"] != "res" || state["read"] != config["read"] {
+		t.Fatalf("unexpected state after import: %v", state)
+	}
+	if _, err := os.Stat(path.Join(dir, "log")); !os.IsNotExist(err) {
+		t.Errorf("expected no script to run on import, got %v", err)
+	}
+
+	// The next refresh reads the resource with the import id
+	state = env.refresh("sys_shell_script", state)
+	if state["id"] != "res" || state["stdout"] != "res\n" {
+		t.Fatalf("expected the refresh to read the imported resource, got %v", state)
+	}
+	if content, err := ioutil.ReadFile(path.Join(dir, "log")); err != nil || string(content) != "res\n" {
+		t.Errorf("expected the resource to be read and not created, got %q (%v)", content, err)
+	}
+
+	// Script changes recreate the resource once it is managed
+	config["read"] = `echo "$SYS_RESOURCE_ID"`
+	if _, replace, errs = env.plan("sys_shell_script", state, config); errs != "" || !replace {
+		t.Errorf("expected a script change to recreate the resource, got %v %s", replace, errs)
+	}
+}
+
+func TestShellScriptImportMissing(t *testing.T) {
+	dir := t.TempDir()
+	env := newTestFrameworkEnvWith(t, &providerConfiguration{})
+	state := env.refresh("sys_shell_script", env.importState("sys_shell_script", "res"))
+
+	config := testShellScriptConfig(dir)
+	delete(config, "read")
+	if _, _, errs := env.plan("sys_shell_script", state, config); !strings.Contains(errs, "without a read script") {
+		t.Errorf("expected the plan to fail without a read script, got %q", errs)
+	}
+
+	// A missing imported resource is removed from the state to be created
+	state = env.mustApply("sys_shell_script", state, testShellScriptConfig(dir))
+	if state = env.refresh("sys_shell_script", state); state != nil {
+		t.Fatalf("expected the missing imported resource to be removed, got %v", state)
+	}
+}
+
+func TestShellScriptMissingExitCode(t *testing.T) {
+	dir := t.TempDir()
+	env := newTestFrameworkEnvWith(t, &providerConfiguration{})
+	config := testShellScriptConfig(dir)
+	config["create"] = "touch res; echo res"
+	config["missing_exit_code"] = 4
+
+	state := env.mustApply("sys_shell_script", nil, config)
+	if state["id"] != "res" {
+		t.Fatalf("unexpected id %v", state["id"])
+	}
+
+	// An update fails rather than forgetting the resource
+	if err := os.Remove(path.Join(dir, "res")); err != nil {
+		t.Fatal(err)
+	}
+	config["missing_exit_code"] = 3
+	if _, errs := env.apply("sys_shell_script", state, config); !strings.Contains(errs, "missing") {
+		t.Errorf("expected the update of a missing resource to fail, got %q", errs)
+	}
+
+	// A refresh removes it from the state to be recreated
+	state["missing_exit_code"] = 3
+	if state = env.refresh("sys_shell_script", state); state != nil {
+		t.Fatalf("expected the missing resource to be removed, got %v", state)
+	}
+}
+
+func TestShellScriptResourceIdEnv(t *testing.T) {
+	dir := t.TempDir()
+	env := newTestFrameworkEnvWith(t, &providerConfiguration{})
+	t.Setenv(shellScriptIdEnv, "inherited")
+
+	state := env.mustApply("sys_shell_script", nil, map[string]interface{}{
+		"working_directory": dir,
+		"create":            `echo "[$SYS_RESOURCE_ID]" >create; echo res`,
+		"read":              `echo "$SYS_RESOURCE_ID"`,
+		"delete":            `echo "[$SYS_RESOURCE_ID]" >delete`,
+	})
+	env.mustApply("sys_shell_script", state, nil)
+
+	for name, expected := range map[string]string{"create": "[]\n", "delete": "[res]\n"} {
+		if content, err := ioutil.ReadFile(path.Join(dir, name)); err != nil || string(content) != expected {
+			t.Errorf("expected %s to get %s=%q, got %q (%v)", name, shellScriptIdEnv, expected, content, err)
+		}
+	}
+}
@@ -1,96 +1,148 @@
 package sys
 
 import (
+	"context"
 	"os"
 	"path"
 	"strconv"
-	"fmt"
 
-	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringdefault"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
 )
 
-func resourceSymlink() *schema.Resource {
-	return &schema.Resource{
-		Create: resourceSymlinkCreate,
-		Read:   resourceSymlinkRead,
-		Delete: resourceSymlinkDelete,
-		Exists: resourceSymlinkExists,
+type symlinkResource struct {
+	host sysHost
+}
+
+type symlinkResourceModel struct {
+	Id                  types.String `tfsdk:"id"`
+	Source              types.String `tfsdk:"source"`
+	Path                types.String `tfsdk:"path"`
+	DirectoryPermission types.String `tfsdk:"directory_permission"`
+}
+
+func newSymlinkResource() resource.Resource {
+	return &symlinkResource{}
+}
+
+func (r *symlinkResource) Metadata(ctx context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_symlink"
+}
 
+func (r *symlinkResource) Configure(ctx context.Context, req resource.ConfigureRequest, resp *resource.ConfigureResponse) {
+	r.host = providerHost(req.ProviderData)
+}
+
+func (r *symlinkResource) Schema(ctx context.Context, req resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
 		Description: "Creates a symlink",
+		Version:     1,
 
-		Schema: map[string]*schema.Schema{
-			"source": {
-				Type:        schema.TypeString,
-				Description: "Symlink source path",
-				Optional:    true,
-				ForceNew:    true,
+		Attributes: map[string]schema.Attribute{
+			"id": schema.StringAttribute{
+				Computed:      true,
+				Description:   "The ID of this resource.",
+				PlanModifiers: []planmodifier.String{stringplanmodifier.UseStateForUnknown()},
 			},
-			"path": {
-				Type:        schema.TypeString,
-				Description: "Path to the output file",
-				Required:    true,
-				ForceNew:    true,
+			"source": schema.StringAttribute{
+				Description:   "Symlink source path",
+				Optional:      true,
+				PlanModifiers: []planmodifier.String{stringplanmodifier.RequiresReplace()},
 			},
-			"directory_permission": {
-				Description:  "(default: \"0777\") The permission to set for any directories created. Expects a string.",
-				Type:         schema.TypeString,
-				Optional:     true,
-				ForceNew:     true,
-				Default:      "0777",
-				ValidateFunc: validateMode,
+			"path": schema.StringAttribute{
+				Description:   "Path to the output file",
+				Required:      true,
+				PlanModifiers: []planmodifier.String{stringplanmodifier.RequiresReplace()},
+			},
+			"directory_permission": schema.StringAttribute{
+				Description:   "(default: \"0777\") The permission to set for any directories created. Expects a string.",
+				Optional:      true,
+				Computed:      true,
+				Default:       stringdefault.StaticString("0777"),
+				Validators:    []validator.String{modeValidator},
+				PlanModifiers: []planmodifier.String{stringplanmodifier.RequiresReplace()},
 			},
 		},
 	}
 }
 
-func resourceSymlinkRead(d *schema.ResourceData, _ interface{}) error {
-	path := d.Get("path").(string)
+func (r *symlinkResource) UpgradeState(ctx context.Context) map[int64]resource.StateUpgrader {
+	return sdkStateUpgraders(ctx, r)
+}
 
-	target, err := os.Readlink(path)
-	if err == nil {
-		d.Set("source", target)
-		d.SetId(path)
-	} else {
-		d.SetId("")
+func (r *symlinkResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	var data symlinkResourceModel
+
+	resp.Diagnostics.Append(req.State.Get(ctx, &data)...)
+
+	if resp.Diagnostics.HasError() {
+		return
 	}
 
-	return nil
+	target, err := r.host.Readlink(data.Path.ValueString())
+	if err != nil {
+		resp.State.RemoveResource(ctx)
+		return
+	}
+
+	data.Source = types.StringValue(target)
+	data.Id = data.Path
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
 }
 
-func resourceSymlinkExists(d *schema.ResourceData, _ interface{}) (bool, error) {
-	path := d.Get("path").(string)
+func (r *symlinkResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	var data symlinkResourceModel
 
-	target, err := os.Readlink(path)
-	if err == nil {
-		d.Set("source", target)
-		return true, nil
-	} else {
-		return false, nil
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &data)...)
+
+	if resp.Diagnostics.HasError() {
+		return
 	}
-}
 
-func resourceSymlinkCreate(d *schema.ResourceData, _ interface{}) error {
-	source := d.Get("source").(string)
-	destination := d.Get("path").(string)
+	destination := data.Path.ValueString()
 
 	destinationDir := path.Dir(destination)
-	if _, err := os.Stat(destinationDir); err != nil {
-		dirPerm := d.Get("directory_permission").(string)
-		dirMode, _ := strconv.ParseInt(dirPerm, 8, 64)
+	if _, err := r.host.Stat(destinationDir); err != nil {
+		dirMode, _ := strconv.ParseInt(data.DirectoryPermission.ValueString(), 8, 64)
 
-		if err := os.MkdirAll(destinationDir, os.FileMode(dirMode)); err != nil {
-			return fmt.Errorf("cannot create parent directories, %v", err)
+		if err := r.host.MkdirAll(destinationDir, os.FileMode(dirMode)); err != nil {
+			resp.Diagnostics.AddError("cannot create parent directories", err.Error())
+			return
 		}
 	}
 
-	err := os.Symlink(source, destination)
-	if err != nil {
-		return err
+	if err := r.host.Symlink(data.Source.ValueString(), destination); err != nil {
+		resp.Diagnostics.AddError("cannot create symlink", err.Error())
+		return
 	}
 
-	return resourceSymlinkRead(d, nil)
+	data.Id = data.Path
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
 }
 
-func resourceSymlinkDelete(d *schema.ResourceData, _ interface{}) error {
-	return os.Remove(d.Get("path").(string))
+// Update is never called, all attributes require replacement
+func (r *symlinkResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	var data symlinkResourceModel
+
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &data)...)
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+func (r *symlinkResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	var data symlinkResourceModel
+
+	resp.Diagnostics.Append(req.State.Get(ctx, &data)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	if err := r.host.Remove(data.Path.ValueString()); err != nil {
+		resp.Diagnostics.AddError("cannot remove symlink", err.Error())
+	}
 }
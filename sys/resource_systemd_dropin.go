@@ -0,0 +1,349 @@
+package sys
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/booldefault"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/boolplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringdefault"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+type systemdDropinResource struct {
+	config *providerConfiguration
+}
+
+type systemdDropinResourceModel struct {
+	Id          types.String      `tfsdk:"id"`
+	Unit        types.String      `tfsdk:"unit"`
+	Name        types.String      `tfsdk:"name"`
+	Content     types.String      `tfsdk:"content"`
+	UnitSection map[string]string `tfsdk:"unit_section"`
+	Service     map[string]string `tfsdk:"service"`
+	Timer       map[string]string `tfsdk:"timer"`
+	Install     map[string]string `tfsdk:"install"`
+	Restart     types.Bool        `tfsdk:"restart"`
+	System      types.Bool        `tfsdk:"system"`
+	User        types.Bool        `tfsdk:"user"`
+	UserName    types.String      `tfsdk:"user_name"`
+	Path        types.String      `tfsdk:"path"`
+}
+
+func newSystemdDropinResource() resource.Resource {
+	return &systemdDropinResource{}
+}
+
+func (r *systemdDropinResource) Metadata(ctx context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_systemd_dropin"
+}
+
+func (r *systemdDropinResource) Configure(ctx context.Context, req resource.ConfigureRequest, resp *resource.ConfigureResponse) {
+	r.config, _ = req.ProviderData.(*providerConfiguration)
+}
+
+func (r *systemdDropinResource) Schema(ctx context.Context, req resource.SchemaRequest, resp *resource.SchemaResponse) {
+	section := func(name string) schema.MapAttribute {
+		return schema.MapAttribute{
+			Description: "[" + name + "] section of the drop-in, multi-line values are written as repeated keys",
+			ElementType: types.StringType,
+			Optional:    true,
+		}
+	}
+
+	resp.Schema = schema.Schema{
+		Description: "Manages a drop-in configuration file overriding a systemd unit, " +
+			"the unit is restarted if running when the drop-in changes.",
+		Version: 1,
+
+		Attributes: map[string]schema.Attribute{
+			"id": schema.StringAttribute{
+				Computed:      true,
+				Description:   "The ID of this resource.",
+				PlanModifiers: []planmodifier.String{stringplanmodifier.UseStateForUnknown()},
+			},
+			"unit": schema.StringAttribute{
+				Description:   "systemd unit name to override",
+				Required:      true,
+				PlanModifiers: []planmodifier.String{stringplanmodifier.RequiresReplace()},
+			},
+			"name": schema.StringAttribute{
+				Description:   "Drop-in name, the .conf suffix is added if missing",
+				Optional:      true,
+				Computed:      true,
+				Default:       stringdefault.StaticString("override"),
+				PlanModifiers: []planmodifier.String{stringplanmodifier.RequiresReplace()},
+			},
+			"content": schema.StringAttribute{
+				Description: "Drop-in file content. Conflicts with the structured `unit_section`, `service`, `timer` and `install` sections",
+				Optional:    true,
+				Computed:    true,
+			},
+			"unit_section": section("Unit"),
+			"service":      section("Service"),
+			"timer":        section("Timer"),
+			"install":      section("Install"),
+			"restart": schema.BoolAttribute{
+				Description: "Restart the unit if it is running when the drop-in changes or is removed",
+				Optional:    true,
+				Computed:    true,
+				Default:     booldefault.StaticBool(true),
+			},
+			"system": schema.BoolAttribute{
+				Description:   "Uses the system systemd socket",
+				Optional:      true,
+				PlanModifiers: []planmodifier.Bool{boolplanmodifier.RequiresReplace()},
+			},
+			"user": schema.BoolAttribute{
+				Description:   "Uses the user systemd socket",
+				Optional:      true,
+				PlanModifiers: []planmodifier.Bool{boolplanmodifier.RequiresReplace()},
+			},
+			"user_name": schema.StringAttribute{
+				Description:   "Uses the user manager of this user instead of the current user, requires root",
+				Optional:      true,
+				PlanModifiers: []planmodifier.String{stringplanmodifier.RequiresReplace()},
+			},
+			"path": schema.StringAttribute{
+				Description:   "Path of the drop-in file",
+				Computed:      true,
+				PlanModifiers: []planmodifier.String{stringplanmodifier.UseStateForUnknown()},
+			},
+		},
+	}
+}
+
+func (r *systemdDropinResource) ConfigValidators(ctx context.Context) []resource.ConfigValidator {
+	return []resource.ConfigValidator{
+		atLeastOneOfAttributes{"content", "unit_section", "service", "timer", "install"},
+		conflictingAttributes{"content", "unit_section"},
+		conflictingAttributes{"content", "service"},
+		conflictingAttributes{"content", "timer"},
+		conflictingAttributes{"content", "install"},
+		conflictingAttributes{"system", "user"},
+		conflictingAttributes{"system", "user_name"},
+	}
+}
+
+func (r *systemdDropinResource) UpgradeState(ctx context.Context) map[int64]resource.StateUpgrader {
+	return sdkStateUpgraders(ctx, r)
+}
+
+// sections maps the drop-in attributes to the unit file sections, the unit
+// attribute being already used for the unit name
+func (data *systemdDropinResourceModel) sections() sdUnitSectionMap {
+	return sdUnitSectionMap{
+		"unit":    data.UnitSection,
+		"service": data.Service,
+		"timer":   data.Timer,
+		"install": data.Install,
+	}
+}
+
+func (data *systemdDropinResourceModel) scope(m *providerConfiguration) sdScope {
+	return newSdScope(data.User.ValueBool(), data.UserName.ValueString(), m)
+}
+
+func sdDropinPath(scope sdScope, unit, name string) (string, error) {
+	dir, err := scope.UnitDir()
+	if err != nil {
+		return "", err
+	}
+
+	if !strings.HasSuffix(name, ".conf") {
+		name = name + ".conf"
+	}
+
+	return filepath.Join(dir, unit+".d", name), nil
+}
+
+func (r *systemdDropinResource) ModifyPlan(ctx context.Context, req resource.ModifyPlanRequest, resp *resource.ModifyPlanResponse) {
+	if req.Plan.Raw.IsNull() {
+		return
+	}
+
+	var content types.String
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root("content"), &content)...)
+	if resp.Diagnostics.HasError() || !content.IsNull() {
+		return
+	}
+
+	if !planKnown(ctx, req.Plan, "unit_section", "service", "timer", "install") {
+		resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, path.Root("content"), types.StringUnknown())...)
+		return
+	}
+
+	var data systemdDropinResourceModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &data)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, path.Root("content"), sdUnitRender(data.sections()))...)
+}
+
+func (r *systemdDropinResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	var data systemdDropinResourceModel
+
+	resp.Diagnostics.Append(req.State.Get(ctx, &data)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	filename := data.Path.ValueString()
+
+	content, err := providerHost(r.config).ReadFile(filename)
+	if os.IsNotExist(err) {
+		resp.State.RemoveResource(ctx)
+		return
+	} else if err != nil {
+		resp.Diagnostics.AddError("cannot read "+filename, err.Error())
+		return
+	}
+
+	data.Content = types.StringValue(string(content))
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+func (r *systemdDropinResource) write(ctx context.Context, data *systemdDropinResourceModel) error {
+	unit := data.Unit.ValueString()
+	scope := data.scope(r.config)
+	lock := sdUnitLock(r.config, scope, unit)
+	lock.Lock()
+	defer lock.Unlock()
+
+	filename, err := sdDropinPath(scope, unit, data.Name.ValueString())
+	if err != nil {
+		return err
+	}
+
+	log.Printf("[DEBUG] Write drop-in %s\n", filename)
+	err = scope.WriteFile(filename, data.Content.ValueString())
+	if err != nil {
+		return err
+	}
+
+	data.Id = types.StringValue(filename)
+	data.Path = types.StringValue(filename)
+
+	return sdDropinReload(ctx, r.config, scope, unit, data.Restart.ValueBool())
+}
+
+func (r *systemdDropinResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	var data systemdDropinResourceModel
+
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &data)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	if err := r.write(ctx, &data); err != nil {
+		resp.Diagnostics.AddError("cannot write the drop-in", err.Error())
+		return
+	}
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+func (r *systemdDropinResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	var data, state systemdDropinResourceModel
+
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &data)...)
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	if !data.Content.Equal(state.Content) {
+		if err := r.write(ctx, &data); err != nil {
+			resp.Diagnostics.AddError("cannot write the drop-in", err.Error())
+			return
+		}
+	}
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+func (r *systemdDropinResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	var data systemdDropinResourceModel
+
+	resp.Diagnostics.Append(req.State.Get(ctx, &data)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	unit := data.Unit.ValueString()
+	scope := data.scope(r.config)
+	lock := sdUnitLock(r.config, scope, unit)
+	lock.Lock()
+	defer lock.Unlock()
+
+	filename := data.Path.ValueString()
+
+	log.Printf("[DEBUG] Remove drop-in %s\n", filename)
+	err := sdUnitRemoveFile(scope.Host(), filename)
+	if err != nil {
+		resp.Diagnostics.AddError("cannot remove the drop-in", err.Error())
+		return
+	}
+
+	// Remove the drop-in directory if this was the last drop-in
+	scope.Host().Remove(filepath.Dir(filename))
+
+	err = sdDropinReload(ctx, r.config, scope, unit, data.Restart.ValueBool())
+	if err != nil {
+		resp.Diagnostics.AddError("cannot remove the drop-in", err.Error())
+	}
+}
+
+// sdDropinReload reloads systemd and restarts the unit if it is running so the
+// drop-in takes effect
+func sdDropinReload(ctx context.Context, m *providerConfiguration, scope sdScope, unit string, restart bool) error {
+	sd, err := scope.Conn(ctx, m)
+	if err != nil {
+		return fmt.Errorf("cannot connect to systemd: %v", err)
+	}
+
+	defer sd.Close()
+
+	err = sd.ReloadContext(ctx)
+	if err != nil {
+		return fmt.Errorf("cannot reload systemd: %v", err)
+	}
+
+	if !restart {
+		return nil
+	}
+
+	log.Printf("[DEBUG] systemctl try-restart %s\n", unit)
+	err = sdTryRestart(ctx, sd, unit)
+	if err != nil {
+		return fmt.Errorf("cannot restart unit %s: %v", unit, err)
+	}
+
+	return nil
+}
+
+func sdTryRestart(ctx context.Context, sd sdManager, unit string) error {
+	complete := make(chan string)
+	_, err := sd.TryRestartUnitContext(ctx, unit, "replace", complete)
+	if err != nil {
+		return err
+	}
+
+	return sdWaitJob(ctx, unit, complete)
+}
@@ -0,0 +1,51 @@
+package sys
+
+import (
+	"os"
+	"path"
+	"testing"
+)
+
+func TestSystemdDropin(t *testing.T) {
+	env := newTestSystemdEnv(t)
+	env.sd.add("postgresql.service", systemdEnabled).start()
+	root := t.TempDir()
+	env.meta.Host = &rootHost{sysHost: localHost{}, Root: root}
+	tf := env.tf
+
+	state := tf.mustApply("sys_systemd_dropin", nil, map[string]interface{}{
+		"unit":    "postgresql.service",
+		"service": map[string]interface{}{"LimitNOFILE": "65536"},
+	})
+	env.expectCalls("try-restart postgresql.service replace")
+
+	filename := "/etc/systemd/system/postgresql.service.d/override.conf"
+	if state["id"] != filename || state["path"] != filename || state["content"] != "[Service]\nLimitNOFILE=65536\n" {
+		t.Fatalf("unexpected state %v", state)
+	}
+	if content, err := os.ReadFile(path.Join(root, filename)); err != nil || string(content) != state["content"] {
+		t.Fatalf("unexpected drop-in %q (%v)", content, err)
+	}
+
+	// Only restart changes, the drop-in is not written again
+	state = tf.mustApply("sys_systemd_dropin", state, map[string]interface{}{
+		"unit":    "postgresql.service",
+		"service": map[string]interface{}{"LimitNOFILE": "65536"},
+		"restart": false,
+	})
+	env.expectCalls()
+
+	tf.mustApply("sys_systemd_dropin", state, nil)
+	env.expectCalls()
+	if _, err := os.Lstat(path.Join(root, path.Dir(filename))); !os.IsNotExist(err) {
+		t.Errorf("expected the drop-in directory to be removed, got %v", err)
+	}
+
+	if _, errs := tf.apply("sys_systemd_dropin", nil, map[string]interface{}{
+		"unit":    "postgresql.service",
+		"content": "[Service]\n",
+		"service": map[string]interface{}{"LimitNOFILE": "65536"},
+	}); errs == "" {
+		t.Errorf("expected content and service to conflict")
+	}
+}
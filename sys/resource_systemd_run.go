@@ -0,0 +1,380 @@
+package sys
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	systemd "github.com/coreos/go-systemd/v22/dbus"
+	"github.com/godbus/dbus/v5"
+
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/boolplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/listplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/mapplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+type systemdRunResource struct {
+	config *providerConfiguration
+}
+
+type systemdRunResourceModel struct {
+	Id          types.String      `tfsdk:"id"`
+	Name        types.String      `tfsdk:"name"`
+	Command     []string          `tfsdk:"command"`
+	Properties  map[string]string `tfsdk:"properties"`
+	OnCalendar  types.String      `tfsdk:"on_calendar"`
+	OnActive    types.String      `tfsdk:"on_active"`
+	System      types.Bool        `tfsdk:"system"`
+	User        types.Bool        `tfsdk:"user"`
+	UserName    types.String      `tfsdk:"user_name"`
+	Service     types.String      `tfsdk:"service"`
+	Timer       types.String      `tfsdk:"timer"`
+	ActiveState types.String      `tfsdk:"active_state"`
+	SubState    types.String      `tfsdk:"sub_state"`
+}
+
+func newSystemdRunResource() resource.Resource {
+	return &systemdRunResource{}
+}
+
+func (r *systemdRunResource) Metadata(ctx context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_systemd_run"
+}
+
+func (r *systemdRunResource) Configure(ctx context.Context, req resource.ConfigureRequest, resp *resource.ConfigureResponse) {
+	r.config, _ = req.ProviderData.(*providerConfiguration)
+}
+
+func (r *systemdRunResource) Schema(ctx context.Context, req resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		Description: "Runs a command in a transient systemd service, optionally triggered by a " +
+			"transient timer, like systemd-run does. The unit is stopped on destroy. Transient units " +
+			"are unloaded once they exit, set the RemainAfterExit property for commands that are not " +
+			"expected to keep running or they will be started again.",
+		Version: 1,
+
+		Attributes: map[string]schema.Attribute{
+			"id": schema.StringAttribute{
+				Computed:      true,
+				Description:   "The ID of this resource.",
+				PlanModifiers: []planmodifier.String{stringplanmodifier.UseStateForUnknown()},
+			},
+			"name": schema.StringAttribute{
+				Description:   "Transient unit name, the .service suffix is added if missing",
+				Required:      true,
+				PlanModifiers: []planmodifier.String{stringplanmodifier.RequiresReplace()},
+			},
+			"command": schema.ListAttribute{
+				Description:   "Command and arguments to run",
+				ElementType:   types.StringType,
+				Required:      true,
+				Validators:    []validator.List{listSizeAtLeast(1)},
+				PlanModifiers: []planmodifier.List{listplanmodifier.RequiresReplace()},
+			},
+			"properties": schema.MapAttribute{
+				Description: "Service properties as given to systemd-run -p, such as MemoryMax, User or " +
+					"Environment. Lists are separated by spaces, except Environment which takes one " +
+					"variable per line",
+				ElementType:   types.StringType,
+				Optional:      true,
+				PlanModifiers: []planmodifier.Map{mapplanmodifier.RequiresReplace()},
+			},
+			"on_calendar": schema.StringAttribute{
+				Description:   "Run the service with a transient timer on this calendar event",
+				Optional:      true,
+				PlanModifiers: []planmodifier.String{stringplanmodifier.RequiresReplace()},
+			},
+			"on_active": schema.StringAttribute{
+				Description:   "Run the service with a transient timer after this duration",
+				Optional:      true,
+				Validators:    []validator.String{durationValidator},
+				PlanModifiers: []planmodifier.String{stringplanmodifier.RequiresReplace()},
+			},
+			"system": schema.BoolAttribute{
+				Description:   "Uses the system systemd socket",
+				Optional:      true,
+				PlanModifiers: []planmodifier.Bool{boolplanmodifier.RequiresReplace()},
+			},
+			"user": schema.BoolAttribute{
+				Description:   "Uses the user systemd socket",
+				Optional:      true,
+				PlanModifiers: []planmodifier.Bool{boolplanmodifier.RequiresReplace()},
+			},
+			"user_name": schema.StringAttribute{
+				Description:   "Uses the user manager of this user instead of the current user, requires root",
+				Optional:      true,
+				PlanModifiers: []planmodifier.String{stringplanmodifier.RequiresReplace()},
+			},
+			"service": schema.StringAttribute{
+				Description:   "Name of the transient service",
+				Computed:      true,
+				PlanModifiers: []planmodifier.String{stringplanmodifier.UseStateForUnknown()},
+			},
+			"timer": schema.StringAttribute{
+				Description:   "Name of the transient timer, if any",
+				Computed:      true,
+				PlanModifiers: []planmodifier.String{stringplanmodifier.UseStateForUnknown()},
+			},
+			"active_state": schema.StringAttribute{
+				Description: "Unit active state",
+				Computed:    true,
+			},
+			"sub_state": schema.StringAttribute{
+				Description: "Unit sub-state (specific to the unit type)",
+				Computed:    true,
+			},
+		},
+	}
+}
+
+func (r *systemdRunResource) ConfigValidators(ctx context.Context) []resource.ConfigValidator {
+	return []resource.ConfigValidator{
+		conflictingAttributes{"system", "user"},
+		conflictingAttributes{"system", "user_name"},
+	}
+}
+
+func (r *systemdRunResource) UpgradeState(ctx context.Context) map[int64]resource.StateUpgrader {
+	return sdkStateUpgraders(ctx, r)
+}
+
+type sdTimerSpec struct {
+	Base string
+	Spec string
+}
+
+type sdTimerUSec struct {
+	Base string
+	USec uint64
+}
+
+func (data *systemdRunResourceModel) scope(m *providerConfiguration) sdScope {
+	return newSdScope(data.User.ValueBool(), data.UserName.ValueString(), m)
+}
+
+// resourceSystemdRunUnits returns the service name and the timer name if
+// the service is started by a timer
+func resourceSystemdRunUnits(data *systemdRunResourceModel) (string, string) {
+	base := strings.TrimSuffix(data.Name.ValueString(), ".service")
+
+	if data.OnCalendar.ValueString() == "" && data.OnActive.ValueString() == "" {
+		return base + ".service", ""
+	}
+	return base + ".service", base + ".timer"
+}
+
+func (r *systemdRunResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	var data systemdRunResourceModel
+
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &data)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	service, timer := resourceSystemdRunUnits(&data)
+	scope := data.scope(r.config)
+	lock := sdUnitLock(r.config, scope, service)
+	lock.Lock()
+	defer lock.Unlock()
+
+	props, err := sdTransientPropertiesFrom(data.Properties)
+	if err != nil {
+		resp.Diagnostics.AddError("invalid properties", err.Error())
+		return
+	}
+	props = append(props, systemd.PropExecStart(data.Command, true))
+
+	sd, err := scope.Conn(ctx, r.config)
+	if err != nil {
+		resp.Diagnostics.AddError("cannot connect to systemd", err.Error())
+		return
+	}
+
+	defer sd.Close()
+
+	complete := make(chan string)
+	if timer != "" {
+		var timerProps []systemd.Property
+		if spec := data.OnCalendar.ValueString(); spec != "" {
+			timerProps = append(timerProps, systemd.Property{
+				Name:  "TimersCalendar",
+				Value: dbus.MakeVariant([]sdTimerSpec{{"OnCalendar", spec}}),
+			})
+		}
+		if spec := data.OnActive.ValueString(); spec != "" {
+			usec, _ := sdParseSeconds(spec)
+			timerProps = append(timerProps, systemd.Property{
+				Name:  "TimersMonotonic",
+				Value: dbus.MakeVariant([]sdTimerUSec{{"OnActiveUSec", usec}}),
+			})
+		}
+
+		log.Printf("[DEBUG] Start transient timer %s for %s\n", timer, service)
+		aux := []systemd.PropertyCollection{{Name: service, Properties: props}}
+		_, err = sd.StartTransientUnitAuxContext(ctx, timer, "fail", timerProps, aux, complete)
+		if err != nil {
+			resp.Diagnostics.AddError("cannot start transient timer "+timer, err.Error())
+			return
+		}
+
+		err = sdWaitJob(ctx, timer, complete)
+		if err != nil {
+			resp.Diagnostics.AddError("cannot start transient timer "+timer, err.Error())
+			return
+		}
+	} else {
+		log.Printf("[DEBUG] Start transient service %s\n", service)
+		_, err = sd.StartTransientUnitContext(ctx, service, "fail", props, complete)
+		if err != nil {
+			resp.Diagnostics.AddError("cannot start transient service "+service, err.Error())
+			return
+		}
+
+		err = sdWaitJob(ctx, service, complete)
+		if err != nil {
+			resp.Diagnostics.AddError("cannot start transient service "+service, err.Error())
+			return
+		}
+	}
+
+	data.Id = types.StringValue(service)
+
+	// A unit that already exited is removed by the next refresh
+	if _, err := r.read(ctx, sd, &data); err != nil {
+		resp.Diagnostics.AddError("cannot read the transient unit", err.Error())
+		return
+	}
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+func (r *systemdRunResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	var data systemdRunResourceModel
+
+	resp.Diagnostics.Append(req.State.Get(ctx, &data)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	service, _ := resourceSystemdRunUnits(&data)
+	scope := data.scope(r.config)
+	lock := sdUnitLock(r.config, scope, service)
+	lock.Lock()
+	defer lock.Unlock()
+
+	sd, err := scope.Conn(ctx, r.config)
+	if err != nil {
+		resp.Diagnostics.AddError("cannot connect to systemd", err.Error())
+		return
+	}
+
+	defer sd.Close()
+
+	found, err := r.read(ctx, sd, &data)
+	if err != nil {
+		resp.Diagnostics.AddError("cannot read the transient unit", err.Error())
+		return
+	} else if !found {
+		resp.State.RemoveResource(ctx)
+		return
+	}
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+// read reads the state of the transient unit, it returns false if the unit
+// is gone
+func (r *systemdRunResource) read(ctx context.Context, sd sdManager, data *systemdRunResourceModel) (bool, error) {
+	service, timer := resourceSystemdRunUnits(data)
+
+	unit := service
+	if timer != "" {
+		unit = timer
+	}
+
+	statuses, err := sd.ListUnitsByNamesContext(ctx, []string{unit})
+	if err != nil || len(statuses) < 1 {
+		return false, fmt.Errorf("cannot query unit %s: %v", unit, err)
+	}
+	status := statuses[0]
+
+	data.Service = types.StringValue(service)
+	data.Timer = types.StringValue(timer)
+	data.ActiveState = types.StringValue(status.ActiveState)
+	data.SubState = types.StringValue(status.SubState)
+
+	// Transient units are unloaded once they stop
+	if status.LoadState == systemdNotFound {
+		log.Printf("[DEBUG] Transient unit %s is gone\n", unit)
+		return false, nil
+	}
+
+	return true, nil
+}
+
+func (r *systemdRunResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	var data systemdRunResourceModel
+
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &data)...)
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+func (r *systemdRunResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	var data systemdRunResourceModel
+
+	resp.Diagnostics.Append(req.State.Get(ctx, &data)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	service, timer := resourceSystemdRunUnits(&data)
+	scope := data.scope(r.config)
+	lock := sdUnitLock(r.config, scope, service)
+	lock.Lock()
+	defer lock.Unlock()
+
+	sd, err := scope.Conn(ctx, r.config)
+	if err != nil {
+		resp.Diagnostics.AddError("cannot connect to systemd", err.Error())
+		return
+	}
+
+	defer sd.Close()
+
+	for _, unit := range []string{timer, service} {
+		if unit == "" {
+			continue
+		}
+
+		log.Printf("[DEBUG] Stop transient unit %s\n", unit)
+		complete := make(chan string)
+		_, err = sd.StopUnitContext(ctx, unit, "replace", complete)
+		if err != nil {
+			// The unit was already unloaded
+			if strings.Contains(err.Error(), "not loaded") {
+				continue
+			}
+			resp.Diagnostics.AddError("cannot stop unit "+unit, err.Error())
+			return
+		}
+
+		err = sdWaitJob(ctx, unit, complete)
+		if err != nil {
+			resp.Diagnostics.AddError("cannot stop unit "+unit, err.Error())
+			return
+		}
+
+		// Unload failed units
+		sd.ResetFailedUnitContext(ctx, unit)
+	}
+}
@@ -0,0 +1,70 @@
+package sys
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSystemdRunTimer(t *testing.T) {
+	env := newTestSystemdEnv(t)
+	tf := env.tf
+
+	state := tf.mustApply("sys_systemd_run", nil, map[string]interface{}{
+		"name":        "backup",
+		"command":     []interface{}{"/bin/backup", "--all"},
+		"properties":  map[string]interface{}{"User": "backup"},
+		"on_calendar": "daily",
+	})
+	env.expectCalls("start-transient backup.timer fail backup.service")
+	if state["timer"] != "backup.timer" || state["active_state"] != systemdActive {
+		t.Fatalf("unexpected state %v", state)
+	}
+
+	// The service is started through the timer job, as an auxiliary unit
+	service := env.sd.Units["backup.service"]
+	if service == nil || service.Properties["User"] != "backup" || service.Properties["ExecStart"] == nil {
+		t.Fatalf("expected the service to be created with its properties, got %v", service)
+	}
+	if env.sd.Units["backup.timer"].Properties["TimersCalendar"] == nil {
+		t.Errorf("expected the timer to get its calendar event")
+	}
+
+	tf.mustApply("sys_systemd_run", state, nil)
+	env.expectCalls("stop backup.timer replace", "reset-failed backup.timer", "stop backup.service replace", "reset-failed backup.service")
+}
+
+func TestSystemdRunTimerFailure(t *testing.T) {
+	env := newTestSystemdEnv(t)
+	env.sd.add("backup.timer", "transient").FailStart = true
+	tf := env.tf
+
+	_, errs := tf.apply("sys_systemd_run", nil, map[string]interface{}{
+		"name":      "backup",
+		"command":   []interface{}{"/bin/backup"},
+		"on_active": "1h",
+	})
+	if !strings.Contains(errs, "backup.timer") {
+		t.Errorf("expected the failed timer job to be reported, got %q", errs)
+	}
+
+	if _, errs = tf.apply("sys_systemd_run", nil, map[string]interface{}{
+		"name":    "backup",
+		"command": []interface{}{},
+	}); !strings.Contains(errs, "item minimum") {
+		t.Errorf("expected an empty command to be rejected, got %q", errs)
+	}
+}
+
+func TestSystemdRunRoot(t *testing.T) {
+	_, root := newTestOffline(t)
+	tf := newTestFrameworkEnvWith(t, &providerConfiguration{Host: &rootHost{sysHost: localHost{}, Root: root}})
+
+	_, errs := tf.apply("sys_systemd_run", nil, map[string]interface{}{
+		"name":        "backup",
+		"command":     []interface{}{"/bin/backup"},
+		"on_calendar": "daily",
+	})
+	if !strings.Contains(errs, "without a running systemd manager") {
+		t.Errorf("expected the timer not to start offline, got %q", errs)
+	}
+}
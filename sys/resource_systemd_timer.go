@@ -0,0 +1,537 @@
+package sys
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/booldefault"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/boolplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+type systemdTimerResource struct {
+	config *providerConfiguration
+}
+
+type systemdTimerResourceModel struct {
+	Id                 types.String      `tfsdk:"id"`
+	Name               types.String      `tfsdk:"name"`
+	Command            types.String      `tfsdk:"command"`
+	Description        types.String      `tfsdk:"description"`
+	OnCalendar         types.String      `tfsdk:"on_calendar"`
+	OnBootSec          types.String      `tfsdk:"on_boot_sec"`
+	Persistent         types.Bool        `tfsdk:"persistent"`
+	RandomizedDelaySec types.String      `tfsdk:"randomized_delay_sec"`
+	Service            map[string]string `tfsdk:"service"`
+	Enable             types.Bool        `tfsdk:"enable"`
+	Start              types.Bool        `tfsdk:"start"`
+	System             types.Bool        `tfsdk:"system"`
+	User               types.Bool        `tfsdk:"user"`
+	UserName           types.String      `tfsdk:"user_name"`
+	TimerContent       types.String      `tfsdk:"timer_content"`
+	ServiceContent     types.String      `tfsdk:"service_content"`
+	NextElapse         types.String      `tfsdk:"next_elapse"`
+	LastTrigger        types.String      `tfsdk:"last_trigger"`
+	ActiveState        types.String      `tfsdk:"active_state"`
+}
+
+func newSystemdTimerResource() resource.Resource {
+	return &systemdTimerResource{}
+}
+
+func (r *systemdTimerResource) Metadata(ctx context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_systemd_timer"
+}
+
+func (r *systemdTimerResource) Configure(ctx context.Context, req resource.ConfigureRequest, resp *resource.ConfigureResponse) {
+	r.config, _ = req.ProviderData.(*providerConfiguration)
+}
+
+func (r *systemdTimerResource) Schema(ctx context.Context, req resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		Description: "Runs a command on a schedule with a systemd timer, writing both the .timer " +
+			"and the .service unit files.",
+		Version: 1,
+
+		Attributes: map[string]schema.Attribute{
+			"id": schema.StringAttribute{
+				Computed:      true,
+				Description:   "The ID of this resource.",
+				PlanModifiers: []planmodifier.String{stringplanmodifier.UseStateForUnknown()},
+			},
+			"name": schema.StringAttribute{
+				Description:   "Name of the timer and service units, without suffix",
+				Required:      true,
+				PlanModifiers: []planmodifier.String{stringplanmodifier.RequiresReplace()},
+			},
+			"command": schema.StringAttribute{
+				Description: "Command to run (ExecStart of the service)",
+				Required:    true,
+			},
+			"description": schema.StringAttribute{
+				Description: "Description of the units",
+				Optional:    true,
+			},
+			"on_calendar": schema.StringAttribute{
+				Description: "Calendar event expression triggering the timer (OnCalendar)",
+				Optional:    true,
+			},
+			"on_boot_sec": schema.StringAttribute{
+				Description: "Time after boot when the timer is triggered (OnBootSec)",
+				Optional:    true,
+			},
+			"persistent": schema.BoolAttribute{
+				Description: "Trigger the service on the next start if the timer elapsed while the system was off (Persistent)",
+				Optional:    true,
+			},
+			"randomized_delay_sec": schema.StringAttribute{
+				Description: "Delay the timer by a random amount of time up to this value (RandomizedDelaySec)",
+				Optional:    true,
+			},
+			"service": schema.MapAttribute{
+				Description: "Additional keys for the [Service] section, such as User or Environment, multi-line values are written as repeated keys",
+				ElementType: types.StringType,
+				Optional:    true,
+			},
+			"enable": schema.BoolAttribute{
+				Description: "Enable the timer",
+				Optional:    true,
+				Computed:    true,
+				Default:     booldefault.StaticBool(true),
+			},
+			"start": schema.BoolAttribute{
+				Description: "Start the timer",
+				Optional:    true,
+				Computed:    true,
+				Default:     booldefault.StaticBool(true),
+			},
+			"system": schema.BoolAttribute{
+				Description:   "Uses the system systemd socket",
+				Optional:      true,
+				PlanModifiers: []planmodifier.Bool{boolplanmodifier.RequiresReplace()},
+			},
+			"user": schema.BoolAttribute{
+				Description:   "Uses the user systemd socket",
+				Optional:      true,
+				PlanModifiers: []planmodifier.Bool{boolplanmodifier.RequiresReplace()},
+			},
+			"user_name": schema.StringAttribute{
+				Description:   "Uses the user manager of this user instead of the current user, requires root",
+				Optional:      true,
+				PlanModifiers: []planmodifier.String{stringplanmodifier.RequiresReplace()},
+			},
+			"timer_content": schema.StringAttribute{
+				Description: "Generated timer unit file",
+				Computed:    true,
+			},
+			"service_content": schema.StringAttribute{
+				Description: "Generated service unit file",
+				Computed:    true,
+			},
+			"next_elapse": schema.StringAttribute{
+				Description: "Next time the timer elapses (RFC 3339), empty if unknown",
+				Computed:    true,
+			},
+			"last_trigger": schema.StringAttribute{
+				Description: "Last time the timer was triggered (RFC 3339), empty if never",
+				Computed:    true,
+			},
+			"active_state": schema.StringAttribute{
+				Description: "Timer active state",
+				Computed:    true,
+			},
+		},
+	}
+}
+
+func (r *systemdTimerResource) ConfigValidators(ctx context.Context) []resource.ConfigValidator {
+	return []resource.ConfigValidator{
+		atLeastOneOfAttributes{"on_calendar", "on_boot_sec"},
+		conflictingAttributes{"system", "user"},
+		conflictingAttributes{"system", "user_name"},
+	}
+}
+
+func (r *systemdTimerResource) UpgradeState(ctx context.Context) map[int64]resource.StateUpgrader {
+	return sdkStateUpgraders(ctx, r)
+}
+
+func (data *systemdTimerResourceModel) scope(m *providerConfiguration) sdScope {
+	return newSdScope(data.User.ValueBool(), data.UserName.ValueString(), m)
+}
+
+func resourceSystemdTimerUnits(data *systemdTimerResourceModel) (string, string) {
+	base := strings.TrimSuffix(data.Name.ValueString(), ".timer")
+	return base + ".timer", base + ".service"
+}
+
+// resourceSystemdTimerContent renders the timer and the service unit files
+func resourceSystemdTimerContent(data *systemdTimerResourceModel) (string, string) {
+	timer, _ := resourceSystemdTimerUnits(data)
+
+	description := data.Description.ValueString()
+	if description == "" {
+		description = "Timer " + timer
+	}
+	unit := map[string]string{"Description": description}
+
+	service := map[string]string{
+		"Type":      "oneshot",
+		"ExecStart": data.Command.ValueString(),
+	}
+	for key, value := range data.Service {
+		service[key] = value
+	}
+
+	timerSection := map[string]string{}
+	if v := data.OnCalendar.ValueString(); v != "" {
+		timerSection["OnCalendar"] = v
+	}
+	if v := data.OnBootSec.ValueString(); v != "" {
+		timerSection["OnBootSec"] = v
+	}
+	if data.Persistent.ValueBool() {
+		timerSection["Persistent"] = "true"
+	}
+	if v := data.RandomizedDelaySec.ValueString(); v != "" {
+		timerSection["RandomizedDelaySec"] = v
+	}
+
+	timerContent := sdUnitRender(sdUnitSectionMap{
+		"unit":    unit,
+		"timer":   timerSection,
+		"install": {"WantedBy": "timers.target"},
+	})
+	serviceContent := sdUnitRender(sdUnitSectionMap{
+		"unit":    unit,
+		"service": service,
+	})
+	return timerContent, serviceContent
+}
+
+// sdFormatTimestamp formats a timestamp in microseconds since the epoch
+func sdFormatTimestamp(usec uint64) string {
+	if usec == 0 || usec == ^uint64(0) {
+		return ""
+	}
+	return time.Unix(0, int64(usec)*int64(time.Microsecond)).UTC().Format(time.RFC3339)
+}
+
+func (r *systemdTimerResource) ModifyPlan(ctx context.Context, req resource.ModifyPlanRequest, resp *resource.ModifyPlanResponse) {
+	if req.Plan.Raw.IsNull() {
+		return
+	}
+
+	if !planKnown(ctx, req.Plan, "name", "command", "description", "on_calendar", "on_boot_sec", "persistent", "randomized_delay_sec", "service") {
+		resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, path.Root("timer_content"), types.StringUnknown())...)
+		resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, path.Root("service_content"), types.StringUnknown())...)
+		return
+	}
+
+	var data systemdTimerResourceModel
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &data)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	timerContent, serviceContent := resourceSystemdTimerContent(&data)
+	resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, path.Root("timer_content"), timerContent)...)
+	resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, path.Root("service_content"), serviceContent)...)
+}
+
+func (r *systemdTimerResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	var data systemdTimerResourceModel
+
+	resp.Diagnostics.Append(req.State.Get(ctx, &data)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	timer, _ := resourceSystemdTimerUnits(&data)
+	lock := sdUnitLock(r.config, data.scope(r.config), timer)
+	lock.Lock()
+	defer lock.Unlock()
+
+	found, err := r.read(ctx, &data)
+	if err != nil {
+		resp.Diagnostics.AddError("cannot read the timer", err.Error())
+		return
+	} else if !found {
+		resp.State.RemoveResource(ctx)
+		return
+	}
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+// read reads the timer state, it returns false if the timer is not found
+func (r *systemdTimerResource) read(ctx context.Context, data *systemdTimerResourceModel) (bool, error) {
+	timer, service := resourceSystemdTimerUnits(data)
+	scope := data.scope(r.config)
+
+	sd, err := scope.Conn(ctx, r.config)
+	if err != nil {
+		return false, fmt.Errorf("cannot connect to systemd: %v", err)
+	}
+
+	defer sd.Close()
+
+	statuses, err := sd.ListUnitsByNamesContext(ctx, []string{timer})
+	if err != nil || len(statuses) < 1 {
+		return false, fmt.Errorf("cannot query unit %s: %v", timer, err)
+	}
+	status := statuses[0]
+
+	if status.LoadState == systemdNotFound {
+		return false, nil
+	}
+
+	for _, unit := range []struct {
+		Name    string
+		Content *types.String
+	}{{timer, &data.TimerContent}, {service, &data.ServiceContent}} {
+		filename, err := sdUnitFilePath(scope, unit.Name)
+		if err != nil {
+			return false, err
+		}
+		content, err := sdUnitReadFile(scope.Host(), filename)
+		if err != nil {
+			return false, err
+		}
+		*unit.Content = types.StringValue(content)
+	}
+
+	unitFileState, err := sd.GetUnitFileStateContext(ctx, timer)
+	if err != nil {
+		return false, fmt.Errorf("cannot get unit file state for %s: %v", timer, err)
+	}
+	enabled, _ := sdIsEnabled(unitFileState)
+
+	props, err := sd.GetUnitTypePropertiesContext(ctx, timer, "Timer")
+	if err != nil {
+		return false, fmt.Errorf("cannot get timer properties of %s: %v", timer, err)
+	}
+	next, _ := props["NextElapseUSecRealtime"].(uint64)
+	last, _ := props["LastTriggerUSec"].(uint64)
+
+	data.Enable = types.BoolValue(enabled)
+	data.Start = types.BoolValue(sdIsActive(status.ActiveState))
+	data.ActiveState = types.StringValue(status.ActiveState)
+	data.NextElapse = types.StringValue(sdFormatTimestamp(next))
+	data.LastTrigger = types.StringValue(sdFormatTimestamp(last))
+
+	return true, nil
+}
+
+// write writes the unit files that changed from the prior state, nil on
+// creation, then enables and starts the timer as configured
+func (r *systemdTimerResource) write(ctx context.Context, data, prior *systemdTimerResourceModel) error {
+	timer, service := resourceSystemdTimerUnits(data)
+	scope := data.scope(r.config)
+	lock := sdUnitLock(r.config, scope, timer)
+	lock.Lock()
+	defer lock.Unlock()
+
+	timerContent, serviceContent := resourceSystemdTimerContent(data)
+
+	changed := false
+	for _, unit := range []struct {
+		Name    string
+		Content string
+		Changed bool
+	}{
+		{timer, timerContent, prior == nil || prior.TimerContent.ValueString() != timerContent},
+		{service, serviceContent, prior == nil || prior.ServiceContent.ValueString() != serviceContent},
+	} {
+		if !unit.Changed {
+			continue
+		}
+
+		filename, err := sdUnitFilePath(scope, unit.Name)
+		if err != nil {
+			return err
+		}
+
+		log.Printf("[DEBUG] Write unit file %s\n", filename)
+		err = scope.WriteFile(filename, unit.Content)
+		if err != nil {
+			return err
+		}
+		changed = true
+	}
+
+	sd, err := scope.Conn(ctx, r.config)
+	if err != nil {
+		return fmt.Errorf("cannot connect to systemd: %v", err)
+	}
+
+	defer sd.Close()
+
+	err = sd.ReloadContext(ctx)
+	if err != nil {
+		return fmt.Errorf("cannot reload systemd: %v", err)
+	}
+
+	unitFileState, err := sd.GetUnitFileStateContext(ctx, timer)
+	if err != nil {
+		return fmt.Errorf("cannot get unit file state for %s: %v", timer, err)
+	}
+
+	enabled, _ := sdIsEnabled(unitFileState)
+	enable := data.Enable.ValueBool()
+	if enable && !enabled {
+		log.Printf("[DEBUG] Enable %s\n", timer)
+		_, _, err = sd.EnableUnitFilesContext(ctx, []string{timer}, false, true)
+	} else if !enable && enabled {
+		log.Printf("[DEBUG] Disable %s\n", timer)
+		_, err = sd.DisableUnitFilesContext(ctx, []string{timer}, false)
+	}
+	if err != nil {
+		return fmt.Errorf("cannot %s unit %s: %v", sdEnableString(enable), timer, err)
+	}
+
+	statuses, err := sd.ListUnitsByNamesContext(ctx, []string{timer})
+	if err != nil || len(statuses) < 1 {
+		return fmt.Errorf("cannot query unit %s: %v", timer, err)
+	}
+	active := sdIsActive(statuses[0].ActiveState)
+
+	start := data.Start.ValueBool()
+	if start && (!active || changed) || !start && active {
+		complete := make(chan string)
+		if start {
+			// Restart so a changed schedule is taken into account
+			log.Printf("[DEBUG] Restart %s\n", timer)
+			_, err = sd.RestartUnitContext(ctx, timer, "replace", complete)
+		} else {
+			log.Printf("[DEBUG] Stop %s\n", timer)
+			_, err = sd.StopUnitContext(ctx, timer, "replace", complete)
+		}
+		if err != nil {
+			return fmt.Errorf("cannot %s unit %s: %v", sdStartString(start), timer, err)
+		}
+
+		err = sdWaitJob(ctx, timer, complete)
+		if err != nil {
+			return err
+		}
+	}
+
+	data.Id = types.StringValue(timer)
+
+	_, err = r.read(ctx, data)
+	return err
+}
+
+func (r *systemdTimerResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	var data systemdTimerResourceModel
+
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &data)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	if err := r.write(ctx, &data, nil); err != nil {
+		resp.Diagnostics.AddError("cannot write the timer", err.Error())
+		return
+	}
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+func (r *systemdTimerResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	var data, state systemdTimerResourceModel
+
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &data)...)
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	if err := r.write(ctx, &data, &state); err != nil {
+		resp.Diagnostics.AddError("cannot write the timer", err.Error())
+		return
+	}
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+func (r *systemdTimerResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	var data systemdTimerResourceModel
+
+	resp.Diagnostics.Append(req.State.Get(ctx, &data)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	timer, service := resourceSystemdTimerUnits(&data)
+	scope := data.scope(r.config)
+	lock := sdUnitLock(r.config, scope, timer)
+	lock.Lock()
+	defer lock.Unlock()
+
+	if err := sdTimerRemove(ctx, r.config, scope, timer, service); err != nil {
+		resp.Diagnostics.AddError("cannot remove the timer", err.Error())
+	}
+}
+
+// sdTimerRemove stops and disables the timer and removes its unit files
+func sdTimerRemove(ctx context.Context, m *providerConfiguration, scope sdScope, timer, service string) error {
+	sd, err := scope.Conn(ctx, m)
+	if err != nil {
+		return fmt.Errorf("cannot connect to systemd: %v", err)
+	}
+
+	defer sd.Close()
+
+	for _, unit := range []string{timer, service} {
+		log.Printf("[DEBUG] Stop %s\n", unit)
+		complete := make(chan string)
+		_, err = sd.StopUnitContext(ctx, unit, "replace", complete)
+		if err != nil {
+			return fmt.Errorf("cannot stop unit %s: %v", unit, err)
+		}
+		err = sdWaitJob(ctx, unit, complete)
+		if err != nil {
+			return err
+		}
+	}
+
+	log.Printf("[DEBUG] Disable %s\n", timer)
+	_, err = sd.DisableUnitFilesContext(ctx, []string{timer}, false)
+	if err != nil {
+		return fmt.Errorf("cannot disable unit %s: %v", timer, err)
+	}
+
+	for _, unit := range []string{timer, service} {
+		filename, err := sdUnitFilePath(scope, unit)
+		if err != nil {
+			return err
+		}
+
+		log.Printf("[DEBUG] Remove unit file %s\n", filename)
+		err = sdUnitRemoveFile(scope.Host(), filename)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = sd.ReloadContext(ctx)
+	if err != nil {
+		return fmt.Errorf("cannot reload systemd: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,53 @@
+package sys
+
+import (
+	"os"
+	"path"
+	"testing"
+)
+
+func TestSystemdTimer(t *testing.T) {
+	env := newTestSystemdEnv(t)
+	env.sd.add("backup.timer", systemdDisabled)
+	env.sd.add("backup.service", "static")
+	root := t.TempDir()
+	env.meta.Host = &rootHost{sysHost: localHost{}, Root: root}
+	tf := env.tf
+
+	config := map[string]interface{}{
+		"name":        "backup",
+		"command":     "/bin/backup",
+		"on_calendar": "daily",
+	}
+	state := tf.mustApply("sys_systemd_timer", nil, config)
+	env.expectCalls("enable backup.timer", "restart backup.timer replace")
+	env.expectUnit("backup.timer", systemdEnabled, systemdActive)
+
+	if state["id"] != "backup.timer" || state["enable"] != true || state["start"] != true || state["active_state"] != systemdActive {
+		t.Fatalf("unexpected state %v", state)
+	}
+	for _, unit := range []string{"backup.timer", "backup.service"} {
+		content, err := os.ReadFile(path.Join(root, sdSystemUnitDir, unit))
+		attr := "timer_content"
+		if unit == "backup.service" {
+			attr = "service_content"
+		}
+		if err != nil || string(content) != state[attr] {
+			t.Errorf("unexpected %s %q (%v)", unit, content, err)
+		}
+	}
+
+	// The timer is restarted only when its schedule changes
+	tf.mustApply("sys_systemd_timer", state, config)
+	env.expectCalls()
+
+	config["on_calendar"] = "weekly"
+	state = tf.mustApply("sys_systemd_timer", state, config)
+	env.expectCalls("restart backup.timer replace")
+
+	tf.mustApply("sys_systemd_timer", state, nil)
+	env.expectCalls("stop backup.timer replace", "stop backup.service replace", "disable backup.timer")
+	if _, err := os.Lstat(path.Join(root, sdSystemUnitDir, "backup.timer")); !os.IsNotExist(err) {
+		t.Errorf("expected the timer unit file to be removed, got %v", err)
+	}
+}
@@ -6,115 +6,287 @@ import (
 	"log"
 	"strconv"
 	"sync"
-
-	systemd "github.com/coreos/go-systemd/v22/dbus"
-
-	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
-	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"syscall"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/booldefault"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/mapplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringdefault"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
 )
 
 // https://www.freedesktop.org/wiki/Software/systemd/dbus/
 
-func resourceSystemdUnit() *schema.Resource {
-	return &schema.Resource{
-		CreateContext: resourceSystemdUnitCreate,
-		ReadContext:   resourceSystemdUnitRead,
-		DeleteContext: resourceSystemdUnitDelete,
-		UpdateContext: resourceSystemdUnitUpdate,
+type systemdUnitResource struct {
+	config *providerConfiguration
+}
+
+type systemdUnitResourceModel struct {
+	Id             types.String        `tfsdk:"id"`
+	Name           types.String        `tfsdk:"name"`
+	Enable         types.Bool          `tfsdk:"enable"`
+	Mask           types.Bool          `tfsdk:"mask"`
+	Also           []string            `tfsdk:"also"`
+	Runtime        types.Bool          `tfsdk:"runtime"`
+	Preset         types.Bool          `tfsdk:"preset"`
+	RollbackMode   types.String        `tfsdk:"rollback_mode"`
+	Start          types.Bool          `tfsdk:"start"`
+	Content        types.String        `tfsdk:"content"`
+	Unit           map[string]string   `tfsdk:"unit"`
+	Service        map[string]string   `tfsdk:"service"`
+	Timer          map[string]string   `tfsdk:"timer"`
+	Install        map[string]string   `tfsdk:"install"`
+	Path           types.String        `tfsdk:"path"`
+	RestartOn      types.Map           `tfsdk:"restart_on"`
+	ReloadOn       types.Map           `tfsdk:"reload_on"`
+	TryRestart     types.Bool          `tfsdk:"try_restart"`
+	JobMode        types.String        `tfsdk:"job_mode"`
+	Kill           types.Bool          `tfsdk:"kill"`
+	ResetFailed    types.Bool          `tfsdk:"reset_failed"`
+	Rollback       types.Map           `tfsdk:"rollback"`
+	System         types.Bool          `tfsdk:"system"`
+	User           types.Bool          `tfsdk:"user"`
+	UserName       types.String        `tfsdk:"user_name"`
+	Linger         types.Bool          `tfsdk:"linger"`
+	Description    types.String        `tfsdk:"description"`
+	LoadState      types.String        `tfsdk:"load_state"`
+	ActiveState    types.String        `tfsdk:"active_state"`
+	SubState       types.String        `tfsdk:"sub_state"`
+	Followed       types.String        `tfsdk:"followed"`
+	JobId          types.Int64         `tfsdk:"job_id"`
+	JobType        types.String        `tfsdk:"job_type"`
+	Triggers       types.List          `tfsdk:"triggers"`
+	TriggeredBy    types.List          `tfsdk:"triggered_by"`
+	WaitActive     []sdWaitActiveModel `tfsdk:"wait_active"`
+	Properties     []string            `tfsdk:"properties"`
+	PropertyValues types.Map           `tfsdk:"property_values"`
+	IgnoreErrors   types.Bool          `tfsdk:"ignore_errors"`
+}
+
+func newSystemdUnitResource() resource.Resource {
+	return &systemdUnitResource{}
+}
+
+func (r *systemdUnitResource) Metadata(ctx context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_systemd_unit"
+}
+
+func (r *systemdUnitResource) Configure(ctx context.Context, req resource.ConfigureRequest, resp *resource.ConfigureResponse) {
+	r.config, _ = req.ProviderData.(*providerConfiguration)
+}
 
+func (r *systemdUnitResource) Schema(ctx context.Context, req resource.SchemaRequest, resp *resource.SchemaResponse) {
+	section := func(name string) schema.MapAttribute {
+		return schema.MapAttribute{
+			Description: "[" + name + "] section of the unit file, multi-line values are written as repeated keys",
+			ElementType: types.StringType,
+			Optional:    true,
+		}
+	}
+
+	resp.Schema = schema.Schema{
 		Description: "Handles a systemd unit with the dBus API.",
+		Version:     1,
 
-		Schema: map[string]*schema.Schema{
-			"name": {
-				Description: "systemd unit name",
-				Type:        schema.TypeString,
-				Required:    true,
-				ForceNew:    true,
-			},
-			"enable": {
-				Description:      "Enable the unit",
-				Type:             schema.TypeBool,
-				Optional:         true,
-				DiffSuppressFunc: diffSuppressIfNil,
-			},
-			"mask": {
-				Description:      "Mask the unit",
-				Type:             schema.TypeBool,
-				Optional:         true,
-				DiffSuppressFunc: diffSuppressIfNil,
-			},
-			"start": {
-				Description:      "Start the unit",
-				Type:             schema.TypeBool,
-				Optional:         true,
-				DiffSuppressFunc: diffSuppressIfNil,
-			},
-			"restart_on": {
-				Description: "Restart unit if this changes",
-				Type:        schema.TypeMap,
+		Attributes: map[string]schema.Attribute{
+			"id": schema.StringAttribute{
+				Computed:      true,
+				Description:   "The ID of this resource.",
+				PlanModifiers: []planmodifier.String{stringplanmodifier.UseStateForUnknown()},
+			},
+			"name": schema.StringAttribute{
+				Description:   "systemd unit name",
+				Required:      true,
+				PlanModifiers: []planmodifier.String{stringplanmodifier.RequiresReplace()},
+			},
+			"enable": schema.BoolAttribute{
+				Description:   "Enable the unit",
+				Optional:      true,
+				Computed:      true,
+				PlanModifiers: []planmodifier.Bool{keepStateIfUnset{}},
+			},
+			"mask": schema.BoolAttribute{
+				Description:   "Mask the unit",
+				Optional:      true,
+				Computed:      true,
+				PlanModifiers: []planmodifier.Bool{keepStateIfUnset{}},
+			},
+			"also": schema.ListAttribute{
+				Description: "Units enabled and disabled together with the unit, like Also= in the [Install] section. They are stopped while the unit restarts and when it stops, units triggering the unit such as its socket being stopped first so they cannot activate it again",
+				ElementType: types.StringType,
+				Optional:    true,
+			},
+			"runtime": schema.BoolAttribute{
+				Description: "Enable and mask the unit until the next reboot only, in /run (systemctl --runtime)",
+				Optional:    true,
+			},
+			"preset": schema.BoolAttribute{
+				Description: "Enable or disable the unit according to the preset policy (systemctl preset) on creation, before `enable` and `mask` are applied",
 				Optional:    true,
 			},
-			"rollback": {
-				Description: "Rollback information to restore once the unit is destroyed",
-				Type:        schema.TypeMap,
+			"rollback_mode": schema.StringAttribute{
+				Description: "How the unit file state is restored once the unit is no longer managed: `restore` enables or disables the unit as found on creation, `preset` applies the preset policy",
+				Optional:    true,
 				Computed:    true,
+				Default:     stringdefault.StaticString(sdRollbackRestore),
+				Validators:  []validator.String{stringOneOf{sdRollbackRestore, sdRollbackPreset}},
 			},
-			"system": {
-				Description:   "Uses the system systemd socket",
-				Type:          schema.TypeBool,
+			"start": schema.BoolAttribute{
+				Description:   "Start the unit",
 				Optional:      true,
-				ConflictsWith: []string{"user"},
+				Computed:      true,
+				PlanModifiers: []planmodifier.Bool{keepStateIfUnset{}},
+			},
+			"content": schema.StringAttribute{
+				Description: "Unit file content, written to the system or user unit directory. Conflicts with the structured `unit`, `service`, `timer` and `install` sections",
+				Optional:    true,
+				Computed:    true,
+			},
+			"unit":    section("Unit"),
+			"service": section("Service"),
+			"timer":   section("Timer"),
+			"install": section("Install"),
+			"path": schema.StringAttribute{
+				Description: "Path of the unit file when its content is managed",
+				Computed:    true,
+			},
+			"restart_on": schema.MapAttribute{
+				Description: "Restart unit if this changes",
+				ElementType: types.StringType,
+				Optional:    true,
+			},
+			"reload_on": schema.MapAttribute{
+				Description: "Reload unit if this changes, restarting it if it does not support reloading",
+				ElementType: types.StringType,
+				Optional:    true,
+			},
+			"try_restart": schema.BoolAttribute{
+				Description: "When `start` is not set, restart or reload the unit on changes only if it is running, without starting or stopping it otherwise",
+				Optional:    true,
+			},
+			"job_mode": schema.StringAttribute{
+				Description: "Job mode used to start, stop, restart or reload the unit: replace, fail, ignore-dependencies, ignore-requirements, flush or replace-irreversibly. Modes only valid for some jobs such as isolate are rejected",
+				Optional:    true,
+				Computed:    true,
+				Default:     stringdefault.StaticString("replace"),
+				Validators: []validator.String{stringOneOf{
+					"replace", "fail", "ignore-dependencies",
+					"ignore-requirements", "flush", "replace-irreversibly",
+				}},
+			},
+			"kill": schema.BoolAttribute{
+				Description: "Kill the remaining processes of a failed unit before starting it",
+				Optional:    true,
+			},
+			"reset_failed": schema.BoolAttribute{
+				Description: "Reset the failed state of a failed unit before starting it",
+				Optional:    true,
+			},
+			"rollback": schema.MapAttribute{
+				Description:   "Rollback information to restore once the unit is destroyed",
+				ElementType:   types.StringType,
+				Computed:      true,
+				PlanModifiers: []planmodifier.Map{mapplanmodifier.UseStateForUnknown()},
+			},
+			"system": schema.BoolAttribute{
+				Description: "Uses the system systemd socket",
+				Optional:    true,
+			},
+			"user": schema.BoolAttribute{
+				Description: "Uses the user systemd socket",
+				Optional:    true,
 			},
-			"user": {
-				Description:   "Uses the user systemd socket",
-				Type:          schema.TypeBool,
+			"user_name": schema.StringAttribute{
+				Description:   "Uses the user manager of this user instead of the current user, requires root",
 				Optional:      true,
-				ConflictsWith: []string{"system"},
+				PlanModifiers: []planmodifier.String{stringplanmodifier.RequiresReplace()},
 			},
-			"description": {
+			"linger": schema.BoolAttribute{
+				Description: "Enable lingering for `user_name` so its user manager runs without a session",
+				Optional:    true,
+			},
+			"description": schema.StringAttribute{
 				Description: "Unit description",
-				Type:        schema.TypeString,
 				Computed:    true,
 			},
-			"load_state": {
+			"load_state": schema.StringAttribute{
 				Description: "Unit load state",
-				Type:        schema.TypeString,
 				Computed:    true,
 			},
-			"active_state": {
+			"active_state": schema.StringAttribute{
 				Description: "Unit active state",
-				Type:        schema.TypeString,
 				Computed:    true,
 			},
-			"sub_state": {
+			"sub_state": schema.StringAttribute{
 				Description: "Unit sub-state (specific to the unit type)",
-				Type:        schema.TypeString,
 				Computed:    true,
 			},
-			"followed": {
-				Type:     schema.TypeString,
+			"followed": schema.StringAttribute{
 				Computed: true,
 			},
-			"job_id": {
+			"job_id": schema.Int64Attribute{
 				Description: "Internal systemd job id",
-				Type:        schema.TypeInt,
 				Computed:    true,
 			},
-			"job_type": {
+			"job_type": schema.StringAttribute{
 				Description: "Internal systemd job type",
-				Type:        schema.TypeString,
 				Computed:    true,
 			},
-			"ignore_errors": {
+			"triggers": schema.ListAttribute{
+				Description: "Units activated by this unit, such as the service of a socket or a timer",
+				ElementType: types.StringType,
+				Computed:    true,
+			},
+			"triggered_by": schema.ListAttribute{
+				Description: "Units that can activate this unit, such as its socket or timer",
+				ElementType: types.StringType,
+				Computed:    true,
+			},
+			"properties": schema.ListAttribute{
+				Description: "Unit properties to read in `property_values`, such as MainPID, NRestarts or ActiveEnterTimestamp",
+				ElementType: types.StringType,
+				Optional:    true,
+			},
+			"property_values": schema.MapAttribute{
+				Description: "Values of the unit properties listed in `properties`",
+				ElementType: types.StringType,
+				Computed:    true,
+			},
+			"ignore_errors": schema.BoolAttribute{
 				Description: "Ignore errors",
-				Type:        schema.TypeBool,
 				Optional:    true,
-				Default:     false,
+				Computed:    true,
+				Default:     booldefault.StaticBool(false),
 			},
 		},
+
+		Blocks: map[string]schema.Block{
+			"wait_active": sdWaitActiveBlock(),
+		},
+	}
+}
+
+func (r *systemdUnitResource) ConfigValidators(ctx context.Context) []resource.ConfigValidator {
+	return []resource.ConfigValidator{
+		conflictingAttributes{"content", "unit"},
+		conflictingAttributes{"content", "service"},
+		conflictingAttributes{"content", "timer"},
+		conflictingAttributes{"content", "install"},
+		conflictingAttributes{"system", "user"},
+		conflictingAttributes{"system", "user_name"},
 	}
 }
 
+func (r *systemdUnitResource) UpgradeState(ctx context.Context) map[int64]resource.StateUpgrader {
+	return sdkStateUpgraders(ctx, r)
+}
+
 const (
 	// Unit File State
 	systemdEnabled        = "enabled"
@@ -149,9 +321,28 @@ const (
 	systemdRunning = "running"
 )
 
-func diffSuppressIfNil(k, old, new string, d *schema.ResourceData) bool {
-	_, has_value := d.GetOkExists(k)
-	return !has_value
+const (
+	// Rollback modes
+	sdRollbackRestore = "restore"
+	sdRollbackPreset  = "preset"
+)
+
+// keepStateIfUnset plans the prior state of an attribute that is not
+// configured, so the unit state it reflects is no longer managed
+type keepStateIfUnset struct{}
+
+func (m keepStateIfUnset) Description(ctx context.Context) string {
+	return "the prior state is kept when the attribute is not configured"
+}
+
+func (m keepStateIfUnset) MarkdownDescription(ctx context.Context) string {
+	return m.Description(ctx)
+}
+
+func (m keepStateIfUnset) PlanModifyBool(ctx context.Context, req planmodifier.BoolRequest, resp *planmodifier.BoolResponse) {
+	if req.ConfigValue.IsNull() {
+		resp.PlanValue = req.StateValue
+	}
 }
 
 func sdIsActive(active_state string) bool {
@@ -197,6 +388,15 @@ func sdIsEnabled(unit_file_state string) (bool, bool) {
 	}
 }
 
+// sdIsEnabledBy is sdIsEnabled for the resource, runtime enabled units can be
+// disabled when the resource manages runtime state
+func sdIsEnabledBy(runtime bool, unit_file_state string) (bool, bool) {
+	if unit_file_state == systemdEnabledRuntime && runtime {
+		return true, true
+	}
+	return sdIsEnabled(unit_file_state)
+}
+
 func sdIsMasked(unit_file_state string) bool {
 	switch unit_file_state {
 	case systemdMasked, systemdMaskedRuntime:
@@ -206,27 +406,14 @@ func sdIsMasked(unit_file_state string) bool {
 	}
 }
 
-func sdUser(ctx context.Context, m *providerConfiguration) (*systemd.Conn, error) {
-	return systemd.NewUserConnectionContext(ctx)
-}
-
-func sdSystem(ctx context.Context, m *providerConfiguration) (*systemd.Conn, error) {
-	return systemd.NewSystemConnectionContext(ctx)
-}
-
-func sdConn(ctx context.Context, d *schema.ResourceData, m interface{}) (*systemd.Conn, error) {
-	user := d.Get("user").(bool)
-	if user {
-		return sdUser(ctx, m.(*providerConfiguration))
-	} else {
-		return sdSystem(ctx, m.(*providerConfiguration))
-	}
-}
-
-func sdUnitLock(m interface{}, unit string) sync.Locker {
+// sdUnitLock returns the lock for a unit, units of different managers having
+// separate locks
+func sdUnitLock(m interface{}, scope sdScope, unit string) sync.Locker {
 	c := m.(*providerConfiguration)
 	var lock sync.Locker
 
+	unit = scope.Bus() + "/" + unit
+
 	c.Lock.Lock()
 	defer c.Lock.Unlock()
 
@@ -245,73 +432,204 @@ func sdUnitLock(m interface{}, unit string) sync.Locker {
 	return lock
 }
 
-func withSeverity(d *schema.ResourceData, errs diag.Diagnostics) diag.Diagnostics {
-	if d.Get("ignore_errors").(bool) {
-		for i := range errs {
-			errs[i].Severity = diag.Warning
+func (data *systemdUnitResourceModel) scope(m *providerConfiguration) sdScope {
+	return newSdScope(data.User.ValueBool(), data.UserName.ValueString(), m)
+}
+
+// units returns the unit followed by its also units
+func (data *systemdUnitResourceModel) units() []string {
+	return append([]string{data.Name.ValueString()}, data.Also...)
+}
+
+// rollback returns the rollback information captured on creation
+func (data *systemdUnitResourceModel) rollback(ctx context.Context) map[string]string {
+	rollback := map[string]string{}
+	data.Rollback.ElementsAs(ctx, &rollback, false)
+	return rollback
+}
+
+// withSeverity reports the errors as warnings when ignore_errors is set
+func (data *systemdUnitResourceModel) withSeverity(errs diag.Diagnostics) diag.Diagnostics {
+	if !data.IgnoreErrors.ValueBool() {
+		return errs
+	}
+
+	var res diag.Diagnostics
+	for _, err := range errs {
+		res.AddWarning(err.Summary(), err.Detail())
+	}
+	return res
+}
+
+// failure reports an error applying the unit, subject to ignore_errors
+func (data *systemdUnitResourceModel) failure(summary string, err error) diag.Diagnostics {
+	var errs diag.Diagnostics
+	errs.AddError(summary, err.Error())
+	return data.withSeverity(errs)
+}
+
+func (r *systemdUnitResource) ModifyPlan(ctx context.Context, req resource.ModifyPlanRequest, resp *resource.ModifyPlanResponse) {
+	if req.Plan.Raw.IsNull() {
+		return
+	}
+
+	if planKnown(ctx, req.Plan, "wait_active") {
+		var waitActive []sdWaitActiveModel
+		resp.Diagnostics.Append(req.Plan.GetAttribute(ctx, path.Root("wait_active"), &waitActive)...)
+		if w := sdWaitActiveFrom(waitActive, providerHost(r.config)); w != nil {
+			if err := w.validate(); err != nil {
+				resp.Diagnostics.AddAttributeError(path.Root("wait_active"), "Invalid wait_active", err.Error())
+			}
 		}
 	}
-	return errs
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	if !planKnown(ctx, req.Plan, "unit", "service", "timer", "install") {
+		resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, path.Root("content"), types.StringUnknown())...)
+		return
+	}
+
+	sections := sdUnitSectionMap{}
+	hasSections := false
+	for _, s := range sdUnitSections {
+		var values map[string]string
+		resp.Diagnostics.Append(req.Plan.GetAttribute(ctx, path.Root(s.Attr), &values)...)
+		sections[s.Attr] = values
+		hasSections = hasSections || len(values) > 0
+	}
+
+	var content types.String
+	resp.Diagnostics.Append(req.Config.GetAttribute(ctx, path.Root("content"), &content)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	if !hasSections && content.IsNull() {
+		// The unit file is not managed, or no longer and removed on update
+		resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, path.Root("path"), "")...)
+		resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, path.Root("content"), "")...)
+		return
+	}
+
+	if hasSections {
+		resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, path.Root("content"), sdUnitRender(sections))...)
+	}
+
+	if !planKnown(ctx, req.Plan, "name", "user", "user_name") {
+		return
+	}
+
+	var data systemdUnitResourceModel
+	resp.Diagnostics.Append(req.Plan.GetAttribute(ctx, path.Root("name"), &data.Name)...)
+	resp.Diagnostics.Append(req.Plan.GetAttribute(ctx, path.Root("user"), &data.User)...)
+	resp.Diagnostics.Append(req.Plan.GetAttribute(ctx, path.Root("user_name"), &data.UserName)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	filename, err := sdUnitFilePath(data.scope(r.config), data.Name.ValueString())
+	if err != nil {
+		resp.Diagnostics.AddError("cannot find the unit file path", err.Error())
+		return
+	}
+
+	resp.Diagnostics.Append(resp.Plan.SetAttribute(ctx, path.Root("path"), filename)...)
 }
 
-func resourceSystemdUnitRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
-	unit := d.Get("name").(string)
+func (r *systemdUnitResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	var data systemdUnitResourceModel
+
+	resp.Diagnostics.Append(req.State.Get(ctx, &data)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	unit := data.Name.ValueString()
+	scope := data.scope(r.config)
 	log.Printf("[DEBUG] About to read %s\n", unit)
-	lock := sdUnitLock(m, unit)
+	lock := sdUnitLock(r.config, scope, unit)
 	lock.Lock()
 	defer lock.Unlock()
 
-	return resourceSystemdUnitReadUnlocked(ctx, d, m)
-}
+	if filename := data.Path.ValueString(); filename != "" {
+		content, err := sdUnitReadFile(scope.Host(), filename)
+		if err != nil {
+			resp.Diagnostics.AddError("cannot read the unit file", err.Error())
+			return
+		}
+		data.Content = types.StringValue(content)
+	}
 
-func resourceSystemdUnitReadUnlocked(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
-	sd, err := sdConn(ctx, d, m)
+	sd, err := scope.Conn(ctx, r.config)
 	if err != nil {
-		return diag.Errorf("cannot connect to systemd: %v", err)
+		resp.Diagnostics.AddError("cannot connect to systemd", err.Error())
+		return
 	}
 
 	defer sd.Close()
 
-	var errs diag.Diagnostics
-	unit := d.Get("name").(string)
+	found, err := r.read(ctx, sd, &data, true)
+	if err != nil {
+		resp.Diagnostics.AddError("cannot read unit "+unit, err.Error())
+		return
+	} else if !found {
+		resp.State.RemoveResource(ctx)
+		return
+	}
 
-	err = sd.ReloadContext(ctx)
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+// read reads the state of the unit, returning whether it exists. The
+// rollback information is captured on the first read. The configured start,
+// enable and mask are only replaced by the unit state on refresh, not while
+// they are applied.
+func (r *systemdUnitResource) read(ctx context.Context, sd sdManager, data *systemdUnitResourceModel, refresh bool) (bool, error) {
+	unit := data.Name.ValueString()
+
+	err := sd.ReloadContext(ctx)
 	if err != nil {
-		return diag.Errorf("cannot reload systemd: %v", err)
+		return false, fmt.Errorf("cannot reload systemd: %v", err)
 	}
 
 	statuses, err := sd.ListUnitsByNamesContext(ctx, []string{unit})
 	if err != nil || len(statuses) < 1 {
-		return diag.Errorf("cannot query unit %s: %v", unit, err)
+		return false, fmt.Errorf("cannot query unit %s: %v", unit, err)
 	}
 	status := statuses[0]
 
-	d.Set("description", status.Description)
-	d.Set("load_state", status.LoadState)
-	d.Set("active_state", status.ActiveState)
-	d.Set("sub_state", status.SubState)
-	d.Set("followed", status.Followed)
-	d.Set("job_id", status.JobId)
-	d.Set("job_type", status.JobType)
+	data.Description = types.StringValue(status.Description)
+	data.LoadState = types.StringValue(status.LoadState)
+	data.ActiveState = types.StringValue(status.ActiveState)
+	data.SubState = types.StringValue(status.SubState)
+	data.Followed = types.StringValue(status.Followed)
+	data.JobId = types.Int64Value(int64(status.JobId))
+	data.JobType = types.StringValue(status.JobType)
 
-	var rollback = map[string]interface{}{
+	rollback := map[string]string{
 		"exists":       strconv.FormatBool(status.LoadState != systemdNotFound),
 		"load_state":   status.LoadState,
 		"active_state": status.ActiveState,
 		"sub_state":    status.SubState,
 	}
 
-	if status.LoadState == systemdNotFound {
-		d.SetId("")
-	} else {
-		d.SetId(status.Name)
+	found := status.LoadState != systemdNotFound
+	props := map[string]string{}
+	triggers, triggeredBy := []string{}, []string{}
+
+	if found {
+		data.Id = types.StringValue(status.Name)
 
 		unitFileState, err := sd.GetUnitFileStateContext(ctx, status.Name)
 		if err != nil {
-			return diag.Errorf("cannot get unit file state for %s: %v", status.Name, err)
+			return false, fmt.Errorf("cannot get unit file state for %s: %v", status.Name, err)
 		}
 
-		enabled, enableable := sdIsEnabled(unitFileState)
+		enabled, enableable := sdIsEnabledBy(data.Runtime.ValueBool(), unitFileState)
 		active := sdIsActive(status.ActiveState)
 		masked := sdIsMasked(status.LoadState)
 		rollback["active"] = strconv.FormatBool(active)
@@ -319,134 +637,288 @@ func resourceSystemdUnitReadUnlocked(ctx context.Context, d *schema.ResourceData
 		rollback["masked"] = strconv.FormatBool(masked)
 		rollback["unit_file_state"] = unitFileState
 
-		if _, has_start := d.GetOkExists("start"); has_start {
-			d.Set("start", active)
+		if refresh {
+			if !data.Start.IsNull() {
+				data.Start = types.BoolValue(active)
+			}
+			if !data.Enable.IsNull() && enableable {
+				data.Enable = types.BoolValue(enabled)
+			}
+			if !data.Mask.IsNull() {
+				data.Mask = types.BoolValue(masked)
+			}
 		}
-		if _, has_enable := d.GetOkExists("enable"); has_enable && enableable {
-			d.Set("enable", enabled)
+
+		props, err = sdUnitProperties(ctx, sd, status.Name, data.Properties)
+		if err != nil {
+			return false, err
 		}
-		if _, has_mask := d.GetOkExists("mask"); has_mask {
-			d.Set("mask", masked)
+
+		triggers, triggeredBy, err = sdUnitTriggers(ctx, sd, status.Name)
+		if err != nil {
+			return false, err
 		}
 	}
 
-	if _, ok := d.GetOk("rollback"); !ok {
-		err := d.Set("rollback", rollback)
-		if err != nil {
-			errs = append(errs, diag.FromErr(err)...)
+	data.PropertyValues, _ = types.MapValueFrom(ctx, types.StringType, props)
+	data.Triggers, _ = types.ListValueFrom(ctx, types.StringType, triggers)
+	data.TriggeredBy, _ = types.ListValueFrom(ctx, types.StringType, triggeredBy)
+
+	if data.Rollback.IsUnknown() || data.Rollback.IsNull() {
+		data.Rollback, _ = types.MapValueFrom(ctx, types.StringType, rollback)
+	}
+
+	return found, nil
+}
+
+// resourceSystemdUnitWriteFile writes the unit file content, or removes the
+// previous unit file if it is no longer managed
+func resourceSystemdUnitWriteFile(scope sdScope, oldPath string, data *systemdUnitResourceModel) error {
+	newPath := data.Path.ValueString()
+
+	if oldPath != "" && oldPath != newPath {
+		log.Printf("[DEBUG] Remove unit file %s\n", oldPath)
+		if err := sdUnitRemoveFile(scope.Host(), oldPath); err != nil {
+			return err
 		}
 	}
 
-	return errs
+	if newPath != "" {
+		log.Printf("[DEBUG] Write unit file %s\n", newPath)
+		return scope.WriteFile(newPath, data.Content.ValueString())
+	}
+
+	return nil
 }
 
-func resourceSystemdUnitCreate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
-	unit := d.Get("name").(string)
+func (r *systemdUnitResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	var data, config systemdUnitResourceModel
+
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &data)...)
+	resp.Diagnostics.Append(req.Config.Get(ctx, &config)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	unit := data.Name.ValueString()
+	scope := data.scope(r.config)
 	log.Printf("[DEBUG] About to create %s\n", unit)
-	lock := sdUnitLock(m, unit)
+	lock := sdUnitLock(r.config, scope, unit)
 	lock.Lock()
 	defer lock.Unlock()
 
-	sd, err := sdConn(ctx, d, m)
+	err := resourceSystemdLinger(ctx, scope, data.Linger.ValueBool())
+	if err != nil {
+		resp.Diagnostics.AddError("cannot enable linger", err.Error())
+		return
+	}
+
+	sd, err := scope.Conn(ctx, r.config)
 	if err != nil {
-		return diag.Errorf("cannot connect to systemd: %v", err)
+		resp.Diagnostics.AddError("cannot connect to systemd", err.Error())
+		return
 	}
 
 	defer sd.Close()
 
-	err = sd.ReloadContext(ctx)
+	// Read the unit state before the unit file is written for the rollback
+	_, err = r.read(ctx, sd, &data, false)
 	if err != nil {
-		return diag.Errorf("cannot reload systemd: %v", err)
+		resp.Diagnostics.AddError("cannot read unit "+unit, err.Error())
+		return
 	}
 
-	errs := resourceSystemdUnitReadUnlocked(ctx, d, m)
-	if errs != nil {
-		return errs
+	err = resourceSystemdUnitWriteFile(scope, "", &data)
+	if err != nil {
+		resp.Diagnostics.AddError("cannot write the unit file", err.Error())
+		return
+	}
+
+	resp.Diagnostics.Append(r.apply(ctx, sd, &data, &config, nil)...)
+	if resp.Diagnostics.HasError() {
+		return
 	}
 
-	return resourceSystemdUnitUpdateUnlocked(ctx, d, m, true)
+	found, err := r.read(ctx, sd, &data, false)
+	if err != nil {
+		resp.Diagnostics.AddError("cannot read unit "+unit, err.Error())
+		return
+	} else if !found {
+		resp.Diagnostics.AddError("cannot create unit "+unit, "unit "+unit+" not found")
+		return
+	}
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+// resourceSystemdLinger enables lingering for user_name if requested, it is
+// never disabled as other units of the user may depend on it
+func resourceSystemdLinger(ctx context.Context, scope sdScope, linger bool) error {
+	if scope.UserName == "" || !linger {
+		return nil
+	}
+
+	log.Printf("[DEBUG] Enable linger for %s\n", scope.UserName)
+	return sdEnableLinger(ctx, scope)
 }
 
-func resourceSystemdUnitDelete(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
-	unit := d.Get("name").(string)
+func (r *systemdUnitResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	var data systemdUnitResourceModel
+
+	resp.Diagnostics.Append(req.State.Get(ctx, &data)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	unit := data.Name.ValueString()
+	scope := data.scope(r.config)
 	log.Printf("[DEBUG] About to delete %s\n", unit)
-	lock := sdUnitLock(m, unit)
+	lock := sdUnitLock(r.config, scope, unit)
 	lock.Lock()
 	defer lock.Unlock()
 
 	log.Printf("[DEBUG] connect to systemd\n")
-	sd, err := sdConn(ctx, d, m)
+	sd, err := scope.Conn(ctx, r.config)
 	if err != nil {
-		return diag.Errorf("cannot connect to systemd: %v", err)
+		resp.Diagnostics.AddError("cannot connect to systemd", err.Error())
+		return
 	}
 
 	defer sd.Close()
 
-	rollback := d.Get("rollback").(map[string]interface{})
-	rollback_active := parseBoolDef(rollback["active"], false)
-	rollback_enable := parseBoolDef(rollback["enabled"], false)
+	rollback := data.rollback(ctx)
+	rollbackActive := parseBoolDef(rollback["active"], false)
+	rollbackEnable := parseBoolDef(rollback["enabled"], false)
+	rollbackLoadState := rollback["load_state"]
 
 	log.Printf("[DEBUG] systemctl daemon-reload\n")
 	err = sd.ReloadContext(ctx)
 	if err != nil {
-		return diag.Errorf("cannot reload systemd: %v", err)
+		resp.Diagnostics.AddError("cannot reload systemd", err.Error())
+		return
 	}
 
-	// If unknown by systemd, there is nothing to rollback
-	if d.Id() == "" {
-		log.Printf("[DEBUG] Deleted %s (no rollback)\n", unit)
+	rollbackMask := func() diag.Diagnostics {
+		if rollbackLoadState == "" {
+			return nil
+		}
+		log.Printf("[DEBUG] Rollback %s %s\n", sdMaskString(sdIsMasked(rollbackLoadState)), unit)
+		err := resourceSystemdMask(ctx, sd, &data, rollbackLoadState)
+		if err != nil {
+			return data.failure(fmt.Sprintf("cannot %s unit %s", sdMaskString(sdIsMasked(rollbackLoadState)), unit), err)
+		}
 		return nil
 	}
 
-	log.Printf("[DEBUG] Rollback %s %s\n", sdEnableString(rollback_enable), unit)
-	err = resourceSystemdEnable(ctx, d, sd, rollback_enable)
-	if err != nil {
-		derr := diag.Errorf("cannot %s unit %s: %v", sdEnableString(rollback_enable), unit, err)
-		return withSeverity(d, derr)
+	if !sdIsMasked(rollbackLoadState) {
+		if errs := rollbackMask(); errs != nil {
+			resp.Diagnostics.Append(errs...)
+			return
+		}
 	}
 
-	restart := d.HasChange("restart_on")
+	log.Printf("[DEBUG] Rollback %s %s (mode: %s)\n", sdEnableString(rollbackEnable), unit, data.RollbackMode.ValueString())
+	err = resourceSystemdRollbackEnable(ctx, sd, &data, rollbackEnable)
+	if err != nil {
+		resp.Diagnostics.Append(data.failure(fmt.Sprintf("cannot %s unit %s", sdEnableString(rollbackEnable), unit), err)...)
+		return
+	}
 
-	log.Printf("[DEBUG] Rollback %s %s (restart: %v)\n", sdStartString(rollback_active), unit, restart)
-	err = resourceSystemdActivate(ctx, d, sd, rollback_active, restart)
+	log.Printf("[DEBUG] Rollback %s %s\n", sdStartString(rollbackActive), unit)
+	err = resourceSystemdActivate(ctx, sd, &data, rollbackActive, false, false)
 	if err != nil {
-		derr := diag.Errorf("cannot %s unit %s: %v", sdStartString(rollback_active), unit, err)
-		return withSeverity(d, derr)
+		resp.Diagnostics.Append(data.failure(fmt.Sprintf("cannot %s unit %s", sdStartString(rollbackActive), unit), err)...)
+		return
 	}
 
-	log.Printf("[DEBUG] Read unit %s after rollback\n", unit)
-	errs := resourceSystemdUnitReadUnlocked(ctx, d, m)
-	if errs != nil {
-		return errs
+	if sdIsMasked(rollbackLoadState) {
+		if errs := rollbackMask(); errs != nil {
+			resp.Diagnostics.Append(errs...)
+			return
+		}
 	}
 
-	return nil
+	err = resourceSystemdUnitDeleteFile(ctx, scope, sd, data.Path.ValueString())
+	if err != nil {
+		resp.Diagnostics.AddError("cannot remove the unit file", err.Error())
+	}
 }
 
-func resourceSystemdEnable(ctx context.Context, d *schema.ResourceData, sd *systemd.Conn, enable bool) error {
-	unit := d.Get("name").(string)
-	unitFileState, err := sd.GetUnitFileStateContext(ctx, unit)
+func resourceSystemdUnitDeleteFile(ctx context.Context, scope sdScope, sd sdManager, filename string) error {
+	if filename == "" {
+		return nil
+	}
+
+	log.Printf("[DEBUG] Remove unit file %s\n", filename)
+	err := sdUnitRemoveFile(scope.Host(), filename)
 	if err != nil {
-		return fmt.Errorf("cannot get unit file state for %s: %v", unit, err)
+		return err
 	}
 
-	is_enabled, is_enableable := sdIsEnabled(unitFileState)
+	err = sd.ReloadContext(ctx)
+	if err != nil {
+		return fmt.Errorf("cannot reload systemd: %v", err)
+	}
 
-	if is_enableable && !is_enabled && enable {
-		log.Printf("[TRACE] Enable %s (enable=%v, is_enabled=%v, is_enableable=%v)\n", unit, enable, is_enabled, is_enableable)
-		_, _, err = sd.EnableUnitFilesContext(ctx, []string{unit}, false, true)
-	} else if is_enableable && is_enabled && !enable {
-		log.Printf("[TRACE] Disasble %s (enable=%v, is_enabled=%v, is_enableable=%v)\n", unit, enable, is_enabled, is_enableable)
-		_, err = sd.DisableUnitFilesContext(ctx, []string{unit}, false)
-	} else {
-		log.Printf("[TRACE] Do not enable %s (enable=%v, is_enabled=%v, is_enableable=%v)\n", unit, enable, is_enabled, is_enableable)
+	return nil
+}
+
+// resourceSystemdEnable enables or disables the unit and its also units
+func resourceSystemdEnable(ctx context.Context, sd sdManager, data *systemdUnitResourceModel, enable bool) error {
+	runtime := data.Runtime.ValueBool()
+	var enableFiles, disableFiles []string
+	var disableRuntime bool
+
+	for _, unit := range data.units() {
+		unitFileState, err := sd.GetUnitFileStateContext(ctx, unit)
+		if err != nil {
+			return fmt.Errorf("cannot get unit file state for %s: %v", unit, err)
+		}
+
+		is_enabled, is_enableable := sdIsEnabledBy(runtime, unitFileState)
+
+		if is_enableable && !is_enabled && enable {
+			log.Printf("[TRACE] Enable %s (enable=%v, is_enabled=%v, is_enableable=%v, runtime=%v)\n", unit, enable, is_enabled, is_enableable, runtime)
+			enableFiles = append(enableFiles, unit)
+		} else if is_enableable && is_enabled && !enable {
+			log.Printf("[TRACE] Disasble %s (enable=%v, is_enabled=%v, is_enableable=%v, runtime=%v)\n", unit, enable, is_enabled, is_enableable, runtime)
+			disableFiles = append(disableFiles, unit)
+			disableRuntime = disableRuntime || unitFileState == systemdEnabledRuntime
+		} else {
+			log.Printf("[TRACE] Do not enable %s (enable=%v, is_enabled=%v, is_enableable=%v)\n", unit, enable, is_enabled, is_enableable)
+		}
 	}
 
+	var err error
+	if len(enableFiles) > 0 {
+		_, _, err = sd.EnableUnitFilesContext(ctx, enableFiles, runtime, true)
+	} else if len(disableFiles) > 0 {
+		_, err = sd.DisableUnitFilesContext(ctx, disableFiles, disableRuntime)
+	}
+
+	return err
+}
+
+func resourceSystemdPreset(ctx context.Context, sd sdManager, data *systemdUnitResourceModel) error {
+	runtime := data.Runtime.ValueBool()
+	log.Printf("[TRACE] Preset %s (runtime=%v)\n", data.Name.ValueString(), runtime)
+	_, err := sd.PresetUnitFilesContext(ctx, data.units(), runtime, true)
 	return err
 }
 
-func resourceSystemdMask(ctx context.Context, d *schema.ResourceData, sd *systemd.Conn, maskState string) error {
-	unit := d.Get("name").(string)
+// resourceSystemdRollbackEnable restores the unit file state found on
+// creation, or applies the preset policy depending on rollback_mode
+func resourceSystemdRollbackEnable(ctx context.Context, sd sdManager, data *systemdUnitResourceModel, enable bool) error {
+	if data.RollbackMode.ValueString() == sdRollbackPreset {
+		return resourceSystemdPreset(ctx, sd, data)
+	}
+	return resourceSystemdEnable(ctx, sd, data, enable)
+}
+
+func resourceSystemdMask(ctx context.Context, sd sdManager, data *systemdUnitResourceModel, maskState string) error {
+	unit := data.Name.ValueString()
 	unitFileState, err := sd.GetUnitFileStateContext(ctx, unit)
 	if err != nil {
 		return fmt.Errorf("cannot get unit file state for %s: %v", unit, err)
@@ -457,7 +929,7 @@ func resourceSystemdMask(ctx context.Context, d *schema.ResourceData, sd *system
 		_, err = sd.MaskUnitFilesContext(ctx, []string{unit}, maskState == systemdMaskedRuntime, true)
 	} else if sdIsMasked(unitFileState) && !sdIsMasked(maskState) {
 		log.Printf("[TRACE] Unmask (%s) %s (state=%v, is_masked=%v, do_mask=%v)\n", maskState, unit, unitFileState, sdIsMasked(maskState), sdIsMasked(maskState))
-		_, err = sd.UnmaskUnitFilesContext(ctx, []string{unit}, false)
+		_, err = sd.UnmaskUnitFilesContext(ctx, []string{unit}, unitFileState == systemdMaskedRuntime)
 	} else {
 		log.Printf("[TRACE] Do not mask (%s) %s (state=%v, is_masked=%v, do_mask=%v)\n", maskState, unit, unitFileState, sdIsMasked(maskState), sdIsMasked(maskState))
 	}
@@ -465,31 +937,76 @@ func resourceSystemdMask(ctx context.Context, d *schema.ResourceData, sd *system
 	return err
 }
 
-func resourceSystemdActivate(ctx context.Context, d *schema.ResourceData, sd *systemd.Conn, activate, restart bool) error {
-	unit := d.Get("name").(string)
+func resourceSystemdActivate(ctx context.Context, sd sdManager, data *systemdUnitResourceModel, activate, restart, reload bool) error {
+	unit := data.Name.ValueString()
+	mode := data.JobMode.ValueString()
 	statuses, err := sd.ListUnitsByNamesContext(ctx, []string{unit})
 	if err != nil || len(statuses) < 1 {
 		return fmt.Errorf("cannot query unit %s: %v", unit, err)
 	}
 
-	log.Printf("[TRACE] Activate %v %s (restart: %v): statuses = %v\n", activate, unit, restart, statuses)
+	log.Printf("[TRACE] Activate %v %s (restart: %v, reload: %v): statuses = %v\n", activate, unit, restart, reload, statuses)
 
 	status := statuses[0]
 	is_active := sdIsActive(status.ActiveState)
 
-	log.Printf("[TRACE] Activate %v %s (restart: %v) active=%s is_active=%v activate=%v\n", activate, unit, restart, status.ActiveState, is_active, activate)
+	log.Printf("[TRACE] Activate %v %s (restart: %v, reload: %v) active=%s is_active=%v activate=%v\n", activate, unit, restart, reload, status.ActiveState, is_active, activate)
+
+	if activate && sdIsFailed(status.ActiveState) {
+		if data.Kill.ValueBool() {
+			log.Printf("[TRACE] Activate %v %s: systemctl kill --signal=SIGKILL %s\n", activate, unit, unit)
+			sd.KillUnitContext(ctx, unit, int32(syscall.SIGKILL))
+		}
+		if data.ResetFailed.ValueBool() {
+			log.Printf("[TRACE] Activate %v %s: systemctl reset-failed %s\n", activate, unit, unit)
+			err = sd.ResetFailedUnitContext(ctx, unit)
+			if err != nil {
+				return fmt.Errorf("cannot reset failed unit %s: %v", unit, err)
+			}
+		}
+	}
+
+	// The also units are stopped while the unit restarts, and when it stops
+	// the units that can activate it are stopped first
+	also := data.Also
+	var triggers, triggeredBy, stopBefore, stopAfter []string
+	if len(also) > 0 {
+		triggers, triggeredBy, err = sdUnitTriggers(ctx, sd, unit)
+		if err != nil {
+			return err
+		}
+	}
+	if restart && activate {
+		stopBefore = also
+	} else if is_active && !activate {
+		for _, other := range also {
+			if stringListContains(triggeredBy, other) {
+				stopBefore = append(stopBefore, other)
+			} else {
+				stopAfter = append(stopAfter, other)
+			}
+		}
+	}
+
+	stopped, err := sdStopUnits(ctx, sd, stopBefore, mode)
+	if err != nil {
+		return err
+	}
 
 	complete := make(chan string)
 
 	if restart && activate {
 		log.Printf("[TRACE] Activate %v %s (restart: %v): systemctl restart %s\n", activate, unit, restart, unit)
-		_, err = sd.RestartUnitContext(ctx, unit, "replace", complete)
+		_, err = sd.RestartUnitContext(ctx, unit, mode, complete)
+	} else if reload && is_active && activate {
+		log.Printf("[TRACE] Activate %v %s (reload: %v): systemctl reload-or-restart %s\n", activate, unit, reload, unit)
+		_, err = sd.ReloadOrRestartUnitContext(ctx, unit, mode, complete)
 	} else if !is_active && activate {
 		log.Printf("[TRACE] Activate %v %s (restart: %v): systemctl start %s\n", activate, unit, restart, unit)
-		_, err = sd.StartUnitContext(ctx, unit, "replace", complete)
+		_, err = sd.StartUnitContext(ctx, unit, mode, complete)
 	} else if is_active && !activate {
 		log.Printf("[TRACE] Activate %v %s (restart: %v): systemctl stop %s\n", activate, unit, restart, unit)
-		_, err = sd.StopUnitContext(ctx, unit, "replace", complete)
+		_, err = sd.StopUnitContext(ctx, unit, mode, complete)
 	} else {
 		log.Printf("[TRACE] Activate %v %s (restart: %v): nothing to do\n", activate, unit, restart)
 		close(complete)
@@ -500,6 +1017,87 @@ func resourceSystemdActivate(ctx context.Context, d *schema.ResourceData, sd *sy
 	}
 
 	log.Printf("[TRACE] Activate %v %s (restart: %v): wait for complete\n", activate, unit, restart)
+	err = sdWaitJob(ctx, unit, complete)
+	if err != nil {
+		return err
+	}
+
+	// Units triggered by the unit are activated on demand
+	for _, other := range stopped {
+		if activate && !stringListContains(triggers, other) {
+			log.Printf("[TRACE] Activate %v %s: systemctl start %s\n", activate, unit, other)
+			complete := make(chan string)
+			_, err = sd.StartUnitContext(ctx, other, mode, complete)
+			if err == nil {
+				err = sdWaitJob(ctx, other, complete)
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	_, err = sdStopUnits(ctx, sd, stopAfter, mode)
+	return err
+}
+
+// sdStopUnits stops the active units, returning them
+func sdStopUnits(ctx context.Context, sd sdManager, units []string, mode string) ([]string, error) {
+	if len(units) == 0 {
+		return nil, nil
+	}
+
+	statuses, err := sd.ListUnitsByNamesContext(ctx, units)
+	if err != nil {
+		return nil, fmt.Errorf("cannot query units %v: %v", units, err)
+	}
+
+	var stopped []string
+	for _, status := range statuses {
+		if !sdIsActive(status.ActiveState) {
+			continue
+		}
+
+		log.Printf("[TRACE] systemctl stop %s\n", status.Name)
+		complete := make(chan string)
+		_, err = sd.StopUnitContext(ctx, status.Name, mode, complete)
+		if err == nil {
+			err = sdWaitJob(ctx, status.Name, complete)
+		}
+		if err != nil {
+			return stopped, err
+		}
+		stopped = append(stopped, status.Name)
+	}
+
+	return stopped, nil
+}
+
+// resourceSystemdTryRestart restarts or reloads the unit only if it is
+// running, leaving it stopped otherwise
+func resourceSystemdTryRestart(ctx context.Context, sd sdManager, data *systemdUnitResourceModel, restart, reload bool) error {
+	unit := data.Name.ValueString()
+	mode := data.JobMode.ValueString()
+	complete := make(chan string)
+
+	var err error
+	if restart {
+		log.Printf("[TRACE] systemctl try-restart %s\n", unit)
+		_, err = sd.TryRestartUnitContext(ctx, unit, mode, complete)
+	} else if reload {
+		log.Printf("[TRACE] systemctl try-reload-or-restart %s\n", unit)
+		_, err = sd.ReloadOrTryRestartUnitContext(ctx, unit, mode, complete)
+	} else {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return sdWaitJob(ctx, unit, complete)
+}
+
+func sdWaitJob(ctx context.Context, unit string, complete <-chan string) error {
 	select {
 	case <-ctx.Done():
 		return ctx.Err()
@@ -509,99 +1107,192 @@ func resourceSystemdActivate(ctx context.Context, d *schema.ResourceData, sd *sy
 		}
 	}
 
-	return err
+	return nil
 }
 
-func resourceSystemdUnitUpdate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
-	unit := d.Get("name").(string)
+func (r *systemdUnitResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	var data, config, state systemdUnitResourceModel
+
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &data)...)
+	resp.Diagnostics.Append(req.Config.Get(ctx, &config)...)
+	resp.Diagnostics.Append(req.State.Get(ctx, &state)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	unit := data.Name.ValueString()
+	scope := data.scope(r.config)
 	log.Printf("[DEBUG] About to update %s\n", unit)
-	lock := sdUnitLock(m, unit)
+	lock := sdUnitLock(r.config, scope, unit)
 	lock.Lock()
 	defer lock.Unlock()
 
-	return resourceSystemdUnitUpdateUnlocked(ctx, d, m, false)
-}
-
-func resourceSystemdUnitUpdateUnlocked(ctx context.Context, d *schema.ResourceData, m interface{}, creating bool) diag.Diagnostics {
-	unit := d.Get("name").(string)
+	if !data.Linger.Equal(state.Linger) {
+		err := resourceSystemdLinger(ctx, scope, data.Linger.ValueBool())
+		if err != nil {
+			resp.Diagnostics.AddError("cannot enable linger", err.Error())
+			return
+		}
+	}
 
-	sd, err := sdConn(ctx, d, m)
+	sd, err := scope.Conn(ctx, r.config)
 	if err != nil {
-		return diag.Errorf("cannot connect to systemd: %v", err)
+		resp.Diagnostics.AddError("cannot connect to systemd", err.Error())
+		return
 	}
 
 	defer sd.Close()
 
-	start, has_start := d.GetOkExists("start")
-	enable, has_enable := d.GetOkExists("enable")
-	mask, has_mask := d.GetOkExists("mask")
-
-	rollback := d.Get("rollback").(map[string]interface{})
-	rollback_active     := parseBoolDef(rollback["active"], false)
-	rollback_enable     := parseBoolDef(rollback["enabled"], false)
-	rollback_load_state := rollback["load_state"]
-	rollback_file_state := rollback["unit_file_state"]
+	resp.Diagnostics.Append(r.apply(ctx, sd, &data, &config, &state)...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
 
-	err = sd.ReloadContext(ctx)
+	_, err = r.read(ctx, sd, &data, false)
 	if err != nil {
-		return diag.Errorf("cannot reload systemd: %v", err)
+		resp.Diagnostics.AddError("cannot read unit "+unit, err.Error())
+		return
 	}
 
-	log.Printf("[TRACE] Update %s start=%v has_start=%v enable=%v has_enable=%v mask=%v has_mask=%v rollback_active=%v rollback_enable=%v, rollback_load_state=%v, rollback_file_state=%v\n",
-		unit, start, has_start, enable, has_enable, mask, has_mask, rollback_active, rollback_enable, rollback_load_state, rollback_file_state)
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+// apply applies the planned unit file, mask, enable and start on creation
+// when prior is nil, or their changes from prior. Unset start, enable and
+// mask attributes restore the rollback information instead.
+func (r *systemdUnitResource) apply(ctx context.Context, sd sdManager, data, config, prior *systemdUnitResourceModel) diag.Diagnostics {
+	unit := data.Name.ValueString()
+	scope := data.scope(r.config)
+	creating := prior == nil
 
-	if enable != nil && has_enable && (creating || d.HasChange("enable")) {
-		err = resourceSystemdEnable(ctx, d, sd, enable.(bool))
+	var errs diag.Diagnostics
+	if !creating && (!data.Content.Equal(prior.Content) || !data.Path.Equal(prior.Path)) {
+		err := resourceSystemdUnitWriteFile(scope, prior.Path.ValueString(), data)
 		if err != nil {
-			derr := diag.Errorf("cannot %s unit %s: %v", sdEnableString(enable.(bool)), unit, err)
-			return withSeverity(d, derr)
+			errs.AddError("cannot write the unit file", err.Error())
+			return errs
 		}
-	} else if !has_enable || enable == nil {
-		err = resourceSystemdEnable(ctx, d, sd, rollback_enable)
-		if err != nil {
-			derr := diag.Errorf("cannot rollback %s unit %s: %v", sdEnableString(rollback_enable), unit, err)
-			return withSeverity(d, derr)
+	}
+
+	start, hasStart := data.Start.ValueBool(), !config.Start.IsNull()
+	enable, hasEnable := data.Enable.ValueBool(), !config.Enable.IsNull()
+	mask, hasMask := data.Mask.ValueBool(), !config.Mask.IsNull()
+
+	rollback := data.rollback(ctx)
+	rollbackActive := parseBoolDef(rollback["active"], false)
+	rollbackEnable := parseBoolDef(rollback["enabled"], false)
+	rollbackLoadState, hasRollbackLoadState := rollback["load_state"]
+
+	err := sd.ReloadContext(ctx)
+	if err != nil {
+		errs.AddError("cannot reload systemd", err.Error())
+		return errs
+	}
+
+	log.Printf("[TRACE] Update %s start=%v has_start=%v enable=%v has_enable=%v mask=%v has_mask=%v rollback_active=%v rollback_enable=%v, rollback_load_state=%v\n",
+		unit, start, hasStart, enable, hasEnable, mask, hasMask, rollbackActive, rollbackEnable, rollbackLoadState)
+
+	// A masked unit cannot be enabled, unmask it first, and mask it once
+	// disabled
+	applyMask := func() diag.Diagnostics {
+		if hasMask && (creating || !data.Mask.Equal(prior.Mask)) {
+			var maskState string
+			if mask && data.Runtime.ValueBool() {
+				maskState = systemdMaskedRuntime
+			} else if mask {
+				maskState = systemdMasked
+			}
+			err := resourceSystemdMask(ctx, sd, data, maskState)
+			if err != nil {
+				return data.failure(fmt.Sprintf("cannot %s unit %s", sdMaskString(mask), unit), err)
+			}
+		} else if hasRollbackLoadState && !hasMask {
+			err := resourceSystemdMask(ctx, sd, data, rollbackLoadState)
+			if err != nil {
+				return data.failure(fmt.Sprintf("cannot rollback %s unit %s", sdMaskString(sdIsMasked(rollbackLoadState)), unit), err)
+			}
 		}
+		return nil
+	}
+
+	masking := hasMask && mask
+	if !hasMask {
+		masking = hasRollbackLoadState && sdIsMasked(rollbackLoadState)
 	}
 
-	if mask != nil && has_mask && (creating || d.HasChange("mask")) {
-		var maskState string
-		if mask.(bool) {
-			maskState = systemdMasked
+	if !masking {
+		if errs := applyMask(); errs != nil {
+			return errs
 		}
-		err = resourceSystemdMask(ctx, d, sd, maskState)
+	}
+
+	preset := data.Preset.ValueBool() && (creating || !data.Preset.Equal(prior.Preset))
+	if preset {
+		err = resourceSystemdPreset(ctx, sd, data)
+		if err != nil {
+			return data.failure("cannot preset unit "+unit, err)
+		}
+	}
+
+	if hasEnable && (creating || !data.Enable.Equal(prior.Enable)) {
+		err = resourceSystemdEnable(ctx, sd, data, enable)
 		if err != nil {
-			derr := diag.Errorf("cannot %s unit %s: %v", sdMaskString(mask.(bool)), unit, err)
-			return withSeverity(d, derr)
+			return data.failure(fmt.Sprintf("cannot %s unit %s", sdEnableString(enable), unit), err)
+		}
+	} else if !hasEnable && data.Preset.ValueBool() {
+		// The preset policy decides if the unit is enabled
+	} else if !hasEnable && data.RollbackMode.ValueString() == sdRollbackPreset {
+		if creating || !data.Enable.Equal(prior.Enable) || !data.RollbackMode.Equal(prior.RollbackMode) {
+			err = resourceSystemdPreset(ctx, sd, data)
+			if err != nil {
+				return data.failure("cannot preset unit "+unit, err)
+			}
 		}
-	} else if rollback_load_state != nil && (!has_mask || mask == nil) {
-		err = resourceSystemdMask(ctx, d, sd, rollback_load_state.(string))
+	} else if !hasEnable {
+		err = resourceSystemdEnable(ctx, sd, data, rollbackEnable)
 		if err != nil {
-			derr := diag.Errorf("cannot rollback %s unit %s: %v", sdMaskString(sdIsMasked(rollback_load_state.(string))), unit, err)
-			return withSeverity(d, derr)
+			return data.failure(fmt.Sprintf("cannot rollback %s unit %s", sdEnableString(rollbackEnable), unit), err)
 		}
 	}
 
-	restart := d.HasChange("restart_on")
-	log.Printf("[TRACE] Update %s restart=%v\n", unit, restart)
+	if masking {
+		if errs := applyMask(); errs != nil {
+			return errs
+		}
+	}
 
-	if start != nil && has_start && (creating || d.HasChange("start") || restart) {
-		err = resourceSystemdActivate(ctx, d, sd, start.(bool), restart)
+	var restart, reload bool
+	if creating {
+		restart = len(data.RestartOn.Elements()) > 0
+		reload = len(data.ReloadOn.Elements()) > 0
+	} else {
+		restart = !data.RestartOn.Equal(prior.RestartOn) || !data.Content.Equal(prior.Content)
+		reload = !data.ReloadOn.Equal(prior.ReloadOn)
+	}
+	log.Printf("[TRACE] Update %s restart=%v reload=%v\n", unit, restart, reload)
+
+	if hasStart && (creating || !data.Start.Equal(prior.Start) || restart || reload) {
+		err = resourceSystemdActivate(ctx, sd, data, start, restart, reload)
 		if err != nil {
-			derr := diag.Errorf("cannot %s unit %s: %v", sdStartString(start.(bool)), unit, err)
-			return withSeverity(d, derr)
+			return data.failure(fmt.Sprintf("cannot %s unit %s", sdStartString(start), unit), err)
 		}
-	} else if !has_start || start == nil {
-		err = resourceSystemdActivate(ctx, d, sd, rollback_active, restart)
+
+		if w := sdWaitActiveFrom(data.WaitActive, scope.Host()); start && w != nil {
+			if errs := sdWaitActiveDiagnostic(ctx, w, scope, sd, unit); errs != nil {
+				return data.withSeverity(errs)
+			}
+		}
+	} else if !hasStart && data.TryRestart.ValueBool() {
+		err = resourceSystemdTryRestart(ctx, sd, data, restart, reload)
 		if err != nil {
-			derr := diag.Errorf("cannot rollback %s unit %s: %v", sdStartString(rollback_active), unit, err)
-			return withSeverity(d, derr)
+			return data.failure("cannot restart unit "+unit, err)
+		}
+	} else if !hasStart {
+		err = resourceSystemdActivate(ctx, sd, data, rollbackActive, restart, reload)
+		if err != nil {
+			return data.failure(fmt.Sprintf("cannot rollback %s unit %s", sdStartString(rollbackActive), unit), err)
 		}
-	}
-
-	errs := resourceSystemdUnitReadUnlocked(ctx, d, m)
-	if errs != nil {
-		return errs
 	}
 
 	return nil
@@ -0,0 +1,489 @@
+package sys
+
+import (
+	"os"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type testSystemdEnv struct {
+	t    *testing.T
+	sd   *fakeSystemd
+	meta *providerConfiguration
+	tf   *testFrameworkEnv
+}
+
+func newTestSystemdEnv(t *testing.T) *testSystemdEnv {
+	sd := newFakeSystemd()
+	meta := &providerConfiguration{SdConnect: sd.connect}
+	return &testSystemdEnv{
+		t:    t,
+		sd:   sd,
+		meta: meta,
+		tf:   newTestFrameworkEnvWith(t, meta),
+	}
+}
+
+func (env *testSystemdEnv) apply(state, config map[string]interface{}) map[string]interface{} {
+	env.t.Helper()
+	return env.tf.mustApply("sys_systemd_unit", state, config)
+}
+
+func (env *testSystemdEnv) expectCalls(expected ...string) {
+	env.t.Helper()
+	calls := env.sd.calls()
+	if !reflect.DeepEqual(calls, expected) && !(len(calls) == 0 && len(expected) == 0) {
+		env.t.Fatalf("expected calls %q, got %q", expected, calls)
+	}
+}
+
+func (env *testSystemdEnv) expectUnit(name, fileState, activeState string) {
+	env.t.Helper()
+	u := env.sd.Units[name]
+	if u.FileState != fileState || u.ActiveState != activeState {
+		env.t.Fatalf("expected %s to be %s and %s, got %s and %s", name, fileState, activeState, u.FileState, u.ActiveState)
+	}
+}
+
+func TestSystemdUnitCreateUpdateDelete(t *testing.T) {
+	env := newTestSystemdEnv(t)
+	env.sd.add("nginx.service", systemdDisabled)
+
+	state := env.apply(nil, map[string]interface{}{
+		"name":   "nginx.service",
+		"enable": true,
+		"start":  true,
+	})
+	env.expectCalls("enable nginx.service", "start nginx.service replace")
+	env.expectUnit("nginx.service", systemdEnabled, systemdActive)
+
+	rollback := state["rollback"].(map[string]interface{})
+	if state["id"] != "nginx.service" || state["active_state"] != systemdActive {
+		t.Fatalf("unexpected state after create: %v", state)
+	}
+	if rollback["active"] != "false" || rollback["enabled"] != "false" {
+		t.Fatalf("unexpected rollback information: %v", rollback)
+	}
+
+	state = env.apply(state, map[string]interface{}{
+		"name":   "nginx.service",
+		"enable": true,
+		"start":  false,
+	})
+	env.expectCalls("stop nginx.service replace")
+	env.expectUnit("nginx.service", systemdEnabled, systemdInactive)
+
+	env.apply(state, nil)
+	env.expectCalls("disable nginx.service")
+	env.expectUnit("nginx.service", systemdDisabled, systemdInactive)
+}
+
+func TestSystemdUnitRollback(t *testing.T) {
+	env := newTestSystemdEnv(t)
+	env.sd.add("cron.service", systemdEnabled).start()
+
+	state := env.apply(nil, map[string]interface{}{
+		"name":   "cron.service",
+		"enable": false,
+		"start":  false,
+	})
+	env.expectCalls("disable cron.service", "stop cron.service replace")
+	env.expectUnit("cron.service", systemdDisabled, systemdInactive)
+
+	// Attributes removed from the configuration are no longer managed
+	state = env.apply(state, map[string]interface{}{
+		"name": "cron.service",
+	})
+	env.expectCalls()
+	env.expectUnit("cron.service", systemdDisabled, systemdInactive)
+
+	env.apply(state, nil)
+	env.expectCalls("enable cron.service", "start cron.service replace")
+	env.expectUnit("cron.service", systemdEnabled, systemdActive)
+}
+
+func TestSystemdUnitMasked(t *testing.T) {
+	env := newTestSystemdEnv(t)
+	env.sd.add("postfix.service", systemdDisabled)
+	env.sd.Units["postfix.service"].FileState = systemdMasked
+
+	state := env.apply(nil, map[string]interface{}{
+		"name":   "postfix.service",
+		"mask":   false,
+		"enable": true,
+		"start":  true,
+	})
+	env.expectCalls("unmask postfix.service", "enable postfix.service", "start postfix.service replace")
+	env.expectUnit("postfix.service", systemdEnabled, systemdActive)
+
+	if rollback := state["rollback"].(map[string]interface{}); rollback["load_state"] != systemdMasked {
+		t.Fatalf("unexpected rollback information: %v", rollback)
+	}
+
+	env.apply(state, nil)
+	env.expectCalls("disable postfix.service", "stop postfix.service replace", "mask postfix.service")
+	env.expectUnit("postfix.service", systemdMasked, systemdInactive)
+}
+
+func TestSystemdUnitMask(t *testing.T) {
+	env := newTestSystemdEnv(t)
+	env.sd.add("bluetooth.service", systemdEnabled).start()
+
+	state := env.apply(nil, map[string]interface{}{
+		"name":   "bluetooth.service",
+		"mask":   true,
+		"enable": false,
+		"start":  false,
+	})
+	env.expectCalls("disable bluetooth.service", "mask bluetooth.service", "stop bluetooth.service replace")
+	env.expectUnit("bluetooth.service", systemdMasked, systemdInactive)
+
+	if state["mask"] != true {
+		t.Fatalf("expected the unit to be masked, got %v", state)
+	}
+
+	env.apply(state, nil)
+	env.expectCalls("unmask bluetooth.service", "enable bluetooth.service", "start bluetooth.service replace")
+	env.expectUnit("bluetooth.service", systemdEnabled, systemdActive)
+}
+
+func TestSystemdUnitIgnoreErrors(t *testing.T) {
+	env := newTestSystemdEnv(t)
+	env.sd.add("broken.service", systemdDisabled).FailStart = true
+
+	config := map[string]interface{}{
+		"name":  "broken.service",
+		"start": true,
+	}
+
+	if _, errs := env.tf.apply("sys_systemd_unit", nil, config); errs == "" {
+		t.Fatalf("expected the start failure to be reported")
+	}
+
+	config["ignore_errors"] = true
+	env.sd.Units["broken.service"].stop()
+	state, errs := env.tf.apply("sys_systemd_unit", nil, config)
+	if errs != "" {
+		t.Fatalf("expected the start failure to be a warning, got %s", errs)
+	}
+	if state["id"] != "broken.service" || state["active_state"] != systemdFailed {
+		t.Fatalf("expected the resource to be created, got %v", state)
+	}
+	env.expectUnit("broken.service", systemdDisabled, systemdFailed)
+}
+
+func TestSystemdUnitRestartReload(t *testing.T) {
+	env := newTestSystemdEnv(t)
+	env.sd.add("haproxy.service", systemdEnabled)
+
+	state := env.apply(nil, map[string]interface{}{
+		"name":       "haproxy.service",
+		"start":      true,
+		"job_mode":   "fail",
+		"restart_on": map[string]interface{}{"binary": "1"},
+		"reload_on":  map[string]interface{}{"config": "1"},
+	})
+	env.expectCalls("restart haproxy.service fail")
+
+	state = env.apply(state, map[string]interface{}{
+		"name":       "haproxy.service",
+		"start":      true,
+		"job_mode":   "fail",
+		"restart_on": map[string]interface{}{"binary": "1"},
+		"reload_on":  map[string]interface{}{"config": "2"},
+	})
+	env.expectCalls("reload-or-restart haproxy.service fail")
+
+	state = env.apply(state, map[string]interface{}{
+		"name":       "haproxy.service",
+		"start":      true,
+		"job_mode":   "fail",
+		"restart_on": map[string]interface{}{"binary": "2"},
+		"reload_on":  map[string]interface{}{"config": "2"},
+	})
+	env.expectCalls("restart haproxy.service fail")
+
+	// The job mode also applies to stop and reload jobs
+	if _, errs := env.tf.apply("sys_systemd_unit", nil, map[string]interface{}{
+		"name":     "haproxy.service",
+		"job_mode": "isolate",
+	}); errs == "" {
+		t.Errorf("expected the isolate job mode to be rejected")
+	}
+}
+
+func TestSystemdUnitFailedCleanup(t *testing.T) {
+	env := newTestSystemdEnv(t)
+	u := env.sd.add("worker.service", systemdDisabled)
+	u.ActiveState, u.SubState = systemdFailed, "failed"
+
+	env.apply(nil, map[string]interface{}{
+		"name":         "worker.service",
+		"start":        true,
+		"kill":         true,
+		"reset_failed": true,
+	})
+	env.expectCalls("kill worker.service 9", "reset-failed worker.service", "start worker.service replace")
+	env.expectUnit("worker.service", systemdDisabled, systemdActive)
+}
+
+func TestSystemdUnitProperties(t *testing.T) {
+	env := newTestSystemdEnv(t)
+	u := env.sd.add("sshd.service", systemdEnabled)
+	u.Properties["MainPID"] = uint32(42)
+	u.Properties["NRestarts"] = uint32(3)
+
+	state := env.apply(nil, map[string]interface{}{
+		"name":       "sshd.service",
+		"properties": []interface{}{"MainPID", "NRestarts"},
+	})
+
+	props := state["property_values"].(map[string]interface{})
+	if props["MainPID"] != "42" || props["NRestarts"] != "3" {
+		t.Fatalf("unexpected properties: %v", props)
+	}
+}
+
+func TestSystemdUnitNotFound(t *testing.T) {
+	env := newTestSystemdEnv(t)
+
+	_, errs := env.tf.apply("sys_systemd_unit", nil, map[string]interface{}{
+		"name":  "missing.service",
+		"start": true,
+	})
+	if errs == "" {
+		t.Fatalf("expected an error for a missing unit")
+	}
+}
+
+func TestSystemdUnitPreset(t *testing.T) {
+	env := newTestSystemdEnv(t)
+	env.sd.add("fstrim.timer", systemdDisabled).Preset = true
+
+	state := env.apply(nil, map[string]interface{}{
+		"name":          "fstrim.timer",
+		"preset":        true,
+		"rollback_mode": "preset",
+	})
+	env.expectCalls("preset fstrim.timer")
+	env.expectUnit("fstrim.timer", systemdEnabled, systemdInactive)
+
+	// The preset policy is not applied again on update
+	state = env.apply(state, map[string]interface{}{
+		"name":          "fstrim.timer",
+		"preset":        true,
+		"rollback_mode": "preset",
+		"start":         true,
+	})
+	env.expectCalls("start fstrim.timer replace")
+
+	env.sd.Units["fstrim.timer"].FileState = systemdDisabled
+	env.apply(state, nil)
+	env.expectCalls("preset fstrim.timer", "stop fstrim.timer replace")
+	env.expectUnit("fstrim.timer", systemdEnabled, systemdInactive)
+}
+
+func TestSystemdUnitRollbackPreset(t *testing.T) {
+	env := newTestSystemdEnv(t)
+	env.sd.add("avahi-daemon.service", systemdEnabled)
+
+	state := env.apply(nil, map[string]interface{}{
+		"name":          "avahi-daemon.service",
+		"enable":        false,
+		"rollback_mode": "preset",
+	})
+	env.expectCalls("disable avahi-daemon.service")
+	env.expectUnit("avahi-daemon.service", systemdDisabled, systemdInactive)
+
+	// Restore the preset policy rather than the state found on creation
+	env.apply(state, nil)
+	env.expectCalls("preset avahi-daemon.service")
+	env.expectUnit("avahi-daemon.service", systemdDisabled, systemdInactive)
+}
+
+func TestSystemdUnitRuntime(t *testing.T) {
+	env := newTestSystemdEnv(t)
+	env.sd.add("debug-shell.service", systemdDisabled)
+
+	state := env.apply(nil, map[string]interface{}{
+		"name":    "debug-shell.service",
+		"enable":  true,
+		"runtime": true,
+	})
+	env.expectCalls("enable debug-shell.service --runtime")
+	env.expectUnit("debug-shell.service", systemdEnabledRuntime, systemdInactive)
+
+	if state["enable"] != true {
+		t.Fatalf("expected the unit to be enabled, got %v", state)
+	}
+
+	state = env.apply(state, map[string]interface{}{
+		"name":    "debug-shell.service",
+		"enable":  false,
+		"mask":    true,
+		"runtime": true,
+	})
+	env.expectCalls("disable debug-shell.service --runtime", "mask debug-shell.service --runtime")
+	env.expectUnit("debug-shell.service", systemdMaskedRuntime, systemdInactive)
+
+	env.apply(state, nil)
+	env.expectCalls("unmask debug-shell.service --runtime")
+	env.expectUnit("debug-shell.service", systemdDisabled, systemdInactive)
+}
+
+func testSystemdSocketUnits(sd *fakeSystemd) {
+	socket := sd.add("echo.socket", systemdDisabled)
+	socket.Properties["Triggers"] = []string{"echo.service"}
+	service := sd.add("echo.service", systemdStatic)
+	service.Properties["TriggeredBy"] = []string{"echo.socket"}
+}
+
+func TestSystemdUnitAlsoSocket(t *testing.T) {
+	env := newTestSystemdEnv(t)
+	testSystemdSocketUnits(env.sd)
+
+	config := map[string]interface{}{
+		"name":       "echo.socket",
+		"enable":     true,
+		"start":      true,
+		"also":       []interface{}{"echo.service"},
+		"restart_on": map[string]interface{}{"config": "1"},
+	}
+	state := env.apply(nil, config)
+	env.expectCalls("enable echo.socket", "restart echo.socket replace")
+	if !reflect.DeepEqual(state["triggers"], []interface{}{"echo.service"}) || len(state["triggered_by"].([]interface{})) != 0 {
+		t.Fatalf("unexpected relationships: %v", state)
+	}
+
+	// The service is stopped while the socket restarts, and activated again
+	// on demand
+	env.sd.Units["echo.service"].start()
+	config["restart_on"] = map[string]interface{}{"config": "2"}
+	state = env.apply(state, config)
+	env.expectCalls("stop echo.service replace", "restart echo.socket replace")
+	env.expectUnit("echo.service", systemdStatic, systemdInactive)
+
+	env.sd.Units["echo.service"].start()
+	env.apply(state, nil)
+	env.expectCalls("disable echo.socket", "stop echo.socket replace", "stop echo.service replace")
+	env.expectUnit("echo.socket", systemdDisabled, systemdInactive)
+	env.expectUnit("echo.service", systemdStatic, systemdInactive)
+}
+
+func TestSystemdUnitAlsoStopTriggers(t *testing.T) {
+	env := newTestSystemdEnv(t)
+	testSystemdSocketUnits(env.sd)
+	env.sd.Units["echo.socket"].FileState = systemdEnabled
+	env.sd.Units["echo.socket"].start()
+	env.sd.Units["echo.service"].start()
+
+	state := env.apply(nil, map[string]interface{}{
+		"name":   "echo.service",
+		"enable": false,
+		"start":  false,
+		"also":   []interface{}{"echo.socket"},
+	})
+
+	// The socket is stopped first so it cannot activate the service again
+	env.expectCalls("disable echo.socket", "stop echo.socket replace", "stop echo.service replace")
+	env.expectUnit("echo.socket", systemdDisabled, systemdInactive)
+	if !reflect.DeepEqual(state["triggered_by"], []interface{}{"echo.socket"}) {
+		t.Fatalf("unexpected relationships: %v", state)
+	}
+}
+
+func TestSystemdUnitAlsoRestart(t *testing.T) {
+	env := newTestSystemdEnv(t)
+	env.sd.add("app.service", systemdEnabled).start()
+	env.sd.add("app-worker.service", systemdEnabled).start()
+
+	config := map[string]interface{}{
+		"name":       "app.service",
+		"start":      true,
+		"also":       []interface{}{"app-worker.service"},
+		"restart_on": map[string]interface{}{"version": "1"},
+	}
+	env.apply(nil, config)
+	env.expectCalls("stop app-worker.service replace", "restart app.service replace", "start app-worker.service replace")
+	env.expectUnit("app-worker.service", systemdEnabled, systemdActive)
+}
+
+func TestSystemdUnitFile(t *testing.T) {
+	env := newTestSystemdEnv(t)
+	env.sd.add("app.service", systemdDisabled)
+	root := t.TempDir()
+	env.meta.Host = &rootHost{sysHost: localHost{}, Root: root}
+
+	config := map[string]interface{}{
+		"name":    "app.service",
+		"start":   true,
+		"service": map[string]interface{}{"ExecStart": "/bin/app"},
+	}
+	state := env.apply(nil, config)
+	env.expectCalls("start app.service replace")
+
+	filename := "/etc/systemd/system/app.service"
+	if state["path"] != filename || state["content"] != "[Service]\nExecStart=/bin/app\n" {
+		t.Fatalf("unexpected state %v", state)
+	}
+	if content, err := os.ReadFile(path.Join(root, filename)); err != nil || string(content) != state["content"] {
+		t.Fatalf("unexpected unit file %q (%v)", content, err)
+	}
+
+	// The unit restarts when its file changes
+	config["service"] = map[string]interface{}{"ExecStart": "/bin/app --verbose"}
+	state = env.apply(state, config)
+	env.expectCalls("restart app.service replace")
+
+	// The unit file is removed once no longer managed
+	delete(config, "service")
+	state = env.apply(state, config)
+	env.expectCalls("restart app.service replace")
+	if state["path"] != "" || state["content"] != "" {
+		t.Fatalf("unexpected state %v", state)
+	}
+	if _, err := os.Lstat(path.Join(root, filename)); !os.IsNotExist(err) {
+		t.Errorf("expected the unit file to be removed, got %v", err)
+	}
+
+	if _, errs := env.tf.apply("sys_systemd_unit", nil, map[string]interface{}{
+		"name":    "app.service",
+		"content": "[Service]\n",
+		"service": map[string]interface{}{"ExecStart": "/bin/app"},
+	}); errs == "" {
+		t.Errorf("expected content and the sections to conflict")
+	}
+}
+
+func TestSystemdUnitWaitActive(t *testing.T) {
+	env := newTestSystemdEnv(t)
+	env.sd.add("api.service", systemdEnabled)
+
+	config := map[string]interface{}{
+		"name":  "api.service",
+		"start": true,
+		"wait_active": []interface{}{map[string]interface{}{
+			"timeout": "0s",
+			"command": "exit 1",
+		}},
+	}
+	if _, errs := env.tf.apply("sys_systemd_unit", nil, config); !strings.Contains(errs, "timeout waiting for unit api.service") {
+		t.Fatalf("expected the health check to fail, got %q", errs)
+	}
+
+	env.sd.Units["api.service"].stop()
+	config["wait_active"] = []interface{}{map[string]interface{}{"command": "true"}}
+	state := env.apply(nil, config)
+	if w := state["wait_active"].([]interface{})[0].(map[string]interface{}); w["timeout"] != "1m" || w["journal_lines"] != 20 {
+		t.Fatalf("unexpected wait_active defaults %v", w)
+	}
+
+	// tcp and http checks cannot reach a remote host
+	env.meta.Host = &sshHost{Host: "example.org"}
+	config["wait_active"] = []interface{}{map[string]interface{}{"tcp": "127.0.0.1:80"}}
+	if _, errs := env.tf.apply("sys_systemd_unit", state, config); !strings.Contains(errs, "only supported on the local host") {
+		t.Errorf("expected the tcp check to be rejected, got %q", errs)
+	}
+}
@@ -0,0 +1,307 @@
+package sys
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/booldefault"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/planmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringplanmodifier"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+const (
+	yumReposDir = "/etc/yum.repos.d"
+	rpmKeysDir  = "/etc/pki/rpm-gpg"
+)
+
+type yumRepositoryResource struct {
+	config *providerConfiguration
+}
+
+type yumRepositoryResourceModel struct {
+	Id          types.String      `tfsdk:"id"`
+	Name        types.String      `tfsdk:"name"`
+	Description types.String      `tfsdk:"description"`
+	BaseURL     []string          `tfsdk:"baseurl"`
+	Mirrorlist  types.String      `tfsdk:"mirrorlist"`
+	Metalink    types.String      `tfsdk:"metalink"`
+	Key         types.String      `tfsdk:"key"`
+	Enabled     types.Bool        `tfsdk:"enabled"`
+	Options     map[string]string `tfsdk:"options"`
+	Filename    types.String      `tfsdk:"filename"`
+	KeyFilename types.String      `tfsdk:"key_filename"`
+}
+
+func newYumRepositoryResource() resource.Resource {
+	return &yumRepositoryResource{}
+}
+
+func (r *yumRepositoryResource) Metadata(ctx context.Context, req resource.MetadataRequest, resp *resource.MetadataResponse) {
+	resp.TypeName = req.ProviderTypeName + "_yum_repository"
+}
+
+func (r *yumRepositoryResource) Configure(ctx context.Context, req resource.ConfigureRequest, resp *resource.ConfigureResponse) {
+	r.config, _ = req.ProviderData.(*providerConfiguration)
+}
+
+func (r *yumRepositoryResource) Schema(ctx context.Context, req resource.SchemaRequest, resp *resource.SchemaResponse) {
+	resp.Schema = schema.Schema{
+		Description: `
+sys_yum_repository manages a yum/dnf repository in /etc/yum.repos.d, with an optional signing key stored in /etc/pki/rpm-gpg. The package metadata is updated again by the next sys_package creation.
+`,
+		Version: 1,
+
+		Attributes: map[string]schema.Attribute{
+			"id": schema.StringAttribute{
+				Computed:    true,
+				Description: "The ID of this resource.",
+			},
+			"name": schema.StringAttribute{
+				Description:   "Repository id, used for the repository and key file names",
+				Required:      true,
+				PlanModifiers: []planmodifier.String{stringplanmodifier.RequiresReplace()},
+			},
+			"description": schema.StringAttribute{
+				Description: "Human readable repository name",
+				Optional:    true,
+			},
+			"baseurl": schema.ListAttribute{
+				Description: "Repository URLs",
+				ElementType: types.StringType,
+				Optional:    true,
+			},
+			"mirrorlist": schema.StringAttribute{
+				Description: "URL of a mirror list",
+				Optional:    true,
+			},
+			"metalink": schema.StringAttribute{
+				Description: "URL of a metalink file",
+				Optional:    true,
+			},
+			"key": schema.StringAttribute{
+				Description: "ASCII armored signing key, enables gpgcheck",
+				Optional:    true,
+			},
+			"enabled": schema.BoolAttribute{
+				Description: "(default: true) Enable the repository",
+				Optional:    true,
+				Computed:    true,
+				Default:     booldefault.StaticBool(true),
+			},
+			"options": schema.MapAttribute{
+				Description: "Additional repository options",
+				ElementType: types.StringType,
+				Optional:    true,
+			},
+			"filename": schema.StringAttribute{
+				Description:   "Path of the generated repository file",
+				Computed:      true,
+				PlanModifiers: []planmodifier.String{stringplanmodifier.UseStateForUnknown()},
+			},
+			"key_filename": schema.StringAttribute{
+				Description: "Path of the generated key file",
+				Computed:    true,
+			},
+		},
+	}
+}
+
+func (r *yumRepositoryResource) ConfigValidators(ctx context.Context) []resource.ConfigValidator {
+	return []resource.ConfigValidator{
+		atLeastOneOfAttributes{"baseurl", "mirrorlist", "metalink"},
+	}
+}
+
+func (r *yumRepositoryResource) UpgradeState(ctx context.Context) map[int64]resource.StateUpgrader {
+	return sdkStateUpgraders(ctx, r)
+}
+
+func yumRepositoryFiles(data *yumRepositoryResourceModel) (string, string) {
+	name := data.Name.ValueString()
+	return path.Join(yumReposDir, name+".repo"), path.Join(rpmKeysDir, "RPM-GPG-KEY-"+name)
+}
+
+func yumBool(b bool) string {
+	if b {
+		return "1"
+	} else {
+		return "0"
+	}
+}
+
+func yumRepositoryContent(data *yumRepositoryResourceModel, keyfile string) string {
+	name := data.Name.ValueString()
+	description := data.Description.ValueString()
+	if description == "" {
+		description = name
+	}
+
+	lines := []string{
+		fmt.Sprintf("[%s]", name),
+		fmt.Sprintf("name=%s", description),
+	}
+	if len(data.BaseURL) > 0 {
+		lines = append(lines, fmt.Sprintf("baseurl=%s", strings.Join(data.BaseURL, "\n        ")))
+	}
+	if mirrorlist := data.Mirrorlist.ValueString(); mirrorlist != "" {
+		lines = append(lines, fmt.Sprintf("mirrorlist=%s", mirrorlist))
+	}
+	if metalink := data.Metalink.ValueString(); metalink != "" {
+		lines = append(lines, fmt.Sprintf("metalink=%s", metalink))
+	}
+	lines = append(lines, fmt.Sprintf("enabled=%s", yumBool(data.Enabled.ValueBool())))
+	if data.Key.ValueString() != "" {
+		lines = append(lines, "gpgcheck=1", fmt.Sprintf("gpgkey=file://%s", keyfile))
+	} else {
+		lines = append(lines, "gpgcheck=0")
+	}
+
+	var keys []string
+	for key := range data.Options {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		lines = append(lines, fmt.Sprintf("%s=%s", key, data.Options[key]))
+	}
+
+	return strings.Join(lines, "\n") + "\n"
+}
+
+func (r *yumRepositoryResource) Read(ctx context.Context, req resource.ReadRequest, resp *resource.ReadResponse) {
+	var data yumRepositoryResourceModel
+
+	resp.Diagnostics.Append(req.State.Get(ctx, &data)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	filename, keyfile := yumRepositoryFiles(&data)
+	host := providerHost(r.config)
+
+	content, err := host.ReadFile(filename)
+	if os.IsNotExist(err) {
+		resp.State.RemoveResource(ctx)
+		return
+	} else if err != nil {
+		resp.Diagnostics.AddError("cannot read "+filename, err.Error())
+		return
+	}
+
+	var key []byte
+	if data.Key.ValueString() != "" {
+		key, err = host.ReadFile(keyfile)
+		if err != nil && !os.IsNotExist(err) {
+			resp.Diagnostics.AddError("cannot read "+keyfile, err.Error())
+			return
+		}
+	}
+
+	// Files modified externally must be written again
+	if repositoryChecksum(string(content), string(key)) != data.Id.ValueString() {
+		resp.State.RemoveResource(ctx)
+	}
+}
+
+func (r *yumRepositoryResource) write(data *yumRepositoryResourceModel) error {
+	filename, keyfile := yumRepositoryFiles(data)
+	key := data.Key.ValueString()
+	content := yumRepositoryContent(data, keyfile)
+	host := providerHost(r.config)
+
+	lock := &dnfLock
+	lock.Lock()
+	defer lock.Unlock()
+
+	if key != "" {
+		err := repositoryWriteFile(host, keyfile, key)
+		if err != nil {
+			return err
+		}
+	} else if err := host.Remove(keyfile); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("cannot remove %s, %v", keyfile, err)
+	}
+
+	err := repositoryWriteFile(host, filename, content)
+	if err != nil {
+		return err
+	}
+
+	r.config.setPkgUpdated("rpm", false)
+
+	data.Filename = types.StringValue(filename)
+	if key != "" {
+		data.KeyFilename = types.StringValue(keyfile)
+	} else {
+		data.KeyFilename = types.StringValue("")
+	}
+	data.Id = types.StringValue(repositoryChecksum(content, key))
+	return nil
+}
+
+func (r *yumRepositoryResource) Create(ctx context.Context, req resource.CreateRequest, resp *resource.CreateResponse) {
+	var data yumRepositoryResourceModel
+
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &data)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	if err := r.write(&data); err != nil {
+		resp.Diagnostics.AddError("cannot write the repository", err.Error())
+		return
+	}
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+func (r *yumRepositoryResource) Update(ctx context.Context, req resource.UpdateRequest, resp *resource.UpdateResponse) {
+	var data yumRepositoryResourceModel
+
+	resp.Diagnostics.Append(req.Plan.Get(ctx, &data)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	if err := r.write(&data); err != nil {
+		resp.Diagnostics.AddError("cannot write the repository", err.Error())
+		return
+	}
+
+	resp.Diagnostics.Append(resp.State.Set(ctx, &data)...)
+}
+
+func (r *yumRepositoryResource) Delete(ctx context.Context, req resource.DeleteRequest, resp *resource.DeleteResponse) {
+	var data yumRepositoryResourceModel
+
+	resp.Diagnostics.Append(req.State.Get(ctx, &data)...)
+
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
+	filename, keyfile := yumRepositoryFiles(&data)
+
+	lock := &dnfLock
+	lock.Lock()
+	defer lock.Unlock()
+
+	for _, f := range []string{filename, keyfile} {
+		if err := providerHost(r.config).Remove(f); err != nil && !os.IsNotExist(err) {
+			resp.Diagnostics.AddError("cannot remove "+f, err.Error())
+			return
+		}
+	}
+
+	r.config.setPkgUpdated("rpm", false)
+}
@@ -0,0 +1,29 @@
+package sys
+
+import (
+	"context"
+
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+)
+
+// sdkSchemaVersion is the schema version of the states written by the SDK
+// implementations of the resources, the framework ones start after it
+const sdkSchemaVersion = 0
+
+// sdkStateUpgraders reads the states written by the SDK implementation of a
+// resource whose attributes kept the same types in the framework schema
+func sdkStateUpgraders(ctx context.Context, r resource.Resource) map[int64]resource.StateUpgrader {
+	var resp resource.SchemaResponse
+	r.Schema(ctx, resource.SchemaRequest{}, &resp)
+	prior := resp.Schema
+	prior.Version = sdkSchemaVersion
+
+	return map[int64]resource.StateUpgrader{
+		sdkSchemaVersion: {
+			PriorSchema: &prior,
+			StateUpgrader: func(ctx context.Context, req resource.UpgradeStateRequest, resp *resource.UpgradeStateResponse) {
+				resp.State.Raw = req.State.Raw
+			},
+		},
+	}
+}
@@ -0,0 +1,402 @@
+package sys
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	systemd "github.com/coreos/go-systemd/v22/dbus"
+)
+
+// fakeUnit is a unit known to the fake systemd manager
+type fakeUnit struct {
+	FileState   string
+	ActiveState string
+	SubState    string
+	FailStart   bool
+	Preset      bool
+	NotLoaded   bool
+	Properties  map[string]interface{}
+
+	unmaskedState string
+}
+
+// fakeSystemd is an in-memory systemd manager implementing sdManager. Jobs
+// complete immediately and every call is recorded.
+type fakeSystemd struct {
+	Units map[string]*fakeUnit
+	Calls []string
+	lock  sync.Mutex
+}
+
+func newFakeSystemd() *fakeSystemd {
+	return &fakeSystemd{Units: map[string]*fakeUnit{}}
+}
+
+// add declares a unit with its unit file state, inactive
+func (f *fakeSystemd) add(name, fileState string) *fakeUnit {
+	u := &fakeUnit{
+		FileState:   fileState,
+		ActiveState: systemdInactive,
+		SubState:    systemdDead,
+		Properties:  map[string]interface{}{},
+	}
+	f.Units[name] = u
+	return u
+}
+
+func (f *fakeSystemd) connect(ctx context.Context, scope sdScope) (sdManager, error) {
+	return f, nil
+}
+
+func (f *fakeSystemd) call(format string, args ...interface{}) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.Calls = append(f.Calls, fmt.Sprintf(format, args...))
+}
+
+// calls returns the recorded calls except the read-only ones, and clears them
+func (f *fakeSystemd) calls() []string {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	var res []string
+	for _, call := range f.Calls {
+		if call != "reload" {
+			res = append(res, call)
+		}
+	}
+	f.Calls = nil
+	return res
+}
+
+func fakeRuntime(runtime bool) string {
+	if runtime {
+		return " --runtime"
+	}
+	return ""
+}
+
+func (f *fakeSystemd) unit(name string) (*fakeUnit, error) {
+	u, ok := f.Units[name]
+	if !ok {
+		return nil, fmt.Errorf("Unit %s not found.", name)
+	}
+	return u, nil
+}
+
+func (f *fakeSystemd) complete(ch chan<- string, result string) {
+	if ch != nil {
+		go func() { ch <- result }()
+	}
+}
+
+func (f *fakeSystemd) Close() {}
+
+func (f *fakeSystemd) ReloadContext(ctx context.Context) error {
+	f.call("reload")
+	return nil
+}
+
+func (f *fakeSystemd) ListUnitsByNamesContext(ctx context.Context, units []string) ([]systemd.UnitStatus, error) {
+	var res []systemd.UnitStatus
+	for _, name := range units {
+		u, ok := f.Units[name]
+		if !ok {
+			res = append(res, systemd.UnitStatus{
+				Name:        name,
+				LoadState:   systemdNotFound,
+				ActiveState: systemdInactive,
+				SubState:    systemdDead,
+			})
+			continue
+		}
+
+		loadState := systemdLoaded
+		if sdIsMasked(u.FileState) {
+			loadState = systemdMasked
+		}
+		res = append(res, systemd.UnitStatus{
+			Name:        name,
+			Description: "Fake " + name,
+			LoadState:   loadState,
+			ActiveState: u.ActiveState,
+			SubState:    u.SubState,
+		})
+	}
+	return res, nil
+}
+
+// fakeMatch matches a name against glob patterns and a value against states,
+// empty lists matching everything
+func fakeMatch(name string, patterns []string, values []string, states []string) bool {
+	matched := len(patterns) == 0
+	for _, pattern := range patterns {
+		if ok, _ := filepath.Match(pattern, name); ok {
+			matched = true
+		}
+	}
+	if !matched || len(states) == 0 {
+		return matched
+	}
+	for _, state := range states {
+		for _, value := range values {
+			if state == value {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (f *fakeSystemd) ListUnitsByPatternsContext(ctx context.Context, states []string, patterns []string) ([]systemd.UnitStatus, error) {
+	var names []string
+	for name, u := range f.Units {
+		if !u.NotLoaded {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	loaded, err := f.ListUnitsByNamesContext(ctx, names)
+	if err != nil {
+		return nil, err
+	}
+
+	var res []systemd.UnitStatus
+	for _, status := range loaded {
+		if fakeMatch(status.Name, patterns, []string{status.LoadState, status.ActiveState, status.SubState}, states) {
+			res = append(res, status)
+		}
+	}
+	return res, nil
+}
+
+func (f *fakeSystemd) ListUnitFilesByPatternsContext(ctx context.Context, states []string, patterns []string) ([]systemd.UnitFile, error) {
+	var res []systemd.UnitFile
+	for name, u := range f.Units {
+		if u.FileState != "transient" && fakeMatch(name, patterns, []string{u.FileState}, states) {
+			res = append(res, systemd.UnitFile{Path: "/lib/systemd/system/" + name, Type: u.FileState})
+		}
+	}
+	return res, nil
+}
+
+func (f *fakeSystemd) GetUnitFileStateContext(ctx context.Context, name string) (string, error) {
+	u, err := f.unit(name)
+	if err != nil {
+		return "", err
+	}
+	return u.FileState, nil
+}
+
+func (f *fakeSystemd) GetUnitPropertiesContext(ctx context.Context, name string) (map[string]interface{}, error) {
+	u, err := f.unit(name)
+	if err != nil {
+		return nil, err
+	}
+	return u.Properties, nil
+}
+
+func (f *fakeSystemd) GetUnitTypePropertiesContext(ctx context.Context, name string, unitType string) (map[string]interface{}, error) {
+	return f.GetUnitPropertiesContext(ctx, name)
+}
+
+func (f *fakeSystemd) EnableUnitFilesContext(ctx context.Context, files []string, runtime bool, force bool) (bool, []systemd.EnableUnitFileChange, error) {
+	for _, name := range files {
+		f.call("enable %s%s", name, fakeRuntime(runtime))
+		u, err := f.unit(name)
+		if err != nil {
+			return false, nil, err
+		} else if sdIsMasked(u.FileState) {
+			return false, nil, fmt.Errorf("Unit file %s is masked.", name)
+		}
+		u.FileState = systemdEnabled
+		if runtime {
+			u.FileState = systemdEnabledRuntime
+		}
+	}
+	return true, nil, nil
+}
+
+func (f *fakeSystemd) DisableUnitFilesContext(ctx context.Context, files []string, runtime bool) ([]systemd.DisableUnitFileChange, error) {
+	for _, name := range files {
+		f.call("disable %s%s", name, fakeRuntime(runtime))
+		u, err := f.unit(name)
+		if err != nil {
+			return nil, err
+		}
+		if u.FileState == systemdEnabled || (runtime && u.FileState == systemdEnabledRuntime) {
+			u.FileState = systemdDisabled
+		}
+	}
+	return nil, nil
+}
+
+func (f *fakeSystemd) MaskUnitFilesContext(ctx context.Context, files []string, runtime bool, force bool) ([]systemd.MaskUnitFileChange, error) {
+	for _, name := range files {
+		f.call("mask %s%s", name, fakeRuntime(runtime))
+		u, err := f.unit(name)
+		if err != nil {
+			return nil, err
+		}
+		if !sdIsMasked(u.FileState) {
+			u.unmaskedState = u.FileState
+		}
+		u.FileState = systemdMasked
+		if runtime {
+			u.FileState = systemdMaskedRuntime
+		}
+	}
+	return nil, nil
+}
+
+func (f *fakeSystemd) UnmaskUnitFilesContext(ctx context.Context, files []string, runtime bool) ([]systemd.UnmaskUnitFileChange, error) {
+	for _, name := range files {
+		f.call("unmask %s%s", name, fakeRuntime(runtime))
+		u, err := f.unit(name)
+		if err != nil {
+			return nil, err
+		}
+		if sdIsMasked(u.FileState) {
+			u.FileState = u.unmaskedState
+			if u.FileState == "" {
+				u.FileState = systemdDisabled
+			}
+		}
+	}
+	return nil, nil
+}
+
+func (f *fakeSystemd) PresetUnitFilesContext(ctx context.Context, files []string, runtime bool, force bool) (bool, error) {
+	for _, name := range files {
+		f.call("preset %s", name)
+		u, err := f.unit(name)
+		if err != nil {
+			return false, err
+		} else if sdIsMasked(u.FileState) {
+			continue
+		}
+		u.FileState = systemdDisabled
+		if u.Preset {
+			u.FileState = systemdEnabled
+		}
+	}
+	return true, nil
+}
+
+func (f *fakeSystemd) job(verb, name, mode string, ch chan<- string, run func(u *fakeUnit) string) (int, error) {
+	f.call("%s %s %s", verb, name, mode)
+	u, err := f.unit(name)
+	if err != nil {
+		return 0, err
+	} else if sdIsMasked(u.FileState) && verb != "stop" {
+		return 0, fmt.Errorf("Unit %s is masked.", name)
+	}
+	f.complete(ch, run(u))
+	return 1, nil
+}
+
+func (u *fakeUnit) start() string {
+	if u.FailStart {
+		u.ActiveState, u.SubState = systemdFailed, "failed"
+		return "failed"
+	}
+	u.ActiveState, u.SubState = systemdActive, systemdRunning
+	return "done"
+}
+
+func (u *fakeUnit) stop() string {
+	u.ActiveState, u.SubState = systemdInactive, systemdDead
+	return "done"
+}
+
+func (f *fakeSystemd) StartUnitContext(ctx context.Context, name string, mode string, ch chan<- string) (int, error) {
+	return f.job("start", name, mode, ch, func(u *fakeUnit) string {
+		if sdIsActive(u.ActiveState) {
+			return "done"
+		}
+		return u.start()
+	})
+}
+
+func (f *fakeSystemd) StopUnitContext(ctx context.Context, name string, mode string, ch chan<- string) (int, error) {
+	return f.job("stop", name, mode, ch, (*fakeUnit).stop)
+}
+
+func (f *fakeSystemd) RestartUnitContext(ctx context.Context, name string, mode string, ch chan<- string) (int, error) {
+	return f.job("restart", name, mode, ch, (*fakeUnit).start)
+}
+
+func (f *fakeSystemd) TryRestartUnitContext(ctx context.Context, name string, mode string, ch chan<- string) (int, error) {
+	return f.job("try-restart", name, mode, ch, func(u *fakeUnit) string {
+		if !sdIsActive(u.ActiveState) {
+			return "done"
+		}
+		return u.start()
+	})
+}
+
+func (f *fakeSystemd) ReloadOrRestartUnitContext(ctx context.Context, name string, mode string, ch chan<- string) (int, error) {
+	return f.job("reload-or-restart", name, mode, ch, (*fakeUnit).start)
+}
+
+func (f *fakeSystemd) ReloadOrTryRestartUnitContext(ctx context.Context, name string, mode string, ch chan<- string) (int, error) {
+	return f.job("try-reload-or-restart", name, mode, ch, func(u *fakeUnit) string {
+		if !sdIsActive(u.ActiveState) {
+			return "done"
+		}
+		return u.start()
+	})
+}
+
+func (f *fakeSystemd) StartTransientUnitContext(ctx context.Context, name string, mode string, properties []systemd.Property, ch chan<- string) (int, error) {
+	f.call("start-transient %s %s", name, mode)
+	u := f.add(name, "transient")
+	for _, prop := range properties {
+		u.Properties[prop.Name] = prop.Value.Value()
+	}
+	f.complete(ch, u.start())
+	return 1, nil
+}
+
+func (f *fakeSystemd) StartTransientUnitAuxContext(ctx context.Context, name string, mode string, properties []systemd.Property, aux []systemd.PropertyCollection, ch chan<- string) (int, error) {
+	var names []string
+	for _, collection := range aux {
+		names = append(names, collection.Name)
+		u := f.add(collection.Name, "transient")
+		for _, prop := range collection.Properties {
+			u.Properties[prop.Name] = prop.Value.Value()
+		}
+	}
+	f.call("start-transient %s %s %s", name, mode, strings.Join(names, " "))
+	u, ok := f.Units[name]
+	if !ok {
+		u = f.add(name, "transient")
+	}
+	for _, prop := range properties {
+		u.Properties[prop.Name] = prop.Value.Value()
+	}
+	f.complete(ch, u.start())
+	return 1, nil
+}
+
+func (f *fakeSystemd) KillUnitContext(ctx context.Context, name string, signal int32) {
+	f.call("kill %s %d", name, signal)
+}
+
+func (f *fakeSystemd) ResetFailedUnitContext(ctx context.Context, name string) error {
+	f.call("reset-failed %s", name)
+	u, err := f.unit(name)
+	if err != nil {
+		return err
+	}
+	if sdIsFailed(u.ActiveState) {
+		u.stop()
+	}
+	return nil
+}
+
+var _ sdManager = (*fakeSystemd)(nil)
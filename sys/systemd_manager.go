@@ -0,0 +1,130 @@
+package sys
+
+import (
+	"context"
+	"path"
+	"strconv"
+
+	systemd "github.com/coreos/go-systemd/v22/dbus"
+	"github.com/godbus/dbus/v5"
+)
+
+// sdManager is the part of the systemd manager dBus API used by the
+// resources, implemented by sdConnection
+type sdManager interface {
+	Close()
+	ReloadContext(ctx context.Context) error
+
+	ListUnitsByNamesContext(ctx context.Context, units []string) ([]systemd.UnitStatus, error)
+	ListUnitsByPatternsContext(ctx context.Context, states []string, patterns []string) ([]systemd.UnitStatus, error)
+	ListUnitFilesByPatternsContext(ctx context.Context, states []string, patterns []string) ([]systemd.UnitFile, error)
+	GetUnitFileStateContext(ctx context.Context, name string) (string, error)
+	GetUnitPropertiesContext(ctx context.Context, unit string) (map[string]interface{}, error)
+	GetUnitTypePropertiesContext(ctx context.Context, unit string, unitType string) (map[string]interface{}, error)
+
+	EnableUnitFilesContext(ctx context.Context, files []string, runtime bool, force bool) (bool, []systemd.EnableUnitFileChange, error)
+	DisableUnitFilesContext(ctx context.Context, files []string, runtime bool) ([]systemd.DisableUnitFileChange, error)
+	MaskUnitFilesContext(ctx context.Context, files []string, runtime bool, force bool) ([]systemd.MaskUnitFileChange, error)
+	UnmaskUnitFilesContext(ctx context.Context, files []string, runtime bool) ([]systemd.UnmaskUnitFileChange, error)
+	PresetUnitFilesContext(ctx context.Context, files []string, runtime bool, force bool) (bool, error)
+
+	StartUnitContext(ctx context.Context, name string, mode string, ch chan<- string) (int, error)
+	StopUnitContext(ctx context.Context, name string, mode string, ch chan<- string) (int, error)
+	RestartUnitContext(ctx context.Context, name string, mode string, ch chan<- string) (int, error)
+	TryRestartUnitContext(ctx context.Context, name string, mode string, ch chan<- string) (int, error)
+	ReloadOrRestartUnitContext(ctx context.Context, name string, mode string, ch chan<- string) (int, error)
+	ReloadOrTryRestartUnitContext(ctx context.Context, name string, mode string, ch chan<- string) (int, error)
+	StartTransientUnitContext(ctx context.Context, name string, mode string, properties []systemd.Property, ch chan<- string) (int, error)
+	StartTransientUnitAuxContext(ctx context.Context, name string, mode string, properties []systemd.Property, aux []systemd.PropertyCollection, ch chan<- string) (int, error)
+	KillUnitContext(ctx context.Context, name string, signal int32)
+	ResetFailedUnitContext(ctx context.Context, name string) error
+}
+
+// sdConnection completes a go-systemd connection with the methods it lacks,
+// called on a raw dBus connection to the same manager
+type sdConnection struct {
+	*systemd.Conn
+	scope sdScope
+}
+
+// PresetUnitFilesContext enables or disables the units according to the
+// preset files, like systemctl preset
+func (c *sdConnection) PresetUnitFilesContext(ctx context.Context, files []string, runtime bool, force bool) (bool, error) {
+	conn, err := c.scope.Dial(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	var carriesInstallInfo bool
+	var changes [][]interface{}
+	manager := conn.Object("org.freedesktop.systemd1", "/org/freedesktop/systemd1")
+	err = manager.CallWithContext(ctx, "org.freedesktop.systemd1.Manager.PresetUnitFiles", 0,
+		files, runtime, force).Store(&carriesInstallInfo, &changes)
+	if err != nil {
+		return false, err
+	}
+
+	return carriesInstallInfo, nil
+}
+
+// StartTransientUnitAuxContext starts a transient unit along with auxiliary
+// transient units, such as the service triggered by a transient timer, which
+// go-systemd cannot pass. Like the go-systemd jobs, the job result is sent to
+// ch once it completes.
+func (c *sdConnection) StartTransientUnitAuxContext(ctx context.Context, name string, mode string, properties []systemd.Property, aux []systemd.PropertyCollection, ch chan<- string) (int, error) {
+	conn, err := c.scope.Dial(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	// Listen to the job completions before the job is queued, the private
+	// sockets have no bus daemon and send them unconditionally
+	signals := make(chan *dbus.Signal, 16)
+	conn.Signal(signals)
+	conn.BusObject().CallWithContext(ctx, "org.freedesktop.DBus.AddMatch", 0,
+		"type='signal', interface='org.freedesktop.systemd1.Manager', member='JobRemoved'")
+
+	var job dbus.ObjectPath
+	manager := conn.Object("org.freedesktop.systemd1", "/org/freedesktop/systemd1")
+	err = manager.CallWithContext(ctx, "org.freedesktop.systemd1.Manager.StartTransientUnit", 0,
+		name, mode, properties, aux).Store(&job)
+	if err != nil || ch == nil {
+		conn.Close()
+		return 0, err
+	}
+
+	go func() {
+		defer conn.Close()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case signal, ok := <-signals:
+				if !ok {
+					return
+				}
+
+				var id uint32
+				var removed dbus.ObjectPath
+				var unit, result string
+				if signal.Name != "org.freedesktop.systemd1.Manager.JobRemoved" ||
+					dbus.Store(signal.Body, &id, &removed, &unit, &result) != nil || removed != job {
+					continue
+				}
+
+				select {
+				case ch <- result:
+				case <-ctx.Done():
+				}
+				return
+			}
+		}
+	}()
+
+	// ignore the error like go-systemd, 0 is fine if the conversion fails
+	jobID, _ := strconv.Atoi(path.Base(string(job)))
+	return jobID, nil
+}
+
+var _ sdManager = (*sdConnection)(nil)
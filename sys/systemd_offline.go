@@ -0,0 +1,510 @@
+package sys
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	systemd "github.com/coreos/go-systemd/v22/dbus"
+)
+
+// Directories searched for system unit files, by priority
+var sdOfflineUnitPath = []string{
+	sdSystemUnitDir,
+	sdRuntimeUnitDir,
+	"/usr/local/lib/systemd/system",
+	"/usr/lib/systemd/system",
+	"/lib/systemd/system",
+}
+
+const sdRuntimeUnitDir = "/run/systemd/system"
+
+// sdOffline manages the system unit files without a running manager, like
+// systemctl --root does: units are enabled by creating the symlinks of their
+// [Install] section and masked by linking them to /dev/null. No unit is ever
+// running, jobs that would start one fail.
+type sdOffline struct {
+	host sysHost
+}
+
+var _ sdManager = (*sdOffline)(nil)
+
+// sdOfflineInstall is the [Install] section of a unit file
+type sdOfflineInstall struct {
+	WantedBy        []string
+	RequiredBy      []string
+	Alias           []string
+	Also            []string
+	DefaultInstance string
+}
+
+func (i *sdOfflineInstall) empty() bool {
+	return len(i.WantedBy) == 0 && len(i.RequiredBy) == 0 && len(i.Alias) == 0 && len(i.Also) == 0
+}
+
+// sdOfflineUnit is a unit file found in the unit path
+type sdOfflineUnit struct {
+	// Name of the unit, instances of a template have the name of the instance
+	Name string
+	// Path of the unit file, or of the template for instances
+	Path        string
+	Description string
+	Install     sdOfflineInstall
+}
+
+func sdOfflineError(verb, name string) error {
+	return fmt.Errorf("cannot %s %s without a running systemd manager", verb, name)
+}
+
+// sdOfflineParse reads the Description and the [Install] section of a unit
+// file, values of repeated keys are accumulated
+func sdOfflineParse(u *sdOfflineUnit, content string) {
+	var section string
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		} else if line[0] == '[' {
+			section = strings.Trim(line, "[]")
+			continue
+		}
+
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		key, value := strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+
+		switch section + "." + key {
+		case "Unit.Description":
+			u.Description = value
+		case "Install.WantedBy":
+			u.Install.WantedBy = append(u.Install.WantedBy, strings.Fields(value)...)
+		case "Install.RequiredBy":
+			u.Install.RequiredBy = append(u.Install.RequiredBy, strings.Fields(value)...)
+		case "Install.Alias":
+			u.Install.Alias = append(u.Install.Alias, strings.Fields(value)...)
+		case "Install.Also":
+			u.Install.Also = append(u.Install.Also, strings.Fields(value)...)
+		case "Install.DefaultInstance":
+			u.Install.DefaultInstance = value
+		}
+	}
+}
+
+// resolve follows the symlinks of a file within the host
+func (sd *sdOffline) resolve(filename string) (string, error) {
+	for i := 0; i < 32; i++ {
+		st, err := sd.host.Lstat(filename)
+		if err != nil {
+			return "", err
+		} else if st.Mode()&os.ModeSymlink == 0 {
+			return filename, nil
+		}
+
+		target, err := sd.host.Readlink(filename)
+		if err != nil {
+			return "", err
+		}
+		if !path.IsAbs(target) {
+			target = path.Join(path.Dir(filename), target)
+		}
+		filename = target
+	}
+	return "", fmt.Errorf("too many levels of symbolic links in %s", filename)
+}
+
+// masked returns the masked unit file state, or an empty string
+func (sd *sdOffline) masked(name string) string {
+	for dir, state := range map[string]string{sdSystemUnitDir: systemdMasked, sdRuntimeUnitDir: systemdMaskedRuntime} {
+		if target, err := sd.host.Readlink(path.Join(dir, name)); err == nil && target == "/dev/null" {
+			return state
+		}
+	}
+	return ""
+}
+
+// find looks up a unit file, instances use the file of their template. It
+// returns nil if there is no unit file.
+func (sd *sdOffline) find(name string) (*sdOfflineUnit, error) {
+	names := []string{name}
+	if at := strings.Index(name, "@"); at >= 0 && !strings.HasPrefix(name[at:], "@.") {
+		names = append(names, name[:at+1]+path.Ext(name))
+	}
+
+	for _, filename := range names {
+		for _, dir := range sdOfflineUnitPath {
+			unitPath := path.Join(dir, filename)
+			resolved, err := sd.resolve(unitPath)
+			if os.IsNotExist(err) {
+				continue
+			} else if err != nil {
+				return nil, err
+			}
+
+			u := &sdOfflineUnit{Name: name, Path: unitPath}
+			if resolved != "/dev/null" {
+				content, err := sd.host.ReadFile(resolved)
+				if err != nil {
+					return nil, fmt.Errorf("cannot read %s, %v", unitPath, err)
+				}
+				sdOfflineParse(u, string(content))
+			}
+			return u, nil
+		}
+	}
+
+	return nil, nil
+}
+
+func (sd *sdOffline) mustFind(name string) (*sdOfflineUnit, error) {
+	u, err := sd.find(name)
+	if err == nil && u == nil {
+		err = fmt.Errorf("Unit file %s does not exist.", name)
+	}
+	return u, err
+}
+
+// links returns the symlinks enabling the unit in the unit directory
+func (sd *sdOffline) links(u *sdOfflineUnit, dir string) []string {
+	name := u.Name
+	if strings.HasSuffix(name, "@"+path.Ext(name)) && u.Install.DefaultInstance != "" {
+		name = strings.TrimSuffix(name, path.Ext(name)) + u.Install.DefaultInstance + path.Ext(name)
+	}
+
+	var res []string
+	for _, target := range u.Install.WantedBy {
+		res = append(res, path.Join(dir, target+".wants", name))
+	}
+	for _, target := range u.Install.RequiredBy {
+		res = append(res, path.Join(dir, target+".requires", name))
+	}
+	for _, alias := range u.Install.Alias {
+		res = append(res, path.Join(dir, alias))
+	}
+	return res
+}
+
+func (sd *sdOffline) enabledIn(u *sdOfflineUnit, dir string) bool {
+	for _, link := range sd.links(u, dir) {
+		if _, err := sd.host.Lstat(link); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+func (sd *sdOffline) state(u *sdOfflineUnit) string {
+	if state := sd.masked(u.Name); state != "" {
+		return state
+	} else if sd.enabledIn(u, sdSystemUnitDir) {
+		return systemdEnabled
+	} else if sd.enabledIn(u, sdRuntimeUnitDir) {
+		return systemdEnabledRuntime
+	} else if u.Install.empty() {
+		return systemdStatic
+	}
+	return systemdDisabled
+}
+
+func (sd *sdOffline) Close() {}
+
+func (sd *sdOffline) ReloadContext(ctx context.Context) error {
+	return nil
+}
+
+func (sd *sdOffline) ListUnitsByNamesContext(ctx context.Context, units []string) ([]systemd.UnitStatus, error) {
+	var res []systemd.UnitStatus
+	for _, name := range units {
+		status := systemd.UnitStatus{
+			Name:        name,
+			LoadState:   systemdLoaded,
+			ActiveState: systemdInactive,
+			SubState:    systemdDead,
+		}
+
+		u, err := sd.find(name)
+		if err != nil {
+			return nil, err
+		} else if u == nil {
+			status.LoadState = systemdNotFound
+		} else if sd.masked(name) != "" {
+			status.LoadState = systemdMasked
+		} else {
+			status.Description = u.Description
+		}
+
+		res = append(res, status)
+	}
+	return res, nil
+}
+
+// ListUnitsByPatternsContext returns nothing, no unit is loaded
+func (sd *sdOffline) ListUnitsByPatternsContext(ctx context.Context, states []string, patterns []string) ([]systemd.UnitStatus, error) {
+	return nil, nil
+}
+
+func (sd *sdOffline) ListUnitFilesByPatternsContext(ctx context.Context, states []string, patterns []string) ([]systemd.UnitFile, error) {
+	seen := map[string]bool{}
+	var names []string
+	for _, dir := range sdOfflineUnitPath {
+		entries, err := sd.host.ReadDir(dir)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			name := entry.Name()
+			if entry.IsDir() || seen[name] || path.Ext(name) == "" {
+				continue
+			}
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var res []systemd.UnitFile
+	for _, name := range names {
+		matched := len(patterns) == 0
+		for _, pattern := range patterns {
+			if ok, _ := filepath.Match(pattern, name); ok {
+				matched = true
+			}
+		}
+		if !matched {
+			continue
+		}
+
+		u, err := sd.find(name)
+		if err != nil {
+			return nil, err
+		}
+		state := sd.state(u)
+		if len(states) > 0 && !stringListContains(states, state) {
+			continue
+		}
+		res = append(res, systemd.UnitFile{Path: u.Path, Type: state})
+	}
+	return res, nil
+}
+
+func (sd *sdOffline) GetUnitFileStateContext(ctx context.Context, name string) (string, error) {
+	u, err := sd.mustFind(name)
+	if err != nil {
+		return "", err
+	}
+	return sd.state(u), nil
+}
+
+func (sd *sdOffline) GetUnitPropertiesContext(ctx context.Context, name string) (map[string]interface{}, error) {
+	statuses, err := sd.ListUnitsByNamesContext(ctx, []string{name})
+	if err != nil {
+		return nil, err
+	}
+	status := statuses[0]
+
+	props := map[string]interface{}{
+		"Id":          name,
+		"Description": status.Description,
+		"LoadState":   status.LoadState,
+		"ActiveState": status.ActiveState,
+		"SubState":    status.SubState,
+	}
+	if u, err := sd.find(name); err == nil && u != nil {
+		props["FragmentPath"] = u.Path
+		props["UnitFileState"] = sd.state(u)
+	}
+	return props, nil
+}
+
+// GetUnitTypePropertiesContext returns no property, they are only known to
+// the manager
+func (sd *sdOffline) GetUnitTypePropertiesContext(ctx context.Context, name string, unitType string) (map[string]interface{}, error) {
+	return map[string]interface{}{}, nil
+}
+
+func sdOfflineDir(runtime bool) string {
+	if runtime {
+		return sdRuntimeUnitDir
+	}
+	return sdSystemUnitDir
+}
+
+func (sd *sdOffline) EnableUnitFilesContext(ctx context.Context, files []string, runtime bool, force bool) (bool, []systemd.EnableUnitFileChange, error) {
+	var changes []systemd.EnableUnitFileChange
+	carriesInstallInfo := false
+	done := map[string]bool{}
+
+	for len(files) > 0 {
+		name := files[0]
+		files = files[1:]
+		if done[name] {
+			continue
+		}
+		done[name] = true
+
+		if sd.masked(name) != "" {
+			return false, changes, fmt.Errorf("Unit file %s is masked.", name)
+		}
+		u, err := sd.mustFind(name)
+		if err != nil {
+			return false, changes, err
+		}
+		carriesInstallInfo = carriesInstallInfo || !u.Install.empty()
+
+		for _, link := range sd.links(u, sdOfflineDir(runtime)) {
+			if target, err := sd.host.Readlink(link); err == nil && target == u.Path {
+				continue
+			} else if err == nil && force {
+				if err := sd.host.Remove(link); err != nil {
+					return false, changes, err
+				}
+			}
+
+			if err := sd.host.MkdirAll(path.Dir(link), 0755); err != nil {
+				return false, changes, err
+			}
+			if err := sd.host.Symlink(u.Path, link); err != nil {
+				return false, changes, err
+			}
+			changes = append(changes, systemd.EnableUnitFileChange{Type: "symlink", Filename: link, Destination: u.Path})
+		}
+
+		files = append(files, u.Install.Also...)
+	}
+
+	return carriesInstallInfo, changes, nil
+}
+
+func (sd *sdOffline) DisableUnitFilesContext(ctx context.Context, files []string, runtime bool) ([]systemd.DisableUnitFileChange, error) {
+	var changes []systemd.DisableUnitFileChange
+	done := map[string]bool{}
+
+	for len(files) > 0 {
+		name := files[0]
+		files = files[1:]
+		if done[name] {
+			continue
+		}
+		done[name] = true
+
+		u, err := sd.mustFind(name)
+		if err != nil {
+			return changes, err
+		}
+
+		for _, link := range sd.links(u, sdOfflineDir(runtime)) {
+			if _, err := sd.host.Readlink(link); err != nil {
+				continue
+			}
+			if err := sd.host.Remove(link); err != nil {
+				return changes, err
+			}
+			changes = append(changes, systemd.DisableUnitFileChange{Type: "unlink", Filename: link})
+		}
+
+		files = append(files, u.Install.Also...)
+	}
+
+	return changes, nil
+}
+
+func (sd *sdOffline) MaskUnitFilesContext(ctx context.Context, files []string, runtime bool, force bool) ([]systemd.MaskUnitFileChange, error) {
+	var changes []systemd.MaskUnitFileChange
+	for _, name := range files {
+		link := path.Join(sdOfflineDir(runtime), name)
+
+		st, err := sd.host.Lstat(link)
+		if err == nil && st.Mode()&os.ModeSymlink == 0 {
+			return changes, fmt.Errorf("File %s already exists.", link)
+		} else if err == nil {
+			if target, _ := sd.host.Readlink(link); target == "/dev/null" {
+				continue
+			} else if !force {
+				return changes, fmt.Errorf("File %s already exists.", link)
+			} else if err := sd.host.Remove(link); err != nil {
+				return changes, err
+			}
+		}
+
+		if err := sd.host.MkdirAll(path.Dir(link), 0755); err != nil {
+			return changes, err
+		}
+		if err := sd.host.Symlink("/dev/null", link); err != nil {
+			return changes, err
+		}
+		changes = append(changes, systemd.MaskUnitFileChange{Type: "symlink", Filename: link, Destination: "/dev/null"})
+	}
+	return changes, nil
+}
+
+func (sd *sdOffline) UnmaskUnitFilesContext(ctx context.Context, files []string, runtime bool) ([]systemd.UnmaskUnitFileChange, error) {
+	var changes []systemd.UnmaskUnitFileChange
+	for _, name := range files {
+		link := path.Join(sdOfflineDir(runtime), name)
+		if target, err := sd.host.Readlink(link); err != nil || target != "/dev/null" {
+			continue
+		}
+		if err := sd.host.Remove(link); err != nil {
+			return changes, err
+		}
+		changes = append(changes, systemd.UnmaskUnitFileChange{Type: "unlink", Filename: link})
+	}
+	return changes, nil
+}
+
+func (sd *sdOffline) PresetUnitFilesContext(ctx context.Context, files []string, runtime bool, force bool) (bool, error) {
+	return false, sdOfflineError("preset", strings.Join(files, ", "))
+}
+
+// done completes a job that has nothing to do, the unit being inactive
+func (sd *sdOffline) done(ch chan<- string) (int, error) {
+	if ch != nil {
+		go func() { ch <- "done" }()
+	}
+	return 0, nil
+}
+
+func (sd *sdOffline) StartUnitContext(ctx context.Context, name string, mode string, ch chan<- string) (int, error) {
+	return 0, sdOfflineError("start", name)
+}
+
+func (sd *sdOffline) StopUnitContext(ctx context.Context, name string, mode string, ch chan<- string) (int, error) {
+	return sd.done(ch)
+}
+
+func (sd *sdOffline) RestartUnitContext(ctx context.Context, name string, mode string, ch chan<- string) (int, error) {
+	return 0, sdOfflineError("restart", name)
+}
+
+func (sd *sdOffline) TryRestartUnitContext(ctx context.Context, name string, mode string, ch chan<- string) (int, error) {
+	return sd.done(ch)
+}
+
+func (sd *sdOffline) ReloadOrRestartUnitContext(ctx context.Context, name string, mode string, ch chan<- string) (int, error) {
+	return 0, sdOfflineError("restart", name)
+}
+
+func (sd *sdOffline) ReloadOrTryRestartUnitContext(ctx context.Context, name string, mode string, ch chan<- string) (int, error) {
+	return sd.done(ch)
+}
+
+func (sd *sdOffline) StartTransientUnitContext(ctx context.Context, name string, mode string, properties []systemd.Property, ch chan<- string) (int, error) {
+	return 0, sdOfflineError("start", name)
+}
+
+func (sd *sdOffline) StartTransientUnitAuxContext(ctx context.Context, name string, mode string, properties []systemd.Property, aux []systemd.PropertyCollection, ch chan<- string) (int, error) {
+	return 0, sdOfflineError("start", name)
+}
+
+func (sd *sdOffline) KillUnitContext(ctx context.Context, name string, signal int32) {}
+
+func (sd *sdOffline) ResetFailedUnitContext(ctx context.Context, name string) error {
+	return nil
+}
@@ -0,0 +1,167 @@
+package sys
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+)
+
+func newTestOffline(t *testing.T) (*sdOffline, string) {
+	t.Helper()
+	root := t.TempDir()
+	lib := path.Join(root, "usr/lib/systemd/system")
+	if err := os.MkdirAll(lib, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, content := range map[string]string{
+		"app.service":     "[Unit]\nDescription=App\n[Service]\nExecStart=/bin/app\n[Install]\nWantedBy=multi-user.target\nAlias=web.service\nAlso=app.socket\n",
+		"app.socket":      "[Socket]\nListenStream=80\n[Install]\nWantedBy=sockets.target\n",
+		"getty@.service":  "[Service]\nExecStart=/sbin/agetty %I\n[Install]\nWantedBy=getty.target\nDefaultInstance=tty1\n",
+		"static.service":  "[Service]\nExecStart=/bin/true\n",
+		"ignored.service": "[Service]\nExecStart=/bin/false\n",
+	} {
+		if err := ioutil.WriteFile(path.Join(lib, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return &sdOffline{host: &rootHost{sysHost: localHost{}, Root: root}}, root
+}
+
+func expectOfflineState(t *testing.T, sd *sdOffline, name, expected string) {
+	t.Helper()
+	state, err := sd.GetUnitFileStateContext(context.Background(), name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state != expected {
+		t.Fatalf("expected %s to be %s, got %s", name, expected, state)
+	}
+}
+
+func TestSystemdOfflineEnable(t *testing.T) {
+	ctx := context.Background()
+	sd, root := newTestOffline(t)
+
+	expectOfflineState(t, sd, "app.service", systemdDisabled)
+	expectOfflineState(t, sd, "static.service", systemdStatic)
+	if _, err := sd.GetUnitFileStateContext(ctx, "missing.service"); err == nil {
+		t.Errorf("expected an error on a missing unit file")
+	}
+
+	install, changes, err := sd.EnableUnitFilesContext(ctx, []string{"app.service", "getty@.service"}, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !install || len(changes) != 4 {
+		t.Errorf("unexpected changes %v", changes)
+	}
+	for link, target := range map[string]string{
+		"etc/systemd/system/multi-user.target.wants/app.service":   "/usr/lib/systemd/system/app.service",
+		"etc/systemd/system/web.service":                           "/usr/lib/systemd/system/app.service",
+		"etc/systemd/system/sockets.target.wants/app.socket":       "/usr/lib/systemd/system/app.socket",
+		"etc/systemd/system/getty.target.wants/getty@tty1.service": "/usr/lib/systemd/system/getty@.service",
+	} {
+		if res, err := os.Readlink(path.Join(root, link)); err != nil || res != target {
+			t.Errorf("expected %s to link to %s, got %q (%v)", link, target, res, err)
+		}
+	}
+	expectOfflineState(t, sd, "app.service", systemdEnabled)
+	expectOfflineState(t, sd, "app.socket", systemdEnabled)
+	expectOfflineState(t, sd, "getty@tty2.service", systemdDisabled)
+
+	if _, changes, err = sd.EnableUnitFilesContext(ctx, []string{"app.service"}, false, false); err != nil || len(changes) != 0 {
+		t.Errorf("expected enable to be idempotent, got %v (%v)", changes, err)
+	}
+
+	if _, err := sd.DisableUnitFilesContext(ctx, []string{"app.service"}, false); err != nil {
+		t.Fatal(err)
+	}
+	expectOfflineState(t, sd, "app.service", systemdDisabled)
+	expectOfflineState(t, sd, "app.socket", systemdDisabled)
+}
+
+func TestSystemdOfflineMask(t *testing.T) {
+	ctx := context.Background()
+	sd, root := newTestOffline(t)
+
+	if _, err := sd.MaskUnitFilesContext(ctx, []string{"app.service"}, false, false); err != nil {
+		t.Fatal(err)
+	}
+	if target, err := os.Readlink(path.Join(root, "etc/systemd/system/app.service")); err != nil || target != "/dev/null" {
+		t.Fatalf("expected the unit to link to /dev/null, got %q (%v)", target, err)
+	}
+	expectOfflineState(t, sd, "app.service", systemdMasked)
+
+	units, err := sd.ListUnitsByNamesContext(ctx, []string{"app.service", "missing.service"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if units[0].LoadState != systemdMasked || units[1].LoadState != systemdNotFound {
+		t.Errorf("unexpected units %v", units)
+	}
+	if _, _, err := sd.EnableUnitFilesContext(ctx, []string{"app.service"}, false, false); err == nil {
+		t.Errorf("expected enable to fail on a masked unit")
+	}
+
+	if _, err := sd.UnmaskUnitFilesContext(ctx, []string{"app.service"}, false); err != nil {
+		t.Fatal(err)
+	}
+	expectOfflineState(t, sd, "app.service", systemdDisabled)
+
+	if _, err := sd.MaskUnitFilesContext(ctx, []string{"static.service"}, true, false); err != nil {
+		t.Fatal(err)
+	}
+	expectOfflineState(t, sd, "static.service", systemdMaskedRuntime)
+
+	if _, err := sd.StartUnitContext(ctx, "app.service", "replace", nil); err == nil {
+		t.Errorf("expected start to fail offline")
+	}
+}
+
+func TestSystemdUnitRoot(t *testing.T) {
+	sd, root := newTestOffline(t)
+	env := newTestFrameworkEnvWith(t, &providerConfiguration{Host: sd.host})
+
+	state := env.mustApply("sys_systemd_unit", nil, map[string]interface{}{
+		"name":    "hello.service",
+		"content": "[Service]\nExecStart=/bin/hello\n[Install]\nWantedBy=multi-user.target\n",
+		"enable":  true,
+	})
+	if state["enable"] != true || state["load_state"] != systemdLoaded {
+		t.Errorf("unexpected state after create: %v", state)
+	}
+	if target, err := os.Readlink(path.Join(root, "etc/systemd/system/multi-user.target.wants/hello.service")); err != nil || target != "/etc/systemd/system/hello.service" {
+		t.Fatalf("expected the unit to be enabled in the root, got %q (%v)", target, err)
+	}
+
+	env.mustApply("sys_systemd_unit", state, nil)
+	if _, err := os.Lstat(path.Join(root, "etc/systemd/system/hello.service")); !os.IsNotExist(err) {
+		t.Errorf("expected the unit file to be removed, got %v", err)
+	}
+	if _, err := os.Lstat(path.Join(root, "etc/systemd/system/multi-user.target.wants/hello.service")); !os.IsNotExist(err) {
+		t.Errorf("expected the unit to be disabled, got %v", err)
+	}
+}
+
+func TestSystemdScopeConnOffline(t *testing.T) {
+	ctx := context.Background()
+	m := &providerConfiguration{}
+
+	sd, err := sdScope{host: &rootHost{sysHost: localHost{}, Root: t.TempDir()}}.Conn(ctx, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := sd.(*sdOffline); !ok {
+		t.Errorf("expected root_dir to be managed offline, got %T", sd)
+	}
+
+	// A host whose bus cannot be reached is an error, not an offline root
+	h := &sshHost{Host: "example.org", Program: "false"}
+	if sd, err := (sdScope{host: h}).Conn(ctx, m); err == nil {
+		t.Errorf("expected a connection error, got %T", sd)
+	}
+}
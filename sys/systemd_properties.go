@@ -0,0 +1,87 @@
+package sys
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// sdUnitType returns the dBus interface suffix for the unit type specific
+// properties (Service for foo.service, Timer for foo.timer, ...)
+func sdUnitType(unit string) string {
+	ext := strings.TrimPrefix(path.Ext(unit), ".")
+	if ext == "" {
+		return ""
+	}
+	return strings.ToUpper(ext[:1]) + ext[1:]
+}
+
+// sdFormatProperty converts a dBus property value to a string
+func sdFormatProperty(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case []string:
+		return strings.Join(v, " ")
+	case []byte:
+		return hex.EncodeToString(v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// sdUnitProperties fetches the named unit properties, looking first at the
+// generic unit properties and then at the unit type specific ones
+func sdUnitProperties(ctx context.Context, sd sdManager, unit string, names []string) (map[string]string, error) {
+	res := map[string]string{}
+	if len(names) == 0 {
+		return res, nil
+	}
+
+	props, err := sd.GetUnitPropertiesContext(ctx, unit)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get properties of unit %s: %v", unit, err)
+	}
+
+	var typeProps map[string]interface{}
+	for _, name := range names {
+		value, ok := props[name]
+		if !ok && typeProps == nil {
+			typeProps, err = sd.GetUnitTypePropertiesContext(ctx, unit, sdUnitType(unit))
+			if err != nil {
+				return nil, fmt.Errorf("cannot get %s properties of unit %s: %v", sdUnitType(unit), unit, err)
+			}
+		}
+		if !ok {
+			value, ok = typeProps[name]
+		}
+		if !ok {
+			return nil, fmt.Errorf("unit %s has no property %s", unit, name)
+		}
+
+		res[name] = sdFormatProperty(value)
+	}
+
+	return res, nil
+}
+
+// sdUnitTriggers returns the units activated by the unit and the units that
+// can activate it
+func sdUnitTriggers(ctx context.Context, sd sdManager, unit string) ([]string, []string, error) {
+	props, err := sd.GetUnitPropertiesContext(ctx, unit)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot get properties of unit %s: %v", unit, err)
+	}
+
+	triggers, _ := props["Triggers"].([]string)
+	triggeredBy, _ := props["TriggeredBy"].([]string)
+	if triggers == nil {
+		triggers = []string{}
+	}
+	if triggeredBy == nil {
+		triggeredBy = []string{}
+	}
+	return triggers, triggeredBy, nil
+}
@@ -0,0 +1,39 @@
+package sys
+
+import (
+	"testing"
+)
+
+func TestSdUnitType(t *testing.T) {
+	for unit, expected := range map[string]string{
+		"nginx.service":     "Service",
+		"logrotate.timer":   "Timer",
+		"home.mount":        "Mount",
+		"system-foo.slice":  "Slice",
+		"no-suffix":         "",
+		"dbus.socket":       "Socket",
+		"multi-user.target": "Target",
+	} {
+		if res := sdUnitType(unit); res != expected {
+			t.Errorf("sdUnitType(%q) = %q, expected %q", unit, res, expected)
+		}
+	}
+}
+
+func TestSdFormatProperty(t *testing.T) {
+	for _, c := range []struct {
+		value    interface{}
+		expected string
+	}{
+		{"running", "running"},
+		{uint32(1234), "1234"},
+		{uint64(1700000000000000), "1700000000000000"},
+		{true, "true"},
+		{[]string{"network.target", "basic.target"}, "network.target basic.target"},
+		{[]byte{0xde, 0xad}, "dead"},
+	} {
+		if res := sdFormatProperty(c.value); res != c.expected {
+			t.Errorf("sdFormatProperty(%#v) = %q, expected %q", c.value, res, c.expected)
+		}
+	}
+}
@@ -0,0 +1,235 @@
+package sys
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	systemd "github.com/coreos/go-systemd/v22/dbus"
+	"github.com/godbus/dbus/v5"
+)
+
+// sdScope identifies the systemd manager a resource talks to: the system
+// manager, the current user's manager or the manager of another user, on the
+// provider host
+type sdScope struct {
+	User     bool
+	UserName string
+	host     sysHost
+}
+
+// newSdScope returns the scope selected by the user and user_name attributes
+func newSdScope(user bool, userName string, m interface{}) sdScope {
+	return sdScope{
+		User:     user,
+		UserName: userName,
+		host:     providerHost(m),
+	}
+}
+
+// Host returns the host of the manager, the local host by default
+func (s sdScope) Host() sysHost {
+	if s.host == nil {
+		return localHost{}
+	}
+	return s.host
+}
+
+// Bus returns a key identifying the manager, used to separate unit locks
+func (s sdScope) Bus() string {
+	if s.UserName != "" {
+		return "user:" + s.UserName
+	} else if s.User {
+		return "user"
+	}
+	return "system"
+}
+
+func (s sdScope) lookup() (*user.User, error) {
+	if s.Host().Remote() {
+		return nil, fmt.Errorf("user_name %s is only supported on the local host", s.UserName)
+	}
+	u, err := user.Lookup(s.UserName)
+	if err != nil {
+		return nil, fmt.Errorf("cannot find user %s: %v", s.UserName, err)
+	}
+	return u, nil
+}
+
+// UnitDir returns the directory where unit files are written
+func (s sdScope) UnitDir() (string, error) {
+	if s.UserName != "" {
+		u, err := s.lookup()
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(u.HomeDir, ".config", "systemd", "user"), nil
+	} else if !s.User {
+		return sdSystemUnitDir, nil
+	} else if _, ok := s.Host().(*rootHost); ok {
+		return "", fmt.Errorf("user unit files are not supported with root_dir")
+	}
+
+	config, err := s.userConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("cannot find user unit directory: %v", err)
+	}
+
+	return filepath.Join(config, "systemd", "user"), nil
+}
+
+// userConfigDir returns the configuration directory of the user, on the host
+// of the manager
+func (s sdScope) userConfigDir() (string, error) {
+	if !s.Host().Remote() {
+		return os.UserConfigDir()
+	}
+
+	cmd := s.Host().Command(context.Background(), nil, "sh", "-c", `printf %s "${XDG_CONFIG_HOME:-$HOME/.config}"`)
+	out, err := cmd.Output()
+	if err != nil {
+		return "", err
+	} else if len(out) == 0 || out[0] != '/' {
+		return "", fmt.Errorf("invalid configuration directory %q", out)
+	}
+	return string(out), nil
+}
+
+// WriteFile writes a unit file, owned by the user if the unit belongs to
+// another user's manager
+func (s sdScope) WriteFile(filename, content string) error {
+	if s.UserName == "" {
+		return repositoryWriteFile(s.Host(), filename, content)
+	}
+
+	u, err := s.lookup()
+	if err != nil {
+		return err
+	}
+	uid, _ := strconv.Atoi(u.Uid)
+	gid, _ := strconv.Atoi(u.Gid)
+
+	// Parent directories that do not exist yet are created for the user
+	var missing []string
+	for dir := filepath.Dir(filename); dir != "/" && dir != "."; dir = filepath.Dir(dir) {
+		if _, err := s.Host().Stat(dir); err == nil {
+			break
+		}
+		missing = append(missing, dir)
+	}
+
+	err = repositoryWriteFile(s.Host(), filename, content)
+	if err != nil {
+		return err
+	}
+
+	// lookup fails on remote hosts, the files are local
+	for _, path := range append(missing, filename) {
+		if err := os.Lchown(path, uid, gid); err != nil {
+			return fmt.Errorf("cannot change owner of %s, %v", path, err)
+		}
+	}
+
+	return nil
+}
+
+// Conn connects to the systemd manager. Within root_dir, there is no system
+// bus and the system unit files are managed offline.
+func (s sdScope) Conn(ctx context.Context, m *providerConfiguration) (sdManager, error) {
+	if m.SdConnect != nil {
+		return m.SdConnect(ctx, s)
+	}
+
+	if root, ok := s.Host().(*rootHost); ok && !s.User && s.UserName == "" {
+		log.Printf("[DEBUG] Manage the unit files offline in %s\n", root.Root)
+		return &sdOffline{host: s.Host()}, nil
+	}
+
+	conn, err := systemd.NewConnection(func() (*dbus.Conn, error) {
+		return s.Dial(ctx)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &sdConnection{Conn: conn, scope: s}, nil
+}
+
+func sdUserSocket(u *user.User) string {
+	return fmt.Sprintf("/run/user/%s/systemd/private", u.Uid)
+}
+
+// Dial opens a raw dBus connection to the systemd manager. Other users'
+// managers are reached through their private socket which accepts
+// connections from root.
+func (s sdScope) Dial(ctx context.Context) (*dbus.Conn, error) {
+	if s.UserName != "" {
+		u, err := s.lookup()
+		if err != nil {
+			return nil, err
+		}
+
+		conn, err := dbus.Dial("unix:path="+sdUserSocket(u), dbus.WithContext(ctx))
+		if err != nil {
+			return nil, err
+		}
+
+		// The private socket has no bus daemon, there is no Hello to send
+		err = conn.Auth([]dbus.Auth{dbus.AuthExternal(strconv.Itoa(os.Getuid()))})
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+
+		return conn, nil
+	}
+
+	return s.Host().DialBus(ctx, s.User)
+}
+
+// sdEnableLinger enables lingering for the user through logind so its
+// manager is started at boot, and waits for the manager to be available
+func sdEnableLinger(ctx context.Context, scope sdScope) error {
+	name := scope.UserName
+	u, err := scope.lookup()
+	if err != nil {
+		return err
+	}
+
+	uid, err := strconv.ParseUint(u.Uid, 10, 32)
+	if err != nil {
+		return fmt.Errorf("invalid uid %s for user %s", u.Uid, name)
+	}
+
+	conn, err := sdScope{host: scope.host}.Dial(ctx)
+	if err != nil {
+		return fmt.Errorf("cannot connect to the system bus: %v", err)
+	}
+	defer conn.Close()
+
+	logind := conn.Object("org.freedesktop.login1", "/org/freedesktop/login1")
+	err = logind.CallWithContext(ctx, "org.freedesktop.login1.Manager.SetUserLinger", 0, uint32(uid), true, false).Err
+	if err != nil {
+		return fmt.Errorf("cannot enable linger for user %s: %v", name, err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	socket := sdUserSocket(u)
+	for {
+		if _, err := os.Stat(socket); err == nil {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("user manager for %s did not start: %v", name, ctx.Err())
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package sys
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	systemd "github.com/coreos/go-systemd/v22/dbus"
+	"github.com/godbus/dbus/v5"
+)
+
+// Conversion of the properties of a transient unit, as given to systemd-run
+// -p, to their dBus type
+type sdPropertyKind int
+
+const (
+	sdPropString sdPropertyKind = iota
+	sdPropStrings
+	sdPropEnvironment
+	sdPropBool
+	sdPropBytes
+	sdPropUint64
+	sdPropInt32
+	sdPropSeconds
+	sdPropPercent
+)
+
+var sdTransientProperties = map[string]struct {
+	Kind sdPropertyKind
+	Name string // dBus property name if different
+}{
+	"Description":         {sdPropString, ""},
+	"Type":                {sdPropString, ""},
+	"User":                {sdPropString, ""},
+	"Group":               {sdPropString, ""},
+	"WorkingDirectory":    {sdPropString, ""},
+	"RootDirectory":       {sdPropString, ""},
+	"Restart":             {sdPropString, ""},
+	"KillMode":            {sdPropString, ""},
+	"Slice":               {sdPropString, ""},
+	"StandardOutput":      {sdPropString, ""},
+	"StandardError":       {sdPropString, ""},
+	"SyslogIdentifier":    {sdPropString, ""},
+	"After":               {sdPropStrings, ""},
+	"Before":              {sdPropStrings, ""},
+	"Wants":               {sdPropStrings, ""},
+	"Requires":            {sdPropStrings, ""},
+	"BindsTo":             {sdPropStrings, ""},
+	"Conflicts":           {sdPropStrings, ""},
+	"SupplementaryGroups": {sdPropStrings, ""},
+	"ReadWritePaths":      {sdPropStrings, ""},
+	"ReadOnlyPaths":       {sdPropStrings, ""},
+	"Environment":         {sdPropEnvironment, ""},
+	"RemainAfterExit":     {sdPropBool, ""},
+	"PrivateTmp":          {sdPropBool, ""},
+	"PrivateDevices":      {sdPropBool, ""},
+	"PrivateNetwork":      {sdPropBool, ""},
+	"NoNewPrivileges":     {sdPropBool, ""},
+	"DynamicUser":         {sdPropBool, ""},
+	"MemoryMax":           {sdPropBytes, ""},
+	"MemoryHigh":          {sdPropBytes, ""},
+	"MemoryLow":           {sdPropBytes, ""},
+	"MemorySwapMax":       {sdPropBytes, ""},
+	"TasksMax":            {sdPropUint64, ""},
+	"CPUWeight":           {sdPropUint64, ""},
+	"IOWeight":            {sdPropUint64, ""},
+	"Nice":                {sdPropInt32, ""},
+	"RuntimeMaxSec":       {sdPropSeconds, "RuntimeMaxUSec"},
+	"TimeoutStartSec":     {sdPropSeconds, "TimeoutStartUSec"},
+	"TimeoutStopSec":      {sdPropSeconds, "TimeoutStopUSec"},
+	"RestartSec":          {sdPropSeconds, "RestartUSec"},
+	"CPUQuota":            {sdPropPercent, "CPUQuotaPerSecUSec"},
+}
+
+var sdByteSuffixes = map[string]uint64{
+	"K": 1 << 10,
+	"M": 1 << 20,
+	"G": 1 << 30,
+	"T": 1 << 40,
+}
+
+func sdParseBytes(value string) (uint64, error) {
+	if value == "infinity" {
+		return math.MaxUint64, nil
+	}
+
+	mult := uint64(1)
+	if len(value) > 0 {
+		if m, ok := sdByteSuffixes[strings.ToUpper(value[len(value)-1:])]; ok {
+			mult = m
+			value = value[:len(value)-1]
+		}
+	}
+
+	n, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	return n * mult, nil
+}
+
+// sdParseSeconds parses a number of seconds or a duration such as 1m30s to
+// microseconds
+func sdParseSeconds(value string) (uint64, error) {
+	if value == "infinity" {
+		return math.MaxUint64, nil
+	}
+	if secs, err := strconv.ParseFloat(value, 64); err == nil {
+		return uint64(secs * 1e6), nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, err
+	}
+	return uint64(d / time.Microsecond), nil
+}
+
+func sdTransientProperty(key, value string) (systemd.Property, error) {
+	def, ok := sdTransientProperties[key]
+	if !ok {
+		return systemd.Property{}, fmt.Errorf("unsupported transient unit property %s", key)
+	}
+
+	name := def.Name
+	if name == "" {
+		name = key
+	}
+
+	var v interface{}
+	var err error
+	switch def.Kind {
+	case sdPropString:
+		v = value
+	case sdPropStrings:
+		v = strings.Fields(value)
+	case sdPropEnvironment:
+		v = strings.Split(strings.TrimSpace(value), "\n")
+	case sdPropBool:
+		v, err = strconv.ParseBool(value)
+	case sdPropBytes:
+		v, err = sdParseBytes(value)
+	case sdPropUint64:
+		if value == "infinity" {
+			v = uint64(math.MaxUint64)
+		} else {
+			v, err = strconv.ParseUint(value, 10, 64)
+		}
+	case sdPropInt32:
+		var n int64
+		n, err = strconv.ParseInt(value, 10, 32)
+		v = int32(n)
+	case sdPropSeconds:
+		v, err = sdParseSeconds(value)
+	case sdPropPercent:
+		var pct float64
+		pct, err = strconv.ParseFloat(strings.TrimSuffix(value, "%"), 64)
+		v = uint64(pct * 10000)
+	}
+	if err != nil {
+		return systemd.Property{}, fmt.Errorf("invalid value %q for property %s: %v", value, key, err)
+	}
+
+	return systemd.Property{Name: name, Value: dbus.MakeVariant(v)}, nil
+}
+
+// sdTransientPropertiesFrom converts a property map, sorted by name so the
+// properties are sent in a stable order
+func sdTransientPropertiesFrom(props map[string]string) ([]systemd.Property, error) {
+	var keys []string
+	for key := range props {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var res []systemd.Property
+	for _, key := range keys {
+		prop, err := sdTransientProperty(key, props[key])
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, prop)
+	}
+	return res, nil
+}
@@ -0,0 +1,49 @@
+package sys
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestSdTransientProperties(t *testing.T) {
+	props, err := sdTransientPropertiesFrom(map[string]string{
+		"MemoryMax":     "512M",
+		"User":          "worker",
+		"Environment":   "FOO=bar\nGREETING=hello world",
+		"After":         "network.target postgresql.service",
+		"CPUQuota":      "50%",
+		"RuntimeMaxSec": "1m30s",
+		"Nice":          "-5",
+		"MemoryHigh":    "infinity",
+		"TasksMax":      "infinity",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]interface{}{
+		"After":              []string{"network.target", "postgresql.service"},
+		"CPUQuotaPerSecUSec": uint64(500000),
+		"Environment":        []string{"FOO=bar", "GREETING=hello world"},
+		"MemoryHigh":         uint64(math.MaxUint64),
+		"MemoryMax":          uint64(512 << 20),
+		"Nice":               int32(-5),
+		"RuntimeMaxUSec":     uint64(90000000),
+		"TasksMax":           uint64(math.MaxUint64),
+		"User":               "worker",
+	}
+
+	if len(props) != len(expected) {
+		t.Fatalf("expected %d properties, got %v", len(expected), props)
+	}
+	for _, prop := range props {
+		if value := prop.Value.Value(); !reflect.DeepEqual(value, expected[prop.Name]) {
+			t.Errorf("property %s: expected %#v, got %#v", prop.Name, expected[prop.Name], value)
+		}
+	}
+
+	if _, err := sdTransientPropertiesFrom(map[string]string{"Unknown": "x"}); err == nil {
+		t.Errorf("expected unknown property to be rejected")
+	}
+}
@@ -0,0 +1,106 @@
+package sys
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const sdSystemUnitDir = "/etc/systemd/system"
+
+// Structured sections of a unit file, in the order they are written
+var sdUnitSections = []struct {
+	Attr    string
+	Section string
+}{
+	{"unit", "Unit"},
+	{"service", "Service"},
+	{"timer", "Timer"},
+	{"install", "Install"},
+}
+
+func sdUnitFilePath(scope sdScope, unit string) (string, error) {
+	dir, err := scope.UnitDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, unit), nil
+}
+
+// sdUnitSectionMap holds the structured sections of a unit file, by
+// attribute name
+type sdUnitSectionMap map[string]map[string]string
+
+// sdUnitRender renders a unit file from the structured section attributes.
+// Keys are sorted and multi-line values are written as repeated keys, in
+// order, like ExecStartPre or Environment would require.
+func sdUnitRender(sections sdUnitSectionMap) string {
+	var res []string
+	for _, s := range sdUnitSections {
+		values := sections[s.Attr]
+		if len(values) == 0 {
+			continue
+		}
+
+		var keys []string
+		for key := range values {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		if len(res) > 0 {
+			res = append(res, "")
+		}
+		res = append(res, fmt.Sprintf("[%s]", s.Section))
+		for _, key := range keys {
+			for _, line := range strings.Split(values[key], "\n") {
+				res = append(res, fmt.Sprintf("%s=%s", key, line))
+			}
+		}
+	}
+
+	if len(res) == 0 {
+		return ""
+	}
+	return strings.Join(res, "\n") + "\n"
+}
+
+func sdUnitReadFile(host sysHost, filename string) (string, error) {
+	content, err := host.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return "", nil
+	} else if err != nil {
+		return "", fmt.Errorf("cannot read %s, %v", filename, err)
+	}
+	return string(content), nil
+}
+
+func sdUnitRemoveFile(host sysHost, filename string) error {
+	err := host.Remove(filename)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("cannot remove %s, %v", filename, err)
+	}
+	return nil
+}
+
+// sdEscape escapes a string for use in a unit name like systemd-escape: "/"
+// becomes "-" and other special characters as well as a leading "." are
+// written as C-style \xNN escapes
+func sdEscape(s string) string {
+	var res strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '/':
+			res.WriteByte('-')
+		case c == '.' && i == 0,
+			!(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == ':' || c == '_' || c == '.'):
+			fmt.Fprintf(&res, "\\x%02x", c)
+		default:
+			res.WriteByte(c)
+		}
+	}
+	return res.String()
+}
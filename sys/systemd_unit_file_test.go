@@ -0,0 +1,100 @@
+package sys
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+func TestSdUnitRender(t *testing.T) {
+	content := sdUnitRender(sdUnitSectionMap{
+		"unit": {
+			"Description": "Test service",
+			"After":       "network.target",
+		},
+		"service": {
+			"ExecStartPre": "/bin/true\n/bin/echo pre",
+			"ExecStart":    "/bin/sleep infinity",
+		},
+		"install": {
+			"WantedBy": "multi-user.target",
+		},
+	})
+
+	expected := `[Unit]
+After=network.target
+Description=Test service
+
+[Service]
+ExecStart=/bin/sleep infinity
+ExecStartPre=/bin/true
+ExecStartPre=/bin/echo pre
+
+[Install]
+WantedBy=multi-user.target
+`
+	if content != expected {
+		t.Fatalf("unexpected unit file:\n%s\nexpected:\n%s", content, expected)
+	}
+
+	if content := sdUnitRender(sdUnitSectionMap{}); content != "" {
+		t.Fatalf("expected empty unit file, got %q", content)
+	}
+}
+
+func TestSdDropinRender(t *testing.T) {
+	d := systemdDropinResourceModel{
+		Unit:        types.StringValue("postgresql.service"),
+		UnitSection: map[string]string{"After": "network-online.target"},
+		Service:     map[string]string{"LimitNOFILE": "65536"},
+	}
+
+	expected := "[Unit]\nAfter=network-online.target\n\n[Service]\nLimitNOFILE=65536\n"
+	if content := sdUnitRender(d.sections()); content != expected {
+		t.Fatalf("unexpected drop-in:\n%s\nexpected:\n%s", content, expected)
+	}
+}
+
+func TestSdTimerRender(t *testing.T) {
+	timer, service := resourceSystemdTimerContent(&systemdTimerResourceModel{
+		Name:               types.StringValue("backup"),
+		Command:            types.StringValue("/usr/local/bin/backup --all"),
+		OnCalendar:         types.StringValue("daily"),
+		Persistent:         types.BoolValue(true),
+		RandomizedDelaySec: types.StringValue("10min"),
+		Service:            map[string]string{"User": "backup"},
+	})
+
+	expectedTimer := `[Unit]
+Description=Timer backup.timer
+
+[Timer]
+OnCalendar=daily
+Persistent=true
+RandomizedDelaySec=10min
+
+[Install]
+WantedBy=timers.target
+`
+	expectedService := `[Unit]
+Description=Timer backup.timer
+
+[Service]
+ExecStart=/usr/local/bin/backup --all
+Type=oneshot
+User=backup
+`
+	if timer != expectedTimer {
+		t.Errorf("unexpected timer unit:\n%s\nexpected:\n%s", timer, expectedTimer)
+	}
+	if service != expectedService {
+		t.Errorf("unexpected service unit:\n%s\nexpected:\n%s", service, expectedService)
+	}
+
+	if ts := sdFormatTimestamp(1700000000000000); ts != "2023-11-14T22:13:20Z" {
+		t.Errorf("unexpected timestamp %s", ts)
+	}
+	if ts := sdFormatTimestamp(0); ts != "" {
+		t.Errorf("expected empty timestamp, got %s", ts)
+	}
+}
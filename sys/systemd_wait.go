@@ -0,0 +1,265 @@
+package sys
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/int64default"
+	"github.com/hashicorp/terraform-plugin-framework/resource/schema/stringdefault"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+func sdWaitActiveBlock() schema.ListNestedBlock {
+	return schema.ListNestedBlock{
+		Description: "Wait for the unit to stay active and pass its health checks once started",
+		Validators:  []validator.List{listSizeAtMost(1)},
+		NestedObject: schema.NestedBlockObject{
+			Attributes: map[string]schema.Attribute{
+				"settle": schema.StringAttribute{
+					Description: "Duration the unit must stay active after its start job completed",
+					Optional:    true,
+					Computed:    true,
+					Default:     stringdefault.StaticString("0s"),
+					Validators:  []validator.String{durationValidator},
+				},
+				"timeout": schema.StringAttribute{
+					Description: "Maximum duration to wait for the unit to be healthy",
+					Optional:    true,
+					Computed:    true,
+					Default:     stringdefault.StaticString("1m"),
+					Validators:  []validator.String{durationValidator},
+				},
+				"interval": schema.StringAttribute{
+					Description: "Duration between two health checks",
+					Optional:    true,
+					Computed:    true,
+					Default:     stringdefault.StaticString("1s"),
+					Validators:  []validator.String{durationValidator},
+				},
+				"tcp": schema.StringAttribute{
+					Description: "Address (host:port) that must accept TCP connections",
+					Optional:    true,
+				},
+				"http": schema.StringAttribute{
+					Description: "URL that must respond with a 200 status",
+					Optional:    true,
+				},
+				"unix_socket": schema.StringAttribute{
+					Description: "Path of a unix socket that must exist",
+					Optional:    true,
+				},
+				"command": schema.StringAttribute{
+					Description: "Shell command that must exit with status 0",
+					Optional:    true,
+				},
+				"journal_lines": schema.Int64Attribute{
+					Description: "Number of journal lines of the unit to include in the error on failure",
+					Optional:    true,
+					Computed:    true,
+					Default:     int64default.StaticInt64(20),
+				},
+			},
+		},
+	}
+}
+
+type sdWaitActiveModel struct {
+	Settle       types.String `tfsdk:"settle"`
+	Timeout      types.String `tfsdk:"timeout"`
+	Interval     types.String `tfsdk:"interval"`
+	TCP          types.String `tfsdk:"tcp"`
+	HTTP         types.String `tfsdk:"http"`
+	UnixSocket   types.String `tfsdk:"unix_socket"`
+	Command      types.String `tfsdk:"command"`
+	JournalLines types.Int64  `tfsdk:"journal_lines"`
+}
+
+type sdWaitActive struct {
+	Host         sysHost
+	Settle       time.Duration
+	Timeout      time.Duration
+	Interval     time.Duration
+	TCP          string
+	HTTP         string
+	UnixSocket   string
+	Command      string
+	JournalLines int
+}
+
+func sdWaitActiveFrom(blocks []sdWaitActiveModel, host sysHost) *sdWaitActive {
+	if len(blocks) == 0 {
+		return nil
+	}
+	block := blocks[0]
+
+	w := &sdWaitActive{
+		Host:         host,
+		TCP:          block.TCP.ValueString(),
+		HTTP:         block.HTTP.ValueString(),
+		UnixSocket:   block.UnixSocket.ValueString(),
+		Command:      block.Command.ValueString(),
+		JournalLines: int(block.JournalLines.ValueInt64()),
+	}
+	w.Settle, _ = time.ParseDuration(block.Settle.ValueString())
+	w.Timeout, _ = time.ParseDuration(block.Timeout.ValueString())
+	w.Interval, _ = time.ParseDuration(block.Interval.ValueString())
+	if w.Interval <= 0 {
+		w.Interval = time.Second
+	}
+	return w
+}
+
+// validate rejects the checks that can only run on the machine running
+// terraform when the unit is on another host
+func (w *sdWaitActive) validate() error {
+	if !w.Host.Remote() {
+		return nil
+	}
+	if w.TCP != "" || w.HTTP != "" {
+		return fmt.Errorf("wait_active tcp and http checks are only supported on the local host, use command instead")
+	}
+	return nil
+}
+
+// check runs the health checks once, returning the first failure
+func (w *sdWaitActive) check(ctx context.Context) error {
+	if w.TCP != "" {
+		conn, err := net.DialTimeout("tcp", w.TCP, w.Interval)
+		if err != nil {
+			return fmt.Errorf("tcp %s: %v", w.TCP, err)
+		}
+		conn.Close()
+	}
+
+	if w.HTTP != "" {
+		client := &http.Client{Timeout: w.Interval}
+		res, err := client.Get(w.HTTP)
+		if err != nil {
+			return fmt.Errorf("http %s: %v", w.HTTP, err)
+		}
+		res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			return fmt.Errorf("http %s: %s", w.HTTP, res.Status)
+		}
+	}
+
+	if w.UnixSocket != "" {
+		st, err := w.Host.Stat(w.UnixSocket)
+		if err != nil {
+			return fmt.Errorf("unix socket %s: %v", w.UnixSocket, err)
+		} else if st.Mode()&os.ModeSocket == 0 {
+			return fmt.Errorf("unix socket %s: not a socket", w.UnixSocket)
+		}
+	}
+
+	if w.Command != "" {
+		out, err := w.Host.Command(ctx, nil, "/bin/sh", "-c", w.Command).CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("command %q: %v: %s", w.Command, err, strings.TrimSpace(string(out)))
+		}
+	}
+
+	return nil
+}
+
+// wait waits for the unit to settle and be healthy, a unit that fails or
+// stops is an immediate error
+func (w *sdWaitActive) wait(ctx context.Context, sd sdManager, unit string) error {
+	deadline := time.Now().Add(w.Timeout)
+	settled := time.Now().Add(w.Settle)
+	var lastErr error
+
+	for {
+		statuses, err := sd.ListUnitsByNamesContext(ctx, []string{unit})
+		if err != nil || len(statuses) < 1 {
+			return fmt.Errorf("cannot query unit %s: %v", unit, err)
+		}
+		status := statuses[0]
+
+		switch {
+		case sdIsFailed(status.ActiveState) || status.ActiveState == systemdInactive:
+			return fmt.Errorf("unit %s is %s (%s)", unit, status.ActiveState, status.SubState)
+		case !sdIsActive(status.ActiveState):
+			lastErr = fmt.Errorf("unit %s is %s (%s)", unit, status.ActiveState, status.SubState)
+		case time.Now().Before(settled):
+			lastErr = fmt.Errorf("unit %s did not settle", unit)
+		default:
+			lastErr = w.check(ctx)
+			if lastErr == nil {
+				return nil
+			}
+		}
+
+		log.Printf("[TRACE] Wait for %s to be active: %v\n", unit, lastErr)
+
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timeout waiting for unit %s to be active: %v", unit, lastErr)
+		}
+
+		delay := w.Interval
+		if wait := time.Until(settled); wait > 0 && wait < delay {
+			delay = wait
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+// sdJournalTail returns the last journal lines of a unit of the scope, or an
+// empty string if the journal cannot be read
+func sdJournalTail(ctx context.Context, scope sdScope, unit string, lines int) string {
+	if lines <= 0 {
+		return ""
+	}
+
+	q := journalQuery{Unit: unit, User: scope.User, Lines: lines, Priority: 7}
+	if scope.UserName != "" {
+		u, err := scope.lookup()
+		if err != nil {
+			log.Printf("[DEBUG] Cannot read the journal of %s: %v\n", unit, err)
+			return ""
+		}
+		q.UID = u.Uid
+	}
+
+	entries, err := journalRead(ctx, scope.Host(), q)
+	if err != nil {
+		log.Printf("[DEBUG] Cannot read the journal of %s: %v\n", unit, err)
+		return ""
+	}
+
+	var res []string
+	for _, e := range entries {
+		res = append(res, fmt.Sprintf("%s %s[%s]: %s", e.Timestamp.Format(time.Stamp), e.Fields["SYSLOG_IDENTIFIER"], e.Fields["_PID"], e.Fields["MESSAGE"]))
+	}
+	return strings.Join(res, "\n")
+}
+
+// sdWaitActiveDiagnostic waits for the unit to be healthy, reporting the last
+// journal lines on failure
+func sdWaitActiveDiagnostic(ctx context.Context, w *sdWaitActive, scope sdScope, sd sdManager, unit string) diag.Diagnostics {
+	err := w.wait(ctx, sd, unit)
+	if err == nil {
+		return nil
+	}
+
+	detail := sdJournalTail(ctx, scope, unit, w.JournalLines)
+	if detail != "" {
+		detail = fmt.Sprintf("Last journal lines of %s:\n%s", unit, detail)
+	}
+
+	return diag.Diagnostics{diag.NewErrorDiagnostic(err.Error(), detail)}
+}
@@ -0,0 +1,113 @@
+package sys
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSdWaitActiveCheck(t *testing.T) {
+	ctx := context.Background()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/health" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+
+	sock := path.Join(t.TempDir(), "test.sock")
+	unixLn, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unixLn.Close()
+
+	healthy := &sdWaitActive{
+		Host:       localHost{},
+		Interval:   time.Second,
+		TCP:        ln.Addr().String(),
+		HTTP:       srv.URL + "/health",
+		UnixSocket: sock,
+		Command:    "true",
+	}
+	if err := healthy.check(ctx); err != nil {
+		t.Fatalf("expected healthy checks, got %v", err)
+	}
+
+	for _, w := range []*sdWaitActive{
+		{Host: localHost{}, Interval: time.Second, HTTP: srv.URL + "/other"},
+		{Host: localHost{}, Interval: time.Second, UnixSocket: path.Join(t.TempDir(), "missing.sock")},
+		{Host: localHost{}, Interval: time.Second, UnixSocket: srv.URL},
+		{Host: localHost{}, Interval: time.Second, Command: "exit 1"},
+	} {
+		if err := w.check(ctx); err == nil {
+			t.Errorf("expected check %+v to fail", w)
+		}
+	}
+}
+
+func TestSdWaitActiveCheckSSH(t *testing.T) {
+	ctx := context.Background()
+	h, log := testSSHHost(t)
+
+	sock := path.Join(t.TempDir(), "test.sock")
+	ln, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	w := &sdWaitActive{Host: h, Interval: time.Second, UnixSocket: sock, Command: "true"}
+	if err := w.validate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.check(ctx); err != nil {
+		t.Fatalf("expected healthy checks, got %v", err)
+	}
+	if args, err := ioutil.ReadFile(log); err != nil || !strings.Contains(string(args), "/bin/sh -c true") {
+		t.Errorf("expected the command to run through ssh, got %q (%v)", args, err)
+	}
+
+	w = &sdWaitActive{Host: h, Interval: time.Second, Command: "exit 1"}
+	if err := w.check(ctx); err == nil {
+		t.Errorf("expected the remote command to fail")
+	}
+
+	w = &sdWaitActive{Host: h, Interval: time.Second, TCP: "127.0.0.1:80"}
+	if err := w.validate(); err == nil {
+		t.Errorf("expected tcp checks to be rejected on a remote host")
+	}
+}
+
+func TestSdJournalTailSSH(t *testing.T) {
+	h, log := testSSHHost(t)
+
+	bin := t.TempDir()
+	script := "#!/bin/sh\necho '{\"__REALTIME_TIMESTAMP\":\"1614859200000000\",\"SYSLOG_IDENTIFIER\":\"app\",\"_PID\":\"42\",\"MESSAGE\":\"crashed\"}'\n"
+	if err := ioutil.WriteFile(path.Join(bin, "journalctl"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+":"+os.Getenv("PATH"))
+
+	tail := sdJournalTail(context.Background(), sdScope{host: h}, "app.service", 5)
+	if !strings.HasSuffix(tail, " app[42]: crashed") {
+		t.Errorf("unexpected journal tail %q", tail)
+	}
+	if args, err := ioutil.ReadFile(log); err != nil || !strings.Contains(string(args), "journalctl --no-pager --quiet -o json -n 5 -u app.service") {
+		t.Errorf("expected journalctl to run through ssh, got %q (%v)", args, err)
+	}
+}
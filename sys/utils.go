@@ -1,16 +1,43 @@
 package sys
 
 import (
+	"context"
 	"strconv"
+
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
 )
 
 func parseBoolDef(val interface{}, def bool) bool {
 	s, ok := val.(string)
 	if ok {
 		res, err := strconv.ParseBool(s)
-		if err != nil {
+		if err == nil {
 			return res
 		}
 	}
 	return def
 }
+
+func stringListContains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// planKnown returns whether the attributes of the plan are all known, so the
+// values computed from them can be planned
+func planKnown(ctx context.Context, plan tfsdk.Plan, attrs ...string) bool {
+	for _, name := range attrs {
+		var value attr.Value
+		plan.GetAttribute(ctx, path.Root(name), &value)
+		if value != nil && value.IsUnknown() {
+			return false
+		}
+	}
+	return true
+}
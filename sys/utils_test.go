@@ -0,0 +1,26 @@
+package sys
+
+import (
+	"testing"
+)
+
+func TestParseBoolDef(t *testing.T) {
+	for _, c := range []struct {
+		val      interface{}
+		def      bool
+		expected bool
+	}{
+		{"true", false, true},
+		{"1", false, true},
+		{"false", true, false},
+		{"0", true, false},
+		{"", true, true},
+		{"invalid", false, false},
+		{nil, true, true},
+		{true, false, false},
+	} {
+		if res := parseBoolDef(c.val, c.def); res != c.expected {
+			t.Errorf("parseBoolDef(%#v, %v): expected %v, got %v", c.val, c.def, c.expected, res)
+		}
+	}
+}
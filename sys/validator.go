@@ -1,8 +1,19 @@
 package sys
 
 import (
+	"context"
 	"fmt"
 	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/path"
+	"github.com/hashicorp/terraform-plugin-framework/resource"
+	"github.com/hashicorp/terraform-plugin-framework/schema/validator"
+	"github.com/hashicorp/terraform-plugin-framework/tfsdk"
 )
 
 func validateMode(i interface{}, k string) (s []string, es []error) {
@@ -25,3 +36,228 @@ func validateMode(i interface{}, k string) (s []string, es []error) {
 
 	return
 }
+
+func validateDuration(i interface{}, k string) (s []string, es []error) {
+	if _, err := time.ParseDuration(i.(string)); err != nil {
+		es = append(es, fmt.Errorf("expected %s to be a duration, %v", k, err))
+	}
+	return
+}
+
+// stringValidator runs an SDK validation function on framework attributes
+type stringValidator struct {
+	description string
+	validate    func(interface{}, string) ([]string, []error)
+}
+
+func (v stringValidator) Description(ctx context.Context) string {
+	return v.description
+}
+
+func (v stringValidator) MarkdownDescription(ctx context.Context) string {
+	return v.description
+}
+
+func (v stringValidator) ValidateString(ctx context.Context, req validator.StringRequest, resp *validator.StringResponse) {
+	if req.ConfigValue.IsNull() || req.ConfigValue.IsUnknown() {
+		return
+	}
+	_, errs := v.validate(req.ConfigValue.ValueString(), req.Path.String())
+	for _, err := range errs {
+		resp.Diagnostics.AddAttributeError(req.Path, "Invalid value", err.Error())
+	}
+}
+
+var modeValidator = stringValidator{"value must be an octal file mode", validateMode}
+var durationValidator = stringValidator{"value must be a duration", validateDuration}
+
+// stringOneOf validates that a string attribute is one of the values, like
+// validation.StringInSlice in the SDK
+type stringOneOf []string
+
+func (v stringOneOf) Description(ctx context.Context) string {
+	return fmt.Sprintf("value must be one of %q", []string(v))
+}
+
+func (v stringOneOf) MarkdownDescription(ctx context.Context) string {
+	return v.Description(ctx)
+}
+
+func (v stringOneOf) ValidateString(ctx context.Context, req validator.StringRequest, resp *validator.StringResponse) {
+	if req.ConfigValue.IsNull() || req.ConfigValue.IsUnknown() {
+		return
+	}
+	value := req.ConfigValue.ValueString()
+	for _, valid := range v {
+		if value == valid {
+			return
+		}
+	}
+	resp.Diagnostics.AddAttributeError(req.Path, "Invalid value",
+		fmt.Sprintf("expected %s to be one of %q, got %s", req.Path, []string(v), value))
+}
+
+// int64Between validates that an integer attribute is within a range
+type int64Between struct {
+	min, max int64
+}
+
+func (v int64Between) Description(ctx context.Context) string {
+	return fmt.Sprintf("value must be between %d and %d", v.min, v.max)
+}
+
+func (v int64Between) MarkdownDescription(ctx context.Context) string {
+	return v.Description(ctx)
+}
+
+func (v int64Between) ValidateInt64(ctx context.Context, req validator.Int64Request, resp *validator.Int64Response) {
+	if req.ConfigValue.IsNull() || req.ConfigValue.IsUnknown() {
+		return
+	}
+	if value := req.ConfigValue.ValueInt64(); value < v.min || value > v.max {
+		resp.Diagnostics.AddAttributeError(req.Path, "Invalid value",
+			fmt.Sprintf("expected %s to be in the range (%d - %d), got %d", req.Path, v.min, v.max, value))
+	}
+}
+
+// listSizeAtMost validates the number of elements of a list, like MaxItems in
+// the SDK
+type listSizeAtMost int
+
+func (v listSizeAtMost) Description(ctx context.Context) string {
+	return fmt.Sprintf("list must contain at most %d elements", int(v))
+}
+
+func (v listSizeAtMost) MarkdownDescription(ctx context.Context) string {
+	return v.Description(ctx)
+}
+
+func (v listSizeAtMost) ValidateList(ctx context.Context, req validator.ListRequest, resp *validator.ListResponse) {
+	if req.ConfigValue.IsNull() || req.ConfigValue.IsUnknown() {
+		return
+	}
+	if n := len(req.ConfigValue.Elements()); n > int(v) {
+		resp.Diagnostics.AddAttributeError(req.Path, "Too many list items",
+			fmt.Sprintf("no more than %d %s blocks are allowed, got %d", int(v), req.Path, n))
+	}
+}
+
+// listSizeAtLeast validates the number of elements of a list, like MinItems
+// in the SDK
+type listSizeAtLeast int
+
+func (v listSizeAtLeast) Description(ctx context.Context) string {
+	return fmt.Sprintf("list must contain at least %d elements", int(v))
+}
+
+func (v listSizeAtLeast) MarkdownDescription(ctx context.Context) string {
+	return v.Description(ctx)
+}
+
+func (v listSizeAtLeast) ValidateList(ctx context.Context, req validator.ListRequest, resp *validator.ListResponse) {
+	if req.ConfigValue.IsNull() || req.ConfigValue.IsUnknown() {
+		return
+	}
+	if n := len(req.ConfigValue.Elements()); n < int(v) {
+		resp.Diagnostics.AddAttributeError(req.Path, "Not enough list items",
+			fmt.Sprintf("attribute %s requires %d item minimum, but config has only %d declared", req.Path, int(v), n))
+	}
+}
+
+// conflictingAttributes fails when more than one of the attributes is set in
+// the configuration, like ConflictsWith in the SDK
+type conflictingAttributes []string
+
+func (v conflictingAttributes) Description(ctx context.Context) string {
+	return fmt.Sprintf("only one of %s can be set", strings.Join(v, ", "))
+}
+
+func (v conflictingAttributes) MarkdownDescription(ctx context.Context) string {
+	return v.Description(ctx)
+}
+
+func (v conflictingAttributes) validate(ctx context.Context, config tfsdk.Config) (diags diag.Diagnostics) {
+	var set []string
+	for _, name := range v {
+		var value attr.Value
+		diags.Append(config.GetAttribute(ctx, path.Root(name), &value)...)
+		if value != nil && !value.IsNull() && !value.IsUnknown() {
+			set = append(set, name)
+		}
+	}
+	if len(set) > 1 {
+		diags.AddAttributeError(path.Root(set[1]), "Conflicting attributes",
+			fmt.Sprintf("%q: conflicts with %s", set[1], set[0]))
+	}
+	return
+}
+
+func (v conflictingAttributes) ValidateResource(ctx context.Context, req resource.ValidateConfigRequest, resp *resource.ValidateConfigResponse) {
+	resp.Diagnostics.Append(v.validate(ctx, req.Config)...)
+}
+
+func (v conflictingAttributes) ValidateDataSource(ctx context.Context, req datasource.ValidateConfigRequest, resp *datasource.ValidateConfigResponse) {
+	resp.Diagnostics.Append(v.validate(ctx, req.Config)...)
+}
+
+// atLeastOneOfAttributes fails when none of the attributes is set in the
+// configuration, like AtLeastOneOf in the SDK
+type atLeastOneOfAttributes []string
+
+func (v atLeastOneOfAttributes) Description(ctx context.Context) string {
+	return fmt.Sprintf("one of %s must be set", strings.Join(v, ", "))
+}
+
+func (v atLeastOneOfAttributes) MarkdownDescription(ctx context.Context) string {
+	return v.Description(ctx)
+}
+
+func (v atLeastOneOfAttributes) validate(ctx context.Context, config tfsdk.Config) (diags diag.Diagnostics) {
+	for _, name := range v {
+		var value attr.Value
+		diags.Append(config.GetAttribute(ctx, path.Root(name), &value)...)
+		if value != nil && !value.IsNull() {
+			return
+		}
+	}
+	diags.AddAttributeError(path.Root(v[0]), "Missing required argument",
+		fmt.Sprintf("%q: one of `%s` must be specified", v[0], strings.Join(v, ",")))
+	return
+}
+
+func (v atLeastOneOfAttributes) ValidateResource(ctx context.Context, req resource.ValidateConfigRequest, resp *resource.ValidateConfigResponse) {
+	resp.Diagnostics.Append(v.validate(ctx, req.Config)...)
+}
+
+// requiredWithAttributes fails when the first attribute is set in the
+// configuration without all the others, like RequiredWith in the SDK
+type requiredWithAttributes []string
+
+func (v requiredWithAttributes) Description(ctx context.Context) string {
+	return fmt.Sprintf("%s requires %s to be set", v[0], strings.Join(v[1:], ", "))
+}
+
+func (v requiredWithAttributes) MarkdownDescription(ctx context.Context) string {
+	return v.Description(ctx)
+}
+
+func (v requiredWithAttributes) validate(ctx context.Context, config tfsdk.Config) (diags diag.Diagnostics) {
+	var value attr.Value
+	diags.Append(config.GetAttribute(ctx, path.Root(v[0]), &value)...)
+	if value == nil || value.IsNull() {
+		return
+	}
+	for _, name := range v[1:] {
+		diags.Append(config.GetAttribute(ctx, path.Root(name), &value)...)
+		if value == nil || value.IsNull() {
+			diags.AddAttributeError(path.Root(v[0]), "Missing required argument",
+				fmt.Sprintf("%q: all of `%s` must be specified", v[0], strings.Join(v, ",")))
+			return
+		}
+	}
+	return
+}
+
+func (v requiredWithAttributes) ValidateResource(ctx context.Context, req resource.ValidateConfigRequest, resp *resource.ValidateConfigResponse) {
+	resp.Diagnostics.Append(v.validate(ctx, req.Config)...)
+}